@@ -0,0 +1,239 @@
+package wrapper
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// runAPICommand implements `claude-wrapper api --stdio`: the daemon's
+// requests, read as newline-delimited JSON from stdin with responses on
+// stdout, for editor plugins that start the wrapper as a child process.
+// Requests that don't name a repository use the current directory's.
+func runAPICommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("api")
+	stdio := fs.Bool("stdio", false, "serve requests on stdin and stdout")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if !*stdio {
+		fs.Usage()
+		return exitUsage, nil
+	}
+
+	settings, err := LoadSettings()
+	if err != nil {
+		return 1, withExitCode(exitConfig, fmt.Errorf("failed to load settings: %w", err))
+	}
+	flags.apply(&settings)
+	// Stderr belongs to the editor; progress would only clutter its log
+	settings.Quiet = true
+
+	d := newDaemon(settings)
+	if d.defaultRepo, err = os.Getwd(); err != nil {
+		return 1, err
+	}
+	d.serveStream(os.Stdin, os.Stdout)
+	return 0, nil
+}
+
+// fileChange is one file that differs between the working tree and the
+// branch store.
+type fileChange struct {
+	Path string `json:"path"`
+	// Change is "modified", "added" (only in the working tree) or
+	// "deleted" (only in storage).
+	Change string `json:"change"`
+}
+
+// diff lists the files of managed items that differ between the working
+// tree and the branch store, limited to path if given. Tracked,
+// never-managed and machine-scoped paths are left out.
+func (d *daemon) diff(dir, path string) ([]fileChange, error) {
+	state, err := d.repo(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer state.mu.Unlock()
+	cfg := state.cfg
+
+	var items []string
+	if path != "" {
+		rel, err := managedPath(cfg, dir, path)
+		if err != nil {
+			return nil, err
+		}
+		items = []string{rel}
+	} else {
+		excluded, err := readExcludeFile(cfg.RepoRoot, cfg.excludeFile())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exclude file: %w", err)
+		}
+		stored, err := listDir(cfg.StoreLocation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list store: %w", err)
+		}
+		seen := make(map[string]bool)
+		for _, item := range append(excluded, filterItems(stored)...) {
+			if !seen[item] {
+				seen[item] = true
+				items = append(items, item)
+			}
+		}
+		tracked, err := trackedItems(cfg, items)
+		if err != nil {
+			return nil, err
+		}
+		kept := items[:0]
+		for _, item := range items {
+			if !tracked[item] {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+	}
+
+	changes := []fileChange{}
+	for _, item := range items {
+		inTree := itemFiles(cfg, cfg.RepoRoot, item)
+		inStore := itemFiles(cfg, cfg.StoreLocation, item)
+		for rel := range inTree {
+			switch {
+			case !inStore[rel]:
+				changes = append(changes, fileChange{Path: rel, Change: "added"})
+			case storedTemplate(cfg, rel) != nil:
+				// Rendered from a template; sync-out never saves it
+			case !sameContents(filepath.Join(cfg.RepoRoot, rel), filepath.Join(cfg.StoreLocation, rel)):
+				changes = append(changes, fileChange{Path: rel, Change: "modified"})
+			}
+		}
+		for rel := range inStore {
+			if name, ok := renderedName(rel); ok && inTree[name] {
+				continue // The template of a rendered file
+			}
+			if !inTree[rel] {
+				changes = append(changes, fileChange{Path: rel, Change: "deleted"})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// itemFiles returns the files under root/item, relative to root, leaving
+// out never-managed and machine-scoped paths.
+func itemFiles(cfg *Config, root, item string) map[string]bool {
+	files := make(map[string]bool)
+	filepath.WalkDir(filepath.Join(root, item), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		if cfg.Settings.neverManaged(rel) || cfg.Settings.machineScoped(rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			files[rel] = true
+		}
+		return nil
+	})
+	return files
+}
+
+// restore replaces the working tree copy of path, or of every item in the
+// branch store if path is empty, with the stored copy, discarding edits
+// since the last sync-in. It returns the restored paths.
+func (d *daemon) restore(dir, path string) ([]string, error) {
+	state, err := d.repo(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer state.mu.Unlock()
+	state.status = nil
+	return restoreItems(state.cfg, dir, path, "claude-wrapper api")
+}
+
+// repoRelative returns path relative to the repository root root. A
+// relative path is taken relative to dir, the directory it was given in
+// (which may be a subdirectory of the repository), or to root if dir is
+// "". Symlinks are resolved on both sides, so a repository reached through
+// a symlinked directory (like /tmp on macOS) still contains its paths.
+func repoRelative(root, dir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		if dir == "" {
+			dir = root
+		}
+		path = filepath.Join(dir, path)
+	}
+	rel, err := filepath.Rel(resolveExisting(root), resolveExisting(path))
+	if err != nil {
+		return "", err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the repository", path)
+	}
+	return rel, nil
+}
+
+// resolveExisting resolves the symlinks in the longest existing prefix of
+// the absolute path path, which need not exist itself.
+func resolveExisting(path string) string {
+	path = filepath.Clean(path)
+	var rest []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, rest...)...)
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// managedPath checks that path, relative to dir (a directory in cfg's
+// repository, or "" for its root) or absolute, lies inside a managed item
+// and returns it relative to the root. Paths in files tracked by git are
+// refused.
+func managedPath(cfg *Config, dir, path string) (string, error) {
+	rel, err := repoRelative(cfg.RepoRoot, dir, path)
+	if err != nil {
+		return "", err
+	}
+
+	item := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+	excluded, err := readExcludeFile(cfg.RepoRoot, cfg.excludeFile())
+	if err != nil {
+		return "", fmt.Errorf("failed to read exclude file: %w", err)
+	}
+	managed := false
+	for _, entry := range excluded {
+		entry = strings.TrimSuffix(entry, "/")
+		managed = managed || filepath.ToSlash(rel) == entry || strings.HasPrefix(filepath.ToSlash(rel), entry+"/")
+	}
+	if _, err := os.Lstat(filepath.Join(cfg.StoreLocation, item)); err == nil {
+		managed = true
+	}
+	if !managed || isReservedItem(item) {
+		return "", fmt.Errorf("%s is not a personal file", path)
+	}
+	tracked, err := trackedItems(cfg, []string{item})
+	if err != nil {
+		return "", err
+	}
+	if tracked[item] {
+		return "", fmt.Errorf("%s is tracked in git", item)
+	}
+	return rel, nil
+}
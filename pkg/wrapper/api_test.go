@@ -0,0 +1,141 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// givenSyncedAPI returns a daemon for a git repository whose store holds
+// CLAUDE.md and .claude/settings.json, already synced into the working
+// tree.
+func givenSyncedAPI(t *testing.T) (*daemon, string) {
+	t.Helper()
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "stored")
+	writeFile(t, filepath.Join(storeBase, ".claude", "settings.json"), "{}")
+
+	d := newDaemon(Settings{})
+	d.defaultRepo = dir
+	if resp := d.handle(daemonRequest{Cmd: "sync-in"}); !resp.OK {
+		t.Fatalf("sync-in failed: %s", resp.Error)
+	}
+	return d, dir
+}
+
+func TestServeStream_AnswersEachLine(t *testing.T) {
+	d, dir := givenSyncedAPI(t)
+	in := strings.NewReader(`{"cmd":"status"}` + "\n" + `not json` + "\n" + `{"cmd":"sync-out"}` + "\n")
+	var out bytes.Buffer
+
+	d.serveStream(in, &out)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d response lines, want 3:\n%s", len(lines), out.String())
+	}
+	var status struct {
+		OK     bool   `json:"ok"`
+		Result Status `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &status); err != nil || !status.OK || status.Result.Repo != dir {
+		t.Errorf("status response = %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `{"ok":false,"error":"invalid request`) {
+		t.Errorf("bad request response = %s", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], `{"ok":true`) {
+		t.Errorf("sync-out response = %s", lines[2])
+	}
+}
+
+func TestDaemonDiff_ListsChangedFiles(t *testing.T) {
+	d, dir := givenSyncedAPI(t)
+	writeFile(t, filepath.Join(dir, "CLAUDE.md"), "edited")
+	writeFile(t, filepath.Join(dir, ".claude", "commands.md"), "new")
+
+	changes, err := d.diff(dir, "")
+	if err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+	want := []fileChange{
+		{Path: filepath.Join(".claude", "commands.md"), Change: "added"},
+		{Path: "CLAUDE.md", Change: "modified"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("diff = %+v, want %+v", changes, want)
+	}
+
+	if changes, err := d.diff(dir, ".claude"); err != nil || len(changes) != 1 {
+		t.Errorf("diff of .claude = %+v, %v; want only the added file", changes, err)
+	}
+}
+
+func TestDaemonRestore_DiscardsWorkingTreeEdits(t *testing.T) {
+	d, dir := givenSyncedAPI(t)
+	writeFile(t, filepath.Join(dir, "CLAUDE.md"), "edited")
+	writeFile(t, filepath.Join(dir, ".claude", "commands.md"), "new")
+
+	restored, err := d.restore(dir, ".claude")
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if !reflect.DeepEqual(restored, []string{".claude"}) {
+		t.Errorf("restored = %v, want [.claude]", restored)
+	}
+	assertNotExists(t, filepath.Join(dir, ".claude", "commands.md"))
+	assertFileContent(t, filepath.Join(dir, "CLAUDE.md"), "edited")
+
+	if _, err := d.restore(dir, ""); err != nil {
+		t.Fatalf("restore of everything failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(dir, "CLAUDE.md"), "stored")
+}
+
+func TestManagedPath_RefusesOtherPaths(t *testing.T) {
+	d, dir := givenSyncedAPI(t)
+	cfg := d.repos[dir].cfg
+
+	for _, path := range []string{"../elsewhere", "README.md", "src/main.go", branchesDir} {
+		if _, err := managedPath(cfg, "", path); err == nil {
+			t.Errorf("managedPath(%q) succeeded, want an error", path)
+		}
+	}
+	if rel, err := managedPath(cfg, "", filepath.Join(dir, ".claude", "settings.json")); err != nil || rel != filepath.Join(".claude", "settings.json") {
+		t.Errorf("managedPath of an absolute path = %q, %v", rel, err)
+	}
+}
+
+func TestManagedPath_RelativeToRequestDirectory(t *testing.T) {
+	d, dir := givenSyncedAPI(t)
+	cfg := d.repos[dir].cfg
+	sub := filepath.Join(dir, ".claude")
+
+	if rel, err := managedPath(cfg, sub, "settings.json"); err != nil || rel != filepath.Join(".claude", "settings.json") {
+		t.Errorf("managedPath from a subdirectory = %q, %v", rel, err)
+	}
+	if rel, err := managedPath(cfg, sub, filepath.Join("..", "CLAUDE.md")); err != nil || rel != "CLAUDE.md" {
+		t.Errorf("managedPath of a parent's item = %q, %v", rel, err)
+	}
+	if _, err := managedPath(cfg, sub, filepath.Join("..", "..", "elsewhere")); err == nil {
+		t.Error("expected paths outside the repository to be refused")
+	}
+
+	// The same repository reached through a symlink
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if rel, err := managedPath(cfg, link, "CLAUDE.md"); err != nil || rel != "CLAUDE.md" {
+		t.Errorf("managedPath through a symlink = %q, %v", rel, err)
+	}
+	if rel, err := managedPath(cfg, "", filepath.Join(link, ".claude", "missing.json")); err != nil || rel != filepath.Join(".claude", "missing.json") {
+		t.Errorf("managedPath of a missing file through a symlink = %q, %v", rel, err)
+	}
+}
@@ -0,0 +1,185 @@
+package wrapper
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	archiveDir                  = "archive"
+	defaultArchiveRetentionDays = 90
+)
+
+// archiveRetention returns how long archived branch stores are kept. A
+// negative archive_retention_days keeps them forever (returns 0).
+func (s Settings) archiveRetention() time.Duration {
+	days := s.ArchiveRetentionDays
+	if days < 0 {
+		return 0
+	}
+	if days == 0 {
+		days = defaultArchiveRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// archiveBranchStore writes the branch store at path to a tar.gz under
+// <storeBase>/archive/ and returns the archive's path. Files still as seeded
+// from the default branch's store are left out.
+func archiveBranchStore(storeBase, branch, path string, now time.Time) (string, error) {
+	dir := filepath.Join(storeBase, archiveDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	base := sanitizeBranchName(branch) + "-" + now.Format("2006-01-02")
+	archivePath := filepath.Join(dir, base+".tar.gz")
+	for n := 2; ; n++ {
+		if _, err := os.Stat(archivePath); os.IsNotExist(err) {
+			break
+		}
+		archivePath = filepath.Join(dir, base+"-"+strconv.Itoa(n)+".tar.gz")
+	}
+
+	m, err := readManifest(path)
+	if err != nil {
+		return "", err
+	}
+	seeded := func(rel string) bool { return !m.diverged(rel) }
+	if err := writeTarGz(archivePath, path, filepath.Base(path), seeded); err != nil {
+		os.Remove(archivePath)
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// divergedFiles reports whether any file of the branch store at path
+// differs from what it was seeded with from the default branch's store.
+func divergedFiles(path string) (bool, error) {
+	m, err := readManifest(path)
+	if err != nil {
+		return false, err
+	}
+	diverged := false
+	err = walkStoreFiles(path, func(rel string) error {
+		diverged = diverged || m.diverged(rel)
+		return nil
+	})
+	return diverged, err
+}
+
+// writeTarGz archives the tree at root into dst, with entries under prefix/,
+// leaving out the regular files whose root-relative paths skip returns true
+// for.
+func writeTarGz(dst, root, prefix string, skip func(rel string) bool) error {
+	file, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSocket != 0 {
+			return nil // tar can't represent sockets, and they're meaningless once closed
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && skip(rel) {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// pruneArchives deletes archived branch stores older than the archive
+// retention period.
+func pruneArchives(cfg *Config, now time.Time) {
+	retention := cfg.Settings.archiveRetention()
+	if retention == 0 {
+		return
+	}
+
+	dir := filepath.Join(cfg.StoreBase, archiveDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		if now.Sub(info.ModTime()) <= retention {
+			continue
+		}
+		modTime := info.ModTime()
+		err = auditedRemoveAll(cfg.StoreBase, auditEntry{
+			Path:     filepath.Join(dir, entry.Name()),
+			Reason:   fmt.Sprintf("archive older than %d days", int(retention.Hours()/24)),
+			Repo:     cfg.RepoRoot,
+			MarkedAt: &modTime,
+		})
+		if err != nil {
+			warnf("failed to delete old archive %s: %v", entry.Name(), err)
+		}
+	}
+}
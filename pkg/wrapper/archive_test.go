@@ -0,0 +1,191 @@
+package wrapper
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readTarGz returns the regular files in a tar.gz archive keyed by name.
+func readTarGz(t *testing.T, path string) map[string]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(data)
+	}
+}
+
+func TestCleanupDeletedBranches_ArchivePolicy(t *testing.T) {
+	store := t.TempDir()
+	branchesPath := filepath.Join(store, branchesDir)
+	branchDir := sanitizeBranchName("feature/old")
+
+	writeFile(t, filepath.Join(branchesPath, branchDir, "CLAUDE.md"), "old notes")
+	writeFile(t, filepath.Join(branchesPath, branchDir, "prompts", "a.md"), "prompt")
+	expiredTs := time.Now().Add(-8 * 24 * time.Hour).Unix()
+	writeFile(t, filepath.Join(branchesPath, branchDir, deletionMarker), fmt.Sprintf("%d", expiredTs))
+	withBranches(t, map[string]bool{"main": true})
+
+	cfg := &Config{
+		CurrentBranch: "main",
+		DefaultBranch: "main",
+		StoreBase:     store,
+		StoreLocation: store,
+		Settings:      Settings{CleanupPolicy: "archive"},
+	}
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNotExists(t, filepath.Join(branchesPath, branchDir))
+
+	archivePath := filepath.Join(store, archiveDir, branchDir+"-"+time.Now().Format("2006-01-02")+".tar.gz")
+	files := readTarGz(t, archivePath)
+	if files[branchDir+"/CLAUDE.md"] != "old notes" {
+		t.Errorf("archive missing CLAUDE.md, got %v", files)
+	}
+	if files[branchDir+"/prompts/a.md"] != "prompt" {
+		t.Errorf("archive missing prompts/a.md, got %v", files)
+	}
+
+	entries := readAuditLog(t, store)
+	if len(entries) != 1 || entries[0].Path != filepath.Join(branchesPath, branchDir) {
+		t.Fatalf("expected the branch removal to be audited, got %+v", entries)
+	}
+}
+
+func TestArchiveBranchStore_AvoidsNameCollisions(t *testing.T) {
+	store := t.TempDir()
+	branchStore := filepath.Join(store, branchesDir, "feature")
+	writeFile(t, filepath.Join(branchStore, "a.txt"), "a")
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	first, err := archiveBranchStore(store, "feature", branchStore, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := archiveBranchStore(store, "feature", branchStore, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Base(first) != "feature-2026-03-04.tar.gz" {
+		t.Errorf("unexpected archive name %s", first)
+	}
+	if filepath.Base(second) != "feature-2026-03-04-2.tar.gz" {
+		t.Errorf("unexpected second archive name %s", second)
+	}
+}
+
+func TestPruneArchives(t *testing.T) {
+	store := t.TempDir()
+	oldArchive := filepath.Join(store, archiveDir, "old-2025-01-01.tar.gz")
+	newArchive := filepath.Join(store, archiveDir, "new-2026-01-01.tar.gz")
+	writeFile(t, oldArchive, "old")
+	writeFile(t, newArchive, "new")
+
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	if err := os.Chtimes(oldArchive, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("keeps archives forever with negative retention", func(t *testing.T) {
+		cfg := &Config{StoreBase: store, Settings: Settings{ArchiveRetentionDays: -1}}
+		pruneArchives(cfg, time.Now())
+		assertExists(t, oldArchive)
+	})
+
+	t.Run("removes archives past the default retention", func(t *testing.T) {
+		cfg := &Config{StoreBase: store}
+		pruneArchives(cfg, time.Now())
+		assertNotExists(t, oldArchive)
+		assertExists(t, newArchive)
+	})
+}
+
+func TestArchiveDirIsNotSynced(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	store := t.TempDir()
+	writeFile(t, filepath.Join(store, archiveDir, "x-2026-01-01.tar.gz"), "data")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "")
+
+	cfg := &Config{
+		RepoRoot:      repoRoot,
+		CurrentBranch: "main",
+		DefaultBranch: "main",
+		StoreBase:     store,
+		StoreLocation: store,
+	}
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(repoRoot, archiveDir))
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(store, archiveDir, "x-2026-01-01.tar.gz"))
+}
+
+func TestArchiveBranchStore_LeavesOutSeededFiles(t *testing.T) {
+	cfg := givenSeededBranch(t)
+	writeFile(t, filepath.Join(cfg.RepoRoot, "notes.md"), "feature notes")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := archiveBranchStore(cfg.StoreBase, "feature", cfg.StoreLocation, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readTarGz(t, path)
+	prefix := filepath.Base(cfg.StoreLocation) + "/"
+	if files[prefix+"notes.md"] != "feature notes" {
+		t.Errorf("archive = %v, want the changed notes.md", files)
+	}
+	if _, ok := files[prefix+"CLAUDE.md"]; ok {
+		t.Errorf("archive = %v, want the seeded CLAUDE.md left out", files)
+	}
+}
+
+func TestRemoveExpiredBranchStore_SkipsArchivingUndivergedStore(t *testing.T) {
+	cfg := givenSeededBranch(t)
+	cfg.Settings.CleanupPolicy = "archive"
+
+	removeExpiredBranchStore(cfg, "feature", cfg.StoreLocation, "test", time.Now(), time.Now())
+	assertNotExists(t, cfg.StoreLocation)
+	assertNotExists(t, filepath.Join(cfg.StoreBase, archiveDir))
+}
@@ -0,0 +1,65 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// auditEntry is one line of the append-only audit log kept in the store base.
+// It records every destructive operation together with the state that
+// triggered it, so accidental data loss can be traced after the fact.
+type auditEntry struct {
+	Time     time.Time  `json:"time"`
+	Action   string     `json:"action"`
+	Path     string     `json:"path"`
+	Reason   string     `json:"reason"`
+	Repo     string     `json:"repo,omitempty"`
+	Branch   string     `json:"branch,omitempty"`
+	Exclude  []string   `json:"exclude,omitempty"`
+	MarkedAt *time.Time `json:"marked_at,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// auditedRemoveAll removes entry.Path and records the removal in the audit
+// log under storeBase. Failing to write the log never blocks the removal.
+func auditedRemoveAll(storeBase string, entry auditEntry) error {
+	return auditedRemoval(storeBase, entry, os.RemoveAll)
+}
+
+// auditedRemoval is auditedRemoveAll removing entry.Path with remove.
+func auditedRemoval(storeBase string, entry auditEntry, remove func(path string) error) error {
+	entry.Time = time.Now()
+	entry.Action = "remove"
+
+	err := remove(entry.Path)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if logErr := appendAuditLog(storeBase, entry); logErr != nil {
+		warnf("failed to write audit log: %v", logErr)
+	}
+	return err
+}
+
+func appendAuditLog(storeBase string, entry auditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(storeBase, 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(storeBase, auditLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\n", data)
+	return err
+}
@@ -0,0 +1,125 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readAuditLog parses every entry in the audit log under storeBase.
+func readAuditLog(t *testing.T, storeBase string) []auditEntry {
+	t.Helper()
+	var entries []auditEntry
+	content := strings.TrimSpace(readFileContent(t, filepath.Join(storeBase, auditLogFile)))
+	for _, line := range strings.Split(content, "\n") {
+		var entry auditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid audit log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditLog_RecordsStaleItemRemoval(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	store := t.TempDir()
+
+	writeFile(t, filepath.Join(store, "old-file.txt"), "stale")
+	writeFile(t, filepath.Join(repoRoot, "current.txt"), "new content")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "current.txt\n")
+
+	cfg := &Config{
+		RepoRoot:      repoRoot,
+		CurrentBranch: "main",
+		DefaultBranch: "main",
+		StoreBase:     store,
+		StoreLocation: store,
+	}
+	givenTombstoned(t, store, "old-file.txt")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readAuditLog(t, store)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Action != "remove" || entry.Path != filepath.Join(store, "old-file.txt") {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+	if entry.Branch != "main" || entry.Repo != repoRoot {
+		t.Errorf("audit entry missing branch/repo context: %+v", entry)
+	}
+	if len(entry.Exclude) != 1 || entry.Exclude[0] != "current.txt" {
+		t.Errorf("audit entry should record exclude state, got %v", entry.Exclude)
+	}
+	if time.Since(entry.Time) > time.Minute {
+		t.Errorf("audit entry timestamp is not recent: %v", entry.Time)
+	}
+}
+
+func TestAuditLog_SurvivesSyncOutOnDefaultBranch(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	store := t.TempDir()
+
+	writeFile(t, filepath.Join(store, auditLogFile), "{}\n")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "")
+
+	cfg := &Config{
+		RepoRoot:      repoRoot,
+		CurrentBranch: "main",
+		DefaultBranch: "main",
+		StoreBase:     store,
+		StoreLocation: store,
+	}
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(store, auditLogFile))
+
+	// And it is never synced into the working tree
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(repoRoot, auditLogFile))
+}
+
+func TestAuditLog_RecordsExpiredBranchRemoval(t *testing.T) {
+	store := t.TempDir()
+	branchesPath := filepath.Join(store, branchesDir)
+
+	writeFile(t, filepath.Join(branchesPath, "old-branch", "file.txt"), "data")
+	expired := time.Now().Add(-8 * 24 * time.Hour).Truncate(time.Second)
+	writeFile(t, filepath.Join(branchesPath, "old-branch", deletionMarker), fmt.Sprintf("%d", expired.Unix()))
+	withBranches(t, map[string]bool{"main": true})
+
+	cfg := &Config{
+		CurrentBranch: "main",
+		DefaultBranch: "main",
+		StoreBase:     store,
+		StoreLocation: store,
+	}
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readAuditLog(t, store)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Branch != "old-branch" || entry.Path != filepath.Join(branchesPath, "old-branch") {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+	if entry.MarkedAt == nil || !entry.MarkedAt.Equal(expired) {
+		t.Errorf("expected marker time %v, got %v", expired, entry.MarkedAt)
+	}
+}
@@ -0,0 +1,229 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// --- Scenario: Branch Switched Outside The Wrapper Mid-Session ---
+
+func TestScenario_UserSwitchesBranchDuringSession(t *testing.T) {
+	t.Run("Given a session started on feature-a", func(t *testing.T) {
+		repoRoot := givenRepo(t)
+		cfgA, storeBase := givenConfig(t, repoRoot, configOpts{currentBranch: "feature-a"})
+
+		writeFile(t, filepath.Join(cfgA.StoreLocation, "CLAUDE.md"), "feature-a config")
+		writeFile(t, filepath.Join(cfgA.StoreLocation, "a-only.md"), "a notes")
+		cfgB := cfgA.forBranch("feature-b")
+		writeFile(t, filepath.Join(cfgB.StoreLocation, "CLAUDE.md"), "feature-b config")
+
+		if err := syncInAfterSwitch(cfgA); err != nil {
+			t.Fatalf("syncIn failed: %v", err)
+		}
+
+		t.Run("And the user edits a personal file then checks out feature-b", func(t *testing.T) {
+			writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "edited on feature-a")
+
+			t.Run("When the wrapper syncs out", func(t *testing.T) {
+				if err := syncOutAndReconcile(cfgA, "feature-b"); err != nil {
+					t.Fatalf("syncOutAndReconcile failed: %v", err)
+				}
+
+				t.Run("Then the edit is saved to feature-a's store, not feature-b's", func(t *testing.T) {
+					assertFileContent(t, filepath.Join(cfgA.StoreLocation, "CLAUDE.md"), "edited on feature-a")
+					assertFileContent(t, filepath.Join(cfgB.StoreLocation, "CLAUDE.md"), "feature-b config")
+				})
+
+				t.Run("Then the working tree holds feature-b's files", func(t *testing.T) {
+					assertFileContent(t, filepath.Join(repoRoot, "CLAUDE.md"), "feature-b config")
+				})
+
+				t.Run("Then files only feature-a had are removed from the working tree", func(t *testing.T) {
+					assertNotExists(t, filepath.Join(repoRoot, "a-only.md"))
+					assertFileContent(t, filepath.Join(cfgA.StoreLocation, "a-only.md"), "a notes")
+				})
+
+				t.Run("Then feature-b is recorded as the synced branch", func(t *testing.T) {
+					if got := lastSyncedBranch(repoRoot); got != "feature-b" {
+						t.Errorf("expected feature-b, got %q", got)
+					}
+				})
+
+				t.Run("Then the default store is untouched", func(t *testing.T) {
+					assertNotExists(t, filepath.Join(storeBase, "CLAUDE.md"))
+				})
+			})
+		})
+	})
+}
+
+func TestScenario_UserDetachesHeadDuringSession(t *testing.T) {
+	t.Run("Given a session started on feature-a", func(t *testing.T) {
+		repoRoot := givenRepo(t)
+		cfg, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature-a"})
+		writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "feature-a config")
+
+		if err := syncInAfterSwitch(cfg); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "edited")
+
+		t.Run("When HEAD is detached at sync-out", func(t *testing.T) {
+			if err := syncOutAndReconcile(cfg, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("Then files are saved to feature-a's store and left in place", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "edited")
+				assertFileContent(t, filepath.Join(repoRoot, "CLAUDE.md"), "edited")
+			})
+		})
+	})
+}
+
+func TestScenario_UserSwitchedBranchBetweenSessions(t *testing.T) {
+	t.Run("Given the last session synced in feature-a", func(t *testing.T) {
+		repoRoot := givenRepo(t)
+		cfgA, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature-a"})
+		writeFile(t, filepath.Join(cfgA.StoreLocation, "CLAUDE.md"), "feature-a config")
+		if err := syncInAfterSwitch(cfgA); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run("And the file was edited before checking out a new branch", func(t *testing.T) {
+			writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "edited between sessions")
+			cfgNew := cfgA.forBranch("feature-new")
+
+			t.Run("When the next session syncs in on the new branch", func(t *testing.T) {
+				if err := syncInAfterSwitch(cfgNew); err != nil {
+					t.Fatal(err)
+				}
+
+				t.Run("Then the edit is preserved in feature-a's store", func(t *testing.T) {
+					assertFileContent(t, filepath.Join(cfgA.StoreLocation, "CLAUDE.md"), "edited between sessions")
+				})
+
+				t.Run("Then the new branch does not inherit feature-a's file", func(t *testing.T) {
+					assertNotExists(t, filepath.Join(repoRoot, "CLAUDE.md"))
+				})
+			})
+		})
+	})
+}
+
+// --- Scenario: Shared Prompt Library Across Branches ---
+
+func TestScenario_SharedItemsFollowEveryBranch(t *testing.T) {
+	t.Run("Given a shared prompt library and a feature-b override", func(t *testing.T) {
+		repoRoot := givenRepo(t)
+		cfgA, storeBase := givenConfig(t, repoRoot, configOpts{currentBranch: "feature-a"})
+		cfgB := cfgA.forBranch("feature-b")
+
+		writeFile(t, filepath.Join(storeBase, sharedDir, "prompts.md"), "canonical prompts")
+		writeFile(t, filepath.Join(cfgA.StoreLocation, "notes.md"), "a notes")
+		writeFile(t, filepath.Join(cfgB.StoreLocation, "prompts.md"), "feature-b prompts")
+
+		if err := syncInAfterSwitch(cfgA); err != nil {
+			t.Fatalf("syncIn failed: %v", err)
+		}
+
+		t.Run("When the user works on feature-a", func(t *testing.T) {
+			t.Run("Then the shared prompts are in the working tree", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(repoRoot, "prompts.md"), "canonical prompts")
+			})
+		})
+
+		t.Run("When the user switches to feature-b", func(t *testing.T) {
+			if err := syncOutAndReconcile(cfgA, "feature-b"); err != nil {
+				t.Fatalf("syncOutAndReconcile failed: %v", err)
+			}
+
+			t.Run("Then feature-b's override replaces the shared copy", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(repoRoot, "prompts.md"), "feature-b prompts")
+			})
+
+			t.Run("Then the shared copy was not saved into feature-a's store", func(t *testing.T) {
+				assertNotExists(t, filepath.Join(cfgA.StoreLocation, "prompts.md"))
+			})
+
+			t.Run("Then the shared library is untouched", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(storeBase, sharedDir, "prompts.md"), "canonical prompts")
+			})
+		})
+	})
+}
+
+// --- Scenario: Mirrored Directory Across A Branch Switch ---
+
+func TestScenario_MirroredDirectoryDropsPreviousBranchFiles(t *testing.T) {
+	t.Run("Given prompts is mirrored and feature-a has a prompt feature-b lacks", func(t *testing.T) {
+		repoRoot := givenRepo(t)
+		cfgA, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature-a"})
+		cfgA.Settings.DirectorySyncItems = map[string]string{"prompts": "mirror"}
+		writeFile(t, filepath.Join(cfgA.StoreLocation, "prompts", "review.md"), "a review")
+		writeFile(t, filepath.Join(cfgA.StoreLocation, "prompts", "a-only.md"), "a prompt")
+		writeFile(t, filepath.Join(cfgA.StoreLocation, ".claude", "a-only.md"), "a setting")
+		cfgB := cfgA.forBranch("feature-b")
+		writeFile(t, filepath.Join(cfgB.StoreLocation, "prompts", "review.md"), "b review")
+		writeFile(t, filepath.Join(cfgB.StoreLocation, ".claude", "settings.json"), "b settings")
+
+		if err := syncInAfterSwitch(cfgA); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run("When the session ends on feature-b", func(t *testing.T) {
+			if err := syncOutAndReconcile(cfgA, "feature-b"); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("Then prompts holds exactly feature-b's files", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(repoRoot, "prompts", "review.md"), "b review")
+				assertNotExists(t, filepath.Join(repoRoot, "prompts", "a-only.md"))
+				assertFileContent(t, filepath.Join(cfgA.StoreLocation, "prompts", "a-only.md"), "a prompt")
+			})
+
+			t.Run("Then directories left to merge keep feature-a's extras", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(repoRoot, ".claude", "a-only.md"), "a setting")
+			})
+		})
+	})
+}
+
+// --- Scenario: Caches Stay On Their Branch ---
+
+func TestScenario_UninheritedCacheStaysOnItsBranch(t *testing.T) {
+	t.Run("Given .claude/cache is not inherited and main has a cache", func(t *testing.T) {
+		repoRoot := givenRepo(t)
+		cfgMain, _ := givenConfig(t, repoRoot, configOpts{})
+		noInherit := false
+		cfgMain.Settings.Items = map[string]ItemSettings{".claude/cache": {Inherit: &noInherit}}
+		writeFile(t, filepath.Join(cfgMain.StoreLocation, ".claude", "settings.json"), "main settings")
+		writeFile(t, filepath.Join(cfgMain.StoreLocation, ".claude", "cache", "index.db"), "main cache")
+
+		if err := syncInAfterSwitch(cfgMain); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run("When the session ends on a new branch", func(t *testing.T) {
+			if err := syncOutAndReconcile(cfgMain, "feature"); err != nil {
+				t.Fatal(err)
+			}
+			cfgFeature := cfgMain.forBranch("feature")
+
+			t.Run("Then the new branch gets the settings but not the cache", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(repoRoot, ".claude", "settings.json"), "main settings")
+				assertNotExists(t, filepath.Join(repoRoot, ".claude", "cache"))
+				assertNotExists(t, filepath.Join(cfgFeature.StoreLocation, ".claude", "cache"))
+				assertFileContent(t, filepath.Join(cfgMain.StoreLocation, ".claude", "cache", "index.db"), "main cache")
+			})
+
+			t.Run("Then a cache created on the branch is kept for it", func(t *testing.T) {
+				writeFile(t, filepath.Join(repoRoot, ".claude", "cache", "index.db"), "feature cache")
+				if err := syncOut(cfgFeature); err != nil {
+					t.Fatal(err)
+				}
+				assertFileContent(t, filepath.Join(cfgFeature.StoreLocation, ".claude", "cache", "index.db"), "feature cache")
+			})
+		})
+	})
+}
@@ -1,4 +1,4 @@
-package main
+package wrapper
 
 import (
 	"fmt"
@@ -115,7 +115,7 @@ func TestScenario_NewPersonalFileCreatedDuringSessionGetsPersisted(t *testing.T)
 			t.Run("And the user creates a new personal file during the session", func(t *testing.T) {
 				writeFile(t, filepath.Join(repoRoot, "new-notes.md"), "brand new notes")
 				// User adds it to exclude (as the wrapper would on next sync-in)
-				if err := addToExclude(repoRoot, "new-notes.md"); err != nil {
+				if err := addToExclude(repoRoot, excludePath(repoRoot), "new-notes.md"); err != nil {
 					t.Fatalf("addToExclude failed: %v", err)
 				}
 
@@ -491,7 +491,7 @@ func TestScenario_ExcludeFileTrailingSlashDirectoryIsHandledCorrectly(t *testing
 		writeFile(t, filepath.Join(repoRoot, excludeFile), ".claude/\n")
 
 		t.Run("When the wrapper reads the exclude file", func(t *testing.T) {
-			items, err := readExcludeFile(repoRoot)
+			items, err := readExcludeFile(repoRoot, excludePath(repoRoot))
 			if err != nil {
 				t.Fatalf("readExcludeFile failed: %v", err)
 			}
@@ -558,7 +558,7 @@ func TestScenario_FirstRunOnFeatureBranchWithNoStorageAnywhere(t *testing.T) {
 				t.Run("Then branch storage is created empty", func(t *testing.T) {
 					assertExists(t, storeLocation)
 					items, _ := listDir(storeLocation)
-					if len(items) != 0 {
+					if items := filterItems(items); len(items) != 0 {
 						t.Errorf("expected empty storage, got %v", items)
 					}
 				})
@@ -575,3 +575,34 @@ func TestScenario_FirstRunOnFeatureBranchWithNoStorageAnywhere(t *testing.T) {
 		})
 	})
 }
+
+// --- Scenario: One Item Fails To Sync ---
+
+func TestScenario_OneFailedItemDoesNotBlockTheRest(t *testing.T) {
+	t.Run("Given two personal files and a store that can't take one of them", func(t *testing.T) {
+		repoRoot := givenRepo(t)
+		cfg, _ := givenConfig(t, repoRoot, configOpts{})
+
+		writeFile(t, filepath.Join(repoRoot, "broken.md"), "can't be saved")
+		writeFile(t, filepath.Join(repoRoot, "notes.md"), "important notes")
+		writeFile(t, filepath.Join(repoRoot, excludeFile), "broken.md\nnotes.md\n")
+		// A directory where the file should go makes its copy fail
+		if err := os.MkdirAll(filepath.Join(cfg.StoreLocation, "broken.md"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run("When the wrapper syncs out", func(t *testing.T) {
+			err := syncOut(cfg)
+
+			t.Run("Then the other file is still saved", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(cfg.StoreLocation, "notes.md"), "important notes")
+			})
+
+			t.Run("Then the failure is reported", func(t *testing.T) {
+				if err == nil || !strings.Contains(err.Error(), "failed to copy broken.md") {
+					t.Errorf("expected an error naming broken.md, got %v", err)
+				}
+			})
+		})
+	})
+}
@@ -1,4 +1,4 @@
-package main
+package wrapper
 
 import (
 	"os"
@@ -219,6 +219,17 @@ func TestScenario_UserRemovesPersonalFileDuringSession(t *testing.T) {
 					t.Fatalf("syncOut failed: %v", err)
 				}
 
+				t.Run("Then the stored copy is kept for the grace period", func(t *testing.T) {
+					assertExists(t, filepath.Join(cfg.StoreLocation, "old-notes.md"))
+				})
+			})
+
+			t.Run("When the wrapper syncs out after the grace period", func(t *testing.T) {
+				givenTombstoned(t, cfg.StoreLocation, "old-notes.md")
+				if err := syncOut(cfg); err != nil {
+					t.Fatalf("syncOut failed: %v", err)
+				}
+
 				t.Run("Then the file is also removed from storage", func(t *testing.T) {
 					assertNotExists(t, filepath.Join(cfg.StoreLocation, "old-notes.md"))
 				})
@@ -298,7 +309,7 @@ func TestScenario_ExcludeFileHandlesVariousFormats(t *testing.T) {
 		writeFile(t, filepath.Join(repoRoot, excludeFile), excludeContent)
 
 		t.Run("When the wrapper reads the exclude file", func(t *testing.T) {
-			items, err := readExcludeFile(repoRoot)
+			items, err := readExcludeFile(repoRoot, excludePath(repoRoot))
 			if err != nil {
 				t.Fatalf("readExcludeFile failed: %v", err)
 			}
@@ -317,3 +328,34 @@ func TestScenario_ExcludeFileHandlesVariousFormats(t *testing.T) {
 		})
 	})
 }
+
+// --- Scenario: Hand-Written Excludes Stay Out Of Storage ---
+
+func TestScenario_HandWrittenExcludeIsNotAPersonalFile(t *testing.T) {
+	t.Run("Given a synced-in personal file and a hand-written exclude", func(t *testing.T) {
+		repoRoot := givenRepo(t)
+		cfg, _ := givenConfig(t, repoRoot, configOpts{})
+		writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "personal")
+		if err := syncIn(cfg); err != nil {
+			t.Fatalf("syncIn failed: %v", err)
+		}
+
+		writeFile(t, filepath.Join(repoRoot, "profile.out"), "big local output")
+		excludePath := filepath.Join(repoRoot, excludeFile)
+		writeFile(t, excludePath, "profile.out\n"+readFileContent(t, excludePath))
+
+		t.Run("When the wrapper syncs out", func(t *testing.T) {
+			if err := syncOut(cfg); err != nil {
+				t.Fatalf("syncOut failed: %v", err)
+			}
+
+			t.Run("Then the personal file is saved", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "personal")
+			})
+
+			t.Run("Then the hand-written exclude is left to git", func(t *testing.T) {
+				assertNotExists(t, filepath.Join(cfg.StoreLocation, "profile.out"))
+			})
+		})
+	})
+}
@@ -0,0 +1,12 @@
+package wrapper
+
+import "golang.org/x/sys/unix"
+
+// bootID identifies the current boot of the machine, or is "" if unknown.
+func bootID() string {
+	id, err := unix.Sysctl("kern.bootsessionuuid")
+	if err != nil {
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,15 @@
+package wrapper
+
+import (
+	"os"
+	"strings"
+)
+
+// bootID identifies the current boot of the machine, or is "" if unknown.
+func bootID() string {
+	data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
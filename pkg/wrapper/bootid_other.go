@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package wrapper
+
+// bootID is only known on Linux and macOS.
+func bootID() string {
+	return ""
+}
@@ -0,0 +1,103 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// branchMetaFile describes a branch store: when it was created and from
+// what, when it was last synced each way, and by which repository and
+// wrapper version. The default branch's store is the store base, next to
+// storeMetaFile.
+const branchMetaFile = ".meta.json"
+
+// Version is the wrapper's version, recorded in branch store metadata. The
+// command sets it at startup.
+var Version = "dev"
+
+// branchMeta is the content of branchMetaFile.
+type branchMeta struct {
+	CreatedAt time.Time `json:"created_at"`
+	// SeededFrom is the branch whose store this one was seeded from, if any.
+	SeededFrom  string     `json:"seeded_from,omitempty"`
+	LastSyncIn  *time.Time `json:"last_sync_in,omitempty"`
+	LastSyncOut *time.Time `json:"last_sync_out,omitempty"`
+	// KeptAt is when the user last declined to delete the store for going
+	// unused (see expire_unused_days).
+	KeptAt *time.Time `json:"kept_at,omitempty"`
+	// MergedAt is when cleanup first saw the branch merged into the
+	// default branch, while it still existed (see promote_merged).
+	MergedAt *time.Time `json:"merged_at,omitempty"`
+	// Commit is the commit cleanup last saw the branch point to, and
+	// Deletion whether the branch was "merged" into the default branch or
+	// "unmerged" when cleanup found it deleted ("" if it couldn't tell).
+	Commit   string `json:"commit,omitempty"`
+	Deletion string `json:"deletion,omitempty"`
+	Repo     string `json:"repo,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// lastUsed returns when the branch store was last synced either way, or
+// the zero time if never.
+func (m branchMeta) lastUsed() time.Time {
+	var last time.Time
+	for _, t := range []*time.Time{m.LastSyncIn, m.LastSyncOut} {
+		if t != nil && t.After(last) {
+			last = *t
+		}
+	}
+	return last
+}
+
+// readBranchMeta returns the metadata of the branch store at store, or false
+// if there is none (the store predates it).
+func readBranchMeta(store string) (branchMeta, bool) {
+	var meta branchMeta
+	data, err := os.ReadFile(filepath.Join(store, branchMetaFile))
+	if err != nil || json.Unmarshal(data, &meta) != nil {
+		return branchMeta{}, false
+	}
+	return meta, true
+}
+
+// updateBranchMeta applies update to the metadata of the branch store at
+// store, recording repo and the wrapper version, and creating it (dated now)
+// if there is none. Failing to only warns.
+func updateBranchMeta(store, repo string, now time.Time, update func(*branchMeta)) {
+	meta, ok := readBranchMeta(store)
+	if !ok {
+		meta.CreatedAt = now.UTC()
+	}
+	meta.Repo, meta.Version = repo, Version
+	update(&meta)
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err == nil {
+		path := filepath.Join(store, branchMetaFile)
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, append(data, '\n'), 0644); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	if err != nil {
+		warnf("failed to record branch store metadata: %v", err)
+	}
+}
+
+// branchStoreExpiry returns when the branch store at store, marked for
+// deletion at markedAt, may go: once the grace period for how its branch
+// was deleted has passed both since it was marked and since it was last
+// used.
+func branchStoreExpiry(s Settings, store string, markedAt time.Time) time.Time {
+	meta, ok := readBranchMeta(store)
+	grace := time.Duration(s.graceDays(meta.Deletion)) * 24 * time.Hour
+	expiry := markedAt.Add(grace)
+	if ok {
+		if used := meta.lastUsed().Add(grace); used.After(expiry) {
+			return used
+		}
+	}
+	return expiry
+}
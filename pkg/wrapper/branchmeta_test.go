@@ -0,0 +1,104 @@
+package wrapper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateBranchMeta(t *testing.T) {
+	store := t.TempDir()
+	cfg := &Config{RepoRoot: "/src/app", StoreLocation: store}
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if _, ok := readBranchMeta(store); ok {
+		t.Fatal("expected no metadata in a new store")
+	}
+	updateBranchMeta(cfg.StoreLocation, cfg.RepoRoot, created, func(meta *branchMeta) { meta.SeededFrom = "main" })
+	synced := created.Add(time.Hour)
+	updateBranchMeta(cfg.StoreLocation, cfg.RepoRoot, synced, func(meta *branchMeta) { meta.LastSyncOut = &synced })
+
+	meta, ok := readBranchMeta(store)
+	if !ok {
+		t.Fatal("expected metadata to be recorded")
+	}
+	if !meta.CreatedAt.Equal(created) || meta.SeededFrom != "main" || meta.Repo != "/src/app" || meta.Version != Version {
+		t.Errorf("metadata = %+v", meta)
+	}
+	if meta.LastSyncIn != nil || meta.LastSyncOut == nil || !meta.lastUsed().Equal(synced) {
+		t.Errorf("last syncs = %v, %v; want never in, out at %v", meta.LastSyncIn, meta.LastSyncOut, synced)
+	}
+}
+
+func TestSyncInAndOut_RecordBranchMeta(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, storeBase := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "from main")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	meta, ok := readBranchMeta(cfg.StoreLocation)
+	if !ok || meta.SeededFrom != cfg.DefaultBranch || meta.LastSyncIn == nil || meta.LastSyncOut != nil {
+		t.Fatalf("metadata after sync-in = %+v, %v", meta, ok)
+	}
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if meta, _ := readBranchMeta(cfg.StoreLocation); meta.LastSyncOut == nil {
+		t.Error("expected sync-out to be recorded")
+	}
+	// The metadata is never synced into the working tree
+	assertNotExists(t, filepath.Join(repoRoot, branchMetaFile))
+}
+
+func TestCleanupDeletedBranches_KeepsRecentlyUsedStore(t *testing.T) {
+	store := t.TempDir()
+	branchStore := filepath.Join(store, branchesDir, "detached")
+	writeFile(t, filepath.Join(branchStore, "file.txt"), "data")
+	writeFile(t, filepath.Join(branchStore, deletionMarker), fmt.Sprintf("%d", time.Now().Add(-8*24*time.Hour).Unix()))
+	updateBranchMeta(branchStore, "", time.Now(), func(meta *branchMeta) {
+		used := time.Now().Add(-24 * time.Hour)
+		meta.LastSyncIn = &used
+	})
+
+	orig := getAllBranchesFunc
+	getAllBranchesFunc = func() (map[string]bool, error) {
+		return map[string]bool{"main": true}, nil
+	}
+	defer func() { getAllBranchesFunc = orig }()
+
+	cfg := &Config{CurrentBranch: "main", DefaultBranch: "main", StoreBase: store, StoreLocation: store}
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(branchStore, "file.txt"), "data")
+
+	preview := collectCleanupPreview(cfg)
+	if len(preview) != 1 || preview[0].ExpiresAt.Before(time.Now().Add(5*24*time.Hour)) {
+		t.Errorf("cleanup preview = %+v, want it to expire a week after last use", preview)
+	}
+}
+
+func TestPrintStatus_ShowsBranchMeta(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	created := time.Date(2026, 3, 4, 12, 0, 0, 0, time.Local)
+	if err := os.MkdirAll(cfg.StoreLocation, 0755); err != nil {
+		t.Fatal(err)
+	}
+	updateBranchMeta(cfg.StoreLocation, cfg.RepoRoot, created, func(meta *branchMeta) { meta.SeededFrom = "main" })
+
+	var out bytes.Buffer
+	if err := printStatus(cfg, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "created 2026-03-04 12:00 from main; last sync in never, out never"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("expected status to contain %q, got:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,258 @@
+package wrapper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// sanitizeBranchName returns the directory name of branch's store. Every
+// byte other than ASCII letters, digits, '-', '_', '.' and '~' is
+// percent-encoded, so the name can't nest directories, climb out of the
+// branches directory ("..") or hold backslashes and control characters. A
+// leading or trailing '.' is encoded too, keeping the directory visible
+// and valid on Windows.
+func sanitizeBranchName(branch string) string {
+	var b strings.Builder
+	for i := 0; i < len(branch); i++ {
+		c := branch[i]
+		if c == '.' && (i == 0 || i == len(branch)-1) || !safeBranchByte(c) {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// safeBranchByte reports whether c is kept as is in a store directory name.
+func safeBranchByte(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-_.~", c) >= 0
+}
+
+// unsanitizeBranchName reverses sanitizeBranchName, ignoring any collision
+// suffix. Characters that aren't part of a valid %XX escape are kept as
+// they are, so directories named by older versions (which only encoded '%'
+// and '/') decode to the same branch.
+func unsanitizeBranchName(name string) string {
+	name, _ = splitCollisionSuffix(name)
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '%' && i+2 < len(name) && isHex(name[i+1]) && isHex(name[i+2]) {
+			b.WriteByte(unhex(name[i+1])<<4 | unhex(name[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	}
+	return c - 'a' + 10
+}
+
+// collisionSuffix marks a branch directory disambiguated from another whose
+// name differs only in case: it is followed by the first 8 hex digits of
+// the SHA-256 of the branch name. sanitizeBranchName never writes "%~".
+const collisionSuffix = "%~"
+
+// splitCollisionSuffix splits name into its sanitized branch name and its
+// collision suffix, which is "" if it has none.
+func splitCollisionSuffix(name string) (base, suffix string) {
+	i := strings.LastIndex(name, collisionSuffix)
+	if i < 0 || len(name)-i != len(collisionSuffix)+8 {
+		return name, ""
+	}
+	for _, c := range []byte(name[i+len(collisionSuffix):]) {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return name, ""
+		}
+	}
+	return name[:i], name[i:]
+}
+
+// shortNamesFile in the branches directory maps the short directory names
+// given to branches whose stores' paths would be too long to the branches'
+// names.
+const shortNamesFile = ".short_names.json"
+
+// shortNamePrefix starts a short branch directory name, followed by the
+// first 16 hex digits of the SHA-256 of the branch name. sanitizeBranchName
+// never writes "%#".
+const shortNamePrefix = "%#"
+
+// maxNameLen is the longest file name most filesystems allow (NAME_MAX).
+const maxNameLen = 255
+
+// maxPathLen is the longest path the OS reliably handles: MAX_PATH on
+// Windows, PATH_MAX elsewhere (smaller on macOS). Replaced in tests.
+var maxPathLen = func() int {
+	switch runtime.GOOS {
+	case "windows":
+		return 260
+	case "darwin":
+		return 1024
+	}
+	return 4096
+}()
+
+// storePathHeadroom is how much of maxPathLen is left for the paths of
+// files inside a branch store.
+const storePathHeadroom = 128
+
+// branchDirName returns the name of branch's store in the branches
+// directory at branchesPath. On case-insensitive filesystems (macOS and
+// Windows by default) branches differing only in case, like Feature/X and
+// feature/x, would share a directory, so a branch whose name folds to that
+// of an existing store gets a directory of its own with a collision suffix.
+// A branch whose directory name or store path would be too long gets a
+// short hashed name instead, recorded in the shortNamesFile.
+func branchDirName(branchesPath, branch string) string {
+	name := sanitizeBranchName(branch)
+	sum := sha256.Sum256([]byte(branch))
+	disambiguated := name + collisionSuffix + hex.EncodeToString(sum[:4])
+	if len(disambiguated) > maxNameLen || len(branchesPath)+1+len(disambiguated) > maxPathLen-storePathHeadroom {
+		short := shortNamePrefix + hex.EncodeToString(sum[:8])
+		if err := recordShortName(branchesPath, short, branch); err != nil {
+			warnf("failed to record the short store name of %s: %v", branch, err)
+		}
+		return short
+	}
+
+	entries, err := os.ReadDir(branchesPath)
+	if err != nil {
+		return name
+	}
+	collides := false
+	for _, entry := range entries {
+		switch {
+		case entry.Name() == name:
+			return name
+		case entry.Name() == disambiguated:
+			return disambiguated
+		case strings.EqualFold(entry.Name(), name):
+			collides = true
+		}
+	}
+	if collides {
+		return disambiguated
+	}
+	return name
+}
+
+// readShortNames returns the short directory names in the branches
+// directory at branchesPath, mapped to their branches.
+func readShortNames(branchesPath string) (map[string]string, error) {
+	names := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(branchesPath, shortNamesFile))
+	if os.IsNotExist(err) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", shortNamesFile, err)
+	}
+	return names, nil
+}
+
+// recordShortName records that the directory short in the branches
+// directory at branchesPath holds branch's store.
+func recordShortName(branchesPath, short, branch string) error {
+	names, err := readShortNames(branchesPath)
+	if err != nil {
+		return err
+	}
+	if names[short] == branch {
+		return nil
+	}
+	names[short] = branch
+	data, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(branchesPath, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(branchesPath, shortNamesFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// branchOfDir returns the branch whose store is the directory named dir,
+// given the branches directory's short names.
+func branchOfDir(shortNames map[string]string, dir string) string {
+	if branch, ok := shortNames[dir]; ok {
+		return branch
+	}
+	return unsanitizeBranchName(dir)
+}
+
+// branchStoreDir returns the directory of branch's store under the
+// branches directory of storeBase, even for the default branch.
+func branchStoreDir(storeBase, branch string) string {
+	branchesPath := filepath.Join(storeBase, branchesDir)
+	return filepath.Join(branchesPath, branchDirName(branchesPath, branch))
+}
+
+// migrateBranchDirs renames branch stores under storeBase named by an
+// older encoding to their current names. A store whose current name is
+// already taken is left alone and reported.
+func migrateBranchDirs(storeBase string) error {
+	branchesPath := filepath.Join(storeBase, branchesDir)
+	entries, err := os.ReadDir(branchesPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), shortNamePrefix) {
+			continue
+		}
+		base, suffix := splitCollisionSuffix(entry.Name())
+		name := sanitizeBranchName(unsanitizeBranchName(base)) + suffix
+		if name == entry.Name() || len(name) > maxNameLen {
+			continue
+		}
+		dst := filepath.Join(branchesPath, name)
+		if _, err := os.Lstat(dst); err == nil {
+			errs = append(errs, fmt.Errorf("can't rename branch store %s to %s: it already exists", entry.Name(), name))
+			continue
+		}
+		if err := os.Rename(filepath.Join(branchesPath, entry.Name()), dst); err != nil {
+			errs = append(errs, fmt.Errorf("failed to rename branch store: %w", err))
+			continue
+		}
+		infof("renamed branch store %s to %s", entry.Name(), name)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,205 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeBranchName(t *testing.T) {
+	tests := map[string]string{
+		"main":          "main",
+		"feature/auth":  "feature%2Fauth",
+		"100%":          "100%25",
+		"..":            "%2E%2E",
+		".hidden":       "%2Ehidden",
+		"release/v1.0.": "release%2Fv1.0%2E",
+		`win\path`:      "win%5Cpath",
+		"tab\there":     "tab%09here",
+		"café":          "caf%C3%A9",
+		"v1..v2":        "v1..v2",
+	}
+	for branch, want := range tests {
+		if got := sanitizeBranchName(branch); got != want {
+			t.Errorf("sanitizeBranchName(%q) = %q, want %q", branch, got, want)
+		}
+	}
+}
+
+func TestSanitizeBranchName_SafeAndReversible(t *testing.T) {
+	for _, branch := range []string{".", "..", "../../etc", "a/../b", "x\x00y", "new\nline", `C:\temp`, "sp ace", "%zz", "%2f", "émoji🎉"} {
+		name := sanitizeBranchName(branch)
+		if name == "." || name == ".." || strings.ContainsAny(name, `/\ `) || filepath.Base(name) != name {
+			t.Errorf("sanitizeBranchName(%q) = %q, not a safe directory name", branch, name)
+		}
+		if got := unsanitizeBranchName(name); got != branch {
+			t.Errorf("unsanitizeBranchName(%q) = %q, want %q", name, got, branch)
+		}
+	}
+}
+
+func TestUnsanitizeBranchName_LegacyNames(t *testing.T) {
+	// Older versions only encoded '%' and '/'
+	tests := map[string]string{
+		"feature%2Fauth": "feature/auth",
+		"100%25":         "100%",
+		"release v1":     "release v1",
+		"café":           "café",
+		"50%":            "50%",
+	}
+	for name, want := range tests {
+		if got := unsanitizeBranchName(name); got != want {
+			t.Errorf("unsanitizeBranchName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestMigrateBranchDirs(t *testing.T) {
+	storeBase := t.TempDir()
+	branches := filepath.Join(storeBase, branchesDir)
+	writeFile(t, filepath.Join(branches, "feature%2Fauth", "CLAUDE.md"), "current")
+	writeFile(t, filepath.Join(branches, "release v1", "CLAUDE.md"), "legacy")
+	writeFile(t, filepath.Join(branches, "café", "CLAUDE.md"), "legacy unicode")
+	// Both the legacy and the current name exist: left for the user
+	writeFile(t, filepath.Join(branches, "fix 2", "CLAUDE.md"), "old")
+	writeFile(t, filepath.Join(branches, "fix%202", "CLAUDE.md"), "new")
+
+	err := migrateBranchDirs(storeBase)
+	if err == nil || !strings.Contains(err.Error(), "fix%202") {
+		t.Errorf("expected the clash to be reported, got %v", err)
+	}
+	assertFileContent(t, filepath.Join(branches, "feature%2Fauth", "CLAUDE.md"), "current")
+	assertFileContent(t, filepath.Join(branches, "release%20v1", "CLAUDE.md"), "legacy")
+	assertFileContent(t, filepath.Join(branches, "caf%C3%A9", "CLAUDE.md"), "legacy unicode")
+	assertNotExists(t, filepath.Join(branches, "release v1"))
+	assertFileContent(t, filepath.Join(branches, "fix 2", "CLAUDE.md"), "old")
+	assertFileContent(t, filepath.Join(branches, "fix%202", "CLAUDE.md"), "new")
+
+	if got := branchStoreLocation(storeBase, "release v1", "main"); got != filepath.Join(branches, "release%20v1") {
+		t.Errorf("migrated store not found for its branch: %s", got)
+	}
+}
+
+func TestMigrateBranchDirs_NoBranches(t *testing.T) {
+	if err := migrateBranchDirs(t.TempDir()); err != nil {
+		t.Errorf("expected nothing to do, got %v", err)
+	}
+}
+
+func TestBranchStoreDir_CaseFoldCollision(t *testing.T) {
+	storeBase := t.TempDir()
+	upper := branchStoreDir(storeBase, "Feature/X")
+	if filepath.Base(upper) != "Feature%2FX" {
+		t.Fatalf("expected the first branch to get the plain name, got %s", upper)
+	}
+	writeFile(t, filepath.Join(upper, "CLAUDE.md"), "upper")
+
+	lower := branchStoreDir(storeBase, "feature/x")
+	if base, suffix := splitCollisionSuffix(filepath.Base(lower)); base != "feature%2Fx" || suffix == "" {
+		t.Fatalf("expected a disambiguated directory, got %s", lower)
+	}
+	writeFile(t, filepath.Join(lower, "CLAUDE.md"), "lower")
+
+	// Both stores keep their own directories and branches
+	if got := branchStoreDir(storeBase, "Feature/X"); got != upper {
+		t.Errorf("Feature/X moved to %s", got)
+	}
+	if got := branchStoreDir(storeBase, "feature/x"); got != lower {
+		t.Errorf("feature/x moved to %s", got)
+	}
+	assertFileContent(t, filepath.Join(upper, "CLAUDE.md"), "upper")
+	assertFileContent(t, filepath.Join(lower, "CLAUDE.md"), "lower")
+	branches, err := storedBranches(storeBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !branches["Feature/X"] || !branches["feature/x"] || len(branches) != 2 {
+		t.Errorf("expected both branches stored, got %v", branches)
+	}
+	if err := migrateBranchDirs(storeBase); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, lower)
+}
+
+func TestSplitCollisionSuffix(t *testing.T) {
+	tests := []struct{ name, base, suffix string }{
+		{"feature%2Fx%~0123abcd", "feature%2Fx", "%~0123abcd"},
+		{"feature%2Fx", "feature%2Fx", ""},
+		{"feature%~short", "feature%~short", ""},
+		{"feature%~0123ABCD", "feature%~0123ABCD", ""},
+	}
+	for _, tt := range tests {
+		if base, suffix := splitCollisionSuffix(tt.name); base != tt.base || suffix != tt.suffix {
+			t.Errorf("splitCollisionSuffix(%q) = %q, %q; want %q, %q", tt.name, base, suffix, tt.base, tt.suffix)
+		}
+	}
+}
+
+func TestBranchStoreDir_LongBranchNameGetsShortName(t *testing.T) {
+	storeBase := t.TempDir()
+	branch := strings.Repeat("very/deep/", 30) + "topic"
+
+	store := branchStoreDir(storeBase, branch)
+	name := filepath.Base(store)
+	if !strings.HasPrefix(name, shortNamePrefix) || len(name) != len(shortNamePrefix)+16 {
+		t.Fatalf("expected a short hashed name, got %s", name)
+	}
+	if got := branchStoreDir(storeBase, branch); got != store {
+		t.Errorf("short name not stable: %s then %s", store, got)
+	}
+	writeFile(t, filepath.Join(store, "CLAUDE.md"), "deep")
+
+	branches, err := storedBranches(storeBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !branches[branch] || len(branches) != 1 {
+		t.Errorf("expected the long branch to be listed by name, got %v", branches)
+	}
+	if err := migrateBranchDirs(storeBase); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(store, "CLAUDE.md"), "deep")
+}
+
+func TestBranchStoreDir_LongStorePathGetsShortName(t *testing.T) {
+	orig := maxPathLen
+	t.Cleanup(func() { maxPathLen = orig })
+	storeBase := t.TempDir()
+	maxPathLen = len(storeBase) + storePathHeadroom + 40
+
+	if name := filepath.Base(branchStoreDir(storeBase, "short")); name != "short" {
+		t.Errorf("expected a short branch to keep its name, got %s", name)
+	}
+	if name := filepath.Base(branchStoreDir(storeBase, "feature/a-rather-long-description")); !strings.HasPrefix(name, shortNamePrefix) {
+		t.Errorf("expected a hashed name when the path would be too long, got %s", name)
+	}
+	names, err := readShortNames(filepath.Join(storeBase, branchesDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Errorf("expected one recorded short name, got %v", names)
+	}
+}
+
+func TestCleanupDeletedBranches_ShortNamedStore(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, storeBase := givenConfig(t, repoRoot, configOpts{})
+	branch := strings.Repeat("nested/", 40) + "gone"
+	store := branchStoreDir(storeBase, branch)
+	writeFile(t, filepath.Join(store, "CLAUDE.md"), "x")
+
+	withBranches(t, map[string]bool{"main": true, branch: true})
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(store, deletionMarker))
+
+	withBranches(t, map[string]bool{"main": true})
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(store, deletionMarker))
+}
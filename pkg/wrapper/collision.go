@@ -0,0 +1,117 @@
+package wrapper
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// trackedItems returns which of items are tracked in git in cfg's
+// repository. Failing to ask git is an error rather than "nothing tracked":
+// callers would otherwise overwrite or delete committed files.
+func trackedItems(cfg *Config, items []string) (map[string]bool, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	tracked, err := newVCS(cfg.Settings, cfg.RepoRoot).TrackedPaths(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ask git which files it tracks: %w", err)
+	}
+	return tracked, nil
+}
+
+// personalName is the working tree name a stored item is synced in under
+// when its strategy is "rename": CLAUDE.md becomes CLAUDE.personal.md.
+func personalName(item string) string {
+	return insertSuffix(item, ".personal")
+}
+
+// localName is the working tree name a stored item is synced in under when
+// its strategy is "import": CLAUDE.md becomes CLAUDE.local.md.
+func localName(item string) string {
+	return insertSuffix(item, ".local")
+}
+
+// insertSuffix adds suffix to item's name before its extension, if any.
+func insertSuffix(item, suffix string) string {
+	ext := filepath.Ext(item)
+	if ext == "" || ext == item {
+		return item + suffix
+	}
+	return strings.TrimSuffix(item, ext) + suffix + ext
+}
+
+// collisionStrategy returns the tracked_collision strategy for item: its
+// collision_strategy entry if it has one, otherwise tracked_collision.
+func (s Settings) collisionStrategy(item string) string {
+	if strategy, ok := s.CollisionStrategy[item]; ok && strategy != "" {
+		return strategy
+	}
+	if s.TrackedCollision == "" {
+		return "skip"
+	}
+	return s.TrackedCollision
+}
+
+// syncedInName returns the working tree name the stored copy of a tracked
+// item is synced in under, or "" if it isn't synced in as a file of its own.
+func (s Settings) syncedInName(item string) string {
+	switch s.collisionStrategy(item) {
+	case "rename":
+		return personalName(item)
+	case "import":
+		return localName(item)
+	}
+	return ""
+}
+
+// collisionTargets applies tracked_collision to stored items about to be
+// synced in. The result maps each item tracked in git to the working tree
+// name to sync it in as, or to "" to leave it out (merge strategies write
+// into the committed file instead; see mergeIn). A "refuse" collision is an
+// error.
+func collisionTargets(cfg *Config, items []string) (map[string]string, error) {
+	tracked, err := trackedItems(cfg, items)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracked) == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	for item := range tracked {
+		names = append(names, item)
+	}
+	sort.Strings(names)
+
+	var refused []string
+	targets := make(map[string]string)
+	for _, item := range names {
+		strategy := cfg.Settings.collisionStrategy(item)
+		if isMergeStrategy(strategy) && !mergeable(cfg, item) {
+			warnf("%s is tracked in git but only regular files can be merged; not syncing your stored copy in", item)
+			targets[item] = ""
+			continue
+		}
+
+		switch strategy {
+		case "refuse":
+			refused = append(refused, item)
+		case "rename", "import":
+			targets[item] = cfg.Settings.syncedInName(item)
+			warnf("%s is tracked in git; syncing your stored copy in as %s", item, targets[item])
+		case "append":
+			targets[item] = ""
+		default:
+			targets[item] = ""
+			warnf("%s is tracked in git; keeping the committed version and not syncing your stored copy in (see tracked_collision)", item)
+		}
+	}
+	if len(refused) > 0 {
+		return nil, fmt.Errorf("%s tracked in git and also in your personal store; remove it from storage or set tracked_collision to skip or rename",
+			strings.Join(refused, ", "))
+	}
+	return targets, nil
+}
@@ -0,0 +1,150 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// givenTrackedCollision returns a Config for a real git repository whose
+// store holds a personal README.md, which the repository also tracks.
+func givenTrackedCollision(t *testing.T, mode string) *Config {
+	t.Helper()
+	repoRoot, _ := givenGitRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.TrackedCollision = mode
+	writeFile(t, filepath.Join(cfg.StoreLocation, "README.md"), "personal readme")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "notes.md"), "notes")
+	return cfg
+}
+
+func TestSyncIn_TrackedCollisionSkipKeepsCommittedFile(t *testing.T) {
+	cfg := givenTrackedCollision(t, "")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.md"), "readme")
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "notes.md"), "notes")
+	excludes, err := readExcludeFile(cfg.RepoRoot, cfg.excludeFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range excludes {
+		if entry == "README.md" {
+			t.Error("tracked README.md was added to the exclude file")
+		}
+	}
+}
+
+func TestSyncIn_TrackedCollisionRenameUsesPersonalName(t *testing.T) {
+	cfg := givenTrackedCollision(t, "rename")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.md"), "readme")
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.personal.md"), "personal readme")
+	assertExcludeContains(t, cfg.RepoRoot, "README.personal.md")
+}
+
+func TestSyncIn_TrackedCollisionRefuseFails(t *testing.T) {
+	cfg := givenTrackedCollision(t, "refuse")
+
+	err := syncIn(cfg)
+	if err == nil || !strings.Contains(err.Error(), "README.md") {
+		t.Fatalf("syncIn error = %v, want a collision error naming README.md", err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.md"), "readme")
+}
+
+func TestSyncOut_NeverSavesOverStoredCopyOfTrackedFile(t *testing.T) {
+	cfg := givenTrackedCollision(t, "")
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "README.md"), "personal readme")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "notes.md"), "notes")
+}
+
+func TestSyncOut_RenamedCopySavedUnderStoredName(t *testing.T) {
+	cfg := givenTrackedCollision(t, "rename")
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	writeFile(t, filepath.Join(cfg.RepoRoot, "README.personal.md"), "edited readme")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "README.md"), "edited readme")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "README.personal.md"))
+}
+
+func TestRemoveSwitchedOutItems_KeepsCommittedFile(t *testing.T) {
+	prev := givenTrackedCollision(t, "rename")
+	if err := syncIn(prev); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	next := prev.forBranch("feature")
+	writeFile(t, filepath.Join(next.StoreLocation, "todo.md"), "feature todo")
+	if err := removeSwitchedOutItems(prev, next); err != nil {
+		t.Fatalf("removeSwitchedOutItems failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(prev.RepoRoot, "README.md"), "readme")
+	assertNotExists(t, filepath.Join(prev.RepoRoot, "README.personal.md"))
+	assertNotExists(t, filepath.Join(prev.RepoRoot, "notes.md"))
+}
+
+// corruptIndex makes git unable to say which files cfg's repository tracks.
+func corruptIndex(t *testing.T, cfg *Config) {
+	t.Helper()
+	writeFile(t, filepath.Join(cfg.RepoRoot, ".git", "index"), "not an index")
+}
+
+func TestSyncIn_FailsWhenGitCantListTrackedFiles(t *testing.T) {
+	cfg := givenTrackedCollision(t, "")
+	corruptIndex(t, cfg)
+
+	if err := syncIn(cfg); err == nil {
+		t.Fatal("syncIn succeeded without knowing which files are tracked")
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.md"), "readme")
+}
+
+func TestRemoveSwitchedOutItems_FailsWhenGitCantListTrackedFiles(t *testing.T) {
+	prev := givenTrackedCollision(t, "")
+	writeFile(t, filepath.Join(prev.RepoRoot, "notes.md"), "notes")
+	corruptIndex(t, prev)
+
+	next := prev.forBranch("feature")
+	if err := removeSwitchedOutItems(prev, next); err == nil {
+		t.Fatal("removeSwitchedOutItems succeeded without knowing which files are tracked")
+	}
+	assertFileContent(t, filepath.Join(prev.RepoRoot, "README.md"), "readme")
+	assertFileContent(t, filepath.Join(prev.RepoRoot, "notes.md"), "notes")
+}
+
+func TestPersonalName(t *testing.T) {
+	tests := map[string]string{
+		"CLAUDE.md":    "CLAUDE.personal.md",
+		".claude":      ".claude.personal",
+		"Makefile":     "Makefile.personal",
+		"notes.tar.gz": "notes.tar.personal.gz",
+	}
+	for item, want := range tests {
+		if got := personalName(item); got != want {
+			t.Errorf("personalName(%q) = %q, want %q", item, got, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package wrapper
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// command is a subcommand owned by the wrapper. Any invocation whose first
+// argument isn't a wrapper command is passed through to claude unchanged.
+type command struct {
+	// usage is the synopsis of the command's arguments, after its name
+	usage   string
+	summary string
+	run     func(flags wrapperFlags, args []string) (int, error)
+	// hidden leaves the command out of listings and generated docs, for
+	// commands only builds use
+	hidden bool
+}
+
+// commands maps subcommand names to their implementations. Names are chosen
+// not to collide with claude's own subcommands.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"init":          {usage: "[--all] [PATH...]", summary: "start managing files git already ignores, picked from a list", run: runInitCommand},
+		"sync":          {usage: "[--in | --out] [--if-branch-changed]", summary: "sync personal files in and/or out without running claude", run: runSyncCommand},
+		"hooks":         {usage: "install|uninstall", summary: "install or remove git hooks that keep personal files in sync", run: runHooksCommand},
+		"gc":            {usage: "--repos [--dry-run]", summary: "remove stores of repositories that no longer exist", run: runGCCommand},
+		"prune":         {usage: "[--dry-run] BRANCH...|--merged|--all-deleted", summary: "delete branch stores now instead of after the grace period", run: runPruneCommand},
+		"rebase-stores": {usage: "[--dry-run] [BRANCH...]", summary: "bring files branches never changed up to date with the default branch's store", run: runRebaseStoresCommand},
+		"tidy-exclude":  {usage: "[--dry-run]", summary: "remove exclude entries for personal files that no longer exist", run: runTidyExcludeCommand},
+		"repos":         {usage: "", summary: "list every repository store with its size and last sync", run: runReposCommand},
+		"status":        {usage: "", summary: "show the personal files managed for the current branch", run: runStatusCommand},
+		"list":          {usage: "[--diverged] [--diff] [--all | BRANCH...]", summary: "list the branch store's files and which still match the default branch's", run: runListCommand},
+		"why":           {usage: "PATH...", summary: "explain how the wrapper sees a path: managed, stored, synced, pending removal", run: runWhyCommand},
+		"undo":          {usage: "[--in | --out] [--dry-run]", summary: "reverse the last sync-in or sync-out", run: runUndoCommand},
+		"restore":       {usage: "[PATH...]", summary: "put stored copies back over the working tree, or keep items pending removal", run: runRestoreCommand},
+		"run":           {usage: "[--] COMMAND [ARGS...]", summary: "run a command with personal files synced in, then sync out", run: runRunCommand},
+		"session":       {usage: "new BRANCH [CLAUDE ARGS...]", summary: "run claude in a worktree of its own for BRANCH, removed afterwards", run: runSessionCommand},
+		"sessions":      {usage: "[--all]", summary: "list running wrapper sessions and the branches they use", run: runSessionsCommand},
+		"unlock":        {usage: "[--force] [BRANCH...]", summary: "remove entries of crashed sessions, or with --force of any, that block prune", run: runUnlockCommand},
+		"daemon":        {usage: "[--socket PATH] [--metrics ADDR]", summary: "serve status and sync requests over a unix socket", run: runDaemonCommand},
+		"api":           {usage: "--stdio", summary: "serve editor requests as JSON lines on stdin and stdout", run: runAPICommand},
+		"commands":      {usage: "", summary: "list the wrapper's own commands and options", run: runCommandsCommand},
+		"gen-docs":      {usage: "[--man DIR] [--completions DIR]", summary: "write the man page and shell completions", run: runGenDocsCommand, hidden: true},
+	}
+}
+
+// newFlagSet returns the flag set of the wrapper command name, whose -h
+// prints the command's usage.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() { printUsage(fs.Output(), name, fs) }
+	return fs
+}
+
+// printUsage writes the usage of the wrapper command name to w: its
+// synopsis and summary, then the options of fs (if given) and the options
+// every command takes.
+func printUsage(w io.Writer, name string, fs *flag.FlagSet) {
+	cmd := commands[name]
+	fmt.Fprintf(w, "usage: claude-wrapper %s\n\n%s\n", strings.TrimSpace(name+" "+cmd.usage), cmd.summary)
+	hasFlags := false
+	if fs != nil {
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	}
+	if hasFlags {
+		fmt.Fprintln(w, "\noptions:")
+		fs.PrintDefaults()
+	}
+	fmt.Fprintf(w, "\nglobal options:\n%s", wrapperFlagsUsage)
+}
+
+// usageError prints the usage of the wrapper command name to stderr, for
+// arguments it can't make sense of, and returns the usage exit code.
+func usageError(name string) (int, error) {
+	printUsage(os.Stderr, name, nil)
+	return exitUsage, nil
+}
+
+// runCommandsCommand implements `claude-wrapper commands`.
+func runCommandsCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("commands")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if err := printCommands(os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// commandNames returns the names of the wrapper commands that aren't
+// hidden, in order.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name, cmd := range commands {
+		if !cmd.hidden {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printCommands lists the wrapper commands, in name order, and the options
+// every command takes.
+func printCommands(w io.Writer) error {
+	names := commandNames()
+
+	fmt.Fprintln(w, "usage: claude-wrapper [global options] COMMAND [options] [args]")
+	fmt.Fprintln(w, "       claude-wrapper [global options] [claude arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].summary)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nglobal options:\n%s", wrapperFlagsUsage)
+	fmt.Fprintln(w, "\nAnything else is passed to claude. Run claude-wrapper COMMAND -h for a command's options.")
+	return nil
+}
+
+// lookupCommand returns the wrapper command named by the first argument.
+func lookupCommand(args []string) (command, bool) {
+	if len(args) == 0 {
+		return command{}, false
+	}
+	cmd, ok := commands[args[0]]
+	return cmd, ok
+}
+
+// openRepo loads settings and repository configuration for a subcommand.
+// Unlike a claude invocation, subcommands fail outside a git repository.
+func openRepo(flags wrapperFlags) (*Config, error) {
+	settings, err := LoadSettings()
+	if err != nil {
+		return nil, withExitCode(exitConfig, fmt.Errorf("failed to load settings: %w", err))
+	}
+	flags.apply(&settings)
+	gitRepo = newVCS(settings, "")
+	return openConfig(gitRepo, settings)
+}
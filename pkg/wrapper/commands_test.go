@@ -0,0 +1,77 @@
+package wrapper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrintCommands_ListsEveryCommand(t *testing.T) {
+	var out bytes.Buffer
+	if err := printCommands(&out); err != nil {
+		t.Fatal(err)
+	}
+	for name, cmd := range commands {
+		if cmd.summary == "" {
+			t.Errorf("command %s has no summary", name)
+		}
+		if cmd.hidden {
+			if strings.Contains(out.String(), "  "+name+" ") {
+				t.Errorf("hidden command %s listed in:\n%s", name, out.String())
+			}
+			continue
+		}
+		if !strings.Contains(out.String(), "  "+name+" ") {
+			t.Errorf("command %s not listed in:\n%s", name, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "--profile NAME") {
+		t.Errorf("global options not listed in:\n%s", out.String())
+	}
+}
+
+func TestNewFlagSet_PrintsTheCommandsUsage(t *testing.T) {
+	fs := newFlagSet("list")
+	var out bytes.Buffer
+	fs.SetOutput(&out)
+	fs.Bool("all", false, "list every branch store")
+	if err := fs.Parse([]string{"-h"}); err == nil {
+		t.Fatal("expected -h to stop parsing")
+	}
+	for _, want := range []string{
+		"usage: claude-wrapper list [--diverged] [--diff] [--all | BRANCH...]",
+		commands["list"].summary,
+		"-all",
+		"global options:",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("usage lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// claudeNames are claude's own subcommands and flags, which the wrapper
+// must leave to it.
+var claudeNames = []string{
+	"config", "mcp", "migrate-installer", "setup-token", "doctor", "update", "install", "plugin",
+	"-p", "--print", "-c", "--continue", "-r", "--resume", "-d", "--debug", "--verbose",
+	"--model", "--fallback-model", "--settings", "--setting-sources", "--add-dir", "--agents",
+	"--output-format", "--input-format", "--allowedTools", "--disallowedTools",
+	"--permission-mode", "--dangerously-skip-permissions", "--mcp-config", "--strict-mcp-config",
+	"--append-system-prompt", "--session-id", "--ide", "--plugin-dir", "-v", "--version", "-h", "--help",
+}
+
+func TestWrapperNames_DontCollideWithClaudes(t *testing.T) {
+	t.Setenv("CLAUDE_WRAPPER_TRACE_GIT", "")
+	t.Setenv(profileEnv, "")
+	for _, name := range claudeNames {
+		if _, ok := lookupCommand([]string{name}); ok {
+			t.Errorf("wrapper command %s shadows claude's", name)
+		}
+		args := []string{name, "value"}
+		if flags, rest := parseWrapperFlags(args); !reflect.DeepEqual(rest, args) || !reflect.DeepEqual(flags, wrapperFlags{}) {
+			t.Errorf("parseWrapperFlags(%q) = %+v, %q; want it left to claude", args, flags, rest)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package wrapper
+
+import (
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyFileData copies src's contents to dst, an empty file, feeding them to
+// sum unless it is nil. On filesystems that support it (btrfs, XFS) dst
+// becomes a reflink sharing src's blocks, which costs next to nothing.
+// Otherwise holes in a sparse src are skipped rather than written out as
+// zeros, so dst stays as sparse, and other files are copied by the kernel
+// where it can (copy_file_range) or through a buffer where it can't.
+func copyFileData(dst, src *os.File, sum hash.Hash) (int64, error) {
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())) == nil {
+		return info.Size(), hashFile(src, sum)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Blocks*512 >= info.Size() {
+		// Not sparse. Without a hash to compute the kernel copies; with
+		// one, reading src once beats reading it twice.
+		return copyData(dst, src, sum)
+	}
+
+	size := info.Size()
+	var offset int64
+	for offset < size {
+		data, err := src.Seek(offset, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			data = size // Only a hole is left
+		} else if err != nil {
+			if offset == 0 && errors.Is(err, unix.EINVAL) {
+				// The filesystem can't report holes
+				if _, err := src.Seek(0, io.SeekStart); err != nil {
+					return 0, err
+				}
+				return copyData(dst, src, sum)
+			}
+			return 0, err
+		}
+		if err := hashZeros(sum, data-offset); err != nil {
+			return 0, err
+		}
+		if data == size {
+			break
+		}
+
+		hole, err := src.Seek(data, unix.SEEK_HOLE)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := src.Seek(data, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := dst.Seek(data, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := copyData(dst, io.LimitReader(src, hole-data), sum); err != nil {
+			return 0, err
+		}
+		offset = hole
+	}
+	// Extends dst over a trailing hole
+	return size, dst.Truncate(size)
+}
+
+// hashFile feeds all of f to sum unless it is nil.
+func hashFile(f *os.File, sum hash.Hash) error {
+	if sum == nil {
+		return nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := copyData(io.Discard, f, sum)
+	return err
+}
+
+// hashZeros feeds n zero bytes, a hole's contents, to sum unless it is nil.
+func hashZeros(sum hash.Hash, n int64) error {
+	if sum == nil || n <= 0 {
+		return nil
+	}
+	_, err := copyData(io.Discard, io.LimitReader(zeroReader{}, n), sum)
+	return err
+}
+
+// zeroReader reads endless zeros.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
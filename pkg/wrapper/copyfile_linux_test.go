@@ -0,0 +1,112 @@
+package wrapper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// allocated returns how many bytes of disk the file at path uses.
+func allocated(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Sys().(*syscall.Stat_t).Blocks * 512
+}
+
+func TestCopyFile_PreservesHoles(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "cache.db"), filepath.Join(dir, "stored.db")
+	const size = 64 << 20
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("in the middle"), size/2); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if allocated(t, src) >= size {
+		t.Skip("the temporary directory's filesystem doesn't support sparse files")
+	}
+
+	c := &copier{verify: true, saved: make(map[string]manifestEntry)}
+	if err := c.copyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != size || string(data[size/2:size/2+13]) != "in the middle" {
+		t.Fatalf("copy differs from the original (%d bytes)", len(data))
+	}
+	if got := allocated(t, dst); got > 1<<20 {
+		t.Errorf("expected the copy to stay sparse, but it uses %d bytes", got)
+	}
+	want := sha256.Sum256(data)
+	if got := c.saved[dst].SHA256; got != hex.EncodeToString(want[:]) {
+		t.Errorf("hash %s doesn't cover the holes' zeros", got)
+	}
+}
+
+func TestCopyFile_TrailingHole(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "cache.db"), filepath.Join(dir, "stored.db")
+	writeFile(t, src, "head")
+	if err := os.Truncate(src, 8<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(dst)
+	if info.Size() != 8<<20 || string(data[:4]) != "head" {
+		t.Errorf("unexpected copy: %d bytes starting %q", info.Size(), data[:4])
+	}
+}
+
+func TestCopyFileData_WithAndWithoutHash(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("personal notes\n", 100000)
+	writeFile(t, filepath.Join(dir, "src"), content)
+	want := sha256.Sum256([]byte(content))
+
+	for _, sum := range []hash.Hash{nil, sha256.New()} {
+		src, err := os.Open(filepath.Join(dir, "src"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, err := os.CreateTemp(dir, "dst")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := copyFileData(dst, src, sum)
+		src.Close()
+		dst.Close()
+		if err != nil || n != int64(len(content)) {
+			t.Fatalf("copyFileData = %d, %v", n, err)
+		}
+		assertFileContent(t, dst.Name(), content)
+		if sum != nil && !bytes.Equal(sum.Sum(nil), want[:]) {
+			t.Error("hash doesn't match the contents")
+		}
+	}
+}
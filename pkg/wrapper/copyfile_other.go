@@ -0,0 +1,15 @@
+//go:build !linux
+
+package wrapper
+
+import (
+	"hash"
+	"os"
+)
+
+// copyFileData copies src's contents to dst, an empty file. Holes in
+// sparse files are only preserved on Linux; elsewhere they are copied as
+// zeros.
+func copyFileData(dst, src *os.File, sum hash.Hash) (int64, error) {
+	return copyData(dst, src, sum)
+}
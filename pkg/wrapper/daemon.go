@@ -0,0 +1,358 @@
+package wrapper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// statusTTL is how long the daemon answers status requests from its cache
+// before scanning the store again. A sync or branch change invalidates the
+// cache immediately.
+const statusTTL = 2 * time.Second
+
+// daemonSocketPath returns where the daemon listens. CLAUDE_WRAPPER_SOCKET
+// overrides the default of $XDG_RUNTIME_DIR/claude-wrapper.sock, falling
+// back to a per-user socket in the temporary directory.
+func daemonSocketPath() string {
+	if path := os.Getenv("CLAUDE_WRAPPER_SOCKET"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "claude-wrapper.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("claude-wrapper-%d.sock", os.Getuid()))
+}
+
+// daemonRequest is one line sent to the daemon. Repo is any directory
+// inside the repository; list ignores it. Path names a file or directory
+// relative to the repository root for diff and restore.
+type daemonRequest struct {
+	Cmd  string `json:"cmd"`
+	Repo string `json:"repo,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// daemonResponse is the line written back for each request.
+type daemonResponse struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Result any    `json:"result,omitempty"`
+}
+
+// daemon answers requests about repositories, keeping their configuration
+// and status warm between requests.
+type daemon struct {
+	settings Settings
+	// defaultRepo is used for requests that don't name a repository
+	defaultRepo string
+	// notify surfaces failed syncs as notifications, when no client is
+	// expected to show the error to anyone
+	notify  bool
+	metrics *daemonMetrics
+
+	mu    sync.Mutex
+	repos map[string]*repoState // Keyed by the requested directory
+}
+
+// repoState is the daemon's cached view of one repository. Its mutex
+// serializes operations on the repository.
+type repoState struct {
+	mu       sync.Mutex
+	cfg      *Config
+	head     time.Time // Modification time of HEAD when cfg was loaded
+	status   *Status
+	statusAt time.Time
+}
+
+func newDaemon(settings Settings) *daemon {
+	return &daemon{settings: settings, metrics: newDaemonMetrics(), repos: make(map[string]*repoState)}
+}
+
+// runDaemonCommand implements
+// `claude-wrapper daemon [--socket path] [--metrics addr]`.
+func runDaemonCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("daemon")
+	socket := fs.String("socket", daemonSocketPath(), "unix socket to listen on")
+	metricsAddr := fs.String("metrics", "", "serve Prometheus metrics over HTTP at `addr`, e.g. 127.0.0.1:9464")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+
+	settings, err := LoadSettings()
+	if err != nil {
+		return 1, withExitCode(exitConfig, fmt.Errorf("failed to load settings: %w", err))
+	}
+	flags.apply(&settings)
+	// Syncs run in the background, so there is nobody to show progress to
+	settings.Quiet = true
+
+	l, err := listenUnix(*socket)
+	if err != nil {
+		return 1, err
+	}
+	defer os.Remove(*socket)
+
+	d := newDaemon(settings)
+	d.notify = true
+
+	var metricsServer *http.Server
+	if *metricsAddr != "" {
+		ml, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			l.Close()
+			return 1, fmt.Errorf("failed to serve metrics: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", d.metrics)
+		metricsServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := metricsServer.Serve(ml); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				warnf("metrics server stopped: %v", err)
+			}
+		}()
+		infof("serving metrics on http://%s/metrics", ml.Addr())
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		l.Close()
+		if metricsServer != nil {
+			metricsServer.Close()
+		}
+	}()
+
+	infof("listening on %s", *socket)
+	if err := d.serve(l); err != nil && !errors.Is(err, net.ErrClosed) {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// listenUnix listens on the unix socket at path, replacing a stale socket
+// left by a daemon that didn't shut down cleanly.
+func listenUnix(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// The socket can sync anyone's files, so only its owner may connect
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// serve answers connections on l until it is closed.
+func (d *daemon) serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			d.serveStream(conn, conn)
+		}()
+	}
+}
+
+// serveStream answers newline-delimited JSON requests read from r, writing
+// one response line per request to w, until r is exhausted.
+func (d *daemon) serveStream(r io.Reader, w io.Writer) {
+	scanner := bufio.NewScanner(r)
+	encoder := json.NewEncoder(w)
+	for scanner.Scan() {
+		var req daemonRequest
+		var resp daemonResponse
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp = daemonResponse{Error: fmt.Sprintf("invalid request: %v", err)}
+		} else {
+			resp = d.handle(req)
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// handle answers a single request.
+func (d *daemon) handle(req daemonRequest) daemonResponse {
+	if req.Repo == "" {
+		req.Repo = d.defaultRepo
+	}
+
+	var result any
+	var err error
+	cmd := req.Cmd
+	switch req.Cmd {
+	case "list":
+		result = d.list()
+	case "status":
+		result, err = d.status(req.Repo)
+	case "sync":
+		result, err = d.sync(req.Repo, true, true)
+	case "sync-in":
+		result, err = d.sync(req.Repo, true, false)
+	case "sync-out":
+		result, err = d.sync(req.Repo, false, true)
+	case "diff":
+		result, err = d.diff(req.Repo, req.Path)
+	case "restore":
+		result, err = d.restore(req.Repo, req.Path)
+	default:
+		// Counted under one label, so clients can't grow the metrics
+		cmd = "unknown"
+		err = fmt.Errorf("unknown command %q (want status, sync, sync-in, sync-out, diff, restore or list)", req.Cmd)
+	}
+	d.metrics.recordRequest(cmd, err != nil)
+	if err != nil {
+		return daemonResponse{Error: err.Error()}
+	}
+	return daemonResponse{OK: true, Result: result}
+}
+
+// repo returns the locked state for the repository containing dir, loading
+// its configuration again if HEAD has changed since it was cached. The
+// caller must unlock it.
+func (d *daemon) repo(dir string) (*repoState, error) {
+	if dir == "" || !filepath.IsAbs(dir) {
+		return nil, fmt.Errorf("repo must be an absolute path")
+	}
+
+	d.mu.Lock()
+	state, ok := d.repos[dir]
+	if !ok {
+		state = &repoState{}
+		d.repos[dir] = state
+	}
+	d.mu.Unlock()
+
+	if !state.mu.TryLock() {
+		// Another request is working on this repository
+		d.metrics.recordLockWait()
+		state.mu.Lock()
+	}
+	if state.cfg != nil && !state.head.IsZero() && headModTime(state.cfg.RepoRoot).Equal(state.head) {
+		return state, nil
+	}
+
+	cfg, err := LoadConfig(dir, d.settings)
+	if err != nil {
+		state.mu.Unlock()
+		return nil, err
+	}
+	state.cfg = cfg
+	state.head = headModTime(cfg.RepoRoot)
+	state.status = nil
+	return state, nil
+}
+
+// status returns the repository's status, from the cache when it is fresh.
+func (d *daemon) status(dir string) (*Status, error) {
+	state, err := d.repo(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer state.mu.Unlock()
+
+	if state.status != nil && time.Since(state.statusAt) < statusTTL {
+		return state.status, nil
+	}
+	status, err := collectStatus(state.cfg)
+	if err != nil {
+		return nil, err
+	}
+	state.status, state.statusAt = status, time.Now()
+	return status, nil
+}
+
+// sync syncs the repository out and/or in, like `claude-wrapper sync`, and
+// returns the run's report.
+func (d *daemon) sync(dir string, in, out bool) (*SyncReport, error) {
+	state, err := d.repo(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer state.mu.Unlock()
+	state.status = nil
+
+	report, err := NewSyncer(state.cfg).sync(in, out)
+	d.metrics.recordSync(report, err)
+	if err != nil && d.notify {
+		notifySyncFailure(d.settings, state.cfg.RepoRoot, err)
+	}
+	return report, err
+}
+
+// repoSummary is one repository in the answer to a list request.
+type repoSummary struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Store  string `json:"store"`
+	// LastUsed is when the branch store was last synced, from its metadata.
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+// list returns the repositories the daemon has cached state for.
+func (d *daemon) list() []repoSummary {
+	d.mu.Lock()
+	states := make([]*repoState, 0, len(d.repos))
+	for _, state := range d.repos {
+		states = append(states, state)
+	}
+	d.mu.Unlock()
+
+	seen := make(map[string]bool)
+	repos := []repoSummary{}
+	for _, state := range states {
+		state.mu.Lock()
+		if cfg := state.cfg; cfg != nil && !seen[cfg.RepoRoot] {
+			seen[cfg.RepoRoot] = true
+			summary := repoSummary{Repo: cfg.RepoRoot, Branch: cfg.CurrentBranch, Store: cfg.StoreLocation}
+			if meta, ok := readBranchMeta(cfg.StoreLocation); ok && !meta.lastUsed().IsZero() {
+				lastUsed := meta.lastUsed()
+				summary.LastUsed = &lastUsed
+			}
+			repos = append(repos, summary)
+		}
+		state.mu.Unlock()
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Repo < repos[j].Repo })
+	return repos
+}
+
+// headModTime returns when the repository's HEAD last changed, which git
+// rewrites on every checkout. The zero time means it couldn't be read.
+func headModTime(repoRoot string) time.Time {
+	gitDir, err := resolveGitDir(repoRoot)
+	if err != nil {
+		return time.Time{}
+	}
+	info, err := os.Stat(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,108 @@
+package wrapper
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// givenDaemon serves a daemon on a socket in a temporary directory and
+// returns a connection to it.
+func givenDaemon(t *testing.T) net.Conn {
+	t.Helper()
+	l, err := listenUnix(filepath.Join(t.TempDir(), "d.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go newDaemon(Settings{}).serve(l)
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestDaemon_SyncsOverSocket(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	writeFile(t, filepath.Join(home, ".workspaces", filepath.Base(dir), "CLAUDE.md"), "stored")
+	conn := givenDaemon(t)
+
+	reader := bufio.NewReader(conn)
+	for _, cmd := range []string{"sync", "status"} {
+		req, _ := json.Marshal(daemonRequest{Cmd: cmd, Repo: dir})
+		if _, err := conn.Write(append(req, '\n')); err != nil {
+			t.Fatal(err)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, `{"ok":true`) {
+			t.Fatalf("%s response = %s, want ok", cmd, line)
+		}
+		if cmd == "status" && !strings.Contains(line, `"name":"CLAUDE.md"`) {
+			t.Errorf("status response = %s, want CLAUDE.md listed", line)
+		}
+	}
+
+	assertFileContent(t, filepath.Join(dir, "CLAUDE.md"), "stored")
+}
+
+func TestDaemon_ReloadsConfigAfterCheckout(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	inRepo(t, dir)
+	d := newDaemon(Settings{})
+
+	status, err := d.status(dir)
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if cached, _ := d.status(dir); cached != status {
+		t.Error("expected a fresh status to be served from the cache")
+	}
+
+	createBranch(t, repo, "feature", true)
+	status, err = d.status(dir)
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if status.Branch != "feature" {
+		t.Errorf("branch after checkout = %q, want feature", status.Branch)
+	}
+	if repos := d.list(); len(repos) != 1 || repos[0].Branch != "feature" {
+		t.Errorf("list = %+v, want the repository on feature", repos)
+	}
+}
+
+func TestDaemon_RejectsBadRequests(t *testing.T) {
+	d := newDaemon(Settings{})
+	for _, req := range []daemonRequest{
+		{Cmd: "explode"},
+		{Cmd: "status"},
+		{Cmd: "status", Repo: "relative/path"},
+	} {
+		if resp := d.handle(req); resp.OK || resp.Error == "" {
+			t.Errorf("handle(%+v) = %+v, want an error", req, resp)
+		}
+	}
+}
+
+func TestListenUnix_RefusesWhenDaemonRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d.sock")
+	l, err := listenUnix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if _, err := listenUnix(path); err == nil {
+		t.Error("expected an error while another daemon listens")
+	}
+}
@@ -0,0 +1,122 @@
+package wrapper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is how many unchanged lines surround each change in a
+// unified diff.
+const diffContext = 3
+
+// diffOp is one line of an edit script: kept (' '), removed ('-') or
+// added ('+').
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the differences between from and to as a unified
+// diff with fromName and toName in its header, or "" if they are the same.
+// Binary files, and files too big to match up, are only said to differ.
+func unifiedDiff(fromName, toName string, from, to []byte) string {
+	if string(from) == string(to) {
+		return ""
+	}
+	if !isText(from) || !isText(to) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", fromName, toName)
+	}
+	a, b := splitLines(from), splitLines(to)
+	match, ok := lcsMatches(a, b)
+	if !ok {
+		return fmt.Sprintf("Files %s and %s differ (too big to compare line by line)\n", fromName, toName)
+	}
+
+	var ops []diffOp
+	j := 0
+	for i, line := range a {
+		if match[i] < 0 {
+			ops = append(ops, diffOp{'-', line})
+			continue
+		}
+		for ; j < match[i]; j++ {
+			ops = append(ops, diffOp{'+', b[j]})
+		}
+		ops = append(ops, diffOp{' ', line})
+		j++
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	// aLine and bLine count the lines of from and to before ops[k]
+	aLine, bLine := 0, 0
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			aLine, bLine = aLine+1, bLine+1
+			k++
+			continue
+		}
+		// A hunk runs from the context before this change to the context
+		// after the last change less than two contexts away
+		start := max(k-diffContext, 0)
+		for s := start; s < k; s++ {
+			aLine, bLine = aLine-1, bLine-1
+		}
+		end := k
+		for unchanged := 0; end < len(ops) && unchanged <= 2*diffContext; end++ {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for end > k && ops[end-1].kind == ' ' && trailingContext(ops[:end], k) > diffContext {
+			end--
+		}
+		aLen, bLen := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLine, aLen), hunkRange(bLine, bLen))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		aLine, bLine = aLine+aLen, bLine+bLen
+		k = end
+	}
+	return out.String()
+}
+
+// trailingContext counts the unchanged lines at the end of ops after the
+// change at k.
+func trailingContext(ops []diffOp, k int) int {
+	n := 0
+	for i := len(ops) - 1; i > k && ops[i].kind == ' '; i-- {
+		n++
+	}
+	return n
+}
+
+// hunkRange formats the lines of a hunk that start after line before and
+// run for n lines, as unified diff headers do.
+func hunkRange(before, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, n)
+}
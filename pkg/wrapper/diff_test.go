@@ -0,0 +1,24 @@
+package wrapper
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	to := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+
+	want := "--- a\n+++ b\n" +
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+		"@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n\\ No newline at end of file\n"
+	if got := unifiedDiff("a", "b", []byte(from), []byte(to)); got != want {
+		t.Errorf("unifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+	if got := unifiedDiff("a", "b", []byte(from), []byte(from)); got != "" {
+		t.Errorf("unifiedDiff of equal files = %q, want nothing", got)
+	}
+	if got := unifiedDiff("a", "b", nil, []byte("new\n")); got != "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n" {
+		t.Errorf("unifiedDiff from nothing = %q", got)
+	}
+	if got := unifiedDiff("a", "b", []byte("x\x00"), []byte("y")); got != "Binary files a and b differ\n" {
+		t.Errorf("unifiedDiff of binary files = %q", got)
+	}
+}
@@ -0,0 +1,152 @@
+package wrapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// confirmMirrorFunc decides whether sync-in may remove the files of a
+// managed directory that its stored copy doesn't have. Replaced in tests.
+var confirmMirrorFunc = confirmMirror
+
+// confirmMirror asks before removing extras when attached to a terminal.
+// assume_yes/--yes removes them; without a terminal they are kept.
+func confirmMirror(cfg *Config, item string, extras []string) bool {
+	if cfg.Settings.AssumeYes {
+		return true
+	}
+	if !isInteractive() {
+		return false
+	}
+	return promptMirror(os.Stdin, os.Stderr, cfg.CurrentBranch, item, extras)
+}
+
+// promptMirror lists extras, the files of the working tree's item that
+// branch's stored copy doesn't have, and asks whether to remove them.
+// Anything but an explicit yes keeps them.
+func promptMirror(in io.Reader, out io.Writer, branch, item string, extras []string) bool {
+	fmt.Fprintf(out, "claude-wrapper: %s in the working tree has files branch %q's stored copy doesn't:\n", item, branch)
+	for i, extra := range extras {
+		if i == maxListedFiles {
+			fmt.Fprintf(out, "  ... and %d more\n", len(extras)-maxListedFiles)
+			break
+		}
+		fmt.Fprintf(out, "  %s\n", filepath.Join(item, extra))
+	}
+	fmt.Fprint(out, "Remove them? [y/N] ")
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	fmt.Fprintln(out, "Keeping them; sync-out will save them to the branch.")
+	return false
+}
+
+// validDirectorySync reports whether mode is a directory_sync mode; empty
+// means the default.
+func validDirectorySync(mode string) bool {
+	switch mode {
+	case "", "merge", "mirror", "prompt":
+		return true
+	}
+	return false
+}
+
+// directorySync returns the directory_sync mode for item: its
+// directory_sync_items entry if it has one, otherwise directory_sync.
+func (s Settings) directorySync(item string) string {
+	if mode, ok := s.DirectorySyncItems[item]; ok && mode != "" {
+		return mode
+	}
+	if s.DirectorySync == "" {
+		return "merge"
+	}
+	return s.DirectorySync
+}
+
+// reconcileDir applies item's directory_sync mode to item, a directory
+// stored at src and about to be synced in over dst in the working tree.
+// With "mirror", or "prompt" and a yes, files and directories in dst that
+// src lacks are removed, so the working tree ends up with exactly the
+// branch's copy.
+func reconcileDir(cfg *Config, item, src, dst string) error {
+	mode := cfg.Settings.directorySync(item)
+	if mode == "merge" {
+		return nil
+	}
+	extras, err := dirExtras(cfg, src, dst)
+	if err != nil || len(extras) == 0 {
+		return err
+	}
+	if mode == "prompt" && !confirmMirrorFunc(cfg, item, extras) {
+		return nil
+	}
+
+	for _, extra := range extras {
+		err := cfg.journal.removeAll(cfg.StoreBase, auditEntry{
+			Path:   filepath.Join(dst, extra),
+			Reason: fmt.Sprintf("not in branch %s's stored copy of %s (directory_sync %s)", cfg.CurrentBranch, item, mode),
+			Repo:   cfg.RepoRoot,
+			Branch: cfg.CurrentBranch,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove %s: %w", filepath.Join(item, extra), err)
+		}
+	}
+	return nil
+}
+
+// dirExtras returns the paths, relative to dst, of what the working tree
+// directory dst has and the stored directory src doesn't. A directory src
+// lacks is listed once rather than file by file. Paths sync-out never
+// saves to the branch (never_manage, machine-scoped paths) and files
+// rendered from stored templates aren't extras.
+func dirExtras(cfg *Config, src, dst string) ([]string, error) {
+	srcInfo, err := os.Lstat(src)
+	if err != nil || !srcInfo.IsDir() {
+		return nil, err
+	}
+	if dstInfo, err := os.Lstat(dst); err != nil || !dstInfo.IsDir() {
+		return nil, nil
+	}
+
+	var extras []string
+	err = filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dst {
+			return err
+		}
+		rel, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+		repoRel, err := filepath.Rel(cfg.RepoRoot, path)
+		if err != nil {
+			return err
+		}
+		if cfg.Settings.neverManaged(repoRel) || cfg.Settings.machineScoped(repoRel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, err := os.Lstat(filepath.Join(src, rel)); err == nil {
+			return nil
+		}
+		if _, err := os.Lstat(filepath.Join(src, rel) + templateSuffix); err == nil {
+			return nil
+		}
+		extras = append(extras, rel)
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return extras, err
+}
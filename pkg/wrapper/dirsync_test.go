@@ -0,0 +1,106 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// givenDivergedDir stores .claude with one file and puts another, extra
+// one next to it in the working tree.
+func givenDivergedDir(t *testing.T, mode string) *Config {
+	t.Helper()
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.DirectorySync = mode
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "stored")
+	writeFile(t, filepath.Join(repoRoot, ".claude", "settings.json"), "edited")
+	writeFile(t, filepath.Join(repoRoot, ".claude", "commands", "other-branch.md"), "extra")
+	return cfg
+}
+
+func TestSyncIn_DirectorySyncMerge(t *testing.T) {
+	cfg := givenDivergedDir(t, "")
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".claude", "settings.json"), "stored")
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".claude", "commands", "other-branch.md"), "extra")
+}
+
+func TestSyncIn_DirectorySyncMirror(t *testing.T) {
+	cfg := givenDivergedDir(t, "mirror")
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".claude", "settings.json"), "stored")
+	assertNotExists(t, filepath.Join(cfg.RepoRoot, ".claude", "commands"))
+
+	entries := readAuditLog(t, cfg.StoreBase)
+	if len(entries) != 1 || entries[0].Path != filepath.Join(cfg.RepoRoot, ".claude", "commands") {
+		t.Errorf("expected the removal audited, got %+v", entries)
+	}
+}
+
+func TestSyncIn_DirectorySyncPrompt(t *testing.T) {
+	for _, answer := range []bool{false, true} {
+		cfg := givenDivergedDir(t, "prompt")
+		orig := confirmMirrorFunc
+		var asked []string
+		confirmMirrorFunc = func(_ *Config, item string, extras []string) bool {
+			asked = append(asked, item)
+			if !reflect.DeepEqual(extras, []string{"commands"}) {
+				t.Errorf("extras = %v, want [commands]", extras)
+			}
+			return answer
+		}
+		t.Cleanup(func() { confirmMirrorFunc = orig })
+
+		if err := syncIn(cfg); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(asked, []string{".claude"}) {
+			t.Errorf("asked about %v, want [.claude]", asked)
+		}
+		extra := filepath.Join(cfg.RepoRoot, ".claude", "commands", "other-branch.md")
+		if answer {
+			assertNotExists(t, extra)
+		} else {
+			assertExists(t, extra)
+		}
+	}
+}
+
+func TestDirExtras_LeavesUnsavedPathsAlone(t *testing.T) {
+	cfg := givenDivergedDir(t, "mirror")
+	cfg.Settings.NeverManage = []string{"node_modules/"}
+	cfg.Settings.MachineScoped = []string{".claude/cache/"}
+	writeFile(t, filepath.Join(cfg.RepoRoot, ".claude", "node_modules", "x.js"), "dep")
+	writeFile(t, filepath.Join(cfg.RepoRoot, ".claude", "cache", "state"), "local")
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "mcp.json"+templateSuffix), "{}")
+	writeFile(t, filepath.Join(cfg.RepoRoot, ".claude", "mcp.json"), "{}")
+
+	extras, err := dirExtras(cfg, filepath.Join(cfg.StoreLocation, ".claude"), filepath.Join(cfg.RepoRoot, ".claude"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(extras, []string{"commands"}) {
+		t.Errorf("extras = %v, want [commands]", extras)
+	}
+}
+
+func TestPromptMirror(t *testing.T) {
+	for answer, want := range map[string]bool{"y\n": true, "yes\n": true, "n\n": false, "": false} {
+		var out bytes.Buffer
+		if got := promptMirror(strings.NewReader(answer), &out, "feature/x", ".claude", []string{"commands"}); got != want {
+			t.Errorf("answer %q: got %v, want %v", answer, got, want)
+		}
+		for _, text := range []string{`branch "feature/x"`, filepath.Join(".claude", "commands"), "[y/N]"} {
+			if !strings.Contains(out.String(), text) {
+				t.Errorf("expected prompt to contain %q, got:\n%s", text, out.String())
+			}
+		}
+	}
+}
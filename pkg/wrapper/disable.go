@@ -0,0 +1,48 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// disableFile in a repository's root turns the wrapper off for that
+// repository, e.g. where teammates commit their own CLAUDE.md.
+const disableFile = ".claude-wrapper-disable"
+
+// ErrRepoDisabled is returned by LoadConfig for repositories the wrapper is
+// turned off in.
+var ErrRepoDisabled = errors.New("claude-wrapper is disabled for this repository")
+
+// repoDisabled reports whether the wrapper is turned off for the repository
+// at repoRoot, and why.
+func repoDisabled(repoRoot string, s Settings) (string, bool) {
+	if _, err := os.Stat(filepath.Join(repoRoot, disableFile)); err == nil {
+		return disableFile + " is present", true
+	}
+	if matchRepo(s.DisabledRepos, repoRoot) {
+		return "listed in disabled_repos", true
+	}
+	if len(s.EnabledRepos) > 0 && !matchRepo(s.EnabledRepos, repoRoot) {
+		return "not listed in enabled_repos", true
+	}
+	return "", false
+}
+
+// matchRepo reports whether repoRoot matches any of patterns. A pattern with
+// a slash is matched against the full path (~ is expanded); one without is
+// matched against the repository's directory name.
+func matchRepo(patterns []string, repoRoot string) bool {
+	for _, pattern := range patterns {
+		target := filepath.Base(repoRoot)
+		if strings.Contains(pattern, "/") {
+			pattern = filepath.Clean(expandHome(pattern))
+			target = repoRoot
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package wrapper
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepoDisabled(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	repo := filepath.Join(home, "work", "team-app")
+
+	tests := []struct {
+		name     string
+		settings Settings
+		want     bool
+	}{
+		{"enabled by default", Settings{}, false},
+		{"disabled by name", Settings{DisabledRepos: []string{"team-*"}}, true},
+		{"disabled by path", Settings{DisabledRepos: []string{"~/work/*"}}, true},
+		{"other path", Settings{DisabledRepos: []string{"~/oss/*"}}, false},
+		{"allowlisted", Settings{EnabledRepos: []string{"team-app"}}, false},
+		{"not allowlisted", Settings{EnabledRepos: []string{"~/personal/*"}}, true},
+		{"disable wins over allowlist", Settings{EnabledRepos: []string{"*"}, DisabledRepos: []string{"team-app"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := repoDisabled(repo, tt.settings); got != tt.want {
+				t.Errorf("repoDisabled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepoDisabled_DisableFile(t *testing.T) {
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, disableFile), "")
+
+	reason, disabled := repoDisabled(repo, Settings{})
+	if !disabled || reason != disableFile+" is present" {
+		t.Errorf("expected disable file to turn the wrapper off, got %v, %q", disabled, reason)
+	}
+}
+
+func TestSyncCommand_NoOpInDisabledRepo(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	writeFile(t, filepath.Join(dir, disableFile), "")
+
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "personal")
+
+	code, err := runSyncCommand(wrapperFlags{}, nil)
+	if err != nil || code != 0 {
+		t.Fatalf("expected a silent no-op, got %d, %v", code, err)
+	}
+	assertNotExists(t, filepath.Join(dir, "CLAUDE.md"))
+
+	if _, err := openRepo(wrapperFlags{}); !errors.Is(err, ErrRepoDisabled) {
+		t.Errorf("expected openRepo to report the repo as disabled, got %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package wrapper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// readLines returns the lines of the file at path, or nil if it doesn't exist.
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// writeLines replaces the file at path with lines, via a temporary file so a
+// crash never leaves it half written.
+func writeLines(path string, lines []string) error {
+	var content string
+	if len(lines) > 0 {
+		content = strings.Join(lines, "\n") + "\n"
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// errUnexcludable is returned for names the exclude file can't list: git
+// reads it line by line, so a name with a line break can't be matched
+// without also matching others.
+var errUnexcludable = errors.New("name contains a line break, which git's exclude file can't list")
+
+// excludeEntry returns the exclude file line matching exactly the item
+// named item. Characters git treats specially (wildcards, backslashes, a
+// leading # or ! and trailing spaces, which git would otherwise trim) are
+// escaped with a backslash.
+func excludeEntry(item string) (string, error) {
+	if strings.ContainsAny(item, "\n\r") {
+		return "", errUnexcludable
+	}
+	trailing := len(item) - len(strings.TrimRight(item, " "))
+	var b strings.Builder
+	for i := 0; i < len(item); i++ {
+		c := item[i]
+		switch {
+		case strings.IndexByte(`\*?[`, c) >= 0,
+			i == 0 && (c == '#' || c == '!'),
+			i >= len(item)-trailing:
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+// normalizeExcludeItem returns item, a path relative to the repository
+// root, in the form the exclude file lists it: slash-separated and clean,
+// without a leading "./" or "/" (which anchors an entry to the root, where
+// items are anyway) or a trailing "/" (which matches only directories).
+// It returns "" for the root itself.
+func normalizeExcludeItem(item string) string {
+	return strings.TrimLeft(path.Clean("/"+filepath.ToSlash(item)), "/")
+}
+
+// gitIgnoresCase reports whether git matches paths in the repository at
+// repoRoot case-insensitively (core.ignorecase, set by git on macOS and
+// Windows filesystems).
+func gitIgnoresCase(repoRoot string) bool {
+	output, err := gitOutput(repoRoot, "config", "--get", "--bool", "core.ignorecase")
+	return err == nil && strings.TrimSpace(output) == "true"
+}
+
+// parseExcludeEntry returns the item an exclude file line matches, the
+// inverse of excludeEntry. Blank lines, comments, negations and patterns
+// with wildcards don't name a single item, so ok is false for them. A
+// leading slash anchors the entry to the repository root, where items are
+// anyway, and a trailing one only limits it to directories; both are
+// dropped. Lines git reads as a path it never matches, like ./notes.md,
+// aren't entries either: see lineNames.
+func parseExcludeEntry(line string) (item string, ok bool) {
+	raw, ok := unescapeExcludeLine(line)
+	if !ok {
+		return "", false
+	}
+	item = strings.TrimSuffix(strings.TrimPrefix(raw, "/"), "/")
+	if item == "" || item != normalizeExcludeItem(item) {
+		return "", false
+	}
+	return item, true
+}
+
+// lineNames returns the item line was meant to name, normalized like
+// ./notes.md to notes.md even where git wouldn't match it.
+func lineNames(line string) (item string, ok bool) {
+	raw, ok := unescapeExcludeLine(line)
+	if !ok {
+		return "", false
+	}
+	item = normalizeExcludeItem(raw)
+	return item, item != ""
+}
+
+// unescapeExcludeLine returns the path an exclude file line lists, with
+// escapes removed. As in git, unescaped trailing spaces are ignored but
+// leading ones are not. ok is false for blank lines, comments, negations
+// and patterns with wildcards.
+func unescapeExcludeLine(line string) (raw string, ok bool) {
+	if line == "" || line[0] == '#' || line[0] == '!' {
+		return "", false
+	}
+	var b strings.Builder
+	spaces := 0 // Unescaped spaces at the end of b
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\':
+			i++
+			if i == len(line) {
+				return "", false // A trailing backslash matches nothing
+			}
+			b.WriteByte(line[i])
+			spaces = 0
+			continue
+		case c == '*' || c == '?' || c == '[':
+			return "", false
+		case c == ' ':
+			spaces++
+		default:
+			spaces = 0
+		}
+		b.WriteByte(c)
+	}
+	raw = b.String()[:b.Len()-spaces]
+	return raw, raw != ""
+}
+
+// managedBlock returns the bounds of the wrapper's block in lines: the
+// indexes of the start and end markers, or -1, -1 if there is none.
+func managedBlock(lines []string) (start, end int) {
+	start, end = -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case managedBlockStart:
+			if start < 0 {
+				start = i
+			}
+		case managedBlockEnd:
+			if start >= 0 && end < 0 {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return -1, -1
+	}
+	return start, end
+}
+
+// insertIntoManagedBlock adds entry to the end of the wrapper's block,
+// creating the block at the end of lines if needed.
+func insertIntoManagedBlock(lines []string, entry string) []string {
+	start, end := managedBlock(lines)
+	if start < 0 {
+		return append(lines, managedBlockStart, entry, managedBlockEnd)
+	}
+	out := make([]string, 0, len(lines)+1)
+	out = append(out, lines[:end]...)
+	out = append(out, entry)
+	return append(out, lines[end:]...)
+}
+
+// adoptStoredEntries moves into the wrapper's block any entry outside it whose
+// item is in cfg's branch store. Such entries were written before the block
+// existed, and leaving them out would make sync-out drop the stored copies.
+func adoptStoredEntries(cfg *Config) error {
+	lines, err := readLines(cfg.excludeFile())
+	if err != nil {
+		return err
+	}
+	start, end := managedBlock(lines)
+	if start < 0 {
+		return nil // Without a block every entry is still managed
+	}
+
+	var errs []error
+	for i, line := range lines {
+		if i >= start && i <= end {
+			continue
+		}
+		entry, ok := parseExcludeEntry(line)
+		if !ok {
+			continue
+		}
+		if existsInAny([]string{cfg.StoreLocation}, entry) {
+			if err := addToExclude(cfg.RepoRoot, cfg.excludeFile(), entry); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tidyExclude removes entries from the wrapper's block in the exclude file
+// whose items exist neither in the working tree nor in cfg's branch store
+// (including the shared area and this machine's sub-store). Lines outside
+// the block are never touched. It returns the removed entries; with dryRun
+// the file is left unchanged.
+func tidyExclude(cfg *Config, dryRun bool) ([]string, error) {
+	path := cfg.excludeFile()
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	start, end := managedBlock(lines)
+	if start < 0 {
+		return nil, nil
+	}
+
+	roots := []string{cfg.RepoRoot, cfg.StoreLocation, filepath.Join(cfg.StoreBase, sharedDir)}
+	if len(cfg.Settings.MachineScoped) > 0 {
+		if store, err := machineStore(cfg); err == nil {
+			roots = append(roots, store)
+		}
+	}
+
+	var removed []string
+	kept := append([]string(nil), lines[:start+1]...)
+	for _, line := range lines[start+1 : end] {
+		entry, ok := parseExcludeEntry(line)
+		if !ok || existsInAny(roots, entry) {
+			kept = append(kept, line)
+			continue
+		}
+		removed = append(removed, entry)
+	}
+	if len(removed) == 0 || dryRun {
+		return removed, nil
+	}
+
+	if end == start+1+len(removed) {
+		// Nothing left in the block; drop the markers too
+		kept = kept[:start]
+	} else {
+		kept = append(kept, lines[end])
+	}
+	kept = append(kept, lines[end+1:]...)
+	return removed, writeLines(path, kept)
+}
+
+// existsInAny reports whether item exists (as anything, even a dangling
+// symlink) under any of roots.
+func existsInAny(roots []string, item string) bool {
+	for _, root := range roots {
+		if _, err := os.Lstat(filepath.Join(root, item)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runTidyExcludeCommand implements `claude-wrapper tidy-exclude [--dry-run]`.
+func runTidyExcludeCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("tidy-exclude")
+	dryRun := fs.Bool("dry-run", false, "show which entries would be removed without changing the exclude file")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	removed, err := tidyExclude(cfg, *dryRun)
+	if err != nil {
+		return 1, fmt.Errorf("failed to tidy exclude file: %w", err)
+	}
+
+	verb := colorize(os.Stdout, toneRemoved, "removed")
+	if *dryRun {
+		verb = colorize(os.Stdout, toneMuted, "would remove")
+	}
+	for _, entry := range removed {
+		fmt.Printf("%s %s\n", verb, entry)
+	}
+	if len(removed) == 0 {
+		fmt.Println("exclude file is already tidy")
+	}
+	return 0, nil
+}
+
+// tidyExcludeAfterSync tidies the exclude file at the end of a run, only
+// logging failures.
+func tidyExcludeAfterSync(cfg *Config) {
+	removed, err := tidyExclude(cfg, false)
+	if err != nil {
+		warnf("failed to tidy exclude file: %v", err)
+		return
+	}
+	if len(removed) > 0 {
+		infof("removed stale exclude entries: %s", strings.Join(removed, ", "))
+	}
+}
@@ -0,0 +1,475 @@
+package wrapper
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAddToExclude_InsertsIntoManagedBlock(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	path := filepath.Join(repoRoot, excludeFile)
+	writeFile(t, path, "# user pattern\n*.swp\n"+managedBlockStart+"\nCLAUDE.md\n"+managedBlockEnd+"\n.env\n")
+
+	if err := addToExclude(repoRoot, excludePath(repoRoot), "notes.md"); err != nil {
+		t.Fatalf("addToExclude failed: %v", err)
+	}
+
+	assertFileContent(t, path, "# user pattern\n*.swp\n"+managedBlockStart+"\nCLAUDE.md\nnotes.md\n"+managedBlockEnd+"\n.env\n")
+}
+
+func TestAddToExclude_HandWrittenLineDoesNotCount(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	path := filepath.Join(repoRoot, excludeFile)
+	writeFile(t, path, ".env\n")
+
+	if err := addToExclude(repoRoot, excludePath(repoRoot), ".env"); err != nil {
+		t.Fatalf("addToExclude failed: %v", err)
+	}
+	assertFileContent(t, path, ".env\n"+managedBlockStart+"\n.env\n"+managedBlockEnd+"\n")
+}
+
+func TestReadExcludeFile_OnlyManagedBlockOnceItExists(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "x")
+	writeFile(t, filepath.Join(repoRoot, "scratch.txt"), "x")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "scratch.txt\n"+managedBlockStart+"\nCLAUDE.md\n"+managedBlockEnd+"\n")
+
+	items, err := readExcludeFile(repoRoot, excludePath(repoRoot))
+	if err != nil {
+		t.Fatalf("readExcludeFile failed: %v", err)
+	}
+	if want := []string{"CLAUDE.md"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("expected %v, got %v", want, items)
+	}
+}
+
+func TestReadExcludeFile_LegacyFileWithoutBlock(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "x")
+	writeFile(t, filepath.Join(repoRoot, "scratch.txt"), "x")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "CLAUDE.md\nscratch.txt\n")
+
+	items, err := readExcludeFile(repoRoot, excludePath(repoRoot))
+	if err != nil {
+		t.Fatalf("readExcludeFile failed: %v", err)
+	}
+	if want := []string{"CLAUDE.md", "scratch.txt"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("expected %v, got %v", want, items)
+	}
+}
+
+func TestAdoptStoredEntries(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	path := filepath.Join(repoRoot, excludeFile)
+	writeFile(t, path, "old-notes.md\nscratch.txt\n"+managedBlockStart+"\nCLAUDE.md\n"+managedBlockEnd+"\n")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "old-notes.md"), "stored before the block existed")
+
+	if err := adoptStoredEntries(cfg); err != nil {
+		t.Fatalf("adoptStoredEntries failed: %v", err)
+	}
+	assertFileContent(t, path, "old-notes.md\nscratch.txt\n"+managedBlockStart+"\nCLAUDE.md\nold-notes.md\n"+managedBlockEnd+"\n")
+}
+
+func TestTidyExclude_RemovesStaleWrapperEntries(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	cfg, storeBase := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	path := filepath.Join(repoRoot, excludeFile)
+	writeFile(t, path, "gone-by-hand\n"+managedBlockStart+"\nin-tree.md\nin-store.md\nin-shared.md\nlong-gone.md\nold-dir/\n"+managedBlockEnd+"\n")
+
+	writeFile(t, filepath.Join(repoRoot, "in-tree.md"), "x")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "in-store.md"), "x")
+	writeFile(t, filepath.Join(storeBase, sharedDir, "in-shared.md"), "x")
+
+	removed, err := tidyExclude(cfg, false)
+	if err != nil {
+		t.Fatalf("tidyExclude failed: %v", err)
+	}
+
+	if want := []string{"long-gone.md", "old-dir"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("expected %v removed, got %v", want, removed)
+	}
+	assertFileContent(t, path, "gone-by-hand\n"+managedBlockStart+"\nin-tree.md\nin-store.md\nin-shared.md\n"+managedBlockEnd+"\n")
+}
+
+func TestTidyExclude_DropsEmptyBlock(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	path := filepath.Join(repoRoot, excludeFile)
+	writeFile(t, path, "*.log\n"+managedBlockStart+"\nlong-gone.md\n"+managedBlockEnd+"\n")
+
+	if _, err := tidyExclude(cfg, false); err != nil {
+		t.Fatalf("tidyExclude failed: %v", err)
+	}
+	assertFileContent(t, path, "*.log\n")
+}
+
+func TestTidyExclude_DryRunLeavesFile(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	path := filepath.Join(repoRoot, excludeFile)
+	content := managedBlockStart + "\nlong-gone.md\n" + managedBlockEnd + "\n"
+	writeFile(t, path, content)
+
+	removed, err := tidyExclude(cfg, true)
+	if err != nil {
+		t.Fatalf("tidyExclude failed: %v", err)
+	}
+	if len(removed) != 1 {
+		t.Errorf("expected one entry reported, got %v", removed)
+	}
+	assertFileContent(t, path, content)
+}
+
+func TestTidyExclude_NoBlock(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	path := filepath.Join(repoRoot, excludeFile)
+	writeFile(t, path, "long-gone.md\n")
+
+	removed, err := tidyExclude(cfg, false)
+	if err != nil || removed != nil {
+		t.Errorf("expected nothing removed, got %v, %v", removed, err)
+	}
+	assertFileContent(t, path, "long-gone.md\n")
+}
+
+// weirdNames are file names the exclude file and store must round-trip.
+var weirdNames = []string{
+	"-rf",
+	"--help",
+	"trailing space ",
+	"two trailing  ",
+	"  leading space",
+	"#notes",
+	"!important",
+	"star*.md",
+	"which?.md",
+	"[draft].md",
+	`back\slash`,
+	"caf\xe9.md", // Latin-1, not valid UTF-8
+	"ünïcödé ✓.md",
+}
+
+func TestExcludeEntry_RoundTrips(t *testing.T) {
+	for _, name := range weirdNames {
+		entry, err := excludeEntry(name)
+		if err != nil {
+			t.Errorf("excludeEntry(%q) failed: %v", name, err)
+			continue
+		}
+		if got, ok := parseExcludeEntry(entry); !ok || got != name {
+			t.Errorf("parseExcludeEntry(%q) = %q, %v; want %q", entry, got, ok, name)
+		}
+	}
+}
+
+func TestExcludeEntry_RefusesLineBreaks(t *testing.T) {
+	for _, name := range []string{"two\nlines", "carriage\r"} {
+		if _, err := excludeEntry(name); !errors.Is(err, errUnexcludable) {
+			t.Errorf("excludeEntry(%q) = %v, want errUnexcludable", name, err)
+		}
+	}
+	repoRoot := setupRepoRoot(t)
+	if err := addToExclude(repoRoot, excludePath(repoRoot), "two\nlines"); !errors.Is(err, errUnexcludable) {
+		t.Errorf("addToExclude = %v, want errUnexcludable", err)
+	}
+	assertNotExists(t, filepath.Join(repoRoot, excludeFile))
+}
+
+func TestParseExcludeEntry(t *testing.T) {
+	tests := []struct {
+		line, item string
+		ok         bool
+	}{
+		{"CLAUDE.md", "CLAUDE.md", true},
+		{".claude/", ".claude", true},
+		{"/CLAUDE.md", "CLAUDE.md", true},
+		{"/.claude/", ".claude", true},
+		{"/", "", false},
+		{"./notes.md", "", false},
+		{"sub//notes.md", "", false},
+		{"sub/../notes.md", "", false},
+		{".", "", false},
+		{"notes.md   ", "notes.md", true},
+		{`notes.md\ `, "notes.md ", true},
+		{"", "", false},
+		{"# comment", "", false},
+		{"!negated", "", false},
+		{"*.swp", "", false},
+		{`\*.swp`, "*.swp", true},
+		{`dangling\`, "", false},
+		{"   ", "", false},
+	}
+	for _, tt := range tests {
+		item, ok := parseExcludeEntry(tt.line)
+		if item != tt.item || ok != tt.ok {
+			t.Errorf("parseExcludeEntry(%q) = %q, %v; want %q, %v", tt.line, item, ok, tt.item, tt.ok)
+		}
+	}
+}
+
+func TestAddToExclude_WeirdNamesIgnoredByGit(t *testing.T) {
+	if !gitBinaryAvailable() {
+		t.Skip("git not available")
+	}
+	repoRoot, _ := givenGitRepo(t)
+	for _, name := range weirdNames {
+		writeFile(t, filepath.Join(repoRoot, name), "x")
+		if err := addToExclude(repoRoot, excludePath(repoRoot), name); err != nil {
+			t.Fatalf("addToExclude(%q) failed: %v", name, err)
+		}
+	}
+	// Escaped wildcards match only their own names
+	writeFile(t, filepath.Join(repoRoot, "starry.md"), "x")
+
+	untracked, err := gitOutput(repoRoot, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSuffix(untracked, "\x00"); got != "starry.md" {
+		t.Errorf("expected only starry.md untracked, got %q", strings.Split(got, "\x00"))
+	}
+
+	items, err := readExcludeFile(repoRoot, excludePath(repoRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, weirdNames) {
+		t.Errorf("readExcludeFile = %q, want %q", items, weirdNames)
+	}
+}
+
+func TestSyncRoundTrip_WeirdNames(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	for _, name := range weirdNames {
+		writeFile(t, filepath.Join(repoRoot, name), name)
+		if err := addToExclude(repoRoot, excludePath(repoRoot), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	m, err := readManifest(cfg.StoreLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range weirdNames {
+		assertFileContent(t, filepath.Join(cfg.StoreLocation, name), name)
+		if _, ok := m.Files[manifestKey(name)]; !ok {
+			t.Errorf("%q missing from the manifest", name)
+		}
+	}
+
+	fresh := givenRepo(t)
+	cfg.RepoRoot = fresh
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range weirdNames {
+		assertFileContent(t, filepath.Join(fresh, name), name)
+	}
+	items, err := readExcludeFile(fresh, excludePath(fresh))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != len(weirdNames) {
+		t.Errorf("expected every name excluded after sync-in, got %q", items)
+	}
+}
+
+func TestSyncIn_SkipsNamesWithLineBreaks(t *testing.T) {
+	diagnostics := captureDiagnostics(t)
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "two\nlines"), "x")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "x")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(repoRoot, "CLAUDE.md"))
+	assertNotExists(t, filepath.Join(repoRoot, "two\nlines"))
+	if !strings.Contains(diagnostics.String(), `not restoring "two\nlines"`) {
+		t.Errorf("expected a warning, got %q", diagnostics.String())
+	}
+}
+
+func TestAddToExclude_NormalizesEntries(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	path := filepath.Join(repoRoot, excludeFile)
+	block := managedBlockStart + "\nnotes.md\n/docs/\n./.claude\n" + managedBlockEnd + "\n"
+	writeFile(t, path, block)
+
+	for _, item := range []string{"notes.md", "./notes.md", "/notes.md", "notes.md/", "docs", "./docs/"} {
+		if err := addToExclude(repoRoot, excludePath(repoRoot), item); err != nil {
+			t.Fatalf("addToExclude(%q) failed: %v", item, err)
+		}
+	}
+	assertFileContent(t, path, block)
+
+	// git never matches ./.claude, so the line is replaced
+	if err := addToExclude(repoRoot, excludePath(repoRoot), ".claude/"); err != nil {
+		t.Fatal(err)
+	}
+	block = managedBlockStart + "\nnotes.md\n/docs/\n.claude\n" + managedBlockEnd + "\n"
+	assertFileContent(t, path, block)
+
+	if err := addToExclude(repoRoot, excludePath(repoRoot), "./sub//new.md/"); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, path, managedBlockStart+"\nnotes.md\n/docs/\n.claude\nsub/new.md\n"+managedBlockEnd+"\n")
+
+	if err := addToExclude(repoRoot, excludePath(repoRoot), "./"); err == nil {
+		t.Error("expected the repository root to be refused")
+	}
+}
+
+func TestAddToExclude_IgnoresCaseWhenGitDoes(t *testing.T) {
+	if !gitBinaryAvailable() {
+		t.Skip("git not available")
+	}
+	repoRoot, _ := givenGitRepo(t)
+	path := filepath.Join(repoRoot, excludeFile)
+	block := managedBlockStart + "\nNotes.md\n" + managedBlockEnd + "\n"
+	writeFile(t, path, block)
+
+	if _, err := gitOutput(repoRoot, "config", "core.ignorecase", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addToExclude(repoRoot, excludePath(repoRoot), "notes.md"); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, path, block)
+
+	if _, err := gitOutput(repoRoot, "config", "core.ignorecase", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addToExclude(repoRoot, excludePath(repoRoot), "notes.md"); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, path, managedBlockStart+"\nNotes.md\nnotes.md\n"+managedBlockEnd+"\n")
+}
+
+func TestReadExcludeFile_AnchoredEntries(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "root")
+	writeFile(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), managedBlockStart+"\n/CLAUDE.md\n/.claude/\n"+managedBlockEnd+"\n")
+
+	items, err := readExcludeFile(repoRoot, excludePath(repoRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"CLAUDE.md", ".claude"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("readExcludeFile = %q, want %q", items, want)
+	}
+	if !excludeManages(excludePath(repoRoot), "CLAUDE.md") || !excludeManages(excludePath(repoRoot), "./.claude") {
+		t.Error("expected anchored entries to be managed")
+	}
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "root")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "{}")
+
+	// The anchored entries already cover the items synced back in
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(repoRoot, excludeFile), managedBlockStart+"\n/CLAUDE.md\n/.claude/\n"+managedBlockEnd+"\n")
+}
+
+func TestReadExcludeFile_AgreesWithGit(t *testing.T) {
+	if !gitBinaryAvailable() {
+		t.Skip("git not available")
+	}
+	repoRoot, _ := givenGitRepo(t)
+	for _, name := range []string{"CLAUDE.md", "notes.md", ".claude/settings.json", "docs/plan.md", "docs/other.md"} {
+		writeFile(t, filepath.Join(repoRoot, name), "x")
+	}
+	writeFile(t, filepath.Join(repoRoot, excludeFile), managedBlockStart+"\n/CLAUDE.md\n./notes.md\n/.claude/\ndocs/plan.md\n"+managedBlockEnd+"\n")
+
+	items, err := readExcludeFile(repoRoot, excludePath(repoRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if _, err := gitOutput(repoRoot, "check-ignore", "-q", "--", item); err != nil {
+			t.Errorf("wrapper manages %s but git doesn't ignore it", item)
+		}
+	}
+	// git doesn't match ./notes.md, so neither does the wrapper
+	if want := []string{"CLAUDE.md", ".claude", "docs/plan.md"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("readExcludeFile = %q, want %q", items, want)
+	}
+
+	// Until the line is fixed when the item is next added
+	if err := addToExclude(repoRoot, excludePath(repoRoot), "notes.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gitOutput(repoRoot, "check-ignore", "-q", "--", "notes.md"); err != nil {
+		t.Error("expected git to ignore notes.md once its entry was fixed")
+	}
+}
+
+func TestExcludeFileSetting(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.ExcludeFile = ".gitignore.local"
+	target := filepath.Join(repoRoot, ".gitignore.local")
+	if got := cfg.excludeFile(); got != target {
+		t.Fatalf("excludeFile = %s, want %s", got, target)
+	}
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "stored")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, target, managedBlockStart+"\nCLAUDE.md\n"+managedBlockEnd+"\n")
+	assertNotExists(t, filepath.Join(repoRoot, excludeFile))
+
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "edited")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "edited")
+
+	abs := filepath.Join(t.TempDir(), "ignore")
+	cfg.Settings.ExcludeFile = abs
+	if got := cfg.excludeFile(); got != abs {
+		t.Errorf("excludeFile = %s, want the absolute path %s", got, abs)
+	}
+	cfg.Settings.ExcludeFile = ""
+	if got := cfg.excludeFile(); got != excludePath(repoRoot) {
+		t.Errorf("excludeFile = %s, want info/exclude by default", got)
+	}
+}
+
+func TestExcludeFileSetting_ReadByGit(t *testing.T) {
+	if !gitBinaryAvailable() {
+		t.Skip("git not available")
+	}
+	repoRoot, _ := givenGitRepo(t)
+	target := filepath.Join(repoRoot, ".gitignore.local")
+	if _, err := gitOutput(repoRoot, "config", "core.excludesFile", target); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{RepoRoot: repoRoot, Settings: Settings{ExcludeFile: ".gitignore.local"}}
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "x")
+
+	if err := addToExclude(repoRoot, cfg.excludeFile(), "CLAUDE.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gitOutput(repoRoot, "check-ignore", "-q", "--", "CLAUDE.md"); err != nil {
+		t.Error("expected git to ignore CLAUDE.md through core.excludesFile")
+	}
+	assertNotExists(t, excludePath(repoRoot))
+}
@@ -0,0 +1,66 @@
+package wrapper
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Exit codes of the wrapper's own failures. They sit above the codes
+// programs commonly use, so scripts can tell them apart from claude's own
+// exit code, which is passed through whenever the wrapper itself succeeds.
+const (
+	// exitFailure is any other wrapper failure.
+	exitFailure = 1
+	// exitUsage is a bad command line.
+	exitUsage = 2
+	// exitLocked means another process holds a lock the wrapper needs.
+	exitLocked = 75
+	// exitConfig is an invalid settings file.
+	exitConfig = 78
+	// exitSyncIn means personal files couldn't be synced in, so claude
+	// wasn't started.
+	exitSyncIn = 79
+	// exitSyncOut means claude ran but its changes couldn't all be saved
+	// to storage. This takes precedence over claude's own exit code.
+	exitSyncOut = 80
+	// exitNotExecutable means claude (or the command) was found but
+	// couldn't be started.
+	exitNotExecutable = 126
+	// exitNotFound means claude (or the command) isn't on PATH.
+	exitNotFound = 127
+)
+
+// exitError is an error that ends the wrapper with a specific exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode attaches an exit code to err; nil stays nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCodeFor returns the exit code the wrapper ends with after err.
+func exitCodeFor(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitFailure
+}
+
+// startFailureCode returns the exit code for a process that couldn't be
+// started because of err, following the shell's conventions.
+func startFailureCode(err error) int {
+	if errors.Is(err, exec.ErrNotFound) {
+		return exitNotFound
+	}
+	return exitNotExecutable
+}
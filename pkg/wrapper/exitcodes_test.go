@@ -0,0 +1,64 @@
+package wrapper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain error", errors.New("boom"), exitFailure},
+		{"coded error", withExitCode(exitSyncIn, errors.New("boom")), exitSyncIn},
+		{"wrapped coded error", fmt.Errorf("session: %w", withExitCode(exitConfig, errors.New("bad"))), exitConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeFor(tt.err); got != tt.want {
+				t.Errorf("exitCodeFor = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	if withExitCode(exitSyncOut, nil) != nil {
+		t.Error("expected withExitCode to keep nil")
+	}
+}
+
+func TestRunProcess_NotFound(t *testing.T) {
+	code, err := runProcess("claude-wrapper-no-such-command", nil)
+	if err == nil || code != exitNotFound {
+		t.Errorf("runProcess = %d, %v; want %d and an error", code, err, exitNotFound)
+	}
+}
+
+func TestMain_InvalidSettingsIsConfigError(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	captureDiagnostics(t)
+	if err := os.WriteFile(filepath.Join(home, "config.toml"), []byte("no_such_setting = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := Main([]string{"status"}); code != exitConfig {
+		t.Errorf("Main = %d, want %d", code, exitConfig)
+	}
+}
+
+func TestMain_SyncInFailure(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	captureDiagnostics(t)
+	// A stored copy of a committed file fails sync-in under "refuse"
+	writeFile(t, filepath.Join(home, "config.toml"), `tracked_collision = "refuse"`)
+	writeFile(t, filepath.Join(home, ".workspaces", filepath.Base(dir), "README.md"), "personal")
+
+	if code := Main([]string{"run", "true"}); code != exitSyncIn {
+		t.Errorf("Main = %d, want %d", code, exitSyncIn)
+	}
+}
@@ -0,0 +1,176 @@
+package wrapper
+
+import (
+	"os"
+	"strings"
+)
+
+// branchEnv picks the branch store, like --branch. The wrapper sets it for
+// claude so git hooks run during the session stay on the same store.
+const branchEnv = "CLAUDE_WRAPPER_BRANCH"
+
+// wrapperFlags are options consumed by the wrapper itself. They are removed
+// from the argument list before the remainder is handed to claude.
+type wrapperFlags struct {
+	traceGit bool
+	yes      bool
+	quiet    bool
+	// profileSync prints sync timings after the run
+	profileSync bool
+	profile     string
+	// seed replaces seed_branch for branch stores created by this run
+	seed []string
+	// branch replaces the checked-out branch in choosing the store
+	branch string
+	// inOnly and outOnly make the session sync in only, or out only
+	inOnly, outOnly bool
+	// ephemeral discards the session's changes to personal files
+	ephemeral bool
+	// sandbox runs the session in a temporary worktree
+	sandbox bool
+	// separated is set when "--" ended the wrapper flags: the rest is
+	// claude's, even if it names a wrapper command
+	separated bool
+}
+
+// wrapperFlagsUsage describes the wrapper flags, for usage messages.
+const wrapperFlagsUsage = `  --yes               answer yes to confirmation prompts
+  --quiet             don't show progress for long syncs
+  --profile NAME      use the store of profile NAME
+  --branch NAME       use branch NAME's store, whatever is checked out
+  --profile-sync      print how long each phase took after the run
+  --seed PATTERNS     seed new branch stores with only these (comma-separated)
+  --trace-git         print every git query
+  --in-only           sync personal files in for the session, but not back out
+  --out-only          sync personal files out after the session, but not in
+  --ephemeral         sync in, then discard the session's changes to personal files
+  --sandbox           run in a temporary worktree, then pick changed files to keep
+`
+
+// apply overrides settings with any flags given on the command line.
+func (f wrapperFlags) apply(s *Settings) {
+	if f.yes {
+		s.AssumeYes = true
+	}
+	if f.quiet {
+		s.Quiet = true
+	}
+	if f.profileSync {
+		s.ProfileSync = true
+	}
+	if f.profile != "" {
+		s.Profile = f.profile
+	}
+	if f.seed != nil {
+		s.SeedBranch = f.seed
+	}
+	if f.branch != "" {
+		s.branch = f.branch
+	}
+	if f.inOnly {
+		s.syncOnly = "in"
+	}
+	if f.outOnly {
+		s.syncOnly = "out"
+	}
+	if f.ephemeral {
+		s.syncOnly, s.ephemeral = "in", true
+	}
+	if f.sandbox {
+		s.sandbox = true
+	}
+}
+
+// parseWrapperFlags splits args into wrapper flags and the rest, by the rule
+//
+//	claude-wrapper [wrapper flags] [--] [claude arguments]
+//	claude-wrapper [wrapper flags] COMMAND [arguments and wrapper flags] [-- ...]
+//
+// Wrapper flags are only taken from the start of the command line: from the
+// first argument that isn't one, the rest goes to claude exactly as given,
+// so claude's own flags and prompts are never mistaken for the wrapper's.
+// A "--" right after the wrapper flags ends them and is dropped; what
+// follows is claude's even if it names a wrapper command. A wrapper
+// command's arguments are the wrapper's own, so wrapper flags may also
+// appear among them, up to a "--".
+func parseWrapperFlags(args []string) (wrapperFlags, []string) {
+	var flags wrapperFlags
+	if os.Getenv("CLAUDE_WRAPPER_TRACE_GIT") != "" {
+		flags.traceGit = true
+	}
+	flags.profile = os.Getenv(profileEnv)
+	flags.branch = os.Getenv(branchEnv)
+
+	i := 0
+	for i < len(args) {
+		next, ok := flags.take(args, i)
+		if !ok {
+			break
+		}
+		i = next
+	}
+	if i < len(args) && args[i] == "--" {
+		flags.separated = true
+		return flags, append([]string{}, args[i+1:]...)
+	}
+	if _, ok := lookupCommand(args[i:]); !ok {
+		return flags, append([]string{}, args[i:]...)
+	}
+
+	rest := []string{args[i]}
+	for i++; i < len(args); {
+		if args[i] == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		next, ok := flags.take(args, i)
+		if !ok {
+			rest = append(rest, args[i])
+			next = i + 1
+		}
+		i = next
+	}
+	return flags, rest
+}
+
+// take consumes the wrapper flag at args[i], if it is one, and returns the
+// index of the argument after it (and its value).
+func (f *wrapperFlags) take(args []string, i int) (int, bool) {
+	arg := args[i]
+	switch {
+	case arg == "--trace-git":
+		f.traceGit = true
+	case arg == "--yes":
+		f.yes = true
+	case arg == "--quiet":
+		f.quiet = true
+	case arg == "--profile-sync":
+		f.profileSync = true
+	case arg == "--in-only":
+		f.inOnly = true
+	case arg == "--out-only":
+		f.outOnly = true
+	case arg == "--ephemeral":
+		f.ephemeral = true
+	case arg == "--sandbox":
+		f.sandbox = true
+	case arg == "--profile" && i+1 < len(args):
+		i++
+		f.profile = args[i]
+	case strings.HasPrefix(arg, "--profile="):
+		f.profile = strings.TrimPrefix(arg, "--profile=")
+	case arg == "--branch" && i+1 < len(args):
+		i++
+		f.branch = args[i]
+	case strings.HasPrefix(arg, "--branch="):
+		f.branch = strings.TrimPrefix(arg, "--branch=")
+	case arg == "--seed" && i+1 < len(args):
+		i++
+		f.seed = append(f.seed, strings.Split(args[i], ",")...)
+	case strings.HasPrefix(arg, "--seed="):
+		f.seed = append(f.seed, strings.Split(strings.TrimPrefix(arg, "--seed="), ",")...)
+	default:
+		return i, false
+	}
+	return i + 1, true
+}
@@ -0,0 +1,236 @@
+package wrapper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWrapperFlags(t *testing.T) {
+	t.Setenv("CLAUDE_WRAPPER_TRACE_GIT", "")
+
+	tests := []struct {
+		name     string
+		args     []string
+		traceGit bool
+		rest     []string
+	}{
+		{
+			name: "no wrapper flags",
+			args: []string{"-p", "hello"},
+			rest: []string{"-p", "hello"},
+		},
+		{
+			name:     "trace-git is consumed",
+			args:     []string{"--trace-git", "-p", "hello"},
+			traceGit: true,
+			rest:     []string{"-p", "hello"},
+		},
+		{
+			name: "flags after separator belong to claude",
+			args: []string{"--", "--trace-git"},
+			rest: []string{"--trace-git"},
+		},
+		{
+			name:     "separator after wrapper flags is dropped",
+			args:     []string{"--trace-git", "--", "-p", "--yes"},
+			traceGit: true,
+			rest:     []string{"-p", "--yes"},
+		},
+		{
+			name: "a doubled separator passes one to claude",
+			args: []string{"--", "--", "-p"},
+			rest: []string{"--", "-p"},
+		},
+		{
+			name: "flags after claude arguments belong to claude",
+			args: []string{"-p", "hello", "--trace-git"},
+			rest: []string{"-p", "hello", "--trace-git"},
+		},
+		{
+			name:     "flags among a wrapper command's arguments are the wrapper's",
+			args:     []string{"run", "--trace-git", "--", "pytest", "--trace-git"},
+			traceGit: true,
+			rest:     []string{"run", "--", "pytest", "--trace-git"},
+		},
+		{
+			name: "empty args",
+			args: []string{},
+			rest: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, rest := parseWrapperFlags(tt.args)
+			if flags.traceGit != tt.traceGit {
+				t.Errorf("expected traceGit=%v, got %v", tt.traceGit, flags.traceGit)
+			}
+			if !reflect.DeepEqual(rest, tt.rest) {
+				t.Errorf("expected remaining args %v, got %v", tt.rest, rest)
+			}
+		})
+	}
+}
+
+func TestParseWrapperFlags_TraceGitFromEnvironment(t *testing.T) {
+	t.Setenv("CLAUDE_WRAPPER_TRACE_GIT", "1")
+
+	flags, _ := parseWrapperFlags(nil)
+	if !flags.traceGit {
+		t.Error("expected CLAUDE_WRAPPER_TRACE_GIT to enable tracing")
+	}
+}
+
+func TestWrapperFlags_Apply(t *testing.T) {
+	flags, rest := parseWrapperFlags([]string{"--yes", "-p", "hi"})
+	if !reflect.DeepEqual(rest, []string{"-p", "hi"}) {
+		t.Errorf("unexpected remaining args %v", rest)
+	}
+
+	var s Settings
+	flags.apply(&s)
+	if !s.AssumeYes {
+		t.Error("expected --yes to set AssumeYes")
+	}
+}
+
+func TestWrapperFlags_ApplyQuiet(t *testing.T) {
+	flags, rest := parseWrapperFlags([]string{"--quiet", "sync"})
+	if !reflect.DeepEqual(rest, []string{"sync"}) {
+		t.Errorf("unexpected remaining args %v", rest)
+	}
+
+	var s Settings
+	flags.apply(&s)
+	if !s.Quiet {
+		t.Error("expected --quiet to set Quiet")
+	}
+}
+
+func TestParseWrapperFlags_Profile(t *testing.T) {
+	t.Setenv(profileEnv, "home")
+
+	tests := []struct {
+		name    string
+		args    []string
+		profile string
+		rest    []string
+	}{
+		{"from environment", []string{"-p", "hi"}, "home", []string{"-p", "hi"}},
+		{"separate value", []string{"--profile", "work", "sync"}, "work", []string{"sync"}},
+		{"joined value", []string{"--profile=work", "sync"}, "work", []string{"sync"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, rest := parseWrapperFlags(tt.args)
+			if flags.profile != tt.profile {
+				t.Errorf("expected profile %q, got %q", tt.profile, flags.profile)
+			}
+			if !reflect.DeepEqual(rest, tt.rest) {
+				t.Errorf("expected remaining args %v, got %v", tt.rest, rest)
+			}
+			var s Settings
+			flags.apply(&s)
+			if s.Profile != tt.profile {
+				t.Errorf("expected apply to set Profile %q, got %q", tt.profile, s.Profile)
+			}
+		})
+	}
+}
+
+func TestWrapperFlags_ApplyProfileSync(t *testing.T) {
+	flags, rest := parseWrapperFlags([]string{"--profile-sync", "sync"})
+	if !reflect.DeepEqual(rest, []string{"sync"}) {
+		t.Errorf("unexpected remaining args %v", rest)
+	}
+
+	var s Settings
+	flags.apply(&s)
+	if !s.ProfileSync || s.Profile != "" {
+		t.Errorf("expected --profile-sync to set only ProfileSync, got %+v", s)
+	}
+}
+
+func TestParseWrapperFlags_Seed(t *testing.T) {
+	flags, rest := parseWrapperFlags([]string{"--seed", "CLAUDE.md,.claude/", "--seed=!.claude/cache/", "sync"})
+	if !reflect.DeepEqual(rest, []string{"sync"}) {
+		t.Errorf("unexpected remaining args %v", rest)
+	}
+
+	s := Settings{SeedBranch: []string{"notes.md"}}
+	flags.apply(&s)
+	if want := []string{"CLAUDE.md", ".claude/", "!.claude/cache/"}; !reflect.DeepEqual(s.SeedBranch, want) {
+		t.Errorf("SeedBranch = %q, want %q", s.SeedBranch, want)
+	}
+}
+
+func TestParseWrapperFlags_SeparatorKeepsWrapperCommandNamesForClaude(t *testing.T) {
+	flags, rest := parseWrapperFlags([]string{"--yes", "--", "status"})
+	if !flags.separated || !reflect.DeepEqual(rest, []string{"status"}) {
+		t.Errorf("got separated=%v, rest %v; want status passed to claude", flags.separated, rest)
+	}
+	if flags, _ := parseWrapperFlags([]string{"--yes", "status"}); flags.separated {
+		t.Error("expected a wrapper command without a separator to stay the wrapper's")
+	}
+}
+
+func TestParseWrapperFlags_SyncOnly(t *testing.T) {
+	flags, rest := parseWrapperFlags([]string{"--in-only", "-p", "explore"})
+	if !reflect.DeepEqual(rest, []string{"-p", "explore"}) {
+		t.Errorf("unexpected remaining args %v", rest)
+	}
+	var s Settings
+	flags.apply(&s)
+	if s.syncOnly != "in" {
+		t.Errorf("syncOnly = %q, want in", s.syncOnly)
+	}
+
+	flags, _ = parseWrapperFlags([]string{"--out-only", "run", "make"})
+	s = Settings{}
+	flags.apply(&s)
+	if s.syncOnly != "out" {
+		t.Errorf("syncOnly = %q, want out", s.syncOnly)
+	}
+}
+
+func TestMain_RejectsInOnlyWithOutOnly(t *testing.T) {
+	diagnostics := captureDiagnostics(t)
+	if code := Main([]string{"--in-only", "--out-only", "status"}); code != exitUsage {
+		t.Errorf("exit code = %d, want %d", code, exitUsage)
+	}
+	if !strings.Contains(diagnostics.String(), "can't be combined") {
+		t.Errorf("expected an error, got %q", diagnostics.String())
+	}
+}
+
+func TestMain_RejectsSandboxWithEphemeral(t *testing.T) {
+	diagnostics := captureDiagnostics(t)
+	if code := Main([]string{"--sandbox", "--ephemeral", "status"}); code != exitUsage {
+		t.Errorf("exit code = %d, want %d", code, exitUsage)
+	}
+	if !strings.Contains(diagnostics.String(), "can't be combined") {
+		t.Errorf("expected an error, got %q", diagnostics.String())
+	}
+}
+
+func TestParseWrapperFlags_Branch(t *testing.T) {
+	t.Setenv(branchEnv, "")
+	for _, args := range [][]string{{"--branch", "main", "-p", "hi"}, {"--branch=main", "-p", "hi"}} {
+		flags, rest := parseWrapperFlags(args)
+		if !reflect.DeepEqual(rest, []string{"-p", "hi"}) {
+			t.Errorf("parseWrapperFlags(%q) left %v", args, rest)
+		}
+		var s Settings
+		flags.apply(&s)
+		if s.branch != "main" {
+			t.Errorf("parseWrapperFlags(%q): branch = %q, want main", args, s.branch)
+		}
+	}
+
+	t.Setenv(branchEnv, "release")
+	if flags, _ := parseWrapperFlags(nil); flags.branch != "release" {
+		t.Errorf("branch = %q, want it from %s", flags.branch, branchEnv)
+	}
+}
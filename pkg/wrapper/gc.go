@@ -0,0 +1,137 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// confirmRepoStoreDeletionFunc decides whether the expired store of a
+// deleted repository may be removed. Replaced in tests.
+var confirmRepoStoreDeletionFunc = confirmRepoStoreDeletion
+
+// confirmRepoStoreDeletion asks before removing the store at path of the
+// deleted repository repo, like confirmBranchDeletion.
+func confirmRepoStoreDeletion(s Settings, repo, path string) bool {
+	if s.AssumeYes || !isInteractive() {
+		return true
+	}
+	return promptStoreDeletion(os.Stdin, os.Stderr, fmt.Sprintf("repository %s", repo), deletedWhy, path, deletionGraceDays)
+}
+
+// runGCCommand implements `claude-wrapper gc --repos`.
+func runGCCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("gc")
+	repos := fs.Bool("repos", false, "flag, then remove, stores of repositories that no longer exist")
+	dryRun := fs.Bool("dry-run", false, "show what would be flagged or removed without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if !*repos || fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage, nil
+	}
+
+	settings, err := LoadSettings()
+	if err != nil {
+		return 1, withExitCode(exitConfig, fmt.Errorf("failed to load settings: %w", err))
+	}
+	flags.apply(&settings)
+	root, err := settings.storeRoot()
+	if err != nil {
+		return 1, err
+	}
+	if err := gcRepoStores(root, settings, time.Now(), *dryRun, false, os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// gcRepoStores handles the stores under root whose repository, on this
+// machine, no longer exists, with the same grace period as branch stores: a
+// store is first flagged with a deletion marker and removed once the marker
+// has expired. With flagOnly, as after a session, expired stores are left
+// for an explicit `gc --repos` to remove, since a repository on a drive
+// that isn't mounted looks just as missing. Stores whose repository is back
+// are unflagged; stores that don't record their repository and host are
+// never touched. What happens is reported to w.
+func gcRepoStores(root string, s Settings, now time.Time, dryRun, flagOnly bool, w io.Writer) error {
+	stores, err := collectRepoStores(root)
+	if err != nil {
+		return err
+	}
+	gracePeriod := deletionGraceDays * 24 * time.Hour
+
+	for _, store := range stores {
+		if store.repo == "" {
+			continue
+		}
+		marker := filepath.Join(store.store, deletionMarker)
+		markedAt, marked := readDeletionMarker(marker)
+
+		if !store.missing {
+			if marked && !dryRun {
+				os.Remove(marker)
+			}
+			continue
+		}
+
+		switch {
+		case !marked && dryRun:
+			fmt.Fprintf(w, "%s %s: %s no longer exists%s\n", colorize(w, toneMuted, "would flag"), store.name, store.repo, remoteNote(store.remote))
+		case !marked:
+			if err := writeDeletionMarker(marker, now); err != nil {
+				fmt.Fprintf(w, "failed to flag %s: %v\n", store.name, err)
+				continue
+			}
+			fmt.Fprintf(w, "%s %s: %s no longer exists%s; `claude-wrapper gc --repos` removes its store after %d days\n",
+				colorize(w, toneConflict, "flagged"), store.name, store.repo, remoteNote(store.remote), deletionGraceDays)
+		case flagOnly && now.Sub(markedAt) > gracePeriod:
+			fmt.Fprintf(w, "%s: %s missing since %s; run `claude-wrapper gc --repos` to remove its store\n",
+				store.name, store.repo, formatTime(markedAt))
+		case flagOnly:
+			// Reported when flagged; gc --repos lists it
+		case now.Sub(markedAt) <= gracePeriod:
+			fmt.Fprintf(w, "%s: %s missing since %s; store will be removed after %s\n",
+				store.name, store.repo, formatTime(markedAt), formatTime(markedAt.Add(gracePeriod)))
+		case dryRun:
+			fmt.Fprintf(w, "%s %s (%s)\n", colorize(w, toneMuted, "would remove"), store.name, formatBytes(store.size))
+		case !confirmRepoStoreDeletionFunc(s, store.repo, store.store):
+			// Declined: restart the grace period
+			if err := writeDeletionMarker(marker, now); err != nil {
+				fmt.Fprintf(w, "failed to reset deletion marker for %s: %v\n", store.name, err)
+			}
+		default:
+			err := auditedRemoveAll(root, auditEntry{
+				Path:     store.store,
+				Reason:   fmt.Sprintf("repository deleted more than %d days ago%s", deletionGraceDays, remoteNote(store.remote)),
+				Repo:     store.repo,
+				MarkedAt: &markedAt,
+			})
+			if err != nil {
+				fmt.Fprintf(w, "failed to remove %s: %v\n", store.name, err)
+				continue
+			}
+			fmt.Fprintf(w, "%s %s (%s)\n", colorize(w, toneRemoved, "removed"), store.name, formatBytes(store.size))
+		}
+	}
+	return nil
+}
+
+// writeDeletionMarker marks the store owning the marker at path as deleted
+// at now.
+func writeDeletionMarker(path string, now time.Time) error {
+	return os.WriteFile(path, []byte(strconv.FormatInt(now.Unix(), 10)), 0644)
+}
+
+// remoteNote mentions remote, if known, so a removed store can be traced
+// back to where the repository can be cloned from.
+func remoteNote(remote string) string {
+	if remote == "" {
+		return ""
+	}
+	return " (remote " + remote + ")"
+}
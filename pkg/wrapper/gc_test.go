@@ -0,0 +1,144 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// givenRepoStore creates a store under root for the repository at repo,
+// holding one personal file.
+func givenRepoStore(t *testing.T, root, name, repo string) string {
+	t.Helper()
+	storeBase := filepath.Join(root, name)
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), name+" config")
+	recordStoreMeta(&Config{RepoRoot: repo, StoreBase: storeBase, remoteURL: func() string { return "git@example.com:me/" + name + ".git" }}, time.Now())
+	return storeBase
+}
+
+func TestGCRepoStores_GracePeriod(t *testing.T) {
+	root := t.TempDir()
+	gone := givenRepoStore(t, root, "gone", filepath.Join(t.TempDir(), "deleted"))
+	kept := givenRepoStore(t, root, "kept", t.TempDir())
+	now := time.Now()
+	var out bytes.Buffer
+
+	if err := gcRepoStores(root, Settings{}, now, false, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(gone, deletionMarker))
+	assertNotExists(t, filepath.Join(kept, deletionMarker))
+	if !strings.Contains(out.String(), "flagged gone") || !strings.Contains(out.String(), "git@example.com:me/gone.git") {
+		t.Errorf("expected the missing repository to be flagged with its remote, got:\n%s", out.String())
+	}
+
+	// Still within the grace period
+	if err := gcRepoStores(root, Settings{}, now.Add(24*time.Hour), false, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(gone, "CLAUDE.md"))
+
+	if err := gcRepoStores(root, Settings{}, now.Add(8*24*time.Hour), false, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, gone)
+	assertExists(t, filepath.Join(kept, "CLAUDE.md"))
+	entries := readAuditLog(t, root)
+	if len(entries) != 1 || entries[0].Path != gone || entries[0].MarkedAt == nil {
+		t.Errorf("expected one audit entry for the removed store, got %+v", entries)
+	}
+}
+
+func TestGCRepoStores_DryRunChangesNothing(t *testing.T) {
+	root := t.TempDir()
+	gone := givenRepoStore(t, root, "gone", filepath.Join(t.TempDir(), "deleted"))
+	var out bytes.Buffer
+
+	if err := gcRepoStores(root, Settings{}, time.Now(), true, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(gone, deletionMarker))
+	if !strings.Contains(out.String(), "would flag gone") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestGCRepoStores_RepositoryBackIsUnflagged(t *testing.T) {
+	root := t.TempDir()
+	repo := t.TempDir()
+	store := givenRepoStore(t, root, "app", repo)
+	if err := writeDeletionMarker(filepath.Join(store, deletionMarker), time.Now().Add(-30*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gcRepoStores(root, Settings{}, time.Now(), false, false, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(store, deletionMarker))
+	assertExists(t, filepath.Join(store, "CLAUDE.md"))
+}
+
+func TestGCRepoStores_UnknownRepositoryNeverTouched(t *testing.T) {
+	root := t.TempDir()
+	legacy := filepath.Join(root, "legacy")
+	writeFile(t, filepath.Join(legacy, "CLAUDE.md"), "legacy config")
+
+	if err := gcRepoStores(root, Settings{}, time.Now(), false, false, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(legacy, deletionMarker))
+}
+
+func TestGCRepoStores_DeclinedRestartsGracePeriod(t *testing.T) {
+	orig := confirmRepoStoreDeletionFunc
+	confirmRepoStoreDeletionFunc = func(Settings, string, string) bool { return false }
+	t.Cleanup(func() { confirmRepoStoreDeletionFunc = orig })
+
+	root := t.TempDir()
+	gone := givenRepoStore(t, root, "gone", filepath.Join(t.TempDir(), "deleted"))
+	marker := filepath.Join(gone, deletionMarker)
+	if err := writeDeletionMarker(marker, time.Now().Add(-30*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if err := gcRepoStores(root, Settings{}, now, false, false, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(gone, "CLAUDE.md"))
+	if markedAt, ok := readDeletionMarker(marker); !ok || markedAt.Unix() != now.Unix() {
+		t.Errorf("expected the deletion marker to be reset to now, got %v", markedAt)
+	}
+}
+
+func TestGCRepoStores_OtherHostsRepositoriesNeverFlagged(t *testing.T) {
+	root := t.TempDir()
+	elsewhere := givenRepoStore(t, root, "elsewhere", filepath.Join(t.TempDir(), "only-on-the-laptop"))
+	meta, _ := readStoreMeta(elsewhere)
+	meta.Host = localHost() + "-laptop"
+	writeStoreMeta(elsewhere, meta)
+
+	if err := gcRepoStores(root, Settings{}, time.Now(), false, false, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(elsewhere, deletionMarker))
+}
+
+func TestGCRepoStores_FlagOnlyLeavesExpiredStores(t *testing.T) {
+	root := t.TempDir()
+	gone := givenRepoStore(t, root, "gone", filepath.Join(t.TempDir(), "deleted"))
+	if err := writeDeletionMarker(filepath.Join(gone, deletionMarker), time.Now().Add(-30*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+
+	if err := gcRepoStores(root, Settings{AssumeYes: true}, time.Now(), false, true, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(gone, "CLAUDE.md"))
+	if !strings.Contains(out.String(), "gc --repos") {
+		t.Errorf("expected a pointer to gc --repos, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,260 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// option is a command line option for documentation: its name, the
+// placeholder of its argument if it takes one, and what it does.
+type option struct {
+	name, arg, summary string
+}
+
+// globalOptions returns the options every command takes, as listed in
+// wrapperFlagsUsage.
+func globalOptions() []option {
+	var options []option
+	for _, line := range strings.Split(strings.TrimRight(wrapperFlagsUsage, "\n"), "\n") {
+		spec, summary, _ := strings.Cut(strings.TrimSpace(line), "  ")
+		name, arg, _ := strings.Cut(spec, " ")
+		options = append(options, option{name: name, arg: arg, summary: strings.TrimSpace(summary)})
+	}
+	return options
+}
+
+// usageFlag matches the long options in a command's usage synopsis.
+var usageFlag = regexp.MustCompile(`--[a-z][a-z-]*`)
+
+// commandFlags returns the options named in the usage synopsis of the
+// wrapper command name.
+func commandFlags(name string) []string {
+	var flags []string
+	seen := make(map[string]bool)
+	for _, flag := range usageFlag.FindAllString(commands[name].usage, -1) {
+		if !seen[flag] {
+			seen[flag] = true
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+// runGenDocsCommand implements the hidden `claude-wrapper gen-docs`, run at
+// build time to write the man page and shell completions packages ship.
+func runGenDocsCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("gen-docs")
+	manDir := fs.String("man", "", "write the man page claude-wrapper.1 to this directory")
+	completionsDir := fs.String("completions", "", "write bash, zsh and fish completions to this directory")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if fs.NArg() > 0 || (*manDir == "" && *completionsDir == "") {
+		fs.Usage()
+		return exitUsage, nil
+	}
+
+	var files []docFile
+	if *manDir != "" {
+		files = append(files, docFile{filepath.Join(*manDir, "claude-wrapper.1"), writeManPage})
+	}
+	if *completionsDir != "" {
+		files = append(files,
+			docFile{filepath.Join(*completionsDir, "claude-wrapper.bash"), writeBashCompletion},
+			docFile{filepath.Join(*completionsDir, "_claude-wrapper"), writeZshCompletion},
+			docFile{filepath.Join(*completionsDir, "claude-wrapper.fish"), writeFishCompletion},
+		)
+	}
+	for _, file := range files {
+		if err := file.write(); err != nil {
+			return 1, err
+		}
+		fmt.Println(file.path)
+	}
+	return 0, nil
+}
+
+// docFile is a file gen-docs writes, and what writes its contents.
+type docFile struct {
+	path   string
+	render func(w io.Writer) error
+}
+
+func (f docFile) write() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(f.path)
+	if err != nil {
+		return err
+	}
+	if err := f.render(out); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	return out.Close()
+}
+
+// roffEscape escapes s for use as text in a man page.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// writeManPage writes the claude-wrapper(1) man page to w.
+func writeManPage(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH CLAUDE\\-WRAPPER 1 \"\" \"claude\\-wrapper %s\" \"User Commands\"\n", roffEscape(Version))
+	b.WriteString(".SH NAME\nclaude\\-wrapper \\- run claude with personal files synced per git branch\n")
+	b.WriteString(".SH SYNOPSIS\n")
+	b.WriteString(".B claude\\-wrapper\n[\\fIglobal options\\fR] \\fICOMMAND\\fR [\\fIoptions\\fR] [\\fIargs\\fR]\n.br\n")
+	b.WriteString(".B claude\\-wrapper\n[\\fIglobal options\\fR] [\\fIclaude arguments\\fR]\n")
+	b.WriteString(".SH DESCRIPTION\n")
+	b.WriteString("Runs claude with the personal files git excludes from a repository, such as\n")
+	b.WriteString("CLAUDE.md or .claude/, synced in from storage kept per branch, and saves\n")
+	b.WriteString("them back once claude exits. Arguments that aren't a wrapper command are\n")
+	b.WriteString("passed to claude unchanged; global options are only read ahead of them, up\n")
+	b.WriteString("to a \\fB\\-\\-\\fR separator.\n")
+	b.WriteString(".SH COMMANDS\n")
+	for _, name := range commandNames() {
+		cmd := commands[name]
+		fmt.Fprintf(&b, ".TP\n.B %s", roffEscape(name))
+		if cmd.usage != "" {
+			fmt.Fprintf(&b, " \\fR%s", roffEscape(cmd.usage))
+		}
+		fmt.Fprintf(&b, "\n%s.\n", roffEscape(cmd.summary))
+	}
+	b.WriteString(".PP\nRun \\fBclaude\\-wrapper\\fR \\fICOMMAND\\fR \\fB\\-h\\fR for a command's options.\n")
+	b.WriteString(".SH GLOBAL OPTIONS\n")
+	for _, opt := range globalOptions() {
+		fmt.Fprintf(&b, ".TP\n.B %s", roffEscape(opt.name))
+		if opt.arg != "" {
+			fmt.Fprintf(&b, " \\fI%s\\fR", roffEscape(opt.arg))
+		}
+		fmt.Fprintf(&b, "\n%s.\n", roffEscape(opt.summary))
+	}
+	b.WriteString(".SH FILES\n.TP\n.I ~/.config/claude\\-wrapper/config.toml\nSettings; \\fBCLAUDE_WRAPPER_CONFIG\\fR names another file.\n")
+	b.WriteString(".TP\n.I ~/.workspaces/\nStorage: a store per repository, with a store per branch under it.\n")
+	b.WriteString(".SH SEE ALSO\n.BR claude (1),\n.BR git (1)\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// optionsTakingArgs returns the global options that take an argument.
+func optionsTakingArgs() []string {
+	var names []string
+	for _, opt := range globalOptions() {
+		if opt.arg != "" {
+			names = append(names, opt.name)
+		}
+	}
+	return names
+}
+
+// writeBashCompletion writes the bash completion script to w.
+func writeBashCompletion(w io.Writer) error {
+	var globals []string
+	for _, opt := range globalOptions() {
+		globals = append(globals, opt.name)
+	}
+	var b strings.Builder
+	b.WriteString("# bash completion for claude-wrapper, written by `claude-wrapper gen-docs`\n")
+	b.WriteString("_claude_wrapper() {\n")
+	b.WriteString("\tlocal cur=${COMP_WORDS[COMP_CWORD]} cmd= i\n")
+	b.WriteString("\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("\t\tcase ${COMP_WORDS[i]} in\n")
+	fmt.Fprintf(&b, "\t\t%s) ((i++)) ;;\n", strings.Join(optionsTakingArgs(), "|"))
+	b.WriteString("\t\t--) return ;;\n")
+	b.WriteString("\t\t-*) ;;\n")
+	b.WriteString("\t\t*) cmd=${COMP_WORDS[i]}; break ;;\n")
+	b.WriteString("\t\tesac\n\tdone\n")
+	b.WriteString("\tcase $cmd in\n")
+	fmt.Fprintf(&b, "\t\"\") COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", strings.Join(append(commandNames(), globals...), " "))
+	for _, name := range commandNames() {
+		if flags := commandFlags(name); len(flags) > 0 {
+			fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", name, strings.Join(flags, " "))
+		}
+	}
+	b.WriteString("\tesac\n}\n")
+	b.WriteString("complete -o default -F _claude_wrapper claude-wrapper\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshQuote quotes s for zsh in single quotes.
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeZshCompletion writes the zsh completion function to w.
+func writeZshCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("#compdef claude-wrapper\n")
+	b.WriteString("# zsh completion for claude-wrapper, written by `claude-wrapper gen-docs`\n")
+	b.WriteString("_claude_wrapper() {\n")
+	b.WriteString("\tlocal -a commands options\n\tcommands=(\n")
+	for _, name := range commandNames() {
+		fmt.Fprintf(&b, "\t\t%s\n", zshQuote(name+":"+commands[name].summary))
+	}
+	b.WriteString("\t)\n\toptions=(\n")
+	for _, opt := range globalOptions() {
+		fmt.Fprintf(&b, "\t\t%s\n", zshQuote(opt.name+":"+opt.summary))
+	}
+	b.WriteString("\t)\n")
+	b.WriteString("\tlocal i cmd\n")
+	b.WriteString("\tfor ((i = 2; i < CURRENT; i++)); do\n")
+	b.WriteString("\t\tcase $words[i] in\n")
+	fmt.Fprintf(&b, "\t\t%s) ((i++)) ;;\n", strings.Join(optionsTakingArgs(), "|"))
+	b.WriteString("\t\t--) _files; return ;;\n")
+	b.WriteString("\t\t-*) ;;\n")
+	b.WriteString("\t\t*) cmd=$words[i]; break ;;\n")
+	b.WriteString("\t\tesac\n\tdone\n")
+	b.WriteString("\tif [[ -z $cmd ]]; then\n")
+	b.WriteString("\t\t_describe -t commands command commands\n")
+	b.WriteString("\t\t_describe -t options option options\n")
+	b.WriteString("\t\treturn\n\tfi\n")
+	b.WriteString("\tcase $cmd in\n")
+	for _, name := range commandNames() {
+		if flags := commandFlags(name); len(flags) > 0 {
+			fmt.Fprintf(&b, "\t%s) compadd -- %s; _files ;;\n", name, strings.Join(flags, " "))
+		}
+	}
+	b.WriteString("\t*) _files ;;\n")
+	b.WriteString("\tesac\n}\n")
+	b.WriteString("_claude_wrapper \"$@\"\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fishQuote quotes s for fish in single quotes.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// writeFishCompletion writes the fish completions to w.
+func writeFishCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# fish completions for claude-wrapper, written by `claude-wrapper gen-docs`\n")
+	for _, opt := range globalOptions() {
+		fmt.Fprintf(&b, "complete -c claude-wrapper -l %s", strings.TrimPrefix(opt.name, "--"))
+		if opt.arg != "" {
+			b.WriteString(" -r")
+		}
+		fmt.Fprintf(&b, " -d %s\n", fishQuote(opt.summary))
+	}
+	for _, name := range commandNames() {
+		fmt.Fprintf(&b, "complete -c claude-wrapper -n __fish_use_subcommand -a %s -d %s\n", name, fishQuote(commands[name].summary))
+		for _, flag := range commandFlags(name) {
+			fmt.Fprintf(&b, "complete -c claude-wrapper -n '__fish_seen_subcommand_from %s' -l %s\n", name, strings.TrimPrefix(flag, "--"))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
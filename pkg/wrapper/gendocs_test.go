@@ -0,0 +1,81 @@
+package wrapper
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGlobalOptions_ParsesTheUsage(t *testing.T) {
+	options := globalOptions()
+	if len(options) != strings.Count(wrapperFlagsUsage, "\n") {
+		t.Fatalf("got %d options from:\n%s", len(options), wrapperFlagsUsage)
+	}
+	for _, opt := range options {
+		if opt.name == "--profile" && (opt.arg != "NAME" || opt.summary != "use the store of profile NAME") {
+			t.Errorf("--profile parsed as %+v", opt)
+		}
+		if !strings.HasPrefix(opt.name, "--") || opt.summary == "" {
+			t.Errorf("malformed option %+v", opt)
+		}
+	}
+}
+
+func TestWriteManPage_DocumentsCommandsAndOptions(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeManPage(&out); err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+	for _, want := range []string{".TH CLAUDE\\-WRAPPER 1", ".B list \\fR[\\-\\-diverged]", ".B \\-\\-profile \\fINAME\\fR"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("man page lacks %q", want)
+		}
+	}
+	if strings.Contains(page, "gen\\-docs") {
+		t.Error("man page documents the hidden gen-docs")
+	}
+}
+
+func TestWriteCompletions_NameCommandsAndFlags(t *testing.T) {
+	for name, write := range map[string]func(*bytes.Buffer) error{
+		"bash": func(b *bytes.Buffer) error { return writeBashCompletion(b) },
+		"zsh":  func(b *bytes.Buffer) error { return writeZshCompletion(b) },
+		"fish": func(b *bytes.Buffer) error { return writeFishCompletion(b) },
+	} {
+		var out bytes.Buffer
+		if err := write(&out); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"rebase-stores", "all-deleted", "profile"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s completion lacks %q", name, want)
+			}
+		}
+		for _, line := range strings.Split(out.String(), "\n") {
+			if !strings.HasPrefix(line, "#") && strings.Contains(line, "gen-docs") {
+				t.Errorf("%s completion offers the hidden gen-docs: %s", name, line)
+			}
+		}
+	}
+}
+
+func TestGenDocsCommand_WritesEveryFile(t *testing.T) {
+	dir := t.TempDir()
+	if code, err := runGenDocsCommand(wrapperFlags{}, []string{"--man", filepath.Join(dir, "man"), "--completions", filepath.Join(dir, "completions")}); err != nil || code != 0 {
+		t.Fatalf("gen-docs = %d, %v", code, err)
+	}
+	for _, rel := range []string{"man/claude-wrapper.1", "completions/claude-wrapper.bash", "completions/_claude-wrapper", "completions/claude-wrapper.fish"} {
+		assertExists(t, filepath.Join(dir, rel))
+	}
+	if bash, err := exec.LookPath("bash"); err == nil {
+		if out, err := exec.Command(bash, "-n", filepath.Join(dir, "completions/claude-wrapper.bash")).CombinedOutput(); err != nil {
+			t.Errorf("bash completion doesn't parse: %v\n%s", err, out)
+		}
+	}
+	if code, _ := runGenDocsCommand(wrapperFlags{}, nil); code != exitUsage {
+		t.Errorf("gen-docs without a directory = %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,507 @@
+package wrapper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// gitTrace receives a line for every git command run when --trace-git is set.
+var gitTrace io.Writer
+
+// ErrNotInRepo is returned by LoadConfig, and by VCS.RepoRoot, outside a
+// repository. It is the one failure the wrapper treats as having nothing to
+// sync rather than as something broken.
+var ErrNotInRepo = errors.New("not in a git repository")
+
+// ErrNotOnBranch is returned by LoadConfig, and by VCS.CurrentBranch, when
+// HEAD is detached (or, with jj, no bookmark is nearby).
+var ErrNotOnBranch = errors.New("not on a branch")
+
+// VCS answers the repository questions the wrapper needs. Git is queried
+// with go-git, which needs no git binary, or by shelling out to the CLI;
+// Jujutsu repositories colocated with git are queried with jj (see jj.go).
+type VCS interface {
+	RepoRoot() (string, error)
+	CurrentBranch() (string, error)
+	DefaultBranch() string
+	Branches() (map[string]bool, error)
+	// MergedBranches returns local branches whose tips are reachable from target.
+	MergedBranches(target string) (map[string]bool, error)
+	// BranchTips returns the commit each local branch points to.
+	BranchTips() (map[string]string, error)
+	// IsAncestor reports whether commit is reachable from target.
+	IsAncestor(commit, target string) (bool, error)
+	// TrackedPaths returns which of paths (relative to the repository root)
+	// are tracked files or directories containing tracked files.
+	TrackedPaths(paths []string) (map[string]bool, error)
+	// RemoteURL returns the URL of the remote the default branch is taken
+	// from by preference, or "" without remotes.
+	RemoteURL() string
+}
+
+// gitRepo is the VCS used for the current run. It is selected from
+// settings in run(); the default prefers go-git and falls back to the CLI.
+var gitRepo VCS = newGitBackend(Settings{}, "")
+
+// newVCS returns the VCS selected by the vcs and git_backend settings for
+// the repository containing dir ("" means the current directory).
+func newVCS(s Settings, dir string) VCS {
+	git := newGitBackend(s, dir)
+	switch s.VCS {
+	case "git":
+		return git
+	case "jj":
+		if jjInstalled() {
+			return jjVCS{dir: dir, git: git}
+		}
+		warnJJMissing.Do(func() { warnf("vcs is jj, but jj isn't installed; using git") })
+		return git
+	}
+	if colocatedJJ(dir) && jjInstalled() {
+		return jjVCS{dir: dir, git: git}
+	}
+	return git
+}
+
+// jjInstalled reports whether a jj executable is on PATH. Without one a
+// colocated repository is used through git alone. Replaced in tests.
+var jjInstalled = func() bool {
+	_, err := exec.LookPath("jj")
+	return err == nil
+}
+
+// warnJJMissing warns once per run that vcs = "jj" can't be honored.
+var warnJJMissing sync.Once
+
+// newGitBackend returns the backend named by the git_backend setting for the
+// repository containing dir ("" means the current directory).
+func newGitBackend(s Settings, dir string) VCS {
+	remotes := s.defaultBranchRemotes()
+	cli := cliGit{dir: dir, remotes: remotes}
+	switch s.GitBackend {
+	case "cli":
+		return cli
+	case "go-git":
+		return newGoGit(dir, remotes)
+	}
+	// go-git ignores GIT_DIR/GIT_WORK_TREE, so let git itself resolve them
+	if os.Getenv("GIT_DIR") != "" || os.Getenv("GIT_WORK_TREE") != "" {
+		return cli
+	}
+	return fallbackGit{primary: newGoGit(dir, remotes), fallback: cli}
+}
+
+// gitOutput runs git with args in dir ("" means the current directory) and
+// returns stdout.
+func gitOutput(dir string, args ...string) (string, error) {
+	output, err := runVCSCommand(dir, "git", args...)
+	return string(output), err
+}
+
+// vcsRetryDelays are the pauses before each retry of a VCS query that
+// failed transiently, so a query is tried at most len(vcsRetryDelays)+1
+// times. Replaced in tests.
+var vcsRetryDelays = []time.Duration{100 * time.Millisecond, 400 * time.Millisecond, 1600 * time.Millisecond}
+
+// runVCSCommand runs name with args in dir and returns stdout, retrying
+// with backoff while it fails in ways that may not happen again, as git
+// does now and then on network filesystems or a heavily loaded machine.
+func runVCSCommand(dir, name string, args ...string) ([]byte, error) {
+	command := name + " " + strings.Join(args, " ")
+	for attempt := 0; ; attempt++ {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		start := time.Now()
+		output, err := cmd.Output()
+		traceGit(dir, command, time.Since(start), output, err)
+		if err == nil || attempt == len(vcsRetryDelays) || !transientVCSError(err) {
+			return output, err
+		}
+		time.Sleep(vcsRetryDelays[attempt])
+	}
+}
+
+// transientMessages are fragments of error output from failures caused by
+// the environment rather than the repository.
+var transientMessages = []string{
+	"resource temporarily unavailable",
+	"stale file handle",
+	"input/output error",
+	"interrupted system call",
+	"too many open files",
+	"cannot allocate memory",
+	"connection timed out",
+	".lock': file exists", // Another git process holds the lock
+}
+
+// transientVCSError reports whether a VCS command failing with err is worth
+// retrying: it couldn't be started for lack of resources, was killed by a
+// signal, or reported one of transientMessages.
+func transientVCSError(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ENOMEM) || errors.Is(err, syscall.EINTR)
+	}
+	if !exitErr.Exited() {
+		return true
+	}
+	stderr := strings.ToLower(string(exitErr.Stderr))
+	for _, message := range transientMessages {
+		if strings.Contains(stderr, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// vcsErrorSays reports whether err is a VCS command's failure whose error
+// output mentions message, ignoring case.
+func vcsErrorSays(err error, message string) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && strings.Contains(strings.ToLower(string(exitErr.Stderr)), strings.ToLower(message))
+}
+
+// vcsFailure wraps err, from a VCS query for what, with the command's error
+// output, which for a failed git command says far more than its exit status.
+func vcsFailure(what string, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if stderr := strings.TrimSpace(string(exitErr.Stderr)); stderr != "" {
+			return fmt.Errorf("failed to %s: %w: %s", what, err, stderr)
+		}
+	}
+	return fmt.Errorf("failed to %s: %w", what, err)
+}
+
+// gitStats counts the VCS queries made and the time spent in them, for
+// timing reports.
+var gitStats struct {
+	sync.Mutex
+	calls int
+	total time.Duration
+}
+
+// traceGit records one VCS query in gitStats and traces it if --trace-git
+// is set.
+func traceGit(dir, command string, duration time.Duration, output []byte, err error) {
+	gitStats.Lock()
+	defer gitStats.Unlock()
+	gitStats.calls++
+	gitStats.total += duration
+	// Queries made at once are traced one after the other
+	if gitTrace != nil {
+		traceGitCommand(gitTrace, dir, command, duration, output, err)
+	}
+}
+
+// gitTotals returns the number of VCS queries made so far and the time
+// spent in them.
+func gitTotals() (int, time.Duration) {
+	gitStats.Lock()
+	defer gitStats.Unlock()
+	return gitStats.calls, gitStats.total
+}
+
+// traceGitCommand writes a trace of one git query: the command, the
+// directory it ran in, how long it took and what it returned.
+func traceGitCommand(w io.Writer, dir, command string, duration time.Duration, output []byte, err error) {
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	fmt.Fprintf(w, "trace-git: %s (dir=%s, %s)\n", command, dir, duration.Round(time.Microsecond))
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if line != "" {
+			fmt.Fprintf(w, "trace-git:   > %s\n", line)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(w, "trace-git:   error: %v\n", err)
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			fmt.Fprintf(w, "trace-git:   stderr: %s\n", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+	}
+}
+
+// cliGit implements VCS by running the git command line tool.
+type cliGit struct {
+	dir string
+	// remotes are probed first, in order, for the default branch
+	remotes []string
+}
+
+func (g cliGit) RepoRoot() (string, error) {
+	output, err := gitOutput(g.dir, "rev-parse", "--show-toplevel")
+	if vcsErrorSays(err, "not a git repository") {
+		return "", ErrNotInRepo
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+func (g cliGit) CurrentBranch() (string, error) {
+	output, err := gitOutput(g.dir, "branch", "--show-current")
+	if err != nil {
+		return "", err
+	}
+	branch := strings.TrimSpace(output)
+	if branch == "" {
+		return "", ErrNotOnBranch
+	}
+	return branch, nil
+}
+
+func (g cliGit) DefaultBranch() string {
+	output, err := gitOutput(g.dir, "for-each-ref", "--format=%(refname)%09%(symref)", "refs/remotes/")
+	if err != nil {
+		return "main"
+	}
+	heads := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		ref, target, _ := strings.Cut(line, "\t")
+		if remote, branch, ok := remoteHead(ref, target); ok {
+			heads[remote] = branch
+		}
+	}
+	return pickDefaultBranch(heads, g.remotes)
+}
+
+// remoteHead parses a refs/remotes/<remote>/HEAD symbolic ref pointing at
+// target into the remote's name and default branch.
+func remoteHead(ref, target string) (remote, branch string, ok bool) {
+	remote, ok = strings.CutPrefix(ref, "refs/remotes/")
+	if !ok {
+		return "", "", false
+	}
+	if remote, ok = strings.CutSuffix(remote, "/HEAD"); !ok {
+		return "", "", false
+	}
+	branch, ok = strings.CutPrefix(target, "refs/remotes/"+remote+"/")
+	if !ok || branch == "" {
+		return "", "", false
+	}
+	return remote, branch, true
+}
+
+// pickDefaultBranch chooses the default branch from each remote's HEAD,
+// keyed by remote name. The preferred remotes are tried in order, then the
+// others by name; without any remote HEAD the default branch is "main".
+func pickDefaultBranch(heads map[string]string, preferred []string) string {
+	if remote := pickRemote(heads, preferred); remote != "" {
+		return heads[remote]
+	}
+	return "main"
+}
+
+// pickRemote returns the first of the preferred remotes in byRemote, else
+// the first other remote by name, or "" if byRemote is empty.
+func pickRemote[T any](byRemote map[string]T, preferred []string) string {
+	for _, remote := range preferred {
+		if _, ok := byRemote[remote]; ok {
+			return remote
+		}
+	}
+	others := make([]string, 0, len(byRemote))
+	for remote := range byRemote {
+		others = append(others, remote)
+	}
+	if len(others) == 0 {
+		return ""
+	}
+	sort.Strings(others)
+	return others[0]
+}
+
+func (g cliGit) RemoteURL() string {
+	output, err := gitOutput(g.dir, "config", "--get-regexp", `^remote\..*\.url$`)
+	if err != nil {
+		return "" // Also when there are no remotes
+	}
+	urls := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, url, _ := strings.Cut(line, " ")
+		if name, ok := strings.CutPrefix(key, "remote."); ok {
+			urls[strings.TrimSuffix(name, ".url")] = url
+		}
+	}
+	return urls[pickRemote(urls, g.remotes)]
+}
+
+func (g cliGit) Branches() (map[string]bool, error) {
+	output, err := gitOutput(g.dir, "branch", "--format=%(refname:short)")
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		branch := strings.TrimSpace(scanner.Text())
+		if branch != "" {
+			branches[branch] = true
+		}
+	}
+	return branches, scanner.Err()
+}
+
+func (g cliGit) MergedBranches(target string) (map[string]bool, error) {
+	output, err := gitOutput(g.dir, "branch", "--merged", target, "--format=%(refname:short)")
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		if branch := strings.TrimSpace(line); branch != "" {
+			branches[branch] = true
+		}
+	}
+	return branches, nil
+}
+
+func (g cliGit) BranchTips() (map[string]string, error) {
+	output, err := gitOutput(g.dir, "for-each-ref", "refs/heads", "--format=%(objectname) %(refname:short)")
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if commit, branch, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			tips[branch] = commit
+		}
+	}
+	return tips, nil
+}
+
+func (g cliGit) IsAncestor(commit, target string) (bool, error) {
+	_, err := gitOutput(g.dir, "merge-base", "--is-ancestor", commit, target)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (g cliGit) TrackedPaths(paths []string) (map[string]bool, error) {
+	args := append([]string{"--literal-pathspecs", "ls-files", "-z", "--"}, paths...)
+	output, err := gitOutput(g.dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return matchTracked(strings.Split(output, "\x00"), paths), nil
+}
+
+// matchTracked returns which of paths are among, or contain, the tracked
+// file names.
+func matchTracked(names, paths []string) map[string]bool {
+	tracked := make(map[string]bool)
+	for _, name := range names {
+		for _, path := range paths {
+			if name == path || strings.HasPrefix(name, path+"/") {
+				tracked[path] = true
+			}
+		}
+	}
+	return tracked
+}
+
+// fallbackGit asks primary first and retries with fallback when primary
+// fails, e.g. for repository layouts go-git does not understand.
+type fallbackGit struct {
+	primary  VCS
+	fallback VCS
+}
+
+func (g fallbackGit) RepoRoot() (string, error) {
+	root, err := g.primary.RepoRoot()
+	if err != nil && gitBinaryAvailable() {
+		return g.fallback.RepoRoot()
+	}
+	return root, err
+}
+
+func (g fallbackGit) CurrentBranch() (string, error) {
+	branch, err := g.primary.CurrentBranch()
+	if err != nil && gitBinaryAvailable() {
+		return g.fallback.CurrentBranch()
+	}
+	return branch, err
+}
+
+func (g fallbackGit) DefaultBranch() string {
+	return g.primary.DefaultBranch()
+}
+
+func (g fallbackGit) RemoteURL() string {
+	return g.primary.RemoteURL()
+}
+
+func (g fallbackGit) Branches() (map[string]bool, error) {
+	branches, err := g.primary.Branches()
+	if err != nil && gitBinaryAvailable() {
+		return g.fallback.Branches()
+	}
+	return branches, err
+}
+
+func (g fallbackGit) MergedBranches(target string) (map[string]bool, error) {
+	branches, err := g.primary.MergedBranches(target)
+	if err != nil && gitBinaryAvailable() {
+		return g.fallback.MergedBranches(target)
+	}
+	return branches, err
+}
+
+func (g fallbackGit) BranchTips() (map[string]string, error) {
+	tips, err := g.primary.BranchTips()
+	if err != nil && gitBinaryAvailable() {
+		return g.fallback.BranchTips()
+	}
+	return tips, err
+}
+
+func (g fallbackGit) IsAncestor(commit, target string) (bool, error) {
+	ancestor, err := g.primary.IsAncestor(commit, target)
+	if err != nil && gitBinaryAvailable() {
+		return g.fallback.IsAncestor(commit, target)
+	}
+	return ancestor, err
+}
+
+func (g fallbackGit) TrackedPaths(paths []string) (map[string]bool, error) {
+	tracked, err := g.primary.TrackedPaths(paths)
+	if err != nil && gitBinaryAvailable() {
+		return g.fallback.TrackedPaths(paths)
+	}
+	return tracked, err
+}
+
+// gitBinaryAvailable reports whether a git executable is on PATH.
+func gitBinaryAvailable() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// getAllBranchesFunc is the function used to get git branches. Replaced in tests.
+var getAllBranchesFunc = getAllBranches
+
+func getAllBranches() (map[string]bool, error) {
+	return gitRepo.Branches()
+}
+
+// mergedBranchesFunc lists the branches merged into target. Replaced in
+// tests.
+var mergedBranchesFunc = func(target string) (map[string]bool, error) {
+	return gitRepo.MergedBranches(target)
+}
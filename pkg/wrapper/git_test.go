@@ -0,0 +1,230 @@
+package wrapper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceGitCommand(t *testing.T) {
+	var buf bytes.Buffer
+	traceGitCommand(&buf, "/repo", "git branch --show-current", 1500*time.Microsecond, []byte("main\n"), nil)
+
+	out := buf.String()
+	if !strings.Contains(out, "trace-git: git branch --show-current (dir=/repo") {
+		t.Errorf("trace missing command line: %q", out)
+	}
+	if !strings.Contains(out, "1.5ms") {
+		t.Errorf("trace missing duration: %q", out)
+	}
+	if !strings.Contains(out, "trace-git:   > main") {
+		t.Errorf("trace missing output: %q", out)
+	}
+}
+
+func TestTraceGitCommand_Error(t *testing.T) {
+	var buf bytes.Buffer
+	traceGitCommand(&buf, "", "git rev-parse --show-toplevel", time.Millisecond, nil, errors.New("exit status 128"))
+
+	if !strings.Contains(buf.String(), "error: exit status 128") {
+		t.Errorf("trace missing error: %q", buf.String())
+	}
+}
+
+func TestGitOutput_TracesWhenEnabled(t *testing.T) {
+	var buf bytes.Buffer
+	gitTrace = &buf
+	t.Cleanup(func() { gitTrace = nil })
+
+	if _, err := gitOutput("", "--version"); err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+	if !strings.Contains(buf.String(), "trace-git: git --version") {
+		t.Errorf("expected git invocation to be traced, got %q", buf.String())
+	}
+}
+
+// givenFlakyGit puts a fake git on PATH that fails with stderr, exiting
+// 128, for its first failures runs and then prints "ok". It returns a
+// function counting the runs so far.
+func givenFlakyGit(t *testing.T, failures int, stderr string) func() int {
+	t.Helper()
+	bin := t.TempDir()
+	runs := filepath.Join(bin, "runs")
+	script := fmt.Sprintf("#!/bin/sh\necho run >> %q\nif [ $(wc -l < %q) -le %d ]; then echo %q >&2; exit 128; fi\necho ok\n", runs, runs, failures, stderr)
+	writeFile(t, filepath.Join(bin, "git"), script)
+	if err := os.Chmod(filepath.Join(bin, "git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	orig := vcsRetryDelays
+	vcsRetryDelays = []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond}
+	t.Cleanup(func() { vcsRetryDelays = orig })
+	return func() int {
+		lines, _ := readLines(runs)
+		return len(lines)
+	}
+}
+
+func TestGitOutput_RetriesTransientFailures(t *testing.T) {
+	runs := givenFlakyGit(t, 2, "fatal: Unable to read current working directory: Stale file handle")
+
+	output, err := gitOutput("", "rev-parse", "--show-toplevel")
+	if err != nil || output != "ok\n" {
+		t.Fatalf("gitOutput = %q, %v; want ok after retrying", output, err)
+	}
+	if runs() != 3 {
+		t.Errorf("expected 3 runs, got %d", runs())
+	}
+}
+
+func TestGitOutput_RetriesAreBounded(t *testing.T) {
+	runs := givenFlakyGit(t, 10, "error: Resource temporarily unavailable")
+
+	_, err := gitOutput("", "rev-parse", "--show-toplevel")
+	if err == nil {
+		t.Fatal("expected git to keep failing")
+	}
+	if runs() != 4 {
+		t.Errorf("expected 1 run and 3 retries, got %d runs", runs())
+	}
+	if got := vcsFailure("find the repository root", err).Error(); !strings.Contains(got, "Resource temporarily unavailable") {
+		t.Errorf("vcsFailure = %q, want git's error output", got)
+	}
+}
+
+func TestGitOutput_PermanentFailureNotRetried(t *testing.T) {
+	runs := givenFlakyGit(t, 10, "fatal: not a git repository (or any of the parent directories): .git")
+
+	if _, err := gitOutput("", "rev-parse", "--show-toplevel"); err == nil {
+		t.Fatal("expected git to fail")
+	}
+	if runs() != 1 {
+		t.Errorf("expected no retries, got %d runs", runs())
+	}
+}
+
+func TestCLIGit_NotARepository(t *testing.T) {
+	if !gitBinaryAvailable() {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+	if _, err := (cliGit{dir: t.TempDir()}).RepoRoot(); !errors.Is(err, ErrNotInRepo) {
+		t.Errorf("expected ErrNotInRepo outside a repository, got %v", err)
+	}
+}
+
+// stubGit is a VCS returning canned answers.
+type stubGit struct {
+	root     string
+	branch   string
+	branches map[string]bool
+	err      error
+}
+
+func (g stubGit) RepoRoot() (string, error)          { return g.root, g.err }
+func (g stubGit) CurrentBranch() (string, error)     { return g.branch, g.err }
+func (g stubGit) DefaultBranch() string              { return "main" }
+func (g stubGit) Branches() (map[string]bool, error) { return g.branches, g.err }
+func (g stubGit) MergedBranches(string) (map[string]bool, error) {
+	return g.branches, g.err
+}
+func (g stubGit) BranchTips() (map[string]string, error)         { return nil, g.err }
+func (g stubGit) IsAncestor(string, string) (bool, error)        { return false, g.err }
+func (g stubGit) TrackedPaths([]string) (map[string]bool, error) { return nil, g.err }
+func (g stubGit) RemoteURL() string                              { return "" }
+
+func TestFallbackGit_UsesFallbackWhenPrimaryFails(t *testing.T) {
+	if !gitBinaryAvailable() {
+		t.Skip("fallback is only used when a git binary is available")
+	}
+	g := fallbackGit{
+		primary:  stubGit{err: errors.New("unsupported repository layout")},
+		fallback: stubGit{root: "/repo", branch: "feature", branches: map[string]bool{"feature": true}},
+	}
+
+	root, err := g.RepoRoot()
+	if err != nil || root != "/repo" {
+		t.Errorf("expected fallback repo root, got %q, %v", root, err)
+	}
+	branch, err := g.CurrentBranch()
+	if err != nil || branch != "feature" {
+		t.Errorf("expected fallback branch, got %q, %v", branch, err)
+	}
+	branches, err := g.Branches()
+	if err != nil || !branches["feature"] {
+		t.Errorf("expected fallback branches, got %v, %v", branches, err)
+	}
+}
+
+func TestFallbackGit_PrefersPrimary(t *testing.T) {
+	g := fallbackGit{
+		primary:  stubGit{root: "/primary", branch: "main"},
+		fallback: stubGit{err: errors.New("should not be called")},
+	}
+
+	root, err := g.RepoRoot()
+	if err != nil || root != "/primary" {
+		t.Errorf("expected primary repo root, got %q, %v", root, err)
+	}
+}
+
+func TestNewGitBackend(t *testing.T) {
+	if _, ok := newGitBackend(Settings{GitBackend: "cli"}, "").(cliGit); !ok {
+		t.Error("expected cli backend")
+	}
+	if _, ok := newGitBackend(Settings{GitBackend: "go-git"}, "").(*goGit); !ok {
+		t.Error("expected go-git backend")
+	}
+	if _, ok := newGitBackend(Settings{}, "").(fallbackGit); !ok {
+		t.Error("expected fallback backend by default")
+	}
+}
+
+func TestNewGitBackend_GitDirEnvironmentUsesCLI(t *testing.T) {
+	t.Setenv("GIT_DIR", t.TempDir())
+
+	if _, ok := newGitBackend(Settings{GitBackend: "auto"}, "").(cliGit); !ok {
+		t.Error("expected cli backend when GIT_DIR is set")
+	}
+}
+
+func TestRemoteHead(t *testing.T) {
+	tests := []struct {
+		ref, target    string
+		remote, branch string
+		ok             bool
+	}{
+		{"refs/remotes/origin/HEAD", "refs/remotes/origin/main", "origin", "main", true},
+		{"refs/remotes/team/fork/HEAD", "refs/remotes/team/fork/feature/x", "team/fork", "feature/x", true},
+		{"refs/remotes/origin/main", "", "", "", false},
+		{"refs/remotes/origin/HEAD", "", "", "", false},
+		{"refs/heads/HEAD", "refs/heads/main", "", "", false},
+	}
+	for _, tt := range tests {
+		remote, branch, ok := remoteHead(tt.ref, tt.target)
+		if remote != tt.remote || branch != tt.branch || ok != tt.ok {
+			t.Errorf("remoteHead(%q, %q) = %q, %q, %v; want %q, %q, %v",
+				tt.ref, tt.target, remote, branch, ok, tt.remote, tt.branch, tt.ok)
+		}
+	}
+}
+
+func TestPickDefaultBranch(t *testing.T) {
+	heads := map[string]string{"upstream": "develop", "fork": "trunk"}
+	if got := pickDefaultBranch(heads, []string{"origin", "upstream"}); got != "develop" {
+		t.Errorf("expected the first preferred remote with a HEAD, got %s", got)
+	}
+	if got := pickDefaultBranch(heads, nil); got != "trunk" {
+		t.Errorf("expected remotes by name without a preference, got %s", got)
+	}
+	if got := pickDefaultBranch(nil, []string{"origin"}); got != "main" {
+		t.Errorf("expected main without any remote HEAD, got %s", got)
+	}
+}
@@ -0,0 +1,83 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveGitDir returns the git directory for the working tree at repoRoot.
+// It honors GIT_DIR and follows "gitdir:" files, which is how worktrees and
+// submodules point at a git directory stored elsewhere.
+func resolveGitDir(repoRoot string) (string, error) {
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		return filepath.Abs(gitDir)
+	}
+
+	dotGit := filepath.Join(repoRoot, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", fmt.Errorf("%s: not a gitdir file", dotGit)
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(repoRoot, gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// resolveCommonDir returns the directory holding state shared by all
+// worktrees of a repository, such as info/exclude. For ordinary repositories
+// and submodules that is the git directory itself.
+func resolveCommonDir(gitDir string) string {
+	if commonDir := os.Getenv("GIT_COMMON_DIR"); commonDir != "" {
+		if abs, err := filepath.Abs(commonDir); err == nil {
+			return abs
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	commonDir := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
+
+// excludeFile returns the ignore file holding the wrapper's entries for
+// cfg's working tree: the exclude_file setting, or info/exclude.
+func (cfg *Config) excludeFile() string {
+	if path := expandHome(cfg.Settings.ExcludeFile); path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.RepoRoot, path)
+		}
+		return path
+	}
+	return excludePath(cfg.RepoRoot)
+}
+
+// excludePath returns the info/exclude file git actually reads for the
+// working tree at repoRoot, equivalent to `git rev-parse --git-path info/exclude`.
+func excludePath(repoRoot string) string {
+	gitDir, err := resolveGitDir(repoRoot)
+	if err != nil {
+		return filepath.Join(repoRoot, excludeFile)
+	}
+	return filepath.Join(resolveCommonDir(gitDir), "info", "exclude")
+}
@@ -0,0 +1,104 @@
+package wrapper
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExcludePath_StandardRepo(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+
+	if got := excludePath(repoRoot); got != filepath.Join(repoRoot, excludeFile) {
+		t.Errorf("expected standard exclude path, got %s", got)
+	}
+}
+
+func TestExcludePath_WorktreeGitFile(t *testing.T) {
+	base := t.TempDir()
+	mainGitDir := filepath.Join(base, "main", ".git")
+	worktreeGitDir := filepath.Join(mainGitDir, "worktrees", "feature")
+	worktree := filepath.Join(base, "feature")
+
+	writeFile(t, filepath.Join(worktreeGitDir, "commondir"), "../..\n")
+	writeFile(t, filepath.Join(worktree, ".git"), "gitdir: "+worktreeGitDir+"\n")
+
+	expected := filepath.Join(mainGitDir, "info", "exclude")
+	if got := excludePath(worktree); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestExcludePath_SubmoduleRelativeGitFile(t *testing.T) {
+	base := t.TempDir()
+	submodule := filepath.Join(base, "libs", "sub")
+	writeFile(t, filepath.Join(submodule, ".git"), "gitdir: ../../.git/modules/sub\n")
+
+	expected := filepath.Join(base, ".git", "modules", "sub", "info", "exclude")
+	if got := excludePath(submodule); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestExcludePath_GitDirEnvironment(t *testing.T) {
+	gitDir := t.TempDir()
+	t.Setenv("GIT_DIR", gitDir)
+
+	expected := filepath.Join(gitDir, "info", "exclude")
+	if got := excludePath(t.TempDir()); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestAddToExclude_WritesThroughGitFile(t *testing.T) {
+	base := t.TempDir()
+	gitDir := filepath.Join(base, "modules", "sub")
+	repoRoot := filepath.Join(base, "sub")
+	writeFile(t, filepath.Join(repoRoot, ".git"), "gitdir: "+gitDir+"\n")
+
+	if err := addToExclude(repoRoot, excludePath(repoRoot), "CLAUDE.md"); err != nil {
+		t.Fatalf("addToExclude failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(gitDir, "info", "exclude"), managedBlockStart+"\nCLAUDE.md\n"+managedBlockEnd+"\n")
+
+	// The .git file must not have been replaced by a directory
+	info, err := os.Stat(filepath.Join(repoRoot, ".git"))
+	if err != nil || info.IsDir() {
+		t.Errorf(".git file was clobbered: %v", err)
+	}
+
+	// And readExcludeFile reads the same file back
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "x")
+	items, err := readExcludeFile(repoRoot, excludePath(repoRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0] != "CLAUDE.md" {
+		t.Errorf("expected CLAUDE.md from exclude file, got %v", items)
+	}
+}
+
+func TestExcludePath_AgreesWithGitForWorktrees(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "feature", false)
+
+	worktree := filepath.Join(t.TempDir(), "feature")
+	if out, err := exec.Command("git", "-C", dir, "worktree", "add", worktree, "feature").CombinedOutput(); err != nil {
+		t.Fatalf("git worktree add failed: %v\n%s", err, out)
+	}
+
+	out, err := exec.Command("git", "-C", worktree, "rev-parse", "--path-format=absolute", "--git-path", "info/exclude").Output()
+	if err != nil {
+		t.Skipf("git too old for --path-format: %v", err)
+	}
+	expected, _ := filepath.EvalSymlinks(filepath.Dir(strings.TrimSpace(string(out))))
+	got, _ := filepath.EvalSymlinks(filepath.Dir(excludePath(worktree)))
+	if got != expected {
+		t.Errorf("expected exclude dir %s, got %s", expected, got)
+	}
+}
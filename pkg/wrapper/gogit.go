@@ -0,0 +1,293 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// goGit implements VCS with go-git, reading the repository directly
+// instead of spawning git processes.
+type goGit struct {
+	dir string
+	// remotes are probed first, in order, for the default branch
+	remotes []string
+
+	once sync.Once
+	repo *git.Repository
+	err  error
+}
+
+func newGoGit(dir string, remotes []string) *goGit {
+	return &goGit{dir: dir, remotes: remotes}
+}
+
+// open locates and opens the repository containing g.dir, once.
+func (g *goGit) open() (*git.Repository, error) {
+	g.once.Do(func() {
+		dir := g.dir
+		if dir == "" {
+			if dir, g.err = os.Getwd(); g.err != nil {
+				return
+			}
+		}
+		g.repo, g.err = git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{
+			DetectDotGit:          true,
+			EnableDotGitCommonDir: true,
+		})
+		if errors.Is(g.err, git.ErrRepositoryNotExists) {
+			g.err = ErrNotInRepo
+		}
+	})
+	return g.repo, g.err
+}
+
+// trace records a go-git query in the --trace-git output.
+func (g *goGit) trace(op string, start time.Time, output string, err error) {
+	traceGit(g.dir, "go-git "+op, time.Since(start), []byte(output), err)
+}
+
+func (g *goGit) RepoRoot() (root string, err error) {
+	defer func(start time.Time) { g.trace("repo-root", start, root, err) }(time.Now())
+
+	repo, err := g.open()
+	if err != nil {
+		return "", err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	// Match `git rev-parse --show-toplevel`, which resolves symlinks
+	return filepath.EvalSymlinks(worktree.Filesystem.Root())
+}
+
+func (g *goGit) CurrentBranch() (branch string, err error) {
+	defer func(start time.Time) { g.trace("current-branch", start, branch, err) }(time.Now())
+
+	repo, err := g.open()
+	if err != nil {
+		return "", err
+	}
+	// Read HEAD without resolving it so unborn branches are still reported
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", ErrNotOnBranch
+	}
+	return head.Target().Short(), nil
+}
+
+func (g *goGit) DefaultBranch() (branch string) {
+	var err error
+	defer func(start time.Time) { g.trace("default-branch", start, branch, err) }(time.Now())
+
+	repo, err := g.open()
+	if err != nil {
+		return "main"
+	}
+	refs, err := repo.References()
+	if err != nil {
+		return "main"
+	}
+	heads := make(map[string]string)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.SymbolicReference {
+			return nil
+		}
+		if remote, branch, ok := remoteHead(ref.Name().String(), ref.Target().String()); ok {
+			heads[remote] = branch
+		}
+		return nil
+	})
+	if err != nil {
+		return "main"
+	}
+	return pickDefaultBranch(heads, g.remotes)
+}
+
+func (g *goGit) RemoteURL() (url string) {
+	var err error
+	defer func(start time.Time) { g.trace("remote-url", start, url, err) }(time.Now())
+
+	repo, err := g.open()
+	if err != nil {
+		return ""
+	}
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return ""
+	}
+	urls := make(map[string]string)
+	for _, remote := range remotes {
+		if config := remote.Config(); len(config.URLs) > 0 {
+			urls[config.Name] = config.URLs[0]
+		}
+	}
+	return urls[pickRemote(urls, g.remotes)]
+}
+
+func (g *goGit) Branches() (branches map[string]bool, err error) {
+	defer func(start time.Time) {
+		var names []string
+		for name := range branches {
+			names = append(names, name)
+		}
+		g.trace("branches", start, strings.Join(names, "\n"), err)
+	}(time.Now())
+
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	branches = make(map[string]bool)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		branches[ref.Name().Short()] = true
+		return nil
+	})
+	return branches, err
+}
+
+func (g *goGit) MergedBranches(target string) (merged map[string]bool, err error) {
+	defer func(start time.Time) {
+		var names []string
+		for name := range merged {
+			names = append(names, name)
+		}
+		g.trace("merged-branches "+target, start, strings.Join(names, "\n"), err)
+	}(time.Now())
+
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+	targetHash, err := repo.ResolveRevision(plumbing.Revision(target))
+	if err != nil {
+		return nil, err
+	}
+	targetCommit, err := repo.CommitObject(*targetHash)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	merged = make(map[string]bool)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Hash() == targetCommit.Hash {
+			merged[ref.Name().Short()] = true
+			return nil
+		}
+		commit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		isAncestor, err := commit.IsAncestor(targetCommit)
+		if err != nil {
+			return err
+		}
+		if isAncestor {
+			merged[ref.Name().Short()] = true
+		}
+		return nil
+	})
+	return merged, err
+}
+
+func (g *goGit) BranchTips() (tips map[string]string, err error) {
+	defer func(start time.Time) {
+		var lines []string
+		for branch, commit := range tips {
+			lines = append(lines, commit+" "+branch)
+		}
+		g.trace("branch-tips", start, strings.Join(lines, "\n"), err)
+	}(time.Now())
+
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	tips = make(map[string]string)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tips[ref.Name().Short()] = ref.Hash().String()
+		return nil
+	})
+	return tips, err
+}
+
+func (g *goGit) IsAncestor(commit, target string) (ancestor bool, err error) {
+	defer func(start time.Time) {
+		g.trace("is-ancestor "+commit+" "+target, start, strconv.FormatBool(ancestor), err)
+	}(time.Now())
+
+	repo, err := g.open()
+	if err != nil {
+		return false, err
+	}
+	targetHash, err := repo.ResolveRevision(plumbing.Revision(target))
+	if err != nil {
+		return false, err
+	}
+	targetCommit, err := repo.CommitObject(*targetHash)
+	if err != nil {
+		return false, err
+	}
+	c, err := repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return false, err
+	}
+	if c.Hash == targetCommit.Hash {
+		return true, nil
+	}
+	return c.IsAncestor(targetCommit)
+}
+
+func (g *goGit) TrackedPaths(paths []string) (tracked map[string]bool, err error) {
+	defer func(start time.Time) {
+		var names []string
+		for name := range tracked {
+			names = append(names, name)
+		}
+		g.trace("tracked-paths "+strings.Join(paths, " "), start, strings.Join(names, "\n"), err)
+	}(time.Now())
+
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(idx.Entries))
+	for i, entry := range idx.Entries {
+		names[i] = entry.Name
+	}
+	return matchTracked(names, paths), nil
+}
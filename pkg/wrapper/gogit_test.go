@@ -0,0 +1,327 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// givenGitRepo creates a real repository with one commit on main using
+// go-git, so tests don't depend on a git binary.
+func givenGitRepo(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(dir, "README.md"), "readme")
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add("README.md"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = worktree.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, repo
+}
+
+// createBranch creates a branch at HEAD, optionally checking it out.
+func createBranch(t *testing.T, repo *git.Repository, name string, checkout bool) {
+	t.Helper()
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), head.Hash())
+	if err := repo.Storer.SetReference(ref); err != nil {
+		t.Fatal(err)
+	}
+	if checkout {
+		worktree, err := repo.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := worktree.Checkout(&git.CheckoutOptions{Branch: ref.Name()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGoGit_RepoRootFromSubdirectory(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := newGoGit(sub, nil).RepoRoot()
+	if err != nil {
+		t.Fatalf("RepoRoot failed: %v", err)
+	}
+	if root != dir {
+		t.Errorf("expected repo root %s, got %s", dir, root)
+	}
+}
+
+func TestGoGit_NotARepository(t *testing.T) {
+	if _, err := newGoGit(t.TempDir(), nil).RepoRoot(); !errors.Is(err, ErrNotInRepo) {
+		t.Errorf("expected ErrNotInRepo outside a repository, got %v", err)
+	}
+}
+
+func TestGoGit_CurrentBranch(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "feature/auth", true)
+
+	branch, err := newGoGit(dir, nil).CurrentBranch()
+	if err != nil {
+		t.Fatalf("CurrentBranch failed: %v", err)
+	}
+	if branch != "feature/auth" {
+		t.Errorf("expected feature/auth, got %s", branch)
+	}
+}
+
+func TestGoGit_CurrentBranchDetachedHead(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newGoGit(dir, nil).CurrentBranch(); !errors.Is(err, ErrNotOnBranch) {
+		t.Errorf("expected ErrNotOnBranch on detached HEAD, got %v", err)
+	}
+}
+
+func TestGoGit_DefaultBranch(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+
+	if got := newGoGit(dir, nil).DefaultBranch(); got != "main" {
+		t.Errorf("expected fallback default branch main, got %s", got)
+	}
+
+	originHead := plumbing.NewSymbolicReference(
+		plumbing.NewRemoteHEADReferenceName("origin"),
+		plumbing.NewRemoteReferenceName("origin", "develop"),
+	)
+	if err := repo.Storer.SetReference(originHead); err != nil {
+		t.Fatal(err)
+	}
+	if got := newGoGit(dir, nil).DefaultBranch(); got != "develop" {
+		t.Errorf("expected default branch develop, got %s", got)
+	}
+}
+
+func TestGoGit_Branches(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "feature/a", false)
+	createBranch(t, repo, "bugfix", false)
+
+	branches, err := newGoGit(dir, nil).Branches()
+	if err != nil {
+		t.Fatalf("Branches failed: %v", err)
+	}
+	for _, name := range []string{"main", "feature/a", "bugfix"} {
+		if !branches[name] {
+			t.Errorf("expected branch %s in %v", name, branches)
+		}
+	}
+	if len(branches) != 3 {
+		t.Errorf("expected 3 branches, got %v", branches)
+	}
+}
+
+func TestGoGit_AgreesWithCLI(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "feature/x", true)
+
+	goRoot, err := newGoGit(dir, nil).RepoRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliRoot, err := cliGit{dir: dir}.RepoRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goRoot != cliRoot {
+		t.Errorf("repo roots differ: go-git %s, cli %s", goRoot, cliRoot)
+	}
+
+	goBranch, _ := newGoGit(dir, nil).CurrentBranch()
+	cliBranch, _ := cliGit{dir: dir}.CurrentBranch()
+	if goBranch != cliBranch {
+		t.Errorf("current branches differ: go-git %s, cli %s", goBranch, cliBranch)
+	}
+}
+
+func TestGoGit_MergedBranches(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "merged", false)
+	commitOnBranch(t, repo, dir, "unmerged")
+
+	merged, err := newGoGit(dir, nil).MergedBranches("main")
+	if err != nil {
+		t.Fatalf("MergedBranches failed: %v", err)
+	}
+	if !merged["main"] || !merged["merged"] {
+		t.Errorf("expected main and merged to be merged, got %v", merged)
+	}
+	if merged["unmerged"] {
+		t.Errorf("expected unmerged not to be merged, got %v", merged)
+	}
+}
+
+func TestBranchTipsAndIsAncestor_AgreeAcrossBackends(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "merged", false)
+	commitOnBranch(t, repo, dir, "unmerged")
+
+	backends := []VCS{newGoGit(dir, nil)}
+	if gitBinaryAvailable() {
+		backends = append(backends, cliGit{dir: dir})
+	}
+	for _, g := range backends {
+		tips, err := g.BranchTips()
+		if err != nil {
+			t.Fatalf("%T: BranchTips failed: %v", g, err)
+		}
+		if len(tips["unmerged"]) != 40 || tips["merged"] != tips["main"] {
+			t.Errorf("%T: unexpected tips %v", g, tips)
+		}
+		for branch, want := range map[string]bool{"merged": true, "unmerged": false} {
+			if got, err := g.IsAncestor(tips[branch], "main"); err != nil || got != want {
+				t.Errorf("%T: IsAncestor(%s, main) = %v, %v; want %v", g, branch, got, err, want)
+			}
+		}
+	}
+}
+
+// commitOnBranch creates branch name with one commit of its own and checks
+// main out again.
+func commitOnBranch(t *testing.T, repo *git.Repository, dir, name string) {
+	t.Helper()
+	createBranch(t, repo, name, true)
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, name+".txt"), name)
+	if _, err := worktree.Add(name + ".txt"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = worktree.Commit("work on "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("main")})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setRemoteHead points remote's HEAD at branch, which is created at the
+// repository's HEAD commit.
+func setRemoteHead(t *testing.T, repo *git.Repository, remote, branch string) {
+	t.Helper()
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := []*plumbing.Reference{
+		plumbing.NewHashReference(plumbing.NewRemoteReferenceName(remote, branch), head.Hash()),
+		plumbing.NewSymbolicReference(plumbing.NewRemoteHEADReferenceName(remote), plumbing.NewRemoteReferenceName(remote, branch)),
+	}
+	for _, ref := range refs {
+		if err := repo.Storer.SetReference(ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDefaultBranch_ProbesAllRemotes(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	setRemoteHead(t, repo, "fork", "trunk")
+	setRemoteHead(t, repo, "upstream", "develop")
+
+	tests := []struct {
+		remotes []string
+		want    string
+	}{
+		{Settings{}.defaultBranchRemotes(), "develop"},
+		{[]string{"fork", "upstream"}, "trunk"},
+		{[]string{"origin"}, "trunk"}, // No origin: other remotes by name
+	}
+	for _, tt := range tests {
+		if got := newGoGit(dir, tt.remotes).DefaultBranch(); got != tt.want {
+			t.Errorf("go-git DefaultBranch with remotes %v = %s, want %s", tt.remotes, got, tt.want)
+		}
+		if !gitBinaryAvailable() {
+			continue
+		}
+		if got := (cliGit{dir: dir, remotes: tt.remotes}).DefaultBranch(); got != tt.want {
+			t.Errorf("cli DefaultBranch with remotes %v = %s, want %s", tt.remotes, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteURL_PrefersConfiguredRemotes(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	if got := newGoGit(dir, nil).RemoteURL(); got != "" {
+		t.Errorf("expected no remote URL without remotes, got %q", got)
+	}
+	for name, url := range map[string]string{"fork": "git@example.com:me/app.git", "upstream": "https://example.com/org/app.git"} {
+		if _, err := repo.CreateRemote(&config.RemoteConfig{Name: name, URLs: []string{url}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		remotes []string
+		want    string
+	}{
+		{Settings{}.defaultBranchRemotes(), "https://example.com/org/app.git"},
+		{[]string{"origin"}, "git@example.com:me/app.git"}, // No origin: other remotes by name
+	}
+	for _, tt := range tests {
+		if got := newGoGit(dir, tt.remotes).RemoteURL(); got != tt.want {
+			t.Errorf("go-git RemoteURL with remotes %v = %s, want %s", tt.remotes, got, tt.want)
+		}
+		if !gitBinaryAvailable() {
+			continue
+		}
+		if got := (cliGit{dir: dir, remotes: tt.remotes}).RemoteURL(); got != tt.want {
+			t.Errorf("cli RemoteURL with remotes %v = %s, want %s", tt.remotes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// managedBlockStart and managedBlockEnd delimit the lines the wrapper owns in
+// files it shares with the user: git hooks and the exclude file.
+const (
+	managedBlockStart = "# >>> claude-wrapper >>>"
+	managedBlockEnd   = "# <<< claude-wrapper <<<"
+)
+
+// managedHooks maps each installed git hook to the shell it runs. Hook
+// failures are swallowed so the wrapper can never break a git operation.
+var managedHooks = map[string]string{
+	// $3 is 1 for branch checkouts and 0 for file checkouts
+	"post-checkout": `[ "$3" = "1" ] && %s sync --in --if-branch-changed || true`,
+	"post-merge":    `%s sync --in --if-branch-changed || true`,
+	"post-commit":   `%s sync --in --if-branch-changed || true`,
+}
+
+// runHooksCommand implements `claude-wrapper hooks install|uninstall`.
+func runHooksCommand(flags wrapperFlags, args []string) (int, error) {
+	if len(args) == 0 || (args[0] != "install" && args[0] != "uninstall") {
+		return usageError("hooks")
+	}
+	fs := newFlagSet("hooks")
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	dir := hooksDir(cfg.RepoRoot)
+
+	if args[0] == "uninstall" {
+		if err := uninstallHooks(dir); err != nil {
+			return 1, err
+		}
+		fmt.Printf("removed claude-wrapper hooks from %s\n", dir)
+		return 0, nil
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "claude-wrapper"
+	}
+	if err := installHooks(dir, executable); err != nil {
+		return 1, err
+	}
+	fmt.Printf("installed claude-wrapper hooks in %s\n", dir)
+	return 0, nil
+}
+
+// hooksDir returns the directory git runs hooks from, honoring core.hooksPath.
+func hooksDir(repoRoot string) string {
+	if gitBinaryAvailable() {
+		if output, err := gitOutput(repoRoot, "rev-parse", "--git-path", "hooks"); err == nil {
+			dir := strings.TrimSpace(output)
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(repoRoot, dir)
+			}
+			return dir
+		}
+	}
+	gitDir, err := resolveGitDir(repoRoot)
+	if err != nil {
+		return filepath.Join(repoRoot, ".git", "hooks")
+	}
+	return filepath.Join(resolveCommonDir(gitDir), "hooks")
+}
+
+// installHooks writes the wrapper's block into each managed hook, keeping
+// any existing hook content around it.
+func installHooks(dir, executable string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, line := range managedHooks {
+		block := strings.Join([]string{
+			managedBlockStart,
+			"# Installed by `claude-wrapper hooks install`; refreshes personal files on branch changes.",
+			fmt.Sprintf(line, shellQuote(executable)),
+			managedBlockEnd,
+		}, "\n") + "\n"
+
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		existing := removeHookBlock(string(content))
+		if strings.TrimSpace(existing) == "" {
+			existing = "#!/bin/sh\n"
+		}
+		if !strings.HasSuffix(existing, "\n") {
+			existing += "\n"
+		}
+		if err := os.WriteFile(path, []byte(existing+block), 0755); err != nil {
+			return fmt.Errorf("failed to write %s hook: %w", name, err)
+		}
+		// WriteFile keeps the mode of existing files; hooks must be executable
+		if err := os.Chmod(path, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uninstallHooks removes the wrapper's block from each managed hook, deleting
+// hook files that contained nothing else.
+func uninstallHooks(dir string) error {
+	for name := range managedHooks {
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		remaining := removeHookBlock(string(content))
+		if strings.TrimSpace(remaining) == "#!/bin/sh" || strings.TrimSpace(remaining) == "" {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.WriteFile(path, []byte(remaining), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeHookBlock strips the wrapper's marked block from hook content.
+func removeHookBlock(content string) string {
+	start := strings.Index(content, managedBlockStart)
+	if start < 0 {
+		return content
+	}
+	end := strings.Index(content[start:], managedBlockEnd)
+	if end < 0 {
+		return content
+	}
+	end += start + len(managedBlockEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[:start] + content[end:]
+}
+
+// shellQuote single-quotes s for use in a POSIX shell script.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
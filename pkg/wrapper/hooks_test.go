@@ -0,0 +1,89 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallHooks_CreatesExecutableHooks(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hooks")
+
+	if err := installHooks(dir, "/usr/local/bin/claude-wrapper"); err != nil {
+		t.Fatalf("installHooks failed: %v", err)
+	}
+
+	for name := range managedHooks {
+		path := filepath.Join(dir, name)
+		content := readFileContent(t, path)
+		if !strings.HasPrefix(content, "#!/bin/sh\n") {
+			t.Errorf("%s: missing shebang:\n%s", name, content)
+		}
+		if !strings.Contains(content, "'/usr/local/bin/claude-wrapper' sync --in --if-branch-changed") {
+			t.Errorf("%s: missing sync command:\n%s", name, content)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0111 == 0 {
+			t.Errorf("%s: hook is not executable (%v)", name, info.Mode())
+		}
+	}
+
+	// post-checkout only syncs on branch checkouts
+	if !strings.Contains(readFileContent(t, filepath.Join(dir, "post-checkout")), `[ "$3" = "1" ]`) {
+		t.Error("post-checkout hook should only run for branch checkouts")
+	}
+}
+
+func TestInstallHooks_IsIdempotent(t *testing.T) {
+	dir := t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		if err := installHooks(dir, "claude-wrapper"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := readFileContent(t, filepath.Join(dir, "post-merge"))
+	if n := strings.Count(content, managedBlockStart); n != 1 {
+		t.Errorf("expected 1 wrapper block, got %d:\n%s", n, content)
+	}
+}
+
+func TestInstallHooks_PreservesExistingHooks(t *testing.T) {
+	dir := t.TempDir()
+	existing := "#!/bin/sh\necho running my own hook\n"
+	writeFile(t, filepath.Join(dir, "post-commit"), existing)
+
+	if err := installHooks(dir, "claude-wrapper"); err != nil {
+		t.Fatal(err)
+	}
+	content := readFileContent(t, filepath.Join(dir, "post-commit"))
+	if !strings.HasPrefix(content, existing) {
+		t.Errorf("existing hook content was not preserved:\n%s", content)
+	}
+
+	if err := uninstallHooks(dir); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(dir, "post-commit"), existing)
+	assertNotExists(t, filepath.Join(dir, "post-merge"))
+	assertNotExists(t, filepath.Join(dir, "post-checkout"))
+}
+
+func TestShellQuote(t *testing.T) {
+	if got := shellQuote("/opt/it's here/claude-wrapper"); got != `'/opt/it'\''s here/claude-wrapper'` {
+		t.Errorf("unexpected quoting: %s", got)
+	}
+}
+
+func TestHooksDir_DefaultsToGitHooks(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+
+	if got := hooksDir(dir); got != filepath.Join(dir, ".git", "hooks") {
+		t.Errorf("expected .git/hooks, got %s", got)
+	}
+}
@@ -0,0 +1,63 @@
+package wrapper
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultNeverManage lists dependency and build directories that are never
+// worth copying to storage. Used when never_manage isn't set.
+var defaultNeverManage = []string{"node_modules/", ".venv/", "venv/", "__pycache__/", ".tox/"}
+
+// neverManagePatterns returns the configured never_manage patterns, or the
+// defaults when the setting is absent. An empty list disables them.
+func (s Settings) neverManagePatterns() []string {
+	if s.NeverManage == nil {
+		return defaultNeverManage
+	}
+	return s.NeverManage
+}
+
+// neverManaged reports whether rel, a path relative to the root being
+// synced, must never be copied. As in .gitignore, a pattern without a slash
+// (other than a trailing one) matches a name at any depth, while one with a
+// slash matches the whole path.
+func (s Settings) neverManaged(rel string) bool {
+	for _, pattern := range s.neverManagePatterns() {
+		if matchesPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPattern reports whether rel matches pattern, matched as
+// never_manage patterns are.
+func matchesPattern(pattern, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	pattern = strings.TrimSuffix(pattern, "/")
+	target := rel
+	if !strings.Contains(pattern, "/") {
+		target = path.Base(rel)
+	}
+	ok, _ := path.Match(pattern, target)
+	return ok
+}
+
+// skipper returns a copier skip func for copies out of root. Never-managed
+// paths are skipped and recorded in the report; machine-scoped paths are
+// skipped too when machineScoped is set.
+func (cfg *Config) skipper(root string, machineScoped bool) func(src string) bool {
+	return func(src string) bool {
+		rel, err := filepath.Rel(root, src)
+		if err != nil {
+			return false
+		}
+		if cfg.Settings.neverManaged(rel) {
+			cfg.report.addIgnored(filepath.ToSlash(rel))
+			return true
+		}
+		return machineScoped && cfg.Settings.machineScoped(rel)
+	}
+}
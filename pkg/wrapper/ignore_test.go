@@ -0,0 +1,78 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNeverManaged(t *testing.T) {
+	s := Settings{NeverManage: []string{"node_modules/", "dist/", "build/output", "*.log"}}
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"node_modules", true},
+		{"tools/node_modules", true},
+		{"dist", true},
+		{"build/output", true},
+		{"sub/build/output", false},
+		{"debug.log", true},
+		{"notes.md", false},
+	}
+	for _, tt := range tests {
+		if got := s.neverManaged(tt.rel); got != tt.want {
+			t.Errorf("neverManaged(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestNeverManagePatterns_Defaults(t *testing.T) {
+	if got := (Settings{}).neverManagePatterns(); !reflect.DeepEqual(got, defaultNeverManage) {
+		t.Errorf("expected defaults, got %v", got)
+	}
+	if got := (Settings{NeverManage: []string{}}).neverManagePatterns(); len(got) != 0 {
+		t.Errorf("expected an empty list to disable defaults, got %v", got)
+	}
+	if !(Settings{}).neverManaged("web/node_modules") {
+		t.Error("expected node_modules to be never-managed by default")
+	}
+}
+
+func TestSyncOut_SkipsNeverManagedPaths(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.report = newSyncReport(cfg)
+
+	writeFile(t, filepath.Join(repoRoot, "node_modules", "dep", "index.js"), "dep")
+	writeFile(t, filepath.Join(repoRoot, "scratch", "notes.md"), "notes")
+	writeFile(t, filepath.Join(repoRoot, "scratch", ".venv", "bin", "python"), "python")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "node_modules/\nscratch/\n")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "node_modules"))
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "scratch", ".venv"))
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "scratch", "notes.md"), "notes")
+	if want := []string{"node_modules", "scratch/.venv"}; !reflect.DeepEqual(cfg.report.IgnoredItems, want) {
+		t.Errorf("expected ignored items %v, got %v", want, cfg.report.IgnoredItems)
+	}
+}
+
+func TestSyncIn_SkipsNeverManagedStoreCopies(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.NeverManage = []string{"dist/"}
+
+	writeFile(t, filepath.Join(cfg.StoreLocation, "dist", "app.js"), "stale build")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "config")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertNotExists(t, filepath.Join(repoRoot, "dist"))
+	assertFileContent(t, filepath.Join(repoRoot, "CLAUDE.md"), "config")
+}
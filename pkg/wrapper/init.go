@@ -0,0 +1,220 @@
+package wrapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runInitCommand implements `claude-wrapper init [--all] [PATH...]`: it
+// starts managing files git already ignores, listing them in the exclude
+// file and saving them to storage. Without paths it offers the ignored,
+// untracked items at the repository root to pick from.
+func runInitCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("init")
+	all := fs.Bool("all", false, "manage every ignored item at the repository root without asking")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+
+	var items []string
+	if fs.NArg() > 0 {
+		dir, err := os.Getwd()
+		if err != nil {
+			return 1, err
+		}
+		for _, path := range fs.Args() {
+			item, err := onboardPath(cfg, dir, path)
+			if err != nil {
+				return 1, err
+			}
+			items = append(items, item)
+		}
+	} else {
+		candidates, err := ignoredItems(cfg)
+		if err != nil {
+			return 1, fmt.Errorf("failed to list ignored files: %w", err)
+		}
+		switch {
+		case len(candidates) == 0:
+			fmt.Println("no ignored files to manage")
+			return 0, nil
+		case *all:
+			items = candidates
+		case isInteractive():
+			items = promptOnboard(os.Stdin, os.Stderr, cfg, candidates)
+		default:
+			fmt.Println("ignored files that could be managed:")
+			for _, item := range candidates {
+				fmt.Printf("  %s\n", item)
+			}
+			fmt.Println("pass the ones to manage, or --all")
+			return 0, nil
+		}
+	}
+
+	if err := onboard(cfg, items); err != nil {
+		return 1, err
+	}
+	for _, item := range items {
+		fmt.Printf("%s %s\n", colorize(os.Stdout, toneAdded, "managing"), item)
+	}
+	return 0, nil
+}
+
+// ignoredItems returns the items at the root of cfg's repository that git
+// ignores and the wrapper doesn't manage yet, in name order. Paths
+// never_manage rules out are left out, and so are nested paths: items are
+// saved by their top-level name.
+func ignoredItems(cfg *Config) ([]string, error) {
+	output, err := gitOutput(cfg.RepoRoot, "status", "--porcelain", "-z", "--ignored", "--untracked-files=normal")
+	if err != nil {
+		return nil, err
+	}
+	var items []string
+	for _, record := range strings.Split(output, "\x00") {
+		path, ok := strings.CutPrefix(record, "!! ")
+		if !ok {
+			continue
+		}
+		item := strings.TrimSuffix(path, "/")
+		if item == "" || strings.Contains(item, "/") || isReservedItem(item) ||
+			cfg.Settings.neverManaged(item) || excludeManages(cfg.excludeFile(), item) {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return items, nil
+}
+
+// onboardPath returns the item that path, relative to dir, names if it can
+// be managed: it must exist at the repository root, untracked.
+func onboardPath(cfg *Config, dir, path string) (string, error) {
+	rel, err := repoRelative(cfg.RepoRoot, dir, path)
+	if err != nil {
+		return "", err
+	}
+	item := filepath.ToSlash(rel)
+	if strings.Contains(item, "/") {
+		return "", fmt.Errorf("%s is not at the repository root; personal files are managed by their top-level name", path)
+	}
+	if _, err := os.Lstat(filepath.Join(cfg.RepoRoot, item)); err != nil {
+		return "", err
+	}
+	if isReservedItem(item) {
+		return "", fmt.Errorf("%s is a name the wrapper reserves for its own files", item)
+	}
+	tracked, err := trackedItems(cfg, []string{item})
+	if err != nil {
+		return "", err
+	}
+	if tracked[item] {
+		return "", fmt.Errorf("%s is tracked in git", item)
+	}
+	if _, err := excludeEntry(item); err != nil {
+		return "", fmt.Errorf("can't manage %q: %w", item, err)
+	}
+	return item, nil
+}
+
+// promptOnboard lists candidates with their sizes and asks which to
+// manage, by number, range or "all". Anything else picks none.
+func promptOnboard(in io.Reader, out io.Writer, cfg *Config, candidates []string) []string {
+	fmt.Fprintln(out, "claude-wrapper: these ignored files could be managed as personal files:")
+	for i, item := range candidates {
+		fmt.Fprintf(out, "  %2d) %-30s %10s\n", i+1, item, formatBytes(itemSize(cfg.RepoRoot, item, cfg.Settings)))
+	}
+	fmt.Fprint(out, "Manage which? (e.g. 1 3-4, or all; empty for none) ")
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	picked, ok := parseSelection(answer, len(candidates))
+	if !ok {
+		fmt.Fprintln(out, "Not a selection; managing nothing.")
+		return nil
+	}
+	var items []string
+	for _, i := range picked {
+		items = append(items, candidates[i])
+	}
+	return items
+}
+
+// parseSelection parses answer, space- or comma-separated numbers and
+// ranges from 1 to n or "all", into sorted indexes from 0.
+func parseSelection(answer string, n int) ([]int, bool) {
+	fields := strings.FieldsFunc(strings.ToLower(answer), func(r rune) bool { return r == ' ' || r == ',' || r == '\t' || r == '\n' || r == '\r' })
+	picked := make(map[int]bool)
+	for _, field := range fields {
+		if field == "all" {
+			for i := 0; i < n; i++ {
+				picked[i] = true
+			}
+			continue
+		}
+		from, to, isRange := strings.Cut(field, "-")
+		if !isRange {
+			to = from
+		}
+		first, err1 := strconv.Atoi(from)
+		last, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || first < 1 || last > n || first > last {
+			return nil, false
+		}
+		for i := first; i <= last; i++ {
+			picked[i-1] = true
+		}
+	}
+	indexes := make([]int, 0, len(picked))
+	for i := range picked {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes, true
+}
+
+// onboard starts managing items, which exist at the root of cfg's
+// repository: they are listed in the exclude file and saved to the branch
+// store, as the first sync-out would. Other stored items are left alone.
+func onboard(cfg *Config, items []string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(cfg.StoreLocation, 0755); err != nil {
+		return err
+	}
+	skip := cfg.skipper(cfg.RepoRoot, true)
+	c := &copier{skip: skip, verify: cfg.Settings.VerifyCopies, saved: make(map[string]manifestEntry), preserveOwnership: cfg.Settings.PreserveOwnership}
+	tombstones := readTombstones(cfg.StoreLocation)
+	for _, item := range items {
+		if err := addToExclude(cfg.RepoRoot, cfg.excludeFile(), item); err != nil {
+			return fmt.Errorf("failed to update exclude for %s: %w", item, err)
+		}
+		delete(tombstones, item) // Listed again
+		if err := writeTombstones(cfg.StoreLocation, tombstones); err != nil {
+			return fmt.Errorf("failed to record items pending removal: %w", err)
+		}
+		if tooBig, size := oversized(cfg, item); tooBig {
+			warnf("not saving %s to storage: %s exceeds max_item_size_mb (%s); add it to never_manage or raise the limit",
+				item, formatBytes(size), formatBytes(cfg.Settings.maxItemSize()))
+			continue
+		}
+		if err := c.copyPath(filepath.Join(cfg.RepoRoot, item), filepath.Join(cfg.StoreLocation, item)); err != nil {
+			return fmt.Errorf("failed to copy %s to storage: %w", item, err)
+		}
+	}
+	if err := updateManifest(cfg.StoreLocation, c.saved); err != nil {
+		return fmt.Errorf("failed to update the store's manifest: %w", err)
+	}
+	return nil
+}
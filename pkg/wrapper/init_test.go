@@ -0,0 +1,120 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// givenIgnoredFiles returns a git repository whose .gitignore ignores
+// personal files at its root and below, and a Config for it.
+func givenIgnoredFiles(t *testing.T) *Config {
+	t.Helper()
+	if !gitBinaryAvailable() {
+		t.Skip("git not available")
+	}
+	repoRoot, _ := givenGitRepo(t)
+	writeFile(t, filepath.Join(repoRoot, ".gitignore"), "CLAUDE.md\n.claude/\nnode_modules/\n*.local\n")
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "my config")
+	writeFile(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(repoRoot, "node_modules", "dep.js"), "dep")
+	writeFile(t, filepath.Join(repoRoot, "src", "dev.local"), "nested")
+	writeFile(t, filepath.Join(repoRoot, "src", "main.go"), "package main")
+	writeFile(t, filepath.Join(repoRoot, "untracked.md"), "not ignored")
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	return cfg
+}
+
+func TestIgnoredItems(t *testing.T) {
+	cfg := givenIgnoredFiles(t)
+
+	items, err := ignoredItems(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".claude", "CLAUDE.md"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("ignoredItems = %q, want %q", items, want)
+	}
+
+	// Managed items aren't offered again
+	if err := onboard(cfg, []string{"CLAUDE.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if items, err := ignoredItems(cfg); err != nil || !reflect.DeepEqual(items, []string{".claude"}) {
+		t.Errorf("ignoredItems after onboarding = %q, %v; want [.claude]", items, err)
+	}
+}
+
+func TestOnboard_ListsAndSavesItems(t *testing.T) {
+	cfg := givenIgnoredFiles(t)
+	writeFile(t, filepath.Join(cfg.StoreLocation, "old.md"), "stored earlier")
+	givenTombstoned(t, cfg.StoreLocation, "CLAUDE.md")
+
+	if err := onboard(cfg, []string{"CLAUDE.md", ".claude"}); err != nil {
+		t.Fatal(err)
+	}
+	items, err := readExcludeFile(cfg.RepoRoot, cfg.excludeFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"CLAUDE.md", ".claude"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("managed items = %q, want %q", items, want)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "my config")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "{}")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "old.md"), "stored earlier")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, tombstonesFile))
+	readManifestEntry(t, cfg.StoreLocation, "CLAUDE.md")
+}
+
+func TestOnboardPath(t *testing.T) {
+	cfg := givenIgnoredFiles(t)
+
+	if item, err := onboardPath(cfg, filepath.Join(cfg.RepoRoot, "src"), "../CLAUDE.md"); err != nil || item != "CLAUDE.md" {
+		t.Errorf("onboardPath(../CLAUDE.md) = %q, %v; want CLAUDE.md", item, err)
+	}
+	for _, path := range []string{"README.md", "missing.md", filepath.Join("src", "dev.local"), "../elsewhere"} {
+		if _, err := onboardPath(cfg, cfg.RepoRoot, path); err == nil {
+			t.Errorf("onboardPath(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   []int
+		ok     bool
+	}{
+		{"\n", []int{}, true},
+		{"2\n", []int{1}, true},
+		{"1, 3-4\n", []int{0, 2, 3}, true},
+		{"all\n", []int{0, 1, 2, 3}, true},
+		{"5\n", nil, false},
+		{"3-1\n", nil, false},
+		{"yes\n", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSelection(tt.answer, 4)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("parseSelection(%q) = %v, %v; want %v, %v", tt.answer, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPromptOnboard(t *testing.T) {
+	cfg := givenIgnoredFiles(t)
+	var out bytes.Buffer
+
+	items := promptOnboard(strings.NewReader("2\n"), &out, cfg, []string{".claude", "CLAUDE.md"})
+	if !reflect.DeepEqual(items, []string{"CLAUDE.md"}) {
+		t.Errorf("picked %q, want [CLAUDE.md]", items)
+	}
+	for _, want := range []string{" 1) .claude", " 2) CLAUDE.md", "Manage which?"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected prompt to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
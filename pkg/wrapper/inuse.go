@@ -0,0 +1,45 @@
+package wrapper
+
+import (
+	"hash"
+	"os"
+	"time"
+)
+
+// inUseAttempts is how many times a file another process has locked is
+// tried before giving up on it, waiting inUseRetryDelay longer after each
+// attempt. Replaced in tests.
+var (
+	inUseAttempts   = 5
+	inUseRetryDelay = 200 * time.Millisecond
+)
+
+// copyContentsWaiting is copyContents, tried again with growing pauses
+// while src is in use by another process.
+func copyContentsWaiting(src, dst string, sum hash.Hash) (int64, string, error) {
+	for attempt := 1; ; attempt++ {
+		n, tmp, err := copyContents(src, dst, sum)
+		if err == nil || !fileInUse(err) || attempt >= inUseAttempts {
+			return n, tmp, err
+		}
+		if sum != nil {
+			sum.Reset()
+		}
+		time.Sleep(time.Duration(attempt) * inUseRetryDelay)
+	}
+}
+
+// renameFile is os.Rename. Replaced in tests.
+var renameFile = os.Rename
+
+// renameWaiting moves the finished copy tmp onto dst, tried again with
+// growing pauses while dst is in use by another process.
+func renameWaiting(tmp, dst string) error {
+	for attempt := 1; ; attempt++ {
+		err := renameFile(tmp, dst)
+		if err == nil || !fileInUse(err) || attempt >= inUseAttempts {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * inUseRetryDelay)
+	}
+}
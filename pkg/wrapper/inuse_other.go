@@ -0,0 +1,17 @@
+//go:build !windows
+
+package wrapper
+
+import (
+	"errors"
+	"syscall"
+)
+
+// fileInUse reports whether err means another process has the file open or
+// locked, which it may well not have a moment later. Unix locks are
+// advisory and don't stop a copy, so this is a busy file (EBUSY, also what
+// SMB mounts report for a file a Windows process opened without sharing),
+// an executable being run (ETXTBSY), or a mandatory lock or lease (EAGAIN).
+func fileInUse(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ETXTBSY) || errors.Is(err, syscall.EAGAIN)
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package wrapper
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+// errInUse is what opening a file another process has locked fails with.
+var errInUse error = syscall.EBUSY
+
+func TestFileInUse_BusyErrors(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.EBUSY, syscall.ETXTBSY, syscall.EAGAIN} {
+		if !fileInUse(&os.PathError{Op: "open", Path: "f", Err: errno}) {
+			t.Errorf("%v should count as in use", errno)
+		}
+	}
+	if fileInUse(&os.PathError{Op: "open", Path: "f", Err: syscall.EACCES}) {
+		t.Error("a permission error shouldn't count as in use")
+	}
+}
@@ -0,0 +1,139 @@
+package wrapper
+
+import (
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// givenFileInUse makes copies of files named name fail as if another
+// process had them locked, the first times times (every time if negative).
+func givenFileInUse(t *testing.T, name string, times int) {
+	t.Helper()
+	orig, origDelay := copyContents, inUseRetryDelay
+	inUseRetryDelay = time.Millisecond
+	copyContents = func(src, dst string, sum hash.Hash) (int64, string, error) {
+		if filepath.Base(src) == name && times != 0 {
+			times--
+			return 0, "", &os.PathError{Op: "open", Path: src, Err: errInUse}
+		}
+		return orig(src, dst, sum)
+	}
+	t.Cleanup(func() { copyContents, inUseRetryDelay = orig, origDelay })
+}
+
+// givenDestinationInUse makes moving finished copies onto files named name
+// fail as if another process had them locked, the first times times (every
+// time if negative).
+func givenDestinationInUse(t *testing.T, name string, times int) {
+	t.Helper()
+	orig, origDelay := renameFile, inUseRetryDelay
+	inUseRetryDelay = time.Millisecond
+	renameFile = func(tmp, dst string) error {
+		if filepath.Base(dst) == name && times != 0 {
+			times--
+			return &os.LinkError{Op: "rename", Old: tmp, New: dst, Err: errInUse}
+		}
+		return orig(tmp, dst)
+	}
+	t.Cleanup(func() { renameFile, inUseRetryDelay = orig, origDelay })
+}
+
+func TestFileInUse(t *testing.T) {
+	if !fileInUse(&os.PathError{Op: "open", Path: "f", Err: errInUse}) {
+		t.Errorf("%v should count as in use", errInUse)
+	}
+	if fileInUse(&os.PathError{Op: "open", Path: "f", Err: os.ErrNotExist}) {
+		t.Error("a missing file shouldn't count as in use")
+	}
+}
+
+func TestCopyFile_WaitsForAFileInUse(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "CLAUDE.md"), filepath.Join(dir, "stored.md")
+	writeFile(t, src, "config")
+	givenFileInUse(t, "CLAUDE.md", inUseAttempts-1)
+
+	c := &copier{skipInUse: true}
+	if err := c.copyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dst, "config")
+	if len(c.inUse) != 0 {
+		t.Errorf("in use = %v, want none", c.inUse)
+	}
+}
+
+func TestSyncOut_SkipsFilesStillInUse(t *testing.T) {
+	repoRoot := givenRepo(t)
+	store := t.TempDir()
+	writeFile(t, filepath.Join(store, "locked.log"), "old")
+	writeFile(t, filepath.Join(repoRoot, "locked.log"), "new")
+	writeFile(t, filepath.Join(repoRoot, "notes.md"), "notes")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "locked.log\nnotes.md\n")
+	givenFileInUse(t, "locked.log", -1)
+	diagnostics := captureDiagnostics(t)
+
+	cfg := &Config{RepoRoot: repoRoot, CurrentBranch: "main", DefaultBranch: "main", StoreBase: store, StoreLocation: store}
+	cfg.report = newSyncReport(cfg)
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("sync-out failed on a file in use: %v", err)
+	}
+	assertFileContent(t, filepath.Join(store, "locked.log"), "old")
+	assertFileContent(t, filepath.Join(store, "notes.md"), "notes")
+	if len(cfg.report.InUse) != 1 || cfg.report.InUse[0] != "locked.log" {
+		t.Errorf("report in use = %v, want locked.log", cfg.report.InUse)
+	}
+	if !strings.Contains(diagnostics.String(), "not saving locked.log") {
+		t.Errorf("expected a warning naming locked.log, got %q", diagnostics.String())
+	}
+}
+
+func TestCopyFile_WaitsForADestinationInUse(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "CLAUDE.md"), filepath.Join(dir, "stored.md")
+	writeFile(t, src, "config")
+	writeFile(t, dst, "old")
+	givenDestinationInUse(t, "stored.md", inUseAttempts-1)
+
+	c := &copier{skipInUse: true}
+	if err := c.copyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dst, "config")
+	if len(c.inUse) != 0 {
+		t.Errorf("in use = %v, want none", c.inUse)
+	}
+}
+
+func TestCopyFile_SkipsADestinationStillInUse(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "CLAUDE.md"), filepath.Join(dir, "stored.md")
+	writeFile(t, src, "config")
+	writeFile(t, dst, "old")
+	givenDestinationInUse(t, "stored.md", -1)
+
+	c := &copier{skipInUse: true}
+	if err := c.copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile failed on a destination in use: %v", err)
+	}
+	assertFileContent(t, dst, "old")
+	if len(c.inUse) != 1 || c.inUse[0] != src {
+		t.Errorf("in use = %v, want %s", c.inUse, src)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected the temporary copy to be removed, found %d entries", len(entries))
+	}
+
+	c = &copier{}
+	if err := c.copyFile(src, dst); !fileInUse(err) {
+		t.Errorf("copyFile without skipInUse = %v, want the in use error", err)
+	}
+}
@@ -0,0 +1,15 @@
+package wrapper
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileInUse reports whether err means another process has the file open or
+// locked, which it may well not have a moment later. Windows refuses to
+// open a file another process opened without sharing it, and to read a
+// range another process locked.
+func fileInUse(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
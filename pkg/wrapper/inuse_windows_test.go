@@ -0,0 +1,62 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// errInUse is what opening a file another process has locked fails with.
+var errInUse error = windows.ERROR_SHARING_VIOLATION
+
+// givenLockedFile opens path without sharing it, as editors and virus
+// scanners do, until the test ends.
+func givenLockedFile(t *testing.T, path string) {
+	t.Helper()
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		t.Fatalf("failed to lock %s: %v", path, err)
+	}
+	t.Cleanup(func() { windows.CloseHandle(handle) })
+}
+
+func TestFileInUse_LockedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CLAUDE.md")
+	writeFile(t, path, "config")
+	givenLockedFile(t, path)
+
+	_, err := os.Open(path)
+	if err == nil {
+		t.Fatal("opened a file locked without sharing")
+	}
+	if !fileInUse(err) {
+		t.Errorf("fileInUse(%v) = false, want true", err)
+	}
+}
+
+func TestCopyFile_SkipsALockedFile(t *testing.T) {
+	origDelay := inUseRetryDelay
+	inUseRetryDelay = time.Millisecond
+	t.Cleanup(func() { inUseRetryDelay = origDelay })
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "CLAUDE.md"), filepath.Join(dir, "stored.md")
+	writeFile(t, src, "config")
+	writeFile(t, dst, "old")
+	givenLockedFile(t, src)
+
+	c := &copier{skipInUse: true}
+	if err := c.copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile failed on a locked file: %v", err)
+	}
+	assertFileContent(t, dst, "old")
+	if len(c.inUse) != 1 || c.inUse[0] != src {
+		t.Errorf("in use = %v, want %s", c.inUse, src)
+	}
+}
@@ -0,0 +1,115 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// jjBookmarksTemplate prints the names of a revision's local bookmarks, one
+// per line.
+const jjBookmarksTemplate = `local_bookmarks.map(|b| b.name()).join("\n") ++ "\n"`
+
+// jjOutput runs jj with args in dir ("" means the current directory) and
+// returns stdout. Replaced in tests.
+var jjOutput = func(dir string, args ...string) (string, error) {
+	output, err := runVCSCommand(dir, "jj", args...)
+	return string(output), err
+}
+
+// jjVCS implements VCS for Jujutsu repositories colocated with git. jj
+// leaves git's HEAD detached, so the branch comes from jj's bookmarks;
+// everything else is shared with git, which jj keeps up to date, including
+// the exclude file.
+type jjVCS struct {
+	dir string
+	git VCS
+}
+
+func (j jjVCS) RepoRoot() (string, error) {
+	output, err := jjOutput(j.dir, "root", "--ignore-working-copy")
+	if vcsErrorSays(err, "no jj repo") {
+		return "", ErrNotInRepo
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// CurrentBranch returns the bookmark on the working copy's nearest ancestor
+// that has one (usually the bookmark being worked on, or trunk for a new
+// change). Of several bookmarks the first by name wins.
+func (j jjVCS) CurrentBranch() (string, error) {
+	output, err := jjOutput(j.dir, "log", "--ignore-working-copy", "--no-graph",
+		"-r", "heads(::@ & bookmarks())", "-T", jjBookmarksTemplate)
+	if err != nil {
+		return "", err
+	}
+	var bookmarks []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			bookmarks = append(bookmarks, line)
+		}
+	}
+	if len(bookmarks) == 0 {
+		return "", fmt.Errorf("%w: no bookmark on the working copy or its ancestors", ErrNotOnBranch)
+	}
+	sort.Strings(bookmarks)
+	return bookmarks[0], nil
+}
+
+func (j jjVCS) DefaultBranch() string {
+	return j.git.DefaultBranch()
+}
+
+func (j jjVCS) RemoteURL() string {
+	return j.git.RemoteURL()
+}
+
+func (j jjVCS) Branches() (map[string]bool, error) {
+	return j.git.Branches()
+}
+
+func (j jjVCS) MergedBranches(target string) (map[string]bool, error) {
+	return j.git.MergedBranches(target)
+}
+
+func (j jjVCS) BranchTips() (map[string]string, error) {
+	return j.git.BranchTips()
+}
+
+func (j jjVCS) IsAncestor(commit, target string) (bool, error) {
+	return j.git.IsAncestor(commit, target)
+}
+
+func (j jjVCS) TrackedPaths(paths []string) (map[string]bool, error) {
+	return j.git.TrackedPaths(paths)
+}
+
+// colocatedJJ reports whether dir ("" means the current directory) is in a
+// Jujutsu repository colocated with git: the nearest directory holding
+// .git or .jj holds both. A repository without .git has no exclude file
+// for the wrapper to use, so it doesn't count.
+func colocatedJJ(dir string) bool {
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return false
+		}
+	}
+	for {
+		_, gitErr := os.Stat(filepath.Join(dir, ".git"))
+		info, jjErr := os.Stat(filepath.Join(dir, ".jj"))
+		if gitErr == nil || jjErr == nil {
+			return gitErr == nil && jjErr == nil && info.IsDir()
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
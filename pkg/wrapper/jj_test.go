@@ -0,0 +1,119 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withJJ answers jj commands with output (or err) for the rest of the test
+// and records the commands run.
+func withJJ(t *testing.T, output string, err error) *[]string {
+	t.Helper()
+	var commands []string
+	orig := jjOutput
+	jjOutput = func(dir string, args ...string) (string, error) {
+		commands = append(commands, strings.Join(args, " "))
+		return output, err
+	}
+	t.Cleanup(func() { jjOutput = orig })
+	return &commands
+}
+
+func TestJJ_CurrentBranchFromNearestBookmark(t *testing.T) {
+	commands := withJJ(t, "topic\nfeature\n\n", nil)
+
+	branch, err := jjVCS{git: stubGit{}}.CurrentBranch()
+	if err != nil || branch != "feature" {
+		t.Errorf("CurrentBranch() = %q, %v; want feature", branch, err)
+	}
+	if len(*commands) != 1 || !strings.Contains((*commands)[0], "--ignore-working-copy") {
+		t.Errorf("jj commands = %q, want one that doesn't snapshot the working copy", *commands)
+	}
+}
+
+func TestJJ_CurrentBranchWithoutBookmarks(t *testing.T) {
+	withJJ(t, "\n", nil)
+	if _, err := (jjVCS{git: stubGit{}}).CurrentBranch(); err == nil {
+		t.Error("expected an error without any bookmark")
+	}
+
+	withJJ(t, "", errors.New("jj failed"))
+	if _, err := (jjVCS{git: stubGit{}}).CurrentBranch(); err == nil {
+		t.Error("expected jj's error")
+	}
+}
+
+func TestJJ_DelegatesToGit(t *testing.T) {
+	withJJ(t, "", errors.New("jj should not be run"))
+	j := jjVCS{git: stubGit{branches: map[string]bool{"main": true}}}
+
+	if branches, err := j.Branches(); err != nil || !branches["main"] {
+		t.Errorf("Branches() = %v, %v; want git's branches", branches, err)
+	}
+	if got := j.DefaultBranch(); got != "main" {
+		t.Errorf("DefaultBranch() = %q, want git's main", got)
+	}
+}
+
+func TestColocatedJJ(t *testing.T) {
+	colocated := t.TempDir()
+	for _, name := range []string{".git", ".jj", "src"} {
+		if err := os.Mkdir(filepath.Join(colocated, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plainGit := givenRepo(t)
+	jjOnly := t.TempDir()
+	if err := os.Mkdir(filepath.Join(jjOnly, ".jj"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{
+		colocated:                       true,
+		filepath.Join(colocated, "src"): true,
+		plainGit:                        false,
+		jjOnly:                          false,
+	}
+	for dir, want := range tests {
+		if got := colocatedJJ(dir); got != want {
+			t.Errorf("colocatedJJ(%s) = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+func TestNewVCS(t *testing.T) {
+	colocated := t.TempDir()
+	for _, name := range []string{".git", ".jj"} {
+		if err := os.Mkdir(filepath.Join(colocated, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plainGit := givenRepo(t)
+	orig := jjInstalled
+	t.Cleanup(func() { jjInstalled = orig })
+	jjInstalled = func() bool { return true }
+
+	if _, ok := newVCS(Settings{}, colocated).(jjVCS); !ok {
+		t.Error("expected jj for a colocated repository")
+	}
+	if _, ok := newVCS(Settings{VCS: "git"}, colocated).(jjVCS); ok {
+		t.Error("expected vcs = git to force git")
+	}
+	if _, ok := newVCS(Settings{}, plainGit).(jjVCS); ok {
+		t.Error("expected git for a plain git repository")
+	}
+	if _, ok := newVCS(Settings{VCS: "jj"}, plainGit).(jjVCS); !ok {
+		t.Error("expected vcs = jj to force jj")
+	}
+
+	jjInstalled = func() bool { return false }
+	if _, ok := newVCS(Settings{}, colocated).(jjVCS); ok {
+		t.Error("expected git for a colocated repository without jj installed")
+	}
+	if _, ok := newVCS(Settings{VCS: "jj"}, plainGit).(jjVCS); ok {
+		t.Error("expected git for vcs = jj without jj installed")
+	}
+}
@@ -0,0 +1,331 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journalDir in a store base holds the journals of the repository's last
+// sync-in and last sync-out, each in a directory named for its direction
+// with what that sync replaced or removed, for `claude-wrapper undo`. A
+// session's sync-out so doesn't discard the journal of its sync-in, which
+// is the one that overwrote working tree files. Each sync discards the
+// previous journal of its direction.
+const journalDir = ".last-sync"
+
+// syncDirections are the directions a journal can record.
+var syncDirections = []string{"sync-in", "sync-out"}
+
+// journalOp is one change a sync made. Ops are undone in reverse order.
+type journalOp struct {
+	// Op is "create" (Path didn't exist), "overwrite" (Stash holds what
+	// Path held), "remove" (Stash holds what was removed) or "rename" (From
+	// was renamed to Path).
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Stash string `json:"stash,omitempty"` // Relative to the journal's directory
+	From  string `json:"from,omitempty"`
+}
+
+// syncJournal records the changes of one sync, in the working tree and the
+// store, so they can be undone. Its methods do nothing on a nil journal,
+// except that removals still happen.
+type syncJournal struct {
+	Direction string      `json:"direction"` // "sync-in" or "sync-out"
+	Time      time.Time   `json:"time"`
+	Repo      string      `json:"repo"`
+	Branch    string      `json:"branch"`
+	Ops       []journalOp `json:"ops"`
+
+	dir     string
+	seen    map[string]bool
+	created []string // Paths created whole, whose contents need no record
+}
+
+// beginJournal starts the journal of a sync in direction of cfg's branch,
+// discarding the previous one of that direction. Without a journal the
+// sync just can't be undone, so failing to start one only warns and
+// returns nil.
+func beginJournal(cfg *Config, direction string) *syncJournal {
+	base := filepath.Join(cfg.StoreBase, journalDir)
+	// A journal from before they were kept per direction
+	os.Remove(filepath.Join(base, "journal.json"))
+	os.RemoveAll(filepath.Join(base, "stash"))
+	dir := filepath.Join(base, direction)
+	err := os.RemoveAll(dir)
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(dir, "stash"), 0755)
+	}
+	if err != nil {
+		warnf("not recording this sync for undo: %v", err)
+		return nil
+	}
+	j := &syncJournal{
+		Direction: direction,
+		Time:      time.Now().UTC(),
+		Repo:      cfg.RepoRoot,
+		Branch:    cfg.CurrentBranch,
+		Ops:       []journalOp{},
+		dir:       dir,
+		seen:      make(map[string]bool),
+	}
+	// The wrapper's bookkeeping changes with the files it describes
+	for _, path := range []string{
+		cfg.excludeFile(),
+		filepath.Join(cfg.StoreLocation, manifestFile),
+		filepath.Join(cfg.StoreLocation, tombstonesFile),
+	} {
+		j.record(path, false)
+	}
+	return j
+}
+
+// beforeWrite records path before a copy creates or replaces it: that it
+// didn't exist, or what it held. Directories are only recorded as created.
+func (j *syncJournal) beforeWrite(path string) {
+	j.record(path, true)
+}
+
+// record records path like beforeWrite. Unless link is set, an existing
+// file is copied: only copies replace files by renaming over them, so only
+// for those does a hard link keep the old content.
+func (j *syncJournal) record(path string, link bool) {
+	if j == nil || j.seen[path] || j.inCreated(path) {
+		return
+	}
+	j.seen[path] = true
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		j.Ops = append(j.Ops, journalOp{Op: "create", Path: path})
+		j.created = append(j.created, path)
+		return
+	}
+	if err != nil || info.IsDir() {
+		return
+	}
+	stash := j.nextStash()
+	if !link || os.Link(path, filepath.Join(j.dir, stash)) != nil {
+		if err := copyPath(path, filepath.Join(j.dir, stash)); err != nil {
+			warnf("not recording %s for undo: %v", path, err)
+			return
+		}
+	}
+	j.Ops = append(j.Ops, journalOp{Op: "overwrite", Path: path, Stash: stash})
+}
+
+// inCreated reports whether path is inside something this sync created.
+func (j *syncJournal) inCreated(path string) bool {
+	for _, root := range j.created {
+		if strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextStash names the stash for the next op.
+func (j *syncJournal) nextStash() string {
+	return filepath.Join("stash", strconv.Itoa(len(j.Ops)))
+}
+
+// removeAll removes entry.Path like auditedRemoveAll, moving it into the
+// journal instead of deleting it so undo can put it back.
+func (j *syncJournal) removeAll(storeBase string, entry auditEntry) error {
+	if j == nil {
+		return auditedRemoveAll(storeBase, entry)
+	}
+	return auditedRemoval(storeBase, entry, func(path string) error {
+		if _, err := os.Lstat(path); err != nil {
+			return nil // Nothing to remove, as with os.RemoveAll
+		}
+		stash := j.nextStash()
+		if err := movePath(path, filepath.Join(j.dir, stash)); err != nil {
+			return err
+		}
+		j.Ops = append(j.Ops, journalOp{Op: "remove", Path: path, Stash: stash})
+		return nil
+	})
+}
+
+// renamed records that from was renamed to path.
+func (j *syncJournal) renamed(from, path string) {
+	if j != nil {
+		j.Ops = append(j.Ops, journalOp{Op: "rename", Path: path, From: from})
+	}
+}
+
+// save writes the journal, completing the record of the sync.
+func (j *syncJournal) save() {
+	if j == nil {
+		return
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(j.dir, "journal.json"), append(data, '\n'), 0644)
+	}
+	if err != nil {
+		warnf("failed to record this sync for undo: %v", err)
+	}
+}
+
+// movePath moves src to dst, copying it across filesystems.
+func movePath(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyPath(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// readJournal returns the journal of the last sync in direction of the
+// repository whose store base is storeBase, or false if there is none to
+// undo.
+func readJournal(storeBase, direction string) (*syncJournal, bool) {
+	dir := filepath.Join(storeBase, journalDir, direction)
+	data, err := os.ReadFile(filepath.Join(dir, "journal.json"))
+	if err != nil {
+		return nil, false
+	}
+	j := &syncJournal{dir: dir}
+	if json.Unmarshal(data, j) != nil {
+		return nil, false
+	}
+	return j, true
+}
+
+// latestJournal returns the journal of the most recent sync of the
+// repository whose store base is storeBase, or false if there is none.
+func latestJournal(storeBase string) (*syncJournal, bool) {
+	var latest *syncJournal
+	for _, direction := range syncDirections {
+		if j, ok := readJournal(storeBase, direction); ok && (latest == nil || j.Time.After(latest.Time)) {
+			latest = j
+		}
+	}
+	return latest, latest != nil
+}
+
+// laterChanges returns the paths a sync after j's, in the other direction,
+// changed again: undoing j must leave them as that sync left them.
+func (j *syncJournal) laterChanges(storeBase string) map[string]bool {
+	changed := make(map[string]bool)
+	for _, direction := range syncDirections {
+		later, ok := readJournal(storeBase, direction)
+		if !ok || direction == j.Direction || !later.Time.After(j.Time) {
+			continue
+		}
+		for _, op := range later.Ops {
+			changed[op.Path] = true
+			if op.From != "" {
+				changed[op.From] = true
+			}
+		}
+	}
+	return changed
+}
+
+// undo reverses j's changes, newest first, reporting each to w, and then
+// discards j. Paths a later sync changed again are kept as they are. A
+// change that can't be reversed doesn't stop the others.
+func (j *syncJournal) undo(storeBase string, w io.Writer) error {
+	later := j.laterChanges(storeBase)
+	var errs []error
+	for i := len(j.Ops) - 1; i >= 0; i-- {
+		op := j.Ops[i]
+		if later[op.Path] || (op.From != "" && later[op.From]) {
+			fmt.Fprintf(w, "%s %s: changed again by a later sync\n", colorize(w, toneMuted, "kept"), op.Path)
+			continue
+		}
+		var err error
+		switch op.Op {
+		case "create":
+			err = os.RemoveAll(op.Path)
+			fmt.Fprintf(w, "%s %s\n", colorize(w, toneRemoved, "removed"), op.Path)
+		case "overwrite", "remove":
+			if err = os.RemoveAll(op.Path); err == nil {
+				if err = os.MkdirAll(filepath.Dir(op.Path), 0755); err == nil {
+					err = movePath(filepath.Join(j.dir, op.Stash), op.Path)
+				}
+			}
+			fmt.Fprintf(w, "%s %s\n", colorize(w, toneAdded, "restored"), op.Path)
+		case "rename":
+			err = os.Rename(op.Path, op.From)
+			fmt.Fprintf(w, "%s %s -> %s\n", colorize(w, toneAdded, "renamed"), op.Path, op.From)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to undo %s of %s: %w", op.Op, op.Path, err))
+		}
+	}
+
+	entry := auditEntry{
+		Time:   time.Now(),
+		Action: "undo",
+		Path:   j.Repo,
+		Reason: fmt.Sprintf("undid the %s of %s at %s", j.Direction, j.Branch, j.Time.Format(time.RFC3339)),
+		Repo:   j.Repo,
+		Branch: j.Branch,
+	}
+	if err := appendAuditLog(storeBase, entry); err != nil {
+		warnf("failed to write audit log: %v", err)
+	}
+	if len(errs) == 0 {
+		if err := os.RemoveAll(j.dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runUndoCommand implements `claude-wrapper undo [--in | --out] [--dry-run]`.
+func runUndoCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("undo")
+	in := fs.Bool("in", false, "undo the last sync-in, even if a sync-out came after it")
+	out := fs.Bool("out", false, "undo the last sync-out")
+	dryRun := fs.Bool("dry-run", false, "list what would be undone without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if fs.NArg() > 0 || (*in && *out) {
+		fs.Usage()
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	var j *syncJournal
+	var ok bool
+	switch {
+	case *in:
+		j, ok = readJournal(cfg.StoreBase, "sync-in")
+	case *out:
+		j, ok = readJournal(cfg.StoreBase, "sync-out")
+	default:
+		j, ok = latestJournal(cfg.StoreBase)
+	}
+	if !ok {
+		return 1, fmt.Errorf("no sync to undo")
+	}
+	fmt.Printf("last sync: %s of %s at %s, %d change(s)\n", j.Direction, j.Branch, formatTime(j.Time), len(j.Ops))
+	if *dryRun {
+		for i := len(j.Ops) - 1; i >= 0; i-- {
+			fmt.Printf("  %s %s\n", j.Ops[i].Op, j.Ops[i].Path)
+		}
+		return 0, nil
+	}
+	if err := j.undo(cfg.StoreBase, os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
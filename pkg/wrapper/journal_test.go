@@ -0,0 +1,131 @@
+package wrapper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// undoLastSync undoes the last sync recorded for cfg's repository.
+func undoLastSync(t *testing.T, cfg *Config) {
+	t.Helper()
+	j, ok := latestJournal(cfg.StoreBase)
+	if !ok {
+		t.Fatal("expected a sync to undo")
+	}
+	if err := j.undo(cfg.StoreBase, new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUndo_SyncOut(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "stored")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "old.md"), "stale")
+	givenTombstoned(t, cfg.StoreLocation, "old.md")
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "clobbering edit")
+	writeFile(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	for _, item := range []string{"CLAUDE.md", ".claude"} {
+		if err := addToExclude(repoRoot, cfg.excludeFile(), item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "clobbering edit")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "old.md"))
+
+	undoLastSync(t, cfg)
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "stored")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "old.md"), "stale")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, ".claude"))
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, manifestFile))
+	if _, ok := readTombstones(cfg.StoreLocation)["old.md"]; !ok {
+		t.Error("expected old.md to be pending removal again")
+	}
+	// The working tree is left alone, and there is nothing more to undo
+	assertFileContent(t, filepath.Join(repoRoot, "CLAUDE.md"), "clobbering edit")
+	if _, ok := latestJournal(cfg.StoreBase); ok {
+		t.Error("expected the journal to be discarded")
+	}
+}
+
+func TestUndo_SyncIn(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "stored")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "prompts", "review.md"), "prompt")
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "unsaved work")
+	exclude, err := os.ReadFile(cfg.excludeFile())
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(repoRoot, "CLAUDE.md"), "stored")
+
+	undoLastSync(t, cfg)
+	assertFileContent(t, filepath.Join(repoRoot, "CLAUDE.md"), "unsaved work")
+	assertNotExists(t, filepath.Join(repoRoot, "prompts"))
+	if exclude == nil {
+		assertNotExists(t, cfg.excludeFile())
+	} else {
+		assertFileContent(t, cfg.excludeFile(), string(exclude))
+	}
+}
+
+func TestUndo_BranchSwitchRestoresSwitchedOutItems(t *testing.T) {
+	repoRoot := givenRepo(t)
+	prev, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	writeFile(t, filepath.Join(prev.StoreLocation, "feature-notes.md"), "feature only")
+	if err := syncIn(prev); err != nil {
+		t.Fatal(err)
+	}
+
+	next := prev.forBranch("main")
+	if err := switchStores(prev, next); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(repoRoot, "feature-notes.md"))
+
+	undoLastSync(t, next)
+	assertFileContent(t, filepath.Join(repoRoot, "feature-notes.md"), "feature only")
+}
+
+func TestUndo_SyncInAfterSyncOut(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "stored")
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "unsaved work")
+
+	// A session: sync-in overwrites the unsaved work, sync-out stores it
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(repoRoot, "notes.md"), "session notes")
+	if err := addToExclude(repoRoot, cfg.excludeFile(), "notes.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	j, ok := readJournal(cfg.StoreBase, "sync-in")
+	if !ok {
+		t.Fatal("expected the sync-in's journal to be kept after the sync-out")
+	}
+	if err := j.undo(cfg.StoreBase, new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(repoRoot, "CLAUDE.md"), "unsaved work")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "notes.md"), "session notes")
+	if _, ok := readJournal(cfg.StoreBase, "sync-out"); !ok {
+		t.Error("expected the sync-out's journal to be left to undo")
+	}
+}
@@ -0,0 +1,156 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+)
+
+// Where a branch store file came from, as listed by `list`.
+const (
+	provenanceSeeded  = "seeded"  // Still the default branch's copy
+	provenanceChanged = "changed" // Seeded, then changed on the branch
+	provenanceOwn     = "own"     // Not from the default branch's store
+)
+
+// listedFile is one file of a branch store.
+type listedFile struct {
+	branch     string
+	rel        string
+	size       int64
+	provenance string
+}
+
+// listOptions are the flags of `list`.
+type listOptions struct {
+	diverged bool // Leave out seeded files
+	diff     bool // Follow the table with diffs against the default branch's copies
+}
+
+// runListCommand implements `claude-wrapper list [--diverged] [--diff]
+// [--all | branch...]`.
+func runListCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("list")
+	var opts listOptions
+	fs.BoolVar(&opts.diverged, "diverged", false, "list only the files that differ from the default branch's store")
+	fs.BoolVar(&opts.diff, "diff", false, "show how each diverged file differs from the default branch's copy")
+	all := fs.Bool("all", false, "list every branch store, not just the current branch's")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	branches := fs.Args()
+	if *all || len(branches) > 0 {
+		stored, err := storedBranches(cfg.StoreBase)
+		if err != nil {
+			return 1, fmt.Errorf("failed to list branch stores: %w", err)
+		}
+		for _, branch := range branches {
+			if !stored[branch] {
+				return 1, fmt.Errorf("no stored files for branch %q", branch)
+			}
+		}
+		if *all {
+			branches = branches[:0]
+			for branch := range stored {
+				branches = append(branches, branch)
+			}
+			sort.Strings(branches)
+		}
+	} else {
+		branches = []string{cfg.CurrentBranch}
+	}
+
+	var files []listedFile
+	for _, branch := range branches {
+		branchFiles, err := collectStoreFiles(cfg.forBranch(branch).StoreLocation)
+		if err != nil {
+			return 1, fmt.Errorf("failed to list %s's store: %w", branch, err)
+		}
+		for i := range branchFiles {
+			branchFiles[i].branch = branch
+		}
+		files = append(files, branchFiles...)
+	}
+	if err := printStoreFiles(cfg, files, len(branches), opts, os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// collectStoreFiles lists the files of the branch store at store, in path
+// order, with where each came from according to its manifest.
+func collectStoreFiles(store string) ([]listedFile, error) {
+	m, err := readManifest(store)
+	if err != nil {
+		return nil, err
+	}
+	var files []listedFile
+	err = walkStoreFiles(store, func(rel string) error {
+		file := listedFile{rel: filepath.ToSlash(rel), provenance: provenanceOwn}
+		if info, err := os.Stat(filepath.Join(store, rel)); err == nil {
+			file.size = info.Size()
+		}
+		if !m.diverged(rel) {
+			file.provenance = provenanceSeeded
+		} else if _, err := os.Stat(filepath.Join(store, defaultBaseDir, rel)); err == nil {
+			file.provenance = provenanceChanged
+		}
+		files = append(files, file)
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+	return files, err
+}
+
+// printStoreFiles writes files, from the stores of the given number of
+// branches, as a table, then with opts.diff the diffs of the diverged ones
+// against the default branch's current copies.
+func printStoreFiles(cfg *Config, files []listedFile, branches int, opts listOptions, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BRANCH\tPATH\tSIZE\tFROM")
+	var diverged []listedFile
+	for _, file := range files {
+		if file.provenance == provenanceSeeded {
+			if opts.diverged {
+				continue
+			}
+		} else {
+			diverged = append(diverged, file)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", file.branch, file.rel, formatBytes(file.size), file.provenance)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%d file(s) in %d branch store(s), %d diverged from the %s branch's\n", len(files), branches, len(diverged), cfg.DefaultBranch)
+
+	if !opts.diff {
+		return nil
+	}
+	for _, file := range diverged {
+		rel := filepath.FromSlash(file.rel)
+		defaults, err := os.ReadFile(filepath.Join(cfg.StoreBase, rel))
+		fromName := cfg.DefaultBranch + "/" + file.rel
+		if os.IsNotExist(err) {
+			fromName = "/dev/null"
+		} else if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Join(cfg.forBranch(file.branch).StoreLocation, rel))
+		if err != nil {
+			return err
+		}
+		if diff := unifiedDiff(fromName, file.branch+"/"+file.rel, defaults, data); diff != "" {
+			fmt.Fprintf(w, "\n%s", diff)
+		}
+	}
+	return nil
+}
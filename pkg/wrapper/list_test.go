@@ -0,0 +1,55 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectStoreFiles_Provenance(t *testing.T) {
+	cfg := givenSeededBranch(t)
+	writeFile(t, filepath.Join(cfg.RepoRoot, "notes.md"), "feature notes")
+	writeFile(t, filepath.Join(cfg.RepoRoot, "own.md"), "branch only")
+	if err := addToExclude(cfg.RepoRoot, cfg.excludeFile(), "own.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := collectStoreFiles(cfg.StoreLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, file := range files {
+		got[file.rel] = file.provenance
+	}
+	want := map[string]string{"CLAUDE.md": provenanceSeeded, "notes.md": provenanceChanged, "own.md": provenanceOwn}
+	for rel, provenance := range want {
+		if got[rel] != provenance {
+			t.Errorf("%s is %q, want %q (all: %v)", rel, got[rel], provenance, got)
+		}
+	}
+
+	for i := range files {
+		files[i].branch = "feature"
+	}
+	var out bytes.Buffer
+	if err := printStoreFiles(cfg, files, 1, listOptions{diverged: true, diff: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"3 file(s) in 1 branch store(s), 2 diverged",
+		"--- main/notes.md\n+++ feature/notes.md\n@@ -1 +1 @@\n-notes v1\n",
+		"--- /dev/null\n+++ feature/own.md\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected --diverged --diff output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "CLAUDE.md") {
+		t.Errorf("expected --diverged to leave out the seeded CLAUDE.md, got:\n%s", out.String())
+	}
+}
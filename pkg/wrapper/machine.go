@@ -0,0 +1,156 @@
+package wrapper
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// machinesDir holds per-host copies of machine-scoped paths, under each
+// branch store: <store>/machines/<host>/<path>.
+const machinesDir = "machines"
+
+// hostnameFunc names the machine-scoped sub-store. Replaced in tests.
+var hostnameFunc = os.Hostname
+
+// machineScoped reports whether rel, a path relative to the repository root,
+// matches one of the machine_scoped patterns.
+func (s Settings) machineScoped(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range s.MachineScoped {
+		if ok, _ := path.Match(strings.TrimSuffix(pattern, "/"), rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// machineStore returns this machine's sub-store of cfg's branch store.
+func machineStore(cfg *Config) (string, error) {
+	host, err := hostnameFunc()
+	if err != nil {
+		return "", fmt.Errorf("failed to get hostname: %w", err)
+	}
+	return filepath.Join(cfg.StoreLocation, machinesDir, host), nil
+}
+
+// walkMachineScoped calls fn with the root-relative path of every
+// machine-scoped file or directory inside root's items. Matching directories
+// are not descended into. Errors from fn don't stop the walk.
+func walkMachineScoped(root string, items []string, s Settings, fn func(rel string) error) error {
+	var errs []error
+	for _, item := range items {
+		err := filepath.WalkDir(filepath.Join(root, item), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			if !s.machineScoped(rel) {
+				return nil
+			}
+			if err := fn(rel); err != nil {
+				errs = append(errs, err)
+			}
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// syncOutMachine saves the machine-scoped paths among the working tree's
+// excluded items to this machine's sub-store. Copies left in the shared part
+// of the branch store (from before a pattern was configured) are moved into
+// this machine's sub-store, or dropped if it already has its own copy.
+func syncOutMachine(cfg *Config, excludeItems []string, c *copier) error {
+	if len(cfg.Settings.MachineScoped) == 0 {
+		return nil
+	}
+	store, err := machineStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	// c skips machine-scoped paths, so copy them with a copier of their own
+	mc := &copier{skip: cfg.skipper(cfg.RepoRoot, false), progress: cfg.progress, verify: c.verify, saved: c.saved, preserveOwnership: c.preserveOwnership}
+	err = walkMachineScoped(cfg.RepoRoot, excludeItems, cfg.Settings, func(rel string) error {
+		dst := filepath.Join(store, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return mc.copyPath(filepath.Join(cfg.RepoRoot, rel), dst)
+	})
+	c.files += mc.files
+	c.bytes += mc.bytes
+	if err != nil {
+		return fmt.Errorf("failed to save machine-scoped files: %w", err)
+	}
+
+	items, err := listDir(cfg.StoreLocation)
+	if err != nil {
+		return err
+	}
+	return walkMachineScoped(cfg.StoreLocation, filterItems(items), cfg.Settings, func(rel string) error {
+		src := filepath.Join(cfg.StoreLocation, rel)
+		dst := filepath.Join(store, rel)
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
+			if err := os.Rename(src, dst); err != nil {
+				return err
+			}
+			cfg.journal.renamed(src, dst)
+			return nil
+		}
+		return cfg.journal.removeAll(cfg.StoreBase, auditEntry{
+			Path:   src,
+			Reason: "machine-scoped path superseded by " + dst,
+			Repo:   cfg.RepoRoot,
+			Branch: cfg.CurrentBranch,
+		})
+	})
+}
+
+// syncInMachine copies this machine's sub-store over the working tree, after
+// the branch's shared items.
+func syncInMachine(cfg *Config, c *copier) error {
+	if len(cfg.Settings.MachineScoped) == 0 {
+		return nil
+	}
+	store, err := machineStore(cfg)
+	if err != nil {
+		return err
+	}
+	items, err := listDir(store)
+	if err != nil {
+		return err
+	}
+	c.skip = cfg.skipper(store, false)
+
+	var errs []error
+	for _, item := range items {
+		if err := c.copyPath(filepath.Join(store, item), filepath.Join(cfg.RepoRoot, item)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to copy machine-scoped %s: %w", item, err))
+		}
+		if err := addToExclude(cfg.RepoRoot, cfg.excludeFile(), item); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update exclude for %s: %w", item, err))
+		}
+	}
+	return errors.Join(errs...)
+}
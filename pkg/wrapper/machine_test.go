@@ -0,0 +1,107 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// onHost makes the machine-scoped sub-store use host for the rest of the test.
+func onHost(t *testing.T, host string) {
+	t.Helper()
+	orig := hostnameFunc
+	hostnameFunc = func() (string, error) { return host, nil }
+	t.Cleanup(func() { hostnameFunc = orig })
+}
+
+func TestMachineScoped(t *testing.T) {
+	s := Settings{MachineScoped: []string{".claude/cache/", "*.local"}}
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{".claude/cache", true},
+		{".claude", false},
+		{".claude/settings.json", false},
+		{"notes.local", true},
+		{"dir/notes.local", false},
+	}
+	for _, tt := range tests {
+		if got := s.machineScoped(tt.rel); got != tt.want {
+			t.Errorf("machineScoped(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestSyncOut_MachineScopedPathsGoToHostStore(t *testing.T) {
+	onHost(t, "laptop")
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	cfg.Settings.MachineScoped = []string{".claude/cache/"}
+
+	writeFile(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(repoRoot, ".claude", "cache", "index"), "laptop cache")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), ".claude\n")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "{}")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, ".claude", "cache"))
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, machinesDir, "laptop", ".claude", "cache", "index"), "laptop cache")
+}
+
+func TestSyncOut_MovesPreviouslySharedCopyToHostStore(t *testing.T) {
+	onHost(t, "laptop")
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.MachineScoped = []string{".claude/cache/"}
+
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "cache", "index"), "old shared cache")
+	writeFile(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), ".claude\n")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, ".claude", "cache"))
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, machinesDir, "laptop", ".claude", "cache", "index"), "old shared cache")
+}
+
+func TestSyncIn_OverlaysOnlyThisHostsStore(t *testing.T) {
+	onHost(t, "desktop")
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.MachineScoped = []string{".claude/cache/"}
+
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(cfg.StoreLocation, machinesDir, "laptop", ".claude", "cache", "index"), "laptop cache")
+	writeFile(t, filepath.Join(cfg.StoreLocation, machinesDir, "desktop", ".claude", "cache", "index"), "desktop cache")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	assertFileContent(t, filepath.Join(repoRoot, ".claude", "cache", "index"), "desktop cache")
+	assertNotExists(t, filepath.Join(repoRoot, machinesDir))
+}
+
+func TestRemoveSwitchedOutItems_IncludesMachineOnlyItems(t *testing.T) {
+	onHost(t, "laptop")
+	repoRoot := givenRepo(t)
+	prev, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature-a"})
+	prev.Settings.MachineScoped = []string{"cache"}
+	next := prev.forBranch("feature-b")
+
+	writeFile(t, filepath.Join(prev.StoreLocation, machinesDir, "laptop", "cache", "x"), "a cache")
+	writeFile(t, filepath.Join(next.StoreLocation, "notes.md"), "b notes")
+	writeFile(t, filepath.Join(repoRoot, "cache", "x"), "a cache")
+
+	if err := removeSwitchedOutItems(prev, next); err != nil {
+		t.Fatalf("removeSwitchedOutItems failed: %v", err)
+	}
+
+	assertNotExists(t, filepath.Join(repoRoot, "cache"))
+}
@@ -0,0 +1,141 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// manifestFile in a branch store records the files sync-out saved there.
+const manifestFile = ".manifest.json"
+
+// manifest lists a branch store's files, keyed by manifestKey of their
+// path relative to the store.
+type manifest struct {
+	Files map[string]manifestEntry `json:"files"`
+}
+
+// manifestEntry describes a file as it was when sync-out last saved it.
+// Seeded files are still identical to the default branch's copy the branch
+// store was seeded with, or last brought up to date with.
+type manifestEntry struct {
+	Size   int64     `json:"size"`
+	SHA256 string    `json:"sha256"`
+	Saved  time.Time `json:"saved"`
+	Seeded bool      `json:"seeded,omitempty"`
+}
+
+// manifestKey returns the manifest key of the store-relative path rel: its
+// slash-separated form, or that quoted as a Go string if it isn't valid
+// UTF-8 (which JSON would mangle) or itself starts with a quote.
+func manifestKey(rel string) string {
+	key := filepath.ToSlash(rel)
+	if !utf8.ValidString(key) || strings.HasPrefix(key, `"`) {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+// manifestPath returns the store-relative path a manifest key stands for.
+func manifestPath(key string) string {
+	if strings.HasPrefix(key, `"`) {
+		if unquoted, err := strconv.Unquote(key); err == nil {
+			key = unquoted
+		}
+	}
+	return filepath.FromSlash(key)
+}
+
+// readManifest returns the manifest of the branch store at store, which is
+// empty if none has been written yet.
+func readManifest(store string) (*manifest, error) {
+	m := &manifest{Files: make(map[string]manifestEntry)}
+	data, err := os.ReadFile(filepath.Join(store, manifestFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]manifestEntry)
+	}
+	return m, nil
+}
+
+// updateManifest records saved, the files just copied (keyed by their
+// absolute paths), in the manifest of the branch store at store, drops
+// entries for files no longer in it, and marks which are still seeded.
+// Files outside the store are ignored.
+func updateManifest(store string, saved map[string]manifestEntry) error {
+	m, err := readManifest(store)
+	if err != nil {
+		return err
+	}
+	for path, entry := range saved {
+		rel, err := filepath.Rel(store, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		m.Files[manifestKey(rel)] = entry
+	}
+	for key := range m.Files {
+		if _, err := os.Lstat(filepath.Join(store, manifestPath(key))); os.IsNotExist(err) {
+			delete(m.Files, key)
+		}
+	}
+	m.markSeeded(store)
+	return m.write(store)
+}
+
+// markSeeded sets Seeded on the entries of files identical to their
+// default branch base in the branch store at store, and clears it on the
+// rest.
+func (m *manifest) markSeeded(store string) {
+	for key, entry := range m.Files {
+		base, err := fileSHA256(filepath.Join(store, defaultBaseDir, manifestPath(key)))
+		entry.Seeded = err == nil && base == entry.SHA256
+		m.Files[key] = entry
+	}
+}
+
+// diverged reports whether the file at the store-relative path rel differs
+// from what the branch store was seeded with: it has no seeded entry.
+func (m *manifest) diverged(rel string) bool {
+	return !m.Files[manifestKey(rel)].Seeded
+}
+
+// rename moves the entries of the stored item old, and of everything in
+// it, to the item name.
+func (m *manifest) rename(old, name string) {
+	prefix := filepath.ToSlash(old)
+	for key, entry := range m.Files {
+		rel := filepath.ToSlash(manifestPath(key))
+		if rel != prefix && !strings.HasPrefix(rel, prefix+"/") {
+			continue
+		}
+		delete(m.Files, key)
+		m.Files[manifestKey(filepath.Join(name, strings.TrimPrefix(rel, prefix)))] = entry
+	}
+}
+
+// write replaces the manifest of the branch store at store with m.
+func (m *manifest) write(store string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(store, manifestFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,95 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSyncOut_RecordsManifest(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.VerifyCopies = true
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "hello")
+	writeFile(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "CLAUDE.md\n.claude/\n")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	m, err := readManifest(cfg.StoreLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := m.Files["CLAUDE.md"]
+	// sha256("hello")
+	if !ok || entry.Size != 5 || entry.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" || entry.Saved.IsZero() {
+		t.Errorf("unexpected manifest entry for CLAUDE.md: %+v", entry)
+	}
+	if _, ok := m.Files[".claude/settings.json"]; !ok {
+		t.Errorf("expected nested files in the manifest, got %v", m.Files)
+	}
+
+	// Items dropped from storage leave the manifest too
+	if err := os.Remove(filepath.Join(repoRoot, "CLAUDE.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(repoRoot, excludeFile), ".claude/\n")
+	givenTombstoned(t, cfg.StoreLocation, "CLAUDE.md")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ = readManifest(cfg.StoreLocation); len(m.Files) != 1 {
+		t.Errorf("expected only .claude/settings.json left, got %v", m.Files)
+	}
+}
+
+func TestUpdateManifest_IgnoresFilesOutsideStore(t *testing.T) {
+	store := t.TempDir()
+	writeFile(t, filepath.Join(store, "CLAUDE.md"), "x")
+	saved := map[string]manifestEntry{
+		filepath.Join(store, "CLAUDE.md"):                  {Size: 1},
+		filepath.Join(filepath.Dir(store), "elsewhere.md"): {Size: 1},
+	}
+	if err := updateManifest(store, saved); err != nil {
+		t.Fatal(err)
+	}
+	m, err := readManifest(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 {
+		t.Errorf("expected only the store's own file, got %v", m.Files)
+	}
+}
+
+func TestManifestKey_RoundTrips(t *testing.T) {
+	for _, rel := range []string{"CLAUDE.md", "caf\xe9.md", `"quoted".md`, filepath.Join(".claude", "ünï.json")} {
+		key := manifestKey(rel)
+		if !utf8.ValidString(key) {
+			t.Errorf("manifestKey(%q) = %q, not valid UTF-8", rel, key)
+		}
+		if got := manifestPath(key); got != rel {
+			t.Errorf("manifestPath(%q) = %q, want %q", key, got, rel)
+		}
+	}
+	if got := manifestKey(filepath.Join(".claude", "settings.json")); got != ".claude/settings.json" {
+		t.Errorf("expected plain paths unquoted, got %q", got)
+	}
+}
+
+func TestUpdateManifest_MarksSeededFiles(t *testing.T) {
+	cfg := givenSeededBranch(t)
+	if entry := readManifestEntry(t, cfg.StoreLocation, "CLAUDE.md"); !entry.Seeded {
+		t.Errorf("manifest entry = %+v, want it seeded", entry)
+	}
+
+	writeFile(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "edited")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if entry := readManifestEntry(t, cfg.StoreLocation, "CLAUDE.md"); entry.Seeded {
+		t.Errorf("manifest entry = %+v, want it no longer seeded", entry)
+	}
+}
@@ -0,0 +1,155 @@
+package wrapper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Markers around the personal section merged into a committed file. Unlike
+// the exclude file's block these are HTML comments, which markdown hides.
+const (
+	mergeBlockStart = "<!-- >>> claude-wrapper >>> -->"
+	mergeBlockEnd   = "<!-- <<< claude-wrapper <<< -->"
+)
+
+// isMergeStrategy reports whether strategy merges the stored copy of a
+// tracked item into the committed file: "import" adds an @import of the
+// copy synced in under its local name, "append" adds the copy's contents.
+func isMergeStrategy(strategy string) bool {
+	return strategy == "import" || strategy == "append"
+}
+
+// mergeable reports whether the stored copy of item can be merged into the
+// committed file, which needs both to be regular files.
+func mergeable(cfg *Config, item string) bool {
+	for _, path := range []string{filepath.Join(cfg.StoreLocation, item), filepath.Join(cfg.RepoRoot, item)} {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeIn writes the personal section of each tracked item with a merge
+// strategy into its committed file. targets is the result of
+// collisionTargets.
+func mergeIn(cfg *Config, targets map[string]string) error {
+	var items []string
+	for item := range targets {
+		if isMergeStrategy(cfg.Settings.collisionStrategy(item)) && mergeable(cfg, item) {
+			items = append(items, item)
+		}
+	}
+	sort.Strings(items)
+
+	var errs []error
+	for _, item := range items {
+		var section []byte
+		if cfg.Settings.collisionStrategy(item) == "import" {
+			// Imports are resolved relative to the importing file
+			section = []byte("@" + filepath.Base(localName(item)) + "\n")
+		} else {
+			data, err := os.ReadFile(filepath.Join(cfg.StoreLocation, item))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to read stored %s: %w", item, err))
+				continue
+			}
+			section = data
+		}
+		if err := writeMergedSection(filepath.Join(cfg.RepoRoot, item), section); err != nil {
+			errs = append(errs, fmt.Errorf("failed to merge personal section into %s: %w", item, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// mergeOut undoes mergeIn for the tracked items among items: an appended
+// personal section is saved back to storage, and every committed file is
+// returned to its committed contents.
+func mergeOut(cfg *Config, items []string, tracked map[string]bool, c *copier) error {
+	var errs []error
+	for _, item := range items {
+		strategy := cfg.Settings.collisionStrategy(item)
+		if !tracked[item] || !isMergeStrategy(strategy) {
+			continue
+		}
+		path := filepath.Join(cfg.RepoRoot, item)
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read %s: %w", item, err))
+			continue
+		}
+		committed, section, found := splitMergedSection(data)
+		if !found {
+			continue // Removed by hand; nothing to save or undo
+		}
+
+		if strategy == "append" {
+			dst := filepath.Join(cfg.StoreLocation, item)
+			if err := os.WriteFile(dst, section, 0644); err != nil {
+				errs = append(errs, fmt.Errorf("failed to save personal section of %s: %w", item, err))
+				continue
+			}
+			c.files++
+			c.bytes += int64(len(section))
+		}
+		if err := os.WriteFile(path, committed, info.Mode().Perm()); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove personal section from %s: %w", item, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeMergedSection appends section to the file at path between merge
+// markers, replacing any section merged in earlier.
+func writeMergedSection(path string, section []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	committed, _, _ := splitMergedSection(data)
+
+	var b bytes.Buffer
+	b.Write(committed)
+	b.WriteString("\n" + mergeBlockStart + "\n")
+	b.Write(section)
+	if len(section) > 0 && section[len(section)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	b.WriteString(mergeBlockEnd + "\n")
+	return os.WriteFile(path, b.Bytes(), info.Mode().Perm())
+}
+
+// splitMergedSection separates data written by writeMergedSection into the
+// committed contents and the personal section. found is false when data
+// holds no complete section, in which case committed is data unchanged.
+func splitMergedSection(data []byte) (committed, section []byte, found bool) {
+	start := bytes.Index(data, []byte("\n"+mergeBlockStart+"\n"))
+	if start < 0 {
+		return data, nil, false
+	}
+	body := start + len(mergeBlockStart) + 2
+	end := bytes.Index(data[body:], []byte(mergeBlockEnd))
+	if end < 0 {
+		return data, nil, false
+	}
+	section = data[body : body+end]
+
+	rest := data[body+end+len(mergeBlockEnd):]
+	rest = bytes.TrimPrefix(rest, []byte("\n"))
+	committed = append(append([]byte(nil), data[:start]...), rest...)
+	return committed, section, true
+}
@@ -0,0 +1,89 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitMergedSection_UndoesWriteMergedSection(t *testing.T) {
+	for _, committed := range []string{"# Team\n", "# Team", ""} {
+		path := filepath.Join(t.TempDir(), "CLAUDE.md")
+		writeFile(t, path, committed)
+
+		if err := writeMergedSection(path, []byte("mine\n")); err != nil {
+			t.Fatalf("writeMergedSection failed: %v", err)
+		}
+		// Merging again replaces the section rather than adding another
+		if err := writeMergedSection(path, []byte("mine again")); err != nil {
+			t.Fatalf("writeMergedSection failed: %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotCommitted, section, found := splitMergedSection(data)
+		if !found || string(gotCommitted) != committed || string(section) != "mine again\n" {
+			t.Errorf("split(%q) = %q, %q, %v; want %q, %q, true",
+				data, gotCommitted, section, found, committed, "mine again\n")
+		}
+	}
+}
+
+func TestSyncIn_ImportStrategyImportsLocalCopy(t *testing.T) {
+	cfg := givenTrackedCollision(t, "")
+	cfg.Settings.CollisionStrategy = map[string]string{"README.md": "import"}
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.local.md"), "personal readme")
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.md"),
+		"readme\n"+mergeBlockStart+"\n@README.local.md\n"+mergeBlockEnd+"\n")
+	assertExcludeContains(t, cfg.RepoRoot, "README.local.md")
+
+	writeFile(t, filepath.Join(cfg.RepoRoot, "README.local.md"), "edited readme")
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.md"), "readme")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "README.md"), "edited readme")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "README.local.md"))
+}
+
+func TestSyncIn_AppendStrategyAppendsPersonalSection(t *testing.T) {
+	cfg := givenTrackedCollision(t, "append")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.md"),
+		"readme\n"+mergeBlockStart+"\npersonal readme\n"+mergeBlockEnd+"\n")
+
+	writeFile(t, filepath.Join(cfg.RepoRoot, "README.md"),
+		"readme\n"+mergeBlockStart+"\nedited readme\n"+mergeBlockEnd+"\n")
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.md"), "readme")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "README.md"), "edited readme\n")
+}
+
+func TestSyncIn_MergeStrategySkipsDirectories(t *testing.T) {
+	cfg := givenTrackedCollision(t, "append")
+	if err := os.Remove(filepath.Join(cfg.StoreLocation, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(cfg.StoreLocation, "README.md", "nested.md"), "x")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "README.md"), "readme")
+}
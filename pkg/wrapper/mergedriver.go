@@ -0,0 +1,248 @@
+package wrapper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Merge drivers, set per path with an items entry's merge key. "text" is
+// what merge_extensions opts files into.
+const (
+	mergeDriverText    = "text"            // Line by line, marking conflicts
+	mergeDriverOurs    = "ours"            // Keep the working tree's copy
+	mergeDriverTheirs  = "theirs"          // Keep storage's copy
+	mergeDriverUnion   = "union-lines"     // Line by line, keeping both sides of conflicts
+	mergeDriverJSON    = "json-deep-merge" // Key by key through JSON objects
+	mergeDriverCommand = "command"         // Run the item's merge_command
+)
+
+func validMergeDriver(driver string) bool {
+	switch driver {
+	case "", mergeDriverText, mergeDriverOurs, mergeDriverTheirs, mergeDriverUnion, mergeDriverJSON, mergeDriverCommand:
+		return true
+	}
+	return false
+}
+
+// lineDriver reports whether driver merges line by line, which only makes
+// sense for text.
+func lineDriver(driver string) bool {
+	return driver == mergeDriverText || driver == mergeDriverUnion
+}
+
+// merges reports whether any files are merged rather than copied over when
+// both copies changed.
+func (s Settings) merges() bool {
+	if len(s.MergeExtensions) > 0 {
+		return true
+	}
+	for _, item := range s.Items {
+		if item.Merge != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeItem returns the items entry that sets the merge driver of the
+// store-relative path rel, matched against it and the directories it is in
+// like never_manage patterns. The longest matching pattern wins.
+func (s Settings) mergeItem(rel string) (ItemSettings, bool) {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	best := ""
+	found := false
+	for pattern, item := range s.Items {
+		if item.Merge == "" {
+			continue
+		}
+		if found && (len(pattern) < len(best) || (len(pattern) == len(best) && pattern > best)) {
+			continue
+		}
+		for i := range parts {
+			if matchesPattern(pattern, strings.Join(parts[:i+1], "/")) {
+				best, found = pattern, true
+				break
+			}
+		}
+	}
+	return s.Items[best], found
+}
+
+// mergeDriver returns the driver that merges rel when both its copies
+// changed, or "" if it is copied over instead.
+func (s Settings) mergeDriver(rel string) string {
+	if item, ok := s.mergeItem(rel); ok {
+		return item.Merge
+	}
+	if s.mergesText(rel) {
+		return mergeDriverText
+	}
+	return ""
+}
+
+// runMergeDriver merges the changes ours (the working tree's copy of rel)
+// and theirs (storage's) made to base with driver, returning the result and
+// how many conflicts it holds.
+func (s Settings) runMergeDriver(driver, rel string, base, ours, theirs []byte) ([]byte, int, error) {
+	switch driver {
+	case mergeDriverOurs:
+		return ours, 0, nil
+	case mergeDriverTheirs:
+		return theirs, 0, nil
+	case mergeDriverJSON:
+		result, conflicts, err := mergeJSONFiles(base, ours, theirs)
+		if err == nil {
+			return result, conflicts, nil
+		}
+		warnf("%s: %v; merging it line by line instead", rel, err)
+		return s.runMergeDriver(mergeDriverText, rel, base, ours, theirs)
+	case mergeDriverCommand:
+		item, _ := s.mergeItem(rel)
+		return runMergeCommand(item.MergeCommand, rel, base, ours, theirs)
+	}
+	lines, conflicts, ok := mergeLines(splitLines(base), splitLines(ours), splitLines(theirs), driver == mergeDriverUnion)
+	if !ok {
+		return nil, 0, fmt.Errorf("too many changes to match up")
+	}
+	return []byte(strings.Join(lines, "")), conflicts, nil
+}
+
+// missingKey stands for a key a JSON object lacks.
+var missingKey = &struct{}{}
+
+// mergeJSONFiles merges JSON documents with mergeJSON, writing the result
+// indented with two spaces (and object keys sorted).
+func mergeJSONFiles(base, ours, theirs []byte) ([]byte, int, error) {
+	var docs [3]any
+	for i, data := range [][]byte{base, ours, theirs} {
+		var err error
+		if docs[i], err = parseJSON(data); err != nil {
+			return nil, 0, fmt.Errorf("not valid JSON: %w", err)
+		}
+	}
+	merged, conflicts := mergeJSON(docs[0], docs[1], docs[2])
+	result := formatJSON(merged)
+	if !bytes.HasSuffix(ours, []byte("\n")) {
+		result = bytes.TrimSuffix(result, []byte("\n"))
+	}
+	return result, conflicts, nil
+}
+
+// mergeJSON merges the changes ours and theirs made to base, going key by
+// key through objects both kept. Arrays are merged as sets: elements either
+// side added are kept, and those either removed are dropped. Where both
+// changed a value differently, ours is kept and counted as a conflict. Any
+// of the three may be missingKey.
+func mergeJSON(base, ours, theirs any) (any, int) {
+	switch {
+	case reflect.DeepEqual(ours, theirs), reflect.DeepEqual(theirs, base):
+		return ours, 0
+	case reflect.DeepEqual(ours, base):
+		return theirs, 0
+	}
+
+	if o, ok := ours.(map[string]any); ok {
+		if t, ok := theirs.(map[string]any); ok {
+			b, _ := base.(map[string]any)
+			merged := make(map[string]any)
+			conflicts := 0
+			for _, m := range []map[string]any{o, t} {
+				for key := range m {
+					if _, done := merged[key]; done {
+						continue
+					}
+					v, n := mergeJSON(jsonKey(b, key), jsonKey(o, key), jsonKey(t, key))
+					conflicts += n
+					if v != missingKey {
+						merged[key] = v
+					}
+				}
+			}
+			return merged, conflicts
+		}
+	}
+	if o, ok := ours.([]any); ok {
+		if t, ok := theirs.([]any); ok {
+			b, _ := base.([]any)
+			var merged []any
+			for _, v := range o {
+				if !containsJSON(b, v) || containsJSON(t, v) {
+					merged = append(merged, v)
+				}
+			}
+			for _, v := range t {
+				if !containsJSON(b, v) && !containsJSON(o, v) {
+					merged = append(merged, v)
+				}
+			}
+			if merged == nil {
+				merged = []any{}
+			}
+			return merged, 0
+		}
+	}
+	return ours, 1
+}
+
+// jsonKey returns m's value for key, or missingKey.
+func jsonKey(m map[string]any, key string) any {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	return missingKey
+}
+
+func containsJSON(values []any, v any) bool {
+	for _, have := range values {
+		if reflect.DeepEqual(have, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// runMergeCommand merges with a shell command, as git runs merge drivers:
+// %O, %A and %B are replaced by files holding base, ours and theirs, and %P
+// by rel. The command leaves the result in %A, and exits non-zero if it
+// holds conflicts.
+func runMergeCommand(command, rel string, base, ours, theirs []byte) ([]byte, int, error) {
+	dir, err := os.MkdirTemp("", "claude-wrapper-merge-")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	replacements := []string{"%P", shellQuote(rel)}
+	for _, f := range []struct {
+		placeholder string
+		data        []byte
+	}{{"%O", base}, {"%A", ours}, {"%B", theirs}} {
+		path := filepath.Join(dir, strings.TrimPrefix(f.placeholder, "%"))
+		if err := os.WriteFile(path, f.data, 0600); err != nil {
+			return nil, 0, err
+		}
+		replacements = append(replacements, f.placeholder, shellQuote(path))
+	}
+
+	cmd := exec.Command("sh", "-c", strings.NewReplacer(replacements...).Replace(command))
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	code, err := exitStatus(cmd.Run())
+	if err != nil {
+		return nil, 0, fmt.Errorf("merge_command: %w", err)
+	}
+	result, err := os.ReadFile(filepath.Join(dir, "A"))
+	if err != nil {
+		return nil, 0, err
+	}
+	conflicts := 0
+	if code != 0 {
+		conflicts = 1
+	}
+	return result, conflicts, nil
+}
@@ -0,0 +1,135 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeJSONFiles(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		wantConflicts      int
+	}{
+		{"separate keys",
+			`{"model": "a", "env": {"X": "1"}}`,
+			`{"model": "b", "env": {"X": "1"}}`,
+			`{"model": "a", "env": {"X": "1", "Y": "2"}}`,
+			`{"env": {"X": "1", "Y": "2"}, "model": "b"}`, 0},
+		{"removed key",
+			`{"a": 1, "b": 2}`, `{"a": 1}`, `{"a": 1, "b": 2, "c": 3}`,
+			`{"a": 1, "c": 3}`, 0},
+		{"arrays as sets",
+			`{"allow": ["ls", "cat"]}`, `{"allow": ["ls", "cat", "git"]}`, `{"allow": ["ls", "make"]}`,
+			`{"allow": ["ls", "git", "make"]}`, 0},
+		{"same value changed both ways keeps ours",
+			`{"model": "a"}`, `{"model": "b"}`, `{"model": "c"}`,
+			`{"model": "b"}`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts, err := mergeJSONFiles([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _, err := mergeJSONFiles([]byte(tt.want), []byte(tt.want), []byte(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) || conflicts != tt.wantConflicts {
+				t.Errorf("merged = %s with %d conflict(s), want %s with %d", got, conflicts, want, tt.wantConflicts)
+			}
+		})
+	}
+	if _, _, err := mergeJSONFiles([]byte("{}"), []byte("{"), []byte("{}")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestMergeDriver(t *testing.T) {
+	s := Settings{
+		MergeExtensions: []string{".md"},
+		Items: map[string]ItemSettings{
+			"*.json":                {Merge: mergeDriverJSON},
+			".claude/settings.json": {Merge: mergeDriverOurs},
+			"notes/":                {Merge: mergeDriverUnion},
+			".claude/cache":         {},
+		},
+	}
+	for rel, want := range map[string]string{
+		".claude/settings.json":       mergeDriverOurs,
+		".claude/settings.local.json": mergeDriverJSON,
+		"notes/today.txt":             mergeDriverUnion,
+		"CLAUDE.md":                   mergeDriverText,
+		".claude/cache/x.bin":         "",
+	} {
+		if got := s.mergeDriver(rel); got != want {
+			t.Errorf("mergeDriver(%q) = %q, want %q", rel, got, want)
+		}
+	}
+}
+
+func TestRunMergeDriver(t *testing.T) {
+	base, ours, theirs := []byte("a\nb\n"), []byte("a\nours\n"), []byte("a\ntheirs\n")
+	tests := []struct {
+		driver        string
+		command       string
+		want          string
+		wantConflicts int
+	}{
+		{mergeDriverOurs, "", "a\nours\n", 0},
+		{mergeDriverTheirs, "", "a\ntheirs\n", 0},
+		{mergeDriverUnion, "", "a\nours\ntheirs\n", 0},
+		{mergeDriverCommand, `cat %B >> %A`, "a\nours\na\ntheirs\n", 0},
+		{mergeDriverCommand, `echo %P > %A; exit 1`, "notes.txt\n", 1},
+	}
+	for _, tt := range tests {
+		s := Settings{Items: map[string]ItemSettings{"notes.txt": {Merge: tt.driver, MergeCommand: tt.command}}}
+		got, conflicts, err := s.runMergeDriver(tt.driver, "notes.txt", base, ours, theirs)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.driver, err)
+		}
+		if string(got) != tt.want || conflicts != tt.wantConflicts {
+			t.Errorf("%s %q: merged = %q with %d conflict(s), want %q with %d", tt.driver, tt.command, got, conflicts, tt.want, tt.wantConflicts)
+		}
+	}
+}
+
+func TestSyncIn_DeepMergesSettingsJSON(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.Items = map[string]ItemSettings{".claude/settings.json": {Merge: mergeDriverJSON}}
+	stored := filepath.Join(cfg.StoreLocation, ".claude", "settings.json")
+	local := filepath.Join(repoRoot, ".claude", "settings.json")
+	writeFile(t, stored, "{\n  \"model\": \"a\"\n}\n")
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, stored, "{\n  \"model\": \"b\"\n}\n")
+	writeFile(t, local, "{\n  \"model\": \"a\",\n  \"theme\": \"dark\"\n}\n")
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, local, "{\n  \"model\": \"b\",\n  \"theme\": \"dark\"\n}\n")
+}
+
+func TestParseSettings_ItemMergeDriver(t *testing.T) {
+	var s Settings
+	if err := parseSettings("[items.\"*.json\"]\nmerge = \"json-deep-merge\"\n\n[items.\"notes.md\"]\nmerge = \"command\"\nmerge_command = \"merge-notes %A %O %B\"\n", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Items["*.json"].Merge != mergeDriverJSON || s.Items["notes.md"].MergeCommand != "merge-notes %A %O %B" {
+		t.Errorf("items = %+v", s.Items)
+	}
+	for _, bad := range []string{
+		"[items.x]\nmerge = \"deep\"\n",
+		"[items.x]\nmerge = \"command\"\n",
+		"[items.x]\nmerge = \"ours\"\nmerge_command = \"true\"\n",
+	} {
+		if err := parseSettings(bad, &Settings{}); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
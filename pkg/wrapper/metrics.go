@@ -0,0 +1,120 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// daemonMetrics counts what the daemon has done since it started, for the
+// /metrics endpoint.
+type daemonMetrics struct {
+	mu       sync.Mutex
+	requests map[string]int64 // By command
+	failures map[string]int64 // Failed requests, by command
+	syncs    map[string]int64 // By result: ok or error
+	filesIn  int64
+	filesOut int64
+	bytes    int64
+	removed  int64
+	// lockWaits counts requests that had to wait for another request on
+	// the same repository to finish.
+	lockWaits int64
+}
+
+func newDaemonMetrics() *daemonMetrics {
+	return &daemonMetrics{
+		requests: make(map[string]int64),
+		failures: make(map[string]int64),
+		syncs:    make(map[string]int64),
+	}
+}
+
+// recordRequest counts a request for cmd and whether it failed.
+func (m *daemonMetrics) recordRequest(cmd string, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[cmd]++
+	if failed {
+		m.failures[cmd]++
+	}
+}
+
+// recordSync counts a sync and what it copied. r may be nil.
+func (m *daemonMetrics) recordSync(r *SyncReport, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.syncs["error"]++
+	} else {
+		m.syncs["ok"]++
+	}
+	if r != nil {
+		m.filesIn += int64(r.FilesIn)
+		m.filesOut += int64(r.FilesOut)
+		m.bytes += r.BytesCopied
+		m.removed += int64(r.Removed)
+	}
+}
+
+// recordLockWait counts a request that waited for a repository's lock.
+func (m *daemonMetrics) recordLockWait() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lockWaits++
+}
+
+// openMetricsType is the content type of the OpenMetrics text format,
+// served to scrapers that ask for it; others get Prometheus' text format.
+const openMetricsType = "application/openmetrics-text"
+
+// ServeHTTP serves the metrics in the Prometheus or OpenMetrics text format.
+func (m *daemonMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), openMetricsType)
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsType+"; version=1.0.0; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	}
+	m.write(w, openMetrics)
+}
+
+// write writes every metric; OpenMetrics requires the terminating EOF line.
+func (m *daemonMetrics) write(w io.Writer, openMetrics bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeCounter(w, "requests", "Requests answered, by command.", "cmd", m.requests)
+	writeCounter(w, "request_failures", "Requests that failed, by command.", "cmd", m.failures)
+	writeCounter(w, "syncs", "Syncs run, by result.", "result", m.syncs)
+	writeCounter(w, "files_in", "Files copied from storage into working trees.", "", map[string]int64{"": m.filesIn})
+	writeCounter(w, "files_out", "Files copied from working trees into storage.", "", map[string]int64{"": m.filesOut})
+	writeCounter(w, "bytes_copied", "Bytes copied in either direction.", "", map[string]int64{"": m.bytes})
+	writeCounter(w, "items_removed", "Items removed from storage by sync-out.", "", map[string]int64{"": m.removed})
+	writeCounter(w, "lock_waits", "Requests that waited for another request on the same repository.", "", map[string]int64{"": m.lockWaits})
+	if openMetrics {
+		fmt.Fprintln(w, "# EOF")
+	}
+}
+
+// writeCounter writes the counter claude_wrapper_<name>_total, with one
+// sample per value keyed by its label (or a single unlabeled sample).
+func writeCounter(w io.Writer, name, help, label string, values map[string]int64) {
+	name = "claude_wrapper_" + name
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if label == "" {
+			fmt.Fprintf(w, "%s_total %d\n", name, values[key])
+		} else {
+			fmt.Fprintf(w, "%s_total{%s=%q} %d\n", name, label, key, values[key])
+		}
+	}
+}
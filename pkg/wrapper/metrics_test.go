@@ -0,0 +1,76 @@
+package wrapper
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func scrapeMetrics(t *testing.T, m *daemonMetrics, accept string) (string, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Body)
+	return string(body), rec.Header().Get("Content-Type")
+}
+
+func TestDaemonMetrics_CountsRequestsAndSyncs(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	writeFile(t, filepath.Join(home, ".workspaces", filepath.Base(dir), "CLAUDE.md"), "stored")
+	d := newDaemon(Settings{})
+
+	if resp := d.handle(daemonRequest{Cmd: "sync", Repo: dir}); !resp.OK {
+		t.Fatalf("sync failed: %s", resp.Error)
+	}
+	d.handle(daemonRequest{Cmd: "frobnicate", Repo: dir})
+
+	body, contentType := scrapeMetrics(t, d.metrics, "")
+	if !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", contentType)
+	}
+	for _, want := range []string{
+		"# TYPE claude_wrapper_syncs counter",
+		`claude_wrapper_requests_total{cmd="sync"} 1`,
+		`claude_wrapper_requests_total{cmd="unknown"} 1`,
+		`claude_wrapper_request_failures_total{cmd="unknown"} 1`,
+		`claude_wrapper_syncs_total{result="ok"} 1`,
+		"claude_wrapper_files_in_total 1",
+		"claude_wrapper_bytes_copied_total 6",
+		"claude_wrapper_lock_waits_total 0",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "# EOF") {
+		t.Errorf("Prometheus format shouldn't end with # EOF:\n%s", body)
+	}
+}
+
+func TestDaemonMetrics_OpenMetrics(t *testing.T) {
+	m := newDaemonMetrics()
+	m.recordSync(nil, errors.New("disk full"))
+	m.recordLockWait()
+
+	body, contentType := scrapeMetrics(t, m, "application/openmetrics-text; version=1.0.0")
+	if !strings.HasPrefix(contentType, openMetricsType) {
+		t.Errorf("Content-Type = %q, want OpenMetrics", contentType)
+	}
+	for _, want := range []string{`claude_wrapper_syncs_total{result="error"} 1`, "claude_wrapper_lock_waits_total 1"} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("OpenMetrics output must end with # EOF:\n%s", body)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package wrapper
+
+import "testing"
+
+// mkfifo skips the test: named pipes are a Unix feature.
+func mkfifo(t *testing.T, path string) {
+	t.Skip("named pipes need a Unix system")
+}
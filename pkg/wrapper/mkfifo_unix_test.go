@@ -0,0 +1,22 @@
+//go:build unix
+
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// mkfifo creates a named pipe at path. Opening it for reading blocks until
+// a writer appears, so copying it naively would hang the test.
+func mkfifo(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(path, 0644); err != nil {
+		t.Skipf("cannot create FIFO: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// notesFile holds a branch's session notes in its store, whatever
+// session_notes names them in the working tree.
+const notesFile = ".session_notes.md"
+
+// notesTemplate starts a branch's session notes.
+const notesTemplate = "# Session notes: %s\n\n"
+
+// validNotesPath reports whether p is usable as session_notes: a path
+// inside the repository, without wildcards.
+func validNotesPath(p string) bool {
+	if p == "" {
+		return true
+	}
+	clean := filepath.Clean(p)
+	return !filepath.IsAbs(p) && clean != "." && !strings.HasPrefix(clean, "..") &&
+		!strings.ContainsAny(p, "*?[]")
+}
+
+// isNotesItem reports whether item, an exclude file entry, is cfg's session
+// notes file, which syncOutNotes saves rather than the item loop.
+func (cfg *Config) isNotesItem(item string) bool {
+	notes := cfg.Settings.SessionNotes
+	return notes != "" && filepath.Clean(item) == filepath.Clean(notes)
+}
+
+// syncInNotes puts the branch's session notes in place, starting a new file
+// if the branch has none, and excludes them from git. A notes file the
+// wrapper already manages but the branch has no copy of came from another
+// branch, so it is started over; one the user wrote before turning the
+// setting on is kept.
+func syncInNotes(cfg *Config, c *copier) error {
+	notes := cfg.Settings.SessionNotes
+	if notes == "" {
+		return nil
+	}
+	tracked, err := trackedItems(cfg, []string{notes})
+	if err != nil {
+		warnf("not syncing in session notes: %v", err)
+		return nil
+	}
+	if tracked[notes] {
+		warnf("not managing session notes: %s is committed to the repository", notes)
+		return nil
+	}
+
+	dst := filepath.Join(cfg.RepoRoot, notes)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create session notes: %w", err)
+	}
+	stored := filepath.Join(cfg.StoreLocation, notesFile)
+	if _, err := os.Stat(stored); err == nil {
+		if err := c.copyFile(stored, dst); err != nil {
+			return fmt.Errorf("failed to copy session notes: %w", err)
+		}
+	} else if _, err := os.Stat(dst); err != nil || excludeManages(cfg.excludeFile(), notes) {
+		content := fmt.Sprintf(notesTemplate, cfg.CurrentBranch)
+		if err := os.WriteFile(dst, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to create session notes: %w", err)
+		}
+	}
+
+	if err := addToExclude(cfg.RepoRoot, cfg.excludeFile(), filepath.ToSlash(filepath.Clean(notes))); err != nil {
+		return fmt.Errorf("failed to update exclude for %s: %w", notes, err)
+	}
+	return nil
+}
+
+// syncOutNotes saves the working tree's session notes to the branch store.
+func syncOutNotes(cfg *Config, c *copier) error {
+	notes := cfg.Settings.SessionNotes
+	if notes == "" {
+		return nil
+	}
+	src := filepath.Join(cfg.RepoRoot, notes)
+	if _, err := os.Stat(src); err != nil {
+		return nil // Deleted during the session; keep the stored copy
+	}
+	tracked, err := trackedItems(cfg, []string{notes})
+	if err != nil {
+		warnf("not saving session notes: %v", err)
+		return nil
+	}
+	if tracked[notes] {
+		return nil
+	}
+	if err := c.copyFile(src, filepath.Join(cfg.StoreLocation, notesFile)); err != nil {
+		return fmt.Errorf("failed to copy session notes to storage: %w", err)
+	}
+	return nil
+}
+
+// excludeManages reports whether item is an entry in the wrapper's block of
+// excludeFile.
+func excludeManages(excludeFile, item string) bool {
+	lines, err := readLines(excludeFile)
+	if err != nil {
+		return false
+	}
+	start, end := managedBlock(lines)
+	if start < 0 {
+		return false
+	}
+	item = normalizeExcludeItem(item)
+	for _, line := range lines[start+1 : end] {
+		if entry, ok := lineNames(line); ok && entry == item {
+			return true
+		}
+	}
+	return false
+}
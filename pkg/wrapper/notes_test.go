@@ -0,0 +1,93 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// givenSessionNotes returns a config for a fresh repository with session
+// notes at notes.
+func givenSessionNotes(t *testing.T, notes string) *Config {
+	t.Helper()
+	repoRoot, _ := givenGitRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	cfg.Settings.SessionNotes = notes
+	return cfg
+}
+
+func TestSessionNotes_CreatedAndSaved(t *testing.T) {
+	cfg := givenSessionNotes(t, "SESSION_NOTES.md")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	notes := filepath.Join(cfg.RepoRoot, "SESSION_NOTES.md")
+	assertFileContent(t, notes, "# Session notes: feature\n\n")
+	assertExcludeContains(t, cfg.RepoRoot, "SESSION_NOTES.md")
+
+	writeFile(t, notes, "remember the migration")
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, notesFile), "remember the migration")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "SESSION_NOTES.md"))
+}
+
+func TestSessionNotes_RestoredFromStore(t *testing.T) {
+	cfg := givenSessionNotes(t, ".claude/scratchpad.md")
+	writeFile(t, filepath.Join(cfg.StoreLocation, notesFile), "where I left off")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".claude", "scratchpad.md"), "where I left off")
+	assertExcludeContains(t, cfg.RepoRoot, ".claude/scratchpad.md")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, ".claude"))
+}
+
+func TestSessionNotes_KeepsUserFileButNotAnotherBranchs(t *testing.T) {
+	cfg := givenSessionNotes(t, "SESSION_NOTES.md")
+	notes := filepath.Join(cfg.RepoRoot, "SESSION_NOTES.md")
+	writeFile(t, notes, "written by hand")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	assertFileContent(t, notes, "written by hand")
+
+	// Now managed, but another branch without notes of its own starts over
+	other := cfg.forBranch("other")
+	if err := syncIn(other); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	assertFileContent(t, notes, "# Session notes: other\n\n")
+}
+
+func TestSessionNotes_NotInheritedByNewBranch(t *testing.T) {
+	cfg := givenSessionNotes(t, "SESSION_NOTES.md")
+	writeFile(t, filepath.Join(cfg.StoreBase, notesFile), "main's notes")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "SESSION_NOTES.md"), "# Session notes: feature\n\n")
+}
+
+func TestValidNotesPath(t *testing.T) {
+	for _, p := range []string{"", "SESSION_NOTES.md", ".claude/scratchpad.md"} {
+		if !validNotesPath(p) {
+			t.Errorf("expected %q to be valid", p)
+		}
+	}
+	for _, p := range []string{"/tmp/notes.md", "../notes.md", ".", "notes-*.md"} {
+		if validNotesPath(p) {
+			t.Errorf("expected %q to be rejected", p)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// webhookTimeout bounds how long a failed sync waits on the webhook.
+const webhookTimeout = 10 * time.Second
+
+// errNoNotifier is returned where no desktop notification tool exists.
+var errNoNotifier = errors.New("no desktop notification tool available")
+
+// notifyFunc delivers a notification. Replaced in tests.
+var notifyFunc = notify
+
+// syncFailure is the notification sent when a background sync fails, and
+// the body of the webhook request. Text duplicates the rest for chat
+// webhooks (Slack, Mattermost) that only show that field.
+type syncFailure struct {
+	Text  string    `json:"text"`
+	Repo  string    `json:"repo"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// notifySyncFailure surfaces a failed sync of repo that ran with nobody
+// watching its output, such as a daemon request or a git hook.
+func notifySyncFailure(s Settings, repo string, err error) {
+	notifyFunc(s, syncFailure{
+		Text:  fmt.Sprintf("claude-wrapper: sync of %s failed: %v", repo, err),
+		Repo:  repo,
+		Error: err.Error(),
+		Time:  time.Now().UTC(),
+	})
+}
+
+// notify delivers f as configured by the notify and notify_webhook
+// settings. Delivery failures are only warned about.
+func notify(s Settings, f syncFailure) {
+	if s.Notify != "none" {
+		if err := desktopNotify("claude-wrapper sync failed", f.Repo+": "+f.Error); err != nil && !errors.Is(err, errNoNotifier) {
+			warnf("failed to show notification: %v", err)
+		}
+	}
+	if s.NotifyWebhook != "" {
+		if err := postWebhook(s.NotifyWebhook, f); err != nil {
+			warnf("failed to notify %s: %v", s.NotifyWebhook, err)
+		}
+	}
+}
+
+// desktopNotify shows a desktop notification with osascript on macOS or
+// notify-send elsewhere.
+func desktopNotify(title, message string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		cmd = exec.Command("osascript", "-e", script)
+	} else {
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return errNoNotifier
+		}
+		cmd = exec.Command("notify-send", "--urgency=critical", "--app-name=claude-wrapper", title, message)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", cmd.Path, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// postWebhook POSTs f as JSON to url.
+func postWebhook(url string, f syncFailure) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
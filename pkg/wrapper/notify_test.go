@@ -0,0 +1,86 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureNotifications collects notifications for the rest of the test
+// instead of delivering them.
+func captureNotifications(t *testing.T) *[]syncFailure {
+	t.Helper()
+	var sent []syncFailure
+	orig := notifyFunc
+	notifyFunc = func(_ Settings, f syncFailure) { sent = append(sent, f) }
+	t.Cleanup(func() { notifyFunc = orig })
+	return &sent
+}
+
+func TestPostWebhook(t *testing.T) {
+	var got syncFailure
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	notify(Settings{Notify: "none", NotifyWebhook: server.URL}, syncFailure{Text: "sync failed", Repo: "/repo", Error: "disk full"})
+	if got.Repo != "/repo" || got.Error != "disk full" || got.Text != "sync failed" {
+		t.Errorf("webhook received %+v", got)
+	}
+}
+
+func TestPostWebhook_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if err := postWebhook(server.URL, syncFailure{}); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected the response status as error, got %v", err)
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	if got := appleScriptString(`say "hi" \o/`); got != `"say \"hi\" \\o/"` {
+		t.Errorf("appleScriptString = %s", got)
+	}
+}
+
+func TestNotifySyncFailure(t *testing.T) {
+	sent := captureNotifications(t)
+
+	notifySyncFailure(Settings{}, "/repo", errors.New("disk full"))
+	if len(*sent) != 1 || (*sent)[0].Text != "claude-wrapper: sync of /repo failed: disk full" {
+		t.Errorf("unexpected notifications %+v", *sent)
+	}
+}
+
+func TestDaemon_NotifiesFailedSync(t *testing.T) {
+	sent := captureNotifications(t)
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	// A stored copy of a committed file fails sync-in under "refuse"
+	writeFile(t, filepath.Join(home, ".workspaces", filepath.Base(dir), "README.md"), "personal")
+
+	d := newDaemon(Settings{TrackedCollision: "refuse"})
+	if resp := d.handle(daemonRequest{Cmd: "sync-in", Repo: dir}); resp.OK {
+		t.Fatal("expected the sync to fail")
+	}
+	if len(*sent) != 0 {
+		t.Errorf("expected no notification for a client that sees the error, got %+v", *sent)
+	}
+
+	d.notify = true
+	d.handle(daemonRequest{Cmd: "sync-in", Repo: dir})
+	if len(*sent) != 1 || (*sent)[0].Repo != dir {
+		t.Errorf("expected one notification for %s, got %+v", dir, *sent)
+	}
+}
@@ -0,0 +1,109 @@
+package wrapper
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// storeProbeTimeout bounds how long checking the store may take: a stat on
+// a network mount whose server is gone can block indefinitely.
+const storeProbeTimeout = 2 * time.Second
+
+// probeStore returns why the store root at root can't be used, or nil. A
+// root that doesn't exist yet is fine if its parent does.
+var probeStore = func(root string) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := os.Stat(root)
+		if os.IsNotExist(err) {
+			_, err = os.Stat(filepath.Dir(root))
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(storeProbeTimeout):
+		return fmt.Errorf("no response after %s", storeProbeTimeout)
+	}
+}
+
+// offlineStoreRoot returns where the selected profile's stores are kept
+// while its store root is unreachable.
+func (s Settings) offlineStoreRoot() (string, error) {
+	root := expandHome(s.OfflineStore)
+	if s.OfflineStore == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get cache directory: %w", err)
+		}
+		root = filepath.Join(cache, "claude-wrapper", "offline")
+	}
+	profile := s.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	return filepath.Join(filepath.Clean(root), profile), nil
+}
+
+// availableStoreRoot returns storeRoot if it can be reached, else the
+// offline store root to use in its place, and whether it fell back.
+func availableStoreRoot(s Settings, storeRoot string) (string, bool, error) {
+	probeErr := probeStore(storeRoot)
+	if probeErr == nil {
+		return storeRoot, false, nil
+	}
+	offline, err := s.offlineStoreRoot()
+	if err != nil {
+		return "", false, fmt.Errorf("storage at %s is unreachable (%v) and so is the offline store: %w", storeRoot, probeErr, err)
+	}
+	warnf("storage at %s is unreachable (%v); keeping personal files in %s until it returns", storeRoot, probeErr, offline)
+	return offline, true, nil
+}
+
+// reconcileOfflineStore moves what was saved to the offline store at
+// cached while the store at storeBase was unreachable back into it. Files
+// missing from storeBase, or older there, are copied over; nothing in
+// storeBase is deleted. The offline store is removed afterwards, as
+// recorded in storeBase's audit log.
+func reconcileOfflineStore(storeBase, cached string) error {
+	if _, err := os.Stat(cached); os.IsNotExist(err) {
+		return nil
+	}
+
+	var c copier
+	err := filepath.WalkDir(cached, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(cached, path)
+		if err != nil {
+			return err
+		}
+		// Bookkeeping is the real store's own
+		if rel == storeMetaFile || rel == auditLogFile || d.Name() == deletionMarker {
+			return nil
+		}
+		dest := filepath.Join(storeBase, rel)
+		if destInfo, err := os.Stat(dest); err == nil {
+			info, err := d.Info()
+			if err != nil || !info.ModTime().After(destInfo.ModTime()) {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		return c.copyFile(path, dest)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move offline changes back to %s: %w", storeBase, err)
+	}
+	if c.files > 0 {
+		infof("storage is back; moved %d file(s) saved offline into %s", c.files, storeBase)
+	}
+	return auditedRemoveAll(storeBase, auditEntry{Path: cached, Reason: "offline cache reconciled into store"})
+}
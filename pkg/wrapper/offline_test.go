@@ -0,0 +1,116 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// givenUnreachableStore makes every store root look unreachable until the
+// returned function is called.
+func givenUnreachableStore(t *testing.T) (restore func()) {
+	t.Helper()
+	orig := probeStore
+	probeStore = func(string) error { return errors.New("transport endpoint is not connected") }
+	restore = func() { probeStore = orig }
+	t.Cleanup(restore)
+	return restore
+}
+
+func TestProbeStore(t *testing.T) {
+	root := t.TempDir()
+	if err := probeStore(root); err != nil {
+		t.Errorf("existing store root: %v", err)
+	}
+	if err := probeStore(filepath.Join(root, ".workspaces")); err != nil {
+		t.Errorf("store root not created yet: %v", err)
+	}
+	if err := probeStore(filepath.Join(root, "mnt", "nas", ".workspaces")); err == nil {
+		t.Error("expected a store root whose parent is missing to be unreachable")
+	}
+}
+
+func TestOfflineStoreRoot(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/cache")
+	t.Setenv("HOME", "/home/me")
+	for _, tt := range []struct {
+		settings Settings
+		want     string
+	}{
+		{Settings{}, "/cache/claude-wrapper/offline/default"},
+		{Settings{Profile: "work"}, "/cache/claude-wrapper/offline/work"},
+		{Settings{OfflineStore: "~/offline"}, "/home/me/offline/default"},
+	} {
+		if got, err := tt.settings.offlineStoreRoot(); err != nil || got != tt.want {
+			t.Errorf("offlineStoreRoot(%+v) = %q, %v; want %q", tt.settings, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigFrom_FallsBackWhileStoreUnreachable(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	offline := filepath.Join(t.TempDir(), "offline")
+	settings := Settings{OfflineStore: offline}
+	g := stubGit{root: "/src/app", branch: "main"}
+	diagnostics := captureDiagnostics(t)
+	restore := givenUnreachableStore(t)
+
+	cfg, err := loadConfigFrom(g, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(offline, "default", "app"); cfg.StoreBase != want {
+		t.Errorf("StoreBase = %s, want the offline store %s", cfg.StoreBase, want)
+	}
+	if !strings.Contains(diagnostics.String(), "is unreachable") {
+		t.Errorf("expected a warning, got %q", diagnostics.String())
+	}
+	writeFile(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "edited offline")
+
+	restore()
+	cfg, err = loadConfigFrom(g, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, ".workspaces", "app"); cfg.StoreBase != want {
+		t.Errorf("StoreBase = %s, want %s once the store is back", cfg.StoreBase, want)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "edited offline")
+	assertNotExists(t, filepath.Join(offline, "default", "app"))
+}
+
+func TestReconcileOfflineStore_NewerWins(t *testing.T) {
+	store := t.TempDir()
+	cached := filepath.Join(t.TempDir(), "app")
+	writeFile(t, filepath.Join(store, "CLAUDE.md"), "updated elsewhere")
+	writeFile(t, filepath.Join(store, "notes.md"), "stale")
+	writeFile(t, filepath.Join(cached, "CLAUDE.md"), "old")
+	writeFile(t, filepath.Join(cached, "notes.md"), "edited offline")
+	writeFile(t, filepath.Join(cached, branchesDir, "feature", ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(cached, storeMetaFile), "{}")
+
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(cached, "CLAUDE.md"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(store, "notes.md"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reconcileOfflineStore(store, cached); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(store, "CLAUDE.md"), "updated elsewhere")
+	assertFileContent(t, filepath.Join(store, "notes.md"), "edited offline")
+	assertFileContent(t, filepath.Join(store, branchesDir, "feature", ".claude", "settings.json"), "{}")
+	assertNotExists(t, filepath.Join(store, storeMetaFile))
+	assertNotExists(t, cached)
+	entries := readAuditLog(t, store)
+	if len(entries) != 1 || entries[0].Path != cached || entries[0].Reason != "offline cache reconciled into store" {
+		t.Errorf("audit log = %+v, want the offline store's removal", entries)
+	}
+}
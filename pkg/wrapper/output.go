@@ -0,0 +1,68 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// diagnostics receives the wrapper's status lines, warnings and errors.
+// Replaced in tests.
+var diagnostics io.Writer = os.Stderr
+
+// tone is how a piece of human-facing output is highlighted.
+type tone int
+
+const (
+	toneAdded    tone = iota // Items brought in or created
+	toneRemoved              // Items deleted
+	toneConflict             // Items needing attention, e.g. too big to save
+	toneMuted                // Secondary details
+	toneWarning
+	toneError
+)
+
+// toneCodes are the ANSI SGR sequences for each tone.
+var toneCodes = map[tone]string{
+	toneAdded:    "32",
+	toneRemoved:  "31",
+	toneConflict: "33",
+	toneMuted:    "2",
+	toneWarning:  "1;33",
+	toneError:    "1;31",
+}
+
+// colorEnabled reports whether output to w should be colored: w must be a
+// terminal, NO_COLOR (https://no-color.org) unset and TERM not "dumb".
+func colorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// colorize returns text highlighted in tone if w is colored, else unchanged.
+func colorize(w io.Writer, t tone, text string) string {
+	if text == "" || !colorEnabled(w) {
+		return text
+	}
+	return "\x1b[" + toneCodes[t] + "m" + text + "\x1b[0m"
+}
+
+// infof reports what the wrapper did or is about to do.
+func infof(format string, args ...any) {
+	fmt.Fprintf(diagnostics, "claude-wrapper: %s\n", fmt.Sprintf(format, args...))
+}
+
+// warnf reports a problem the wrapper works around.
+func warnf(format string, args ...any) {
+	fmt.Fprintf(diagnostics, "claude-wrapper: %s %s\n", colorize(diagnostics, toneWarning, "warning:"), fmt.Sprintf(format, args...))
+}
+
+// errorf reports a failure.
+func errorf(format string, args ...any) {
+	fmt.Fprintf(diagnostics, "claude-wrapper: %s %s\n", colorize(diagnostics, toneError, "error:"), fmt.Sprintf(format, args...))
+}
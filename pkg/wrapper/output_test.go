@@ -0,0 +1,56 @@
+package wrapper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// captureDiagnostics collects warnings and errors for the rest of the test.
+func captureDiagnostics(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	orig := diagnostics
+	diagnostics = &buf
+	t.Cleanup(func() { diagnostics = orig })
+	return &buf
+}
+
+func TestColorize_OnlyOnTerminals(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+
+	if got := colorize(&bytes.Buffer{}, toneRemoved, "removed"); got != "removed" {
+		t.Errorf("expected no color when not writing to a terminal, got %q", got)
+	}
+
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("no pseudo-terminal: %v", err)
+	}
+	defer master.Close()
+	defer slave.Close()
+	if got := colorize(slave, toneRemoved, "removed"); got != "\x1b[31mremoved\x1b[0m" {
+		t.Errorf("expected red on a terminal, got %q", got)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if got := colorize(slave, toneRemoved, "removed"); got != "removed" {
+		t.Errorf("expected NO_COLOR to disable color, got %q", got)
+	}
+}
+
+func TestWarnfAndErrorf(t *testing.T) {
+	buf := captureDiagnostics(t)
+
+	warnf("skipping %s", "socket")
+	errorf("sync failed: %v", "disk full")
+
+	want := "claude-wrapper: warning: skipping socket\nclaude-wrapper: error: sync failed: disk full\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Error("expected no color in captured output")
+	}
+}
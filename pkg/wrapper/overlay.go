@@ -0,0 +1,332 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Markers of conflicts between a branch's layer and the default branch's
+// changes.
+const (
+	conflictBranch  = "<<<<<<< branch\n"
+	conflictDefault = ">>>>>>> default branch\n"
+)
+
+// overlays reports whether the store-relative path rel, or a directory it
+// is in, is matched by an items entry with overlay = true: whether branch
+// stores layer the branch's changes over the default branch's copy.
+func (s Settings) overlays(rel string) bool {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for pattern, item := range s.Items {
+		if !item.Overlay {
+			continue
+		}
+		for i := range parts {
+			if matchesPattern(pattern, strings.Join(parts[:i+1], "/")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// overlaysAny reports whether any items entry sets overlay = true.
+func (s Settings) overlaysAny() bool {
+	for _, item := range s.Items {
+		if item.Overlay {
+			return true
+		}
+	}
+	return false
+}
+
+// configFormat is how an overlaid config file's layers are made and
+// applied.
+type configFormat struct {
+	// apply returns the default branch's copy with a branch's layer applied.
+	apply func(defaults, layer []byte) ([]byte, error)
+	// layer returns the changes that turn the default branch's copy into
+	// data, as apply applies them.
+	layer func(defaults, data []byte) ([]byte, error)
+}
+
+// configFormatOf returns the format of rel by its extension: JSON or YAML.
+func configFormatOf(rel string) (configFormat, bool) {
+	switch strings.ToLower(filepath.Ext(rel)) {
+	case ".json":
+		return configFormat{jsonLayers(applyMergePatch), jsonLayers(createMergePatch)}, true
+	case ".yaml", ".yml":
+		return configFormat{yamlLayers(applyYAMLPatch), yamlLayers(createYAMLPatch)}, true
+	}
+	return configFormat{}, false
+}
+
+// jsonLayers returns a configFormat func that parses both JSON documents
+// and formats what merge makes of them.
+func jsonLayers(merge func(base, doc any) any) func(base, doc []byte) ([]byte, error) {
+	return func(baseData, docData []byte) ([]byte, error) {
+		base, err := parseJSON(baseData)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := parseJSON(docData)
+		if err != nil {
+			return nil, err
+		}
+		return formatJSON(merge(base, doc)), nil
+	}
+}
+
+// yamlLayers returns a configFormat func that parses both YAML documents
+// and formats what merge makes of them.
+func yamlLayers(merge func(base, doc *yaml.Node) *yaml.Node) func(base, doc []byte) ([]byte, error) {
+	return func(baseData, docData []byte) ([]byte, error) {
+		base, err := parseYAML(baseData)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := parseYAML(docData)
+		if err != nil {
+			return nil, err
+		}
+		return formatYAML(merge(base, doc))
+	}
+}
+
+func parseJSON(data []byte) (any, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// formatJSON writes v indented with two spaces (and object keys sorted).
+func formatJSON(v any) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(v) // Values parsed from JSON always encode
+	return buf.Bytes()
+}
+
+// overlayer returns a copier merge func for the copies of a sync in (from
+// the branch store) or out (to it) that keeps only the branch's changes to
+// overlaid config files in its store, or nil on the default branch or if
+// nothing is overlaid. The default branch's copies are read, never written.
+func (cfg *Config) overlayer(in bool) func(src, dst string) (bool, error) {
+	if cfg.CurrentBranch == cfg.DefaultBranch || cfg.StoreLocation == cfg.StoreBase {
+		return nil
+	}
+	if !cfg.Settings.overlaysAny() {
+		return nil
+	}
+	return func(src, dst string) (bool, error) {
+		store := src
+		if !in {
+			store = dst
+		}
+		rel, err := filepath.Rel(cfg.StoreLocation, store)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false, nil
+		}
+		if isReservedItem(strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]) || !cfg.Settings.overlays(rel) {
+			return false, nil
+		}
+		format, ok := configFormatOf(rel)
+		if !ok {
+			return false, nil
+		}
+		return overlayConfig(format, rel, filepath.Join(cfg.StoreBase, rel), src, dst, in)
+	}
+}
+
+// overlayConfig syncs the overlaid config file rel from src to dst. In, the
+// branch store's changes at src are applied to the default branch's copy at
+// defaults and the result written to the working tree at dst. Out, the
+// working tree's differences from the default branch's copy are saved at
+// dst. Without a default branch copy, or one that can't be parsed, the
+// file is copied whole as usual.
+func overlayConfig(format configFormat, rel, defaults, src, dst string, in bool) (bool, error) {
+	defaultData, err := os.ReadFile(defaults)
+	if err != nil {
+		return false, nil
+	}
+	srcData, err := os.ReadFile(src)
+	if err != nil {
+		return false, nil // The copy reports the error
+	}
+	merge := format.layer
+	if in {
+		merge = format.apply
+	}
+	result, err := merge(defaultData, srcData)
+	if err == nil {
+		return true, writeConfig(dst, result)
+	}
+	warnf("not overlaying %s on the default branch's copy: %v", rel, err)
+	return false, nil
+}
+
+// writeConfig writes data to path unless it already holds it.
+func writeConfig(path string, data []byte) error {
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := replaceFile(path, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyMergePatch applies patch to target as a JSON merge patch (RFC 7386):
+// objects are merged key by key, a null removes the key, and anything else
+// replaces the target's value. target is left unchanged.
+func applyMergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, _ := target.(map[string]any)
+	merged := make(map[string]any, len(t))
+	for key, v := range t {
+		merged[key] = v
+	}
+	for key, v := range p {
+		if v == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = applyMergePatch(merged[key], v)
+		}
+	}
+	return merged
+}
+
+// createMergePatch returns the JSON merge patch that turns from into to, as
+// applyMergePatch applies it. Nulls in to can't be told apart from
+// removals, as with any merge patch.
+func createMergePatch(from, to any) any {
+	f, ok := from.(map[string]any)
+	t, ok2 := to.(map[string]any)
+	if !ok || !ok2 {
+		return to
+	}
+	patch := make(map[string]any)
+	for key := range f {
+		if _, kept := t[key]; !kept {
+			patch[key] = nil
+		}
+	}
+	for key, v := range t {
+		was, had := f[key]
+		switch {
+		case !had:
+			patch[key] = v
+		case !reflect.DeepEqual(was, v):
+			patch[key] = createMergePatch(was, v)
+		}
+	}
+	return patch
+}
+
+// rebaseOverlays brings the changes made on the default branch since the
+// branch's layers were last brought up to date into the layers of cfg's
+// branch store: overlaid files the default branch's store added are copied
+// in, and text files are merged three ways against the copy the layer was
+// based on. JSON and YAML layers already apply to the default branch's
+// current copy. Nothing happens on the default branch.
+func rebaseOverlays(cfg *Config) error {
+	if cfg.CurrentBranch == cfg.DefaultBranch || cfg.StoreLocation == cfg.StoreBase || !cfg.Settings.overlaysAny() {
+		return nil
+	}
+	items, err := listDir(cfg.StoreBase)
+	if err != nil {
+		return err
+	}
+	tombstones := readTombstones(cfg.StoreBase)
+	for _, item := range filterItems(items) {
+		if _, pending := tombstones[item]; pending {
+			continue
+		}
+		err := filepath.WalkDir(filepath.Join(cfg.StoreBase, item), func(path string, d os.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(cfg.StoreBase, path)
+			if err != nil || !cfg.Settings.overlays(rel) {
+				return err
+			}
+			return rebaseOverlay(cfg, rel)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebaseOverlay brings the default branch's changes to the overlaid file
+// rel into cfg's branch store, recording the default branch's copy as what
+// the layer is now based on.
+func rebaseOverlay(cfg *Config, rel string) error {
+	layer := filepath.Join(cfg.StoreLocation, rel)
+	basePath := filepath.Join(cfg.StoreLocation, defaultBaseDir, rel)
+	defaults, err := os.ReadFile(filepath.Join(cfg.StoreBase, rel))
+	if err != nil {
+		return err
+	}
+	base, baseErr := os.ReadFile(basePath)
+	data, err := os.ReadFile(layer)
+	switch {
+	case os.IsNotExist(err) && baseErr == nil:
+		return nil // The branch removed it
+	case os.IsNotExist(err):
+		infof("adding %s from the %s branch's store", rel, cfg.DefaultBranch)
+		cfg.journal.beforeWrite(layer)
+		if err := writeConfig(layer, defaults); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case baseErr == nil && !bytes.Equal(base, defaults):
+		if _, structured := configFormatOf(rel); !structured && isText(data) && isText(base) && isText(defaults) {
+			if err := mergeDefaultChanges(cfg, rel, layer, base, data, defaults); err != nil {
+				return err
+			}
+		}
+	case baseErr == nil:
+		return nil // Up to date
+	}
+	return writeConfig(basePath, defaults)
+}
+
+// mergeDefaultChanges merges the changes the default branch made to its
+// copy of rel since base into the branch's layer data at layer.
+func mergeDefaultChanges(cfg *Config, rel, layer string, base, data, defaults []byte) error {
+	lines, conflicts, ok := mergeLinesLabeled(splitLines(base), splitLines(data), splitLines(defaults), false, conflictBranch, conflictDefault)
+	if !ok {
+		warnf("%s changed too much on both this branch and %s to merge; keeping this branch's copy", rel, cfg.DefaultBranch)
+		return nil
+	}
+	if conflicts > 0 {
+		warnf("%s changed on both this branch and %s: %d conflict(s) marked with <<<<<<< in the branch's copy", rel, cfg.DefaultBranch, conflicts)
+		cfg.report.addConflicted(rel)
+	} else {
+		infof("merged %s's changes to %s into this branch's copy", cfg.DefaultBranch, rel)
+	}
+	cfg.journal.beforeWrite(layer)
+	return writeConfig(layer, []byte(strings.Join(lines, "")))
+}
@@ -0,0 +1,152 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	from := map[string]any{"model": "a", "env": map[string]any{"X": "1", "Y": "2"}, "gone": "x"}
+	to := map[string]any{"model": "a", "env": map[string]any{"X": "1", "Y": "3"}, "new": []any{"z"}}
+
+	patch := createMergePatch(from, to)
+	want := map[string]any{"env": map[string]any{"Y": "3"}, "gone": nil, "new": []any{"z"}}
+	if !reflect.DeepEqual(patch, want) {
+		t.Errorf("createMergePatch = %#v, want %#v", patch, want)
+	}
+	if got := applyMergePatch(from, patch); !reflect.DeepEqual(got, to) {
+		t.Errorf("applyMergePatch = %#v, want %#v", got, to)
+	}
+	if _, ok := from["gone"]; !ok {
+		t.Error("applyMergePatch changed its target")
+	}
+}
+
+// givenOverlaidBranch returns the config of a feature branch whose stores
+// overlay .claude/settings.json and config.yaml.
+func givenOverlaidBranch(t *testing.T) *Config {
+	t.Helper()
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	cfg.Settings.Items = map[string]ItemSettings{
+		".claude/settings.json": {Overlay: true},
+		"*.yaml":                {Overlay: true},
+	}
+	return cfg
+}
+
+func TestSyncOut_SavesOnlyTheBranchsChanges(t *testing.T) {
+	cfg := givenOverlaidBranch(t)
+	writeFile(t, filepath.Join(cfg.StoreBase, ".claude", "settings.json"), `{"model": "a", "theme": "dark"}`)
+	writeFile(t, filepath.Join(cfg.StoreBase, "config.yaml"), "servers:\n  github: npx\n  local: ./run\n")
+	writeFile(t, filepath.Join(cfg.RepoRoot, ".claude", "settings.json"), `{"model": "b", "theme": "dark"}`)
+	writeFile(t, filepath.Join(cfg.RepoRoot, "config.yaml"), "servers:\n  github: npx\n  local: ./run-feature\n")
+	for _, item := range []string{".claude", "config.yaml"} {
+		if err := addToExclude(cfg.RepoRoot, cfg.excludeFile(), item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "{\n  \"model\": \"b\"\n}\n")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "config.yaml"), "servers:\n  local: ./run-feature\n")
+	// The default branch's copies are left alone
+	assertFileContent(t, filepath.Join(cfg.StoreBase, ".claude", "settings.json"), `{"model": "a", "theme": "dark"}`)
+	if entry := readManifestEntry(t, cfg.StoreLocation, "config.yaml"); entry.Size != int64(len("servers:\n  local: ./run-feature\n")) {
+		t.Errorf("manifest entry = %+v, want the saved overlay", entry)
+	}
+}
+
+func TestSyncIn_AppliesBranchChangesToDefaults(t *testing.T) {
+	cfg := givenOverlaidBranch(t)
+	writeFile(t, filepath.Join(cfg.StoreLocation, "seeded"), "")
+	writeFile(t, filepath.Join(cfg.StoreBase, ".claude", "settings.json"), `{"model": "a", "theme": "light", "env": {"A": "1"}}`)
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), `{"model": "b", "env": {"B": "2"}, "theme": null}`)
+	writeFile(t, filepath.Join(cfg.StoreBase, "config.yaml"), "servers:\n  github: npx\n")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "config.yaml"), "servers:\n  local: ./run\n")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".claude", "settings.json"),
+		"{\n  \"env\": {\n    \"A\": \"1\",\n    \"B\": \"2\"\n  },\n  \"model\": \"b\"\n}\n")
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "config.yaml"), "servers:\n  github: npx\n  local: ./run\n")
+}
+
+func TestSyncIn_CopiesOverlaidFileWholeWithoutDefault(t *testing.T) {
+	cfg := givenOverlaidBranch(t)
+	writeFile(t, filepath.Join(cfg.StoreLocation, "config.yaml"), "only: here  # kept as is\n")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "config.yaml"), "only: here  # kept as is\n")
+}
+
+func TestParseSettings_ItemOverlay(t *testing.T) {
+	var s Settings
+	if err := parseSettings("[items.\".claude/settings.json\"]\noverlay = true\n", &s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.overlays(".claude/settings.json") || s.overlays(".claude/settings.local.json") {
+		t.Errorf("overlays disagrees with %+v", s.Items)
+	}
+}
+
+func TestSyncIn_BringsDefaultBranchChangesIntoLayers(t *testing.T) {
+	cfg := givenOverlaidBranch(t)
+	cfg.Settings.Items["CLAUDE.md"] = ItemSettings{Overlay: true}
+	cfg.Settings.Items["prompts/"] = ItemSettings{Overlay: true}
+	defaults := filepath.Join(cfg.StoreBase, "CLAUDE.md")
+	layer := filepath.Join(cfg.StoreLocation, "CLAUDE.md")
+	writeFile(t, defaults, "# Rules\nuse tabs\n\nend\n")
+	writeFile(t, layer, "# Rules\nuse tabs\n\nend\nfeature rule\n")
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, defaultBaseDir, "CLAUDE.md"), "# Rules\nuse tabs\n\nend\n")
+
+	// An improvement on the default branch, and a file it added
+	writeFile(t, defaults, "# Rules\nuse spaces\n\nend\n")
+	writeFile(t, filepath.Join(cfg.StoreBase, "prompts", "review.md"), "review")
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Rules\nuse spaces\n\nend\nfeature rule\n"
+	assertFileContent(t, layer, want)
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), want)
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "prompts", "review.md"), "review")
+
+	// A file the branch removed stays removed
+	if err := os.RemoveAll(filepath.Join(cfg.StoreLocation, "prompts")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(cfg.StoreBase, "prompts", "review.md"), "review v2")
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "prompts", "review.md"))
+}
+
+func TestSyncIn_MarksConflictsWithDefaultBranch(t *testing.T) {
+	cfg := givenOverlaidBranch(t)
+	cfg.Settings.Items["CLAUDE.md"] = ItemSettings{Overlay: true}
+	cfg.report = &SyncReport{}
+	writeFile(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "a\nb\nc\n")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "a\nbranch\nc\n")
+	writeFile(t, filepath.Join(cfg.StoreLocation, defaultBaseDir, "CLAUDE.md"), "a\nb\nc\n")
+	writeFile(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "a\ndefault\nc\n")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := "a\n" + conflictBranch + "branch\n" + conflictSep + "default\n" + conflictDefault + "c\n"
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), want)
+	if len(cfg.report.Conflicted) != 1 {
+		t.Errorf("conflicted = %q, want CLAUDE.md", cfg.report.Conflicted)
+	}
+}
@@ -0,0 +1,58 @@
+package wrapper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// aclAttrs are the extended attributes holding a file's POSIX ACLs: its
+// access ACL and, for directories, the default ACL new entries inherit.
+var aclAttrs = []string{"system.posix_acl_access", "system.posix_acl_default"}
+
+// copyOwnership gives dst the owner, group and POSIX ACLs of src, described
+// by srcInfo. Changing the owner needs privileges (root or CAP_CHOWN)
+// unless it stays the current user.
+func copyOwnership(src string, srcInfo os.FileInfo, dst string) error {
+	st, ok := srcInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	var errs []error
+	if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+		errs = append(errs, err)
+	}
+	for _, attr := range aclAttrs {
+		if attr == aclAttrs[1] && !srcInfo.IsDir() {
+			continue
+		}
+		value, err := getxattr(src, attr)
+		if errors.Is(err, unix.ENODATA) || errors.Is(err, unix.ENOTSUP) {
+			continue // No ACL, or a filesystem without them
+		}
+		if err == nil {
+			err = unix.Setxattr(dst, attr, value, 0)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to copy ACL of %s: %w", src, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// getxattr returns the value of the extended attribute attr of path.
+func getxattr(path, attr string) ([]byte, error) {
+	size, err := unix.Getxattr(path, attr, nil)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, size)
+	size, err = unix.Getxattr(path, attr, value)
+	if err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
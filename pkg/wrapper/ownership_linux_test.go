@@ -0,0 +1,96 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// posixACL encodes a POSIX access ACL granting uid read access, in the
+// format of the system.posix_acl_access attribute.
+func posixACL(uid uint32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(2)) // Version
+	for _, e := range []struct {
+		tag, perm uint16
+		id        uint32
+	}{
+		{0x01, 6, 0xffffffff}, // Owner: rw
+		{0x02, 4, uid},        // Named user: r
+		{0x04, 4, 0xffffffff}, // Group: r
+		{0x10, 4, 0xffffffff}, // Mask: r
+		{0x20, 4, 0xffffffff}, // Others: r
+	} {
+		binary.Write(&buf, binary.LittleEndian, e)
+	}
+	return buf.Bytes()
+}
+
+func TestCopyFile_PreservesOwnershipAndACL(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing a file's owner needs root")
+	}
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "service.conf"), filepath.Join(dir, "stored.conf")
+	writeFile(t, src, "owned by a service user")
+	if err := os.Chown(src, 12345, 23456); err != nil {
+		t.Fatal(err)
+	}
+	acl := posixACL(4242)
+	err := unix.Setxattr(src, aclAttrs[0], acl, 0)
+	if errors.Is(err, unix.ENOTSUP) {
+		acl = nil // The filesystem has no ACLs; still check ownership
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &copier{preserveOwnership: true}
+	if err := c.copyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := info.Sys().(*syscall.Stat_t); st.Uid != 12345 || st.Gid != 23456 {
+		t.Errorf("copy is owned by %d:%d, want 12345:23456", st.Uid, st.Gid)
+	}
+	if acl != nil {
+		if got, err := getxattr(dst, aclAttrs[0]); err != nil || !bytes.Equal(got, acl) {
+			t.Errorf("ACL not copied: %v, %v", got, err)
+		}
+	}
+}
+
+func TestKeepOwnership_WarnsOnce(t *testing.T) {
+	diagnostics := captureDiagnostics(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeFile(t, src, "x")
+	info, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &copier{preserveOwnership: true}
+	for i := 0; i < 2; i++ {
+		c.keepOwnership(src, info, filepath.Join(dir, "missing"))
+	}
+	if got := strings.Count(diagnostics.String(), "can't preserve ownership"); got != 1 {
+		t.Errorf("expected one warning, got %d:\n%s", got, diagnostics.String())
+	}
+
+	// Off by default
+	diagnostics.Reset()
+	new(copier).keepOwnership(src, info, filepath.Join(dir, "missing"))
+	if diagnostics.Len() != 0 {
+		t.Errorf("expected nothing without preserveOwnership, got %q", diagnostics.String())
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package wrapper
+
+import (
+	"errors"
+	"os"
+)
+
+// copyOwnership is only implemented on Linux.
+func copyOwnership(src string, srcInfo os.FileInfo, dst string) error {
+	return errors.New("preserving ownership is only supported on Linux")
+}
@@ -0,0 +1,81 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// passthroughReason reports whether the wrapper should skip syncing before
+// looking at the repository at all, and why: in CI there is no personal
+// workspace to restore and syncing would only write into the runner's home
+// directory, and a bare repository has no working tree to sync into.
+func passthroughReason(s Settings, dir string) (string, bool) {
+	if inCI() && !s.SyncInCI {
+		return "running in CI", true
+	}
+	if inBareRepo(dir) {
+		return "in a bare repository", true
+	}
+	return "", false
+}
+
+// logPassthrough notes that the wrapper was bypassed, if s asks for it.
+func logPassthrough(s Settings, reason string) {
+	if s.LogPassthrough {
+		infof("bypassed: %s", reason)
+	}
+}
+
+// inCI reports whether the CI environment variable is set, as CI services
+// do: CI=1 or CI=true, or on some services the service's name.
+func inCI() bool {
+	switch strings.ToLower(os.Getenv("CI")) {
+	case "", "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// inBareRepo reports whether dir ("" means the current directory) is in a
+// bare repository or inside a repository's git directory: the nearest
+// directory holding .git, or looking like a git directory itself, is the
+// latter. GIT_DIR is left for git to make sense of.
+func inBareRepo(dir string) bool {
+	if os.Getenv("GIT_DIR") != "" {
+		return false
+	}
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return false
+		}
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return false
+		}
+		if isGitDir(dir) {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// isGitDir reports whether dir has the layout of a git directory: a HEAD
+// file next to objects and refs directories.
+func isGitDir(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil || info.IsDir() {
+		return false
+	}
+	for _, sub := range []string{"objects", "refs"} {
+		if info, err := os.Stat(filepath.Join(dir, sub)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
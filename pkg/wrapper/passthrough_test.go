@@ -0,0 +1,79 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestInCI(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"", false},
+		{"0", false},
+		{"false", false},
+		{"1", true},
+		{"true", true},
+		{"woodpecker", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("CI", tt.value)
+		if got := inCI(); got != tt.want {
+			t.Errorf("inCI() with CI=%q = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPassthroughReason_CI(t *testing.T) {
+	t.Setenv("CI", "1")
+	dir := t.TempDir()
+
+	if reason, ok := passthroughReason(Settings{}, dir); !ok || reason != "running in CI" {
+		t.Errorf("expected CI passthrough, got %q, %v", reason, ok)
+	}
+	if _, ok := passthroughReason(Settings{SyncInCI: true}, dir); ok {
+		t.Error("sync_in_ci should keep the wrapper syncing in CI")
+	}
+}
+
+func TestInBareRepo(t *testing.T) {
+	t.Setenv("GIT_DIR", "")
+	bare := t.TempDir()
+	if _, err := git.PlainInit(bare, true); err != nil {
+		t.Fatal(err)
+	}
+	if !inBareRepo(bare) {
+		t.Error("expected a bare repository to be detected")
+	}
+	if !inBareRepo(filepath.Join(bare, "refs", "heads")) {
+		t.Error("expected a bare repository's subdirectory to be detected")
+	}
+
+	repoRoot, _ := givenGitRepo(t)
+	if inBareRepo(repoRoot) {
+		t.Error("a working tree is not a bare repository")
+	}
+	if !inBareRepo(filepath.Join(repoRoot, ".git")) {
+		t.Error("expected a working tree's git directory to pass through")
+	}
+	if inBareRepo(t.TempDir()) {
+		t.Error("a plain directory is not a bare repository")
+	}
+}
+
+func TestLogPassthrough(t *testing.T) {
+	buf := captureDiagnostics(t)
+
+	logPassthrough(Settings{}, "running in CI")
+	if buf.Len() != 0 {
+		t.Errorf("expected no log without log_passthrough, got %q", buf.String())
+	}
+	logPassthrough(Settings{LogPassthrough: true}, "running in CI")
+	if !strings.Contains(buf.String(), "claude-wrapper: bypassed: running in CI") {
+		t.Errorf("expected bypass to be logged, got %q", buf.String())
+	}
+}
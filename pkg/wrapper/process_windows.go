@@ -0,0 +1,35 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code of a process that hasn't exited:
+// STILL_ACTIVE, which x/sys/windows only has as STATUS_PENDING.
+const stillActive = uint32(windows.STATUS_PENDING)
+
+// processAlive reports whether a process with the given PID exists.
+// Signalling a process doesn't work on Windows, so its handle is asked for
+// an exit code instead.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	if pid == os.Getpid() {
+		return true
+	}
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Another user's process can't be opened, but exists
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(handle)
+	var code uint32
+	if err := windows.GetExitCodeProcess(handle, &code); err != nil {
+		return true // Can't tell; don't take its store away
+	}
+	return code == stillActive
+}
@@ -0,0 +1,68 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// profileEnv selects a profile, like --profile. The wrapper sets it for
+// claude so git hooks run during the session use the same profile.
+const profileEnv = "CLAUDE_WRAPPER_PROFILE"
+
+// validProfileName matches names usable as a profile (and so in a path).
+var validProfileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Profile configures a named, independent set of personal files, selected
+// with --profile or CLAUDE_WRAPPER_PROFILE.
+type Profile struct {
+	// Store is the directory holding the profile's repository stores and
+	// its _templates/ (default ~/.workspaces-<name>).
+	Store string `toml:"store"`
+	// Templates replaces the top-level templates configuration for the
+	// profile's template sets.
+	Templates map[string]TemplateSet `toml:"templates"`
+}
+
+// storeRoot returns the directory holding the repository stores of the
+// selected profile: ~/.workspaces without one.
+func (s Settings) storeRoot() (string, error) {
+	if s.Profile != "" && !validProfileName.MatchString(s.Profile) {
+		return "", fmt.Errorf("invalid profile name %q", s.Profile)
+	}
+	if p, ok := s.Profiles[s.Profile]; ok && s.Profile != "" && p.Store != "" {
+		return filepath.Clean(expandHome(p.Store)), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	if s.Profile != "" {
+		return filepath.Join(homeDir, ".workspaces-"+s.Profile), nil
+	}
+	return filepath.Join(homeDir, ".workspaces"), nil
+}
+
+// templateSetConfig returns the template set configuration of the selected
+// profile.
+func (s Settings) templateSetConfig() map[string]TemplateSet {
+	if p, ok := s.Profiles[s.Profile]; ok && s.Profile != "" && p.Templates != nil {
+		return p.Templates
+	}
+	return s.Templates
+}
+
+// forProfile returns a copy of cfg using profile's store for the same
+// repository and branch.
+func (cfg *Config) forProfile(profile string) (*Config, error) {
+	other := *cfg
+	other.Settings.Profile = profile
+	root, err := other.Settings.storeRoot()
+	if err != nil {
+		return nil, err
+	}
+	other.StoreBase = filepath.Join(root, filepath.Base(cfg.StoreBase))
+	other.StoreLocation = branchStoreLocation(other.StoreBase, cfg.CurrentBranch, cfg.DefaultBranch)
+	return &other, nil
+}
@@ -0,0 +1,139 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSettingsStoreRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name     string
+		settings Settings
+		want     string
+	}{
+		{
+			name: "no profile",
+			want: filepath.Join(home, ".workspaces"),
+		},
+		{
+			name:     "unconfigured profile",
+			settings: Settings{Profile: "work"},
+			want:     filepath.Join(home, ".workspaces-work"),
+		},
+		{
+			name: "profile with its own store",
+			settings: Settings{
+				Profile:  "work",
+				Profiles: map[string]Profile{"work": {Store: "~/work/stores/"}},
+			},
+			want: filepath.Join(home, "work", "stores"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.settings.storeRoot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("storeRoot = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsStoreRoot_RejectsPathLikeName(t *testing.T) {
+	if _, err := (Settings{Profile: "../elsewhere"}).storeRoot(); err == nil {
+		t.Error("expected an error for a profile name that is a path")
+	}
+}
+
+func TestSettingsTemplateSetConfig(t *testing.T) {
+	top := map[string]TemplateSet{"go": {Repos: []string{"*/go-*"}}}
+	work := map[string]TemplateSet{"go": {Repos: []string{"*/work-*"}}}
+	s := Settings{Templates: top, Profiles: map[string]Profile{"work": {Templates: work}, "home": {}}}
+
+	if got := s.templateSetConfig(); !reflect.DeepEqual(got, top) {
+		t.Errorf("without a profile got %v, want the top-level sets", got)
+	}
+	s.Profile = "work"
+	if got := s.templateSetConfig(); !reflect.DeepEqual(got, work) {
+		t.Errorf("with profile work got %v, want its sets", got)
+	}
+	s.Profile = "home"
+	if got := s.templateSetConfig(); !reflect.DeepEqual(got, top) {
+		t.Errorf("with profile home got %v, want the top-level sets", got)
+	}
+}
+
+func TestConfigForProfile(t *testing.T) {
+	workStore := t.TempDir()
+	cfg, _ := givenConfig(t, givenRepo(t), configOpts{currentBranch: "feature"})
+	cfg.Settings.Profiles = map[string]Profile{"work": {Store: workStore}}
+
+	work, err := cfg.forProfile("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBase := filepath.Join(workStore, filepath.Base(cfg.StoreBase))
+	if work.StoreBase != wantBase {
+		t.Errorf("StoreBase = %s, want %s", work.StoreBase, wantBase)
+	}
+	if want := filepath.Join(wantBase, branchesDir, "feature"); work.StoreLocation != want {
+		t.Errorf("StoreLocation = %s, want %s", work.StoreLocation, want)
+	}
+	if cfg.Settings.Profile != "" {
+		t.Error("forProfile modified the original config")
+	}
+}
+
+func TestScenario_UserSwitchesProfile(t *testing.T) {
+	t.Run("Given the last session synced in the work profile", func(t *testing.T) {
+		repoRoot := givenRepo(t)
+		cfg, _ := givenConfig(t, repoRoot, configOpts{})
+		cfg.Settings.Profiles = map[string]Profile{
+			"work": {Store: t.TempDir()},
+			"home": {Store: t.TempDir()},
+		}
+		work, err := cfg.forProfile("work")
+		if err != nil {
+			t.Fatal(err)
+		}
+		home, err := cfg.forProfile("home")
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(work.StoreLocation, "CLAUDE.md"), "work config")
+		writeFile(t, filepath.Join(home.StoreLocation, "notes.md"), "home notes")
+		if err := syncInAfterSwitch(work); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "edited at work")
+
+		t.Run("When the next session uses the home profile", func(t *testing.T) {
+			if err := syncInAfterSwitch(home); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("Then the edit is saved to the work profile's store", func(t *testing.T) {
+				assertFileContent(t, filepath.Join(work.StoreLocation, "CLAUDE.md"), "edited at work")
+			})
+
+			t.Run("Then only the home profile's files are in the working tree", func(t *testing.T) {
+				assertNotExists(t, filepath.Join(repoRoot, "CLAUDE.md"))
+				assertFileContent(t, filepath.Join(repoRoot, "notes.md"), "home notes")
+			})
+
+			t.Run("Then the home profile is recorded as synced in", func(t *testing.T) {
+				if branch, profile := readSyncState(repoRoot); branch != "main" || profile != "home" {
+					t.Errorf("sync state = %q, %q; want main, home", branch, profile)
+				}
+			})
+		})
+	})
+}
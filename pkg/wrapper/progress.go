@@ -0,0 +1,114 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// progressDelay is how long a sync runs before progress is shown, so
+	// ordinary fast syncs stay silent.
+	progressDelay = time.Second
+	// The progress intervals throttle updates: a terminal line is redrawn in
+	// place, while other outputs get a new line each interval.
+	ttyProgressInterval  = 100 * time.Millisecond
+	lineProgressInterval = time.Second
+)
+
+// progressMeter reports files and bytes copied during a long sync. A nil
+// *progressMeter is valid and reports nothing.
+type progressMeter struct {
+	w   io.Writer
+	tty bool
+	now func() time.Time
+
+	phase string
+	root  string
+	start time.Time
+	last  time.Time
+	files int
+	bytes int64
+	shown bool
+}
+
+// newProgressMeter returns a meter writing to stderr, or nil when quiet.
+func newProgressMeter(settings Settings) *progressMeter {
+	if settings.Quiet {
+		return nil
+	}
+	return &progressMeter{w: os.Stderr, tty: term.IsTerminal(int(os.Stderr.Fd())), now: time.Now}
+}
+
+// begin starts timing a sync phase; copied paths are shown relative to root.
+func (p *progressMeter) begin(phase, root string) {
+	if p == nil {
+		return
+	}
+	p.phase, p.root = phase, root
+	p.start = p.now()
+	p.last = time.Time{}
+	p.files, p.bytes = 0, 0
+	p.shown = false
+}
+
+// add records a copied file and prints progress once the phase has run for
+// longer than progressDelay.
+func (p *progressMeter) add(src string, n int64) {
+	if p == nil {
+		return
+	}
+	p.files++
+	p.bytes += n
+
+	now := p.now()
+	interval := lineProgressInterval
+	if p.tty {
+		interval = ttyProgressInterval
+	}
+	if now.Sub(p.start) < progressDelay || now.Sub(p.last) < interval {
+		return
+	}
+	p.last = now
+	p.print(src)
+}
+
+// end finishes the phase, printing a final total if progress was shown.
+func (p *progressMeter) end() {
+	if p == nil || !p.shown {
+		return
+	}
+	p.print("")
+	if p.tty {
+		fmt.Fprintln(p.w)
+	}
+}
+
+func (p *progressMeter) print(src string) {
+	line := fmt.Sprintf("claude-wrapper: %s: %d file(s), %s", p.phase, p.files, formatBytes(p.bytes))
+	if src == "" {
+		line += fmt.Sprintf(" in %s", p.now().Sub(p.start).Round(100*time.Millisecond))
+	} else {
+		line += " (" + p.relative(src) + ")"
+	}
+	if p.tty {
+		// Redraw in place, clearing the rest of the previous line
+		fmt.Fprintf(p.w, "\r%s\x1b[K", line)
+	} else {
+		fmt.Fprintln(p.w, line)
+	}
+	p.shown = true
+}
+
+// relative shortens src for display.
+func (p *progressMeter) relative(src string) string {
+	if rel, err := filepath.Rel(p.root, src); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return filepath.Base(src)
+}
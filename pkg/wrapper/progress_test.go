@@ -0,0 +1,79 @@
+package wrapper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// givenProgressMeter returns a meter writing to a buffer with a clock the
+// test advances by hand.
+func givenProgressMeter(tty bool) (*progressMeter, *bytes.Buffer, *time.Time) {
+	var buf bytes.Buffer
+	now := time.Unix(0, 0)
+	p := &progressMeter{w: &buf, tty: tty, now: func() time.Time { return now }}
+	return p, &buf, &now
+}
+
+func TestProgressMeter_SilentForFastSyncs(t *testing.T) {
+	p, buf, now := givenProgressMeter(false)
+	p.begin("sync out", "/repo")
+	for i := 0; i < 100; i++ {
+		p.add("/repo/notes/a.md", 10)
+		*now = now.Add(time.Millisecond)
+	}
+	p.end()
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
+
+func TestProgressMeter_ReportsLongSyncs(t *testing.T) {
+	p, buf, now := givenProgressMeter(false)
+	p.begin("sync out", "/repo")
+	p.add("/repo/data/a.bin", 1024)
+	*now = now.Add(1500 * time.Millisecond)
+	p.add("/repo/data/b.bin", 1024)
+	*now = now.Add(100 * time.Millisecond)
+	p.add("/repo/data/c.bin", 1024) // throttled
+	p.end()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a progress line and a total, got %q", buf.String())
+	}
+	if want := "claude-wrapper: sync out: 2 file(s), 2.0 KiB (data/b.bin)"; lines[0] != want {
+		t.Errorf("expected %q, got %q", want, lines[0])
+	}
+	if want := "claude-wrapper: sync out: 3 file(s), 3.0 KiB in 1.6s"; lines[1] != want {
+		t.Errorf("expected %q, got %q", want, lines[1])
+	}
+}
+
+func TestProgressMeter_RedrawsOnTerminal(t *testing.T) {
+	p, buf, now := givenProgressMeter(true)
+	p.begin("sync in", "/repo")
+	*now = now.Add(2 * time.Second)
+	p.add("/elsewhere/x.md", 1)
+	p.end()
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "\rclaude-wrapper: sync in: 1 file(s), 1 B (x.md)\x1b[K") || !strings.HasSuffix(out, "\n") {
+		t.Errorf("unexpected terminal output %q", out)
+	}
+}
+
+func TestProgressMeter_NilIsSafe(t *testing.T) {
+	var p *progressMeter
+	p.begin("sync in", "/repo")
+	p.add("/repo/x", 1)
+	p.end()
+}
+
+func TestNewProgressMeter_QuietDisables(t *testing.T) {
+	if p := newProgressMeter(Settings{Quiet: true}); p != nil {
+		t.Error("expected no meter when quiet")
+	}
+}
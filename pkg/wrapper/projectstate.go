@@ -0,0 +1,151 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// projectStateDir holds a branch's snapshot of Claude Code's state for
+	// the repository, under each branch store.
+	projectStateDir = "claude-project"
+	// projectStateFile records, per working tree, which branch's state
+	// Claude Code's project directory holds. It lives in the git directory.
+	projectStateFile = "claude-wrapper-project-state"
+)
+
+// nonAlphanumeric matches the characters Claude Code replaces with "-" when
+// naming a project's state directory after its path.
+var nonAlphanumeric = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// claudeProjectDir returns where Claude Code keeps its state (conversations,
+// history) for the project at repoRoot: ~/.claude/projects/<encoded path>,
+// or under $CLAUDE_CONFIG_DIR if set.
+func claudeProjectDir(repoRoot string) (string, error) {
+	configDir := os.Getenv("CLAUDE_CONFIG_DIR")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configDir = filepath.Join(home, ".claude")
+	}
+	return filepath.Join(configDir, "projects", nonAlphanumeric.ReplaceAllString(repoRoot, "-")), nil
+}
+
+// syncInProjectState restores the branch's snapshot of Claude Code's
+// project state. When the project directory holds another branch's state,
+// that is saved to the other branch's store first, and a branch without a
+// snapshot starts with none. State from before the setting was turned on
+// is kept for the first branch synced.
+func syncInProjectState(cfg *Config) error {
+	if !cfg.Settings.ClaudeProjectState {
+		return nil
+	}
+	defer func(start time.Time) { cfg.report.addTiming("project_state", time.Since(start)) }(time.Now())
+	live, err := claudeProjectDir(cfg.RepoRoot)
+	if err != nil {
+		return fmt.Errorf("failed to locate Claude Code project state: %w", err)
+	}
+
+	holder := projectStateBranch(cfg.RepoRoot)
+	if holder == cfg.CurrentBranch {
+		return nil // Still this branch's state
+	}
+	if holder != "" {
+		if err := saveProjectState(cfg.forBranch(holder), live); err != nil {
+			return err
+		}
+	}
+
+	snapshot := filepath.Join(cfg.StoreLocation, projectStateDir)
+	if _, err := os.Stat(snapshot); err == nil {
+		if err := replaceDir(snapshot, live); err != nil {
+			return fmt.Errorf("failed to restore Claude Code project state: %w", err)
+		}
+	} else if holder != "" {
+		if err := os.RemoveAll(live); err != nil {
+			return fmt.Errorf("failed to clear Claude Code project state: %w", err)
+		}
+	}
+	recordProjectStateBranch(cfg.RepoRoot, cfg.CurrentBranch)
+	return nil
+}
+
+// syncOutProjectState snapshots Claude Code's project state into the
+// branch store, if it holds this branch's state.
+func syncOutProjectState(cfg *Config) error {
+	if !cfg.Settings.ClaudeProjectState || projectStateBranch(cfg.RepoRoot) != cfg.CurrentBranch {
+		return nil
+	}
+	defer func(start time.Time) { cfg.report.addTiming("project_state", time.Since(start)) }(time.Now())
+	live, err := claudeProjectDir(cfg.RepoRoot)
+	if err != nil {
+		return fmt.Errorf("failed to locate Claude Code project state: %w", err)
+	}
+	return saveProjectState(cfg, live)
+}
+
+// saveProjectState replaces cfg's snapshot with the project state in live.
+func saveProjectState(cfg *Config, live string) error {
+	if _, err := os.Stat(live); err != nil {
+		return nil // Nothing recorded yet
+	}
+	if err := os.MkdirAll(cfg.StoreLocation, 0755); err != nil {
+		return err
+	}
+	if err := replaceDir(live, filepath.Join(cfg.StoreLocation, projectStateDir)); err != nil {
+		return fmt.Errorf("failed to save Claude Code project state for %s: %w", cfg.CurrentBranch, err)
+	}
+	return nil
+}
+
+// replaceDir replaces dst with a copy of the directory src. The copy is
+// made beside dst first, so a failed copy leaves dst as it was.
+func replaceDir(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	tmp := dst + ".claude-wrapper-tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := copyDir(src, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// projectStateBranch returns the branch whose state Claude Code's project
+// directory holds for the working tree at repoRoot, or "" if unknown.
+func projectStateBranch(repoRoot string) string {
+	gitDir, err := resolveGitDir(repoRoot)
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(gitDir, projectStateFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// recordProjectStateBranch remembers that the project directory now holds
+// branch's state.
+func recordProjectStateBranch(repoRoot, branch string) {
+	gitDir, err := resolveGitDir(repoRoot)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, projectStateFile), []byte(branch+"\n"), 0644); err != nil {
+		warnf("failed to record Claude Code project state branch: %v", err)
+	}
+}
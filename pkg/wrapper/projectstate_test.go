@@ -0,0 +1,76 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// givenProjectState returns a config with claude_project_state on and the
+// directory Claude Code keeps the repository's state in.
+func givenProjectState(t *testing.T) (*Config, string) {
+	t.Helper()
+	t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir())
+	cfg, _ := givenConfig(t, givenRepo(t), configOpts{})
+	cfg.Settings.ClaudeProjectState = true
+	live, err := claudeProjectDir(cfg.RepoRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg, live
+}
+
+func TestClaudeProjectDir(t *testing.T) {
+	t.Setenv("CLAUDE_CONFIG_DIR", "/config")
+
+	got, err := claudeProjectDir("/home/me/my.repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/config/projects/-home-me-my-repo"; got != want {
+		t.Errorf("claudeProjectDir = %s, want %s", got, want)
+	}
+}
+
+func TestProjectState_ExistingStateKeptAndSaved(t *testing.T) {
+	cfg, live := givenProjectState(t)
+	writeFile(t, filepath.Join(live, "session.jsonl"), "earlier conversation")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(live, "session.jsonl"), "earlier conversation")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, projectStateDir, "session.jsonl"), "earlier conversation")
+}
+
+func TestProjectState_PerBranch(t *testing.T) {
+	cfg, live := givenProjectState(t)
+	writeFile(t, filepath.Join(live, "session.jsonl"), "main conversation")
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	// A new branch starts without main's conversations, which are saved
+	feature := cfg.forBranch("feature")
+	if err := syncIn(feature); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	assertNotExists(t, filepath.Join(live, "session.jsonl"))
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, projectStateDir, "session.jsonl"), "main conversation")
+
+	writeFile(t, filepath.Join(live, "feature.jsonl"), "feature conversation")
+	if err := syncOut(feature); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	// Back on main, its conversations return
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(live, "session.jsonl"), "main conversation")
+	assertNotExists(t, filepath.Join(live, "feature.jsonl"))
+	assertFileContent(t, filepath.Join(feature.StoreLocation, projectStateDir, "feature.jsonl"), "feature conversation")
+}
@@ -0,0 +1,165 @@
+package wrapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// promotesMerged reports whether promote_merged has cleanup promote the
+// diverged files of merged branches, asking first or not.
+func (s Settings) promotesMerged() bool {
+	return s.PromoteMerged == "ask" || s.PromoteMerged == "always"
+}
+
+// recordMerged notes in the metadata of branch's store at path whether the
+// branch is among merged, the branches merged into the default branch, so
+// that once it is deleted from git cleanup knows it was merged.
+func recordMerged(cfg *Config, branch, path string, merged map[string]bool, now time.Time) {
+	meta, _ := readBranchMeta(path)
+	switch {
+	case merged[branch] && meta.MergedAt == nil:
+		updateBranchMeta(path, cfg.RepoRoot, now, func(meta *branchMeta) {
+			at := now.UTC()
+			meta.MergedAt = &at
+		})
+	case !merged[branch] && meta.MergedAt != nil:
+		// Committed to again since
+		updateBranchMeta(path, cfg.RepoRoot, now, func(meta *branchMeta) {
+			meta.MergedAt = nil
+		})
+	}
+}
+
+// confirmPromotionFunc decides whether the diverged files of a merged
+// branch are promoted under promote_merged = "ask". Replaced in tests.
+var confirmPromotionFunc = confirmPromotion
+
+// confirmPromotion asks before promoting when attached to a terminal.
+// assume_yes/--yes promotes; automation (no TTY) leaves the files alone.
+func confirmPromotion(cfg *Config, branch string, files []string) bool {
+	if cfg.Settings.AssumeYes {
+		return true
+	}
+	if !isInteractive() {
+		return false
+	}
+	return promptPromotion(os.Stdin, os.Stderr, branch, cfg.DefaultBranch, files)
+}
+
+// promptPromotion lists the diverged files of the merged branch and asks
+// whether to copy them into the default branch's store. Anything but an
+// explicit yes leaves them.
+func promptPromotion(in io.Reader, out io.Writer, branch, defaultBranch string, files []string) bool {
+	fmt.Fprintf(out, "claude-wrapper: branch %q was merged into %s and deleted.\n", branch, defaultBranch)
+	fmt.Fprintf(out, "Its store has %d file(s) that differ from the %s branch's:\n", len(files), defaultBranch)
+	for i, rel := range files {
+		if i == maxListedFiles {
+			fmt.Fprintf(out, "  ... and %d more\n", len(files)-maxListedFiles)
+			break
+		}
+		fmt.Fprintf(out, "  %s\n", rel)
+	}
+	fmt.Fprintf(out, "Copy them into the %s branch's store? [y/N] ", defaultBranch)
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	fmt.Fprintln(out, "Leaving them; the store is deleted with the branch after its grace period.")
+	return false
+}
+
+// promoteMerged copies the diverged files of the store at path into the
+// default branch's store, if the deleted branch was merged into the default
+// branch (see recordDeletion) and promote_merged allows it.
+func promoteMerged(cfg *Config, branch, path string) {
+	if meta, _ := readBranchMeta(path); meta.Deletion != deletedMerged {
+		return
+	}
+	files, err := promotableFiles(cfg, path)
+	if err != nil {
+		warnf("failed to list the files of merged branch %s: %v", branch, err)
+		return
+	}
+	if len(files) == 0 {
+		return
+	}
+	if cfg.Settings.PromoteMerged == "ask" && !confirmPromotionFunc(cfg, branch, files) {
+		return
+	}
+	if err := promoteFiles(cfg, path, files); err != nil {
+		warnf("failed to promote the files of merged branch %s: %v", branch, err)
+		return
+	}
+	infof("promoted %d file(s) of merged branch %s into the %s branch's store", len(files), branch, cfg.DefaultBranch)
+}
+
+// promotableFiles lists the store-relative paths of the files of the
+// branch store at path that differ from what it was seeded with. Files the
+// default branch's store changed too are left out, with a warning, rather
+// than have its changes overwritten.
+func promotableFiles(cfg *Config, path string) ([]string, error) {
+	m, err := readManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = walkStoreFiles(path, func(rel string) error {
+		if !m.diverged(rel) {
+			return nil
+		}
+		defaults := filepath.Join(cfg.StoreBase, rel)
+		if _, err := os.Stat(defaults); err == nil && !promotesOverlay(cfg, rel) {
+			if !sameContents(filepath.Join(path, defaultBaseDir, rel), defaults) {
+				warnf("not promoting %s: the %s branch's store changed it too", rel, cfg.DefaultBranch)
+				return nil
+			}
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
+
+// promotesOverlay reports whether rel is an overlaid config file, whose
+// store copy holds only the branch's changes to the default branch's copy.
+func promotesOverlay(cfg *Config, rel string) bool {
+	_, ok := configFormatOf(rel)
+	return ok && cfg.Settings.overlays(rel)
+}
+
+// promoteFiles copies files from the branch store at path into the default
+// branch's store. Overlaid config files are applied to the default
+// branch's copy rather than replacing it.
+func promoteFiles(cfg *Config, path string, files []string) error {
+	c := &copier{saved: make(map[string]manifestEntry)}
+	for _, rel := range files {
+		src, dst := filepath.Join(path, rel), filepath.Join(cfg.StoreBase, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if promotesOverlay(cfg, rel) {
+			format, _ := configFormatOf(rel)
+			merged, err := overlayConfig(format, rel, dst, src, dst, true)
+			if err != nil {
+				return err
+			}
+			if merged {
+				if err := c.recordSaved(dst); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := c.copyFile(src, dst); err != nil {
+			return err
+		}
+	}
+	return updateManifest(cfg.StoreBase, c.saved)
+}
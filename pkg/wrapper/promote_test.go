@@ -0,0 +1,108 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// givenMergedBranch returns the config of the default branch of a repository
+// whose feature branch changed notes.md, added own.md and was seen merged
+// into the default branch by cleanup.
+func givenMergedBranch(t *testing.T, mode string) (*Config, string) {
+	t.Helper()
+	feature := givenSeededBranch(t)
+	writeFile(t, filepath.Join(feature.RepoRoot, "notes.md"), "feature notes")
+	writeFile(t, filepath.Join(feature.RepoRoot, "own.md"), "branch only")
+	if err := addToExclude(feature.RepoRoot, feature.excludeFile(), "own.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncOut(feature); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := feature.forBranch("main")
+	cfg.Settings.PromoteMerged = mode
+	orig := mergedBranchesFunc
+	mergedBranchesFunc = func(string) (map[string]bool, error) { return map[string]bool{"main": true, "feature": true}, nil }
+	t.Cleanup(func() { mergedBranchesFunc = orig })
+	withBranches(t, map[string]bool{"main": true, "feature": true})
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if meta, _ := readBranchMeta(feature.StoreLocation); meta.MergedAt == nil {
+		t.Fatalf("branch meta = %+v, want the merge noted", meta)
+	}
+	return cfg, feature.StoreLocation
+}
+
+func TestCleanupDeletedBranches_PromotesMergedBranchFiles(t *testing.T) {
+	cfg, store := givenMergedBranch(t, "always")
+
+	withBranches(t, map[string]bool{"main": true})
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreBase, "notes.md"), "feature notes")
+	assertFileContent(t, filepath.Join(cfg.StoreBase, "own.md"), "branch only")
+	assertFileContent(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "v1")
+	assertExists(t, filepath.Join(store, deletionMarker))
+}
+
+func TestCleanupDeletedBranches_AsksBeforePromoting(t *testing.T) {
+	cfg, _ := givenMergedBranch(t, "ask")
+	var offered []string
+	orig := confirmPromotionFunc
+	confirmPromotionFunc = func(_ *Config, _ string, files []string) bool {
+		offered = files
+		return false
+	}
+	t.Cleanup(func() { confirmPromotionFunc = orig })
+
+	withBranches(t, map[string]bool{"main": true})
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(offered, ",") != "notes.md,own.md" {
+		t.Errorf("offered %q, want notes.md and own.md", offered)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreBase, "notes.md"), "notes v1")
+	assertNotExists(t, filepath.Join(cfg.StoreBase, "own.md"))
+}
+
+func TestPromotableFiles_LeavesOutFilesChangedOnTheDefaultBranchToo(t *testing.T) {
+	cfg, store := givenMergedBranch(t, "always")
+	writeFile(t, filepath.Join(cfg.StoreBase, "notes.md"), "notes v2")
+
+	files, err := promotableFiles(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(files, ",") != "own.md" {
+		t.Errorf("promotable = %q, want only own.md", files)
+	}
+}
+
+func TestPromptPromotion(t *testing.T) {
+	var out bytes.Buffer
+	if !promptPromotion(strings.NewReader("y\n"), &out, "feature", "main", []string{"notes.md"}) {
+		t.Error("expected yes to promote")
+	}
+	if !strings.Contains(out.String(), "  notes.md\n") {
+		t.Errorf("expected the files listed, got:\n%s", out.String())
+	}
+	if promptPromotion(strings.NewReader("\n"), &out, "feature", "main", []string{"notes.md"}) {
+		t.Error("expected no answer to leave the files")
+	}
+}
+
+func TestParseSettings_PromoteMerged(t *testing.T) {
+	var s Settings
+	if err := parseSettings(`promote_merged = "ask"`, &s); err != nil || !s.promotesMerged() {
+		t.Fatalf("parseSettings = %v, promote_merged %q", err, s.PromoteMerged)
+	}
+	if err := parseSettings(`promote_merged = "yes"`, &Settings{}); err == nil {
+		t.Error("expected error for unknown promote_merged mode")
+	}
+}
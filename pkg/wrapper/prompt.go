@@ -0,0 +1,106 @@
+package wrapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// maxListedFiles caps how many files a deletion prompt lists individually.
+const maxListedFiles = 20
+
+// isInteractive reports whether the wrapper can prompt the user: both stdin
+// and stderr must be attached to a terminal.
+func isInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// confirmBranchDeletionFunc decides whether an expired branch store may be
+// deleted. Replaced in tests.
+var confirmBranchDeletionFunc = confirmBranchDeletion
+
+// confirmBranchDeletion asks before deleting an expired branch store when
+// attached to a terminal. Automation (no TTY) and assume_yes/--yes keep the
+// silent behavior.
+func confirmBranchDeletion(cfg *Config, branch, path string) bool {
+	if cfg.Settings.AssumeYes || !isInteractive() {
+		return true
+	}
+	meta, _ := readBranchMeta(path)
+	return promptBranchDeletion(os.Stdin, os.Stderr, branch, path, cfg.Settings.graceDays(meta.Deletion))
+}
+
+// promptBranchDeletion lists the files in a branch store with their sizes
+// and asks whether to delete it, the branch having been deleted more than
+// graceDays ago. Anything but an explicit yes keeps it.
+func promptBranchDeletion(in io.Reader, out io.Writer, branch, path string, graceDays int) bool {
+	why := fmt.Sprintf("was deleted more than %d days ago", graceDays)
+	return promptStoreDeletion(in, out, fmt.Sprintf("branch %q", branch), why, path, graceDays)
+}
+
+// deletedWhy is why the store of a branch or repository gone for the grace
+// period is up for deletion.
+var deletedWhy = fmt.Sprintf("was deleted more than %d days ago", deletionGraceDays)
+
+// promptStoreDeletion lists the files in the store at path, belonging to
+// what, with their sizes and asks whether to delete it, saying why (as in
+// "what why") and that declining asks again in askAgainDays.
+func promptStoreDeletion(in io.Reader, out io.Writer, what, why, path string, askAgainDays int) bool {
+	files, total := storeContents(path)
+
+	fmt.Fprintf(out, "claude-wrapper: %s %s.\n", what, why)
+	fmt.Fprintf(out, "Its personal files (%s) will be permanently deleted from %s:\n", formatBytes(total), path)
+	for i, f := range files {
+		if i == maxListedFiles {
+			fmt.Fprintf(out, "  ... and %d more\n", len(files)-maxListedFiles)
+			break
+		}
+		fmt.Fprintf(out, "  %10s  %s\n", formatBytes(f.size), f.path)
+	}
+	fmt.Fprint(out, "Delete now? [y/N] ")
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	fmt.Fprintf(out, "Keeping %s; you will be asked again in %d days.\n", what, askAgainDays)
+	return false
+}
+
+type storeFile struct {
+	path string
+	size int64
+}
+
+// storeContents lists the regular files under root (relative paths, sorted)
+// along with their total size. Wrapper markers are left out.
+func storeContents(root string) ([]storeFile, int64) {
+	var files []storeFile
+	var total int64
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if rel == deletionMarker || rel == branchMetaFile {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, storeFile{path: rel, size: info.Size()})
+		total += info.Size()
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, total
+}
@@ -0,0 +1,106 @@
+package wrapper
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromptBranchDeletion(t *testing.T) {
+	store := t.TempDir()
+	writeFile(t, filepath.Join(store, "CLAUDE.md"), strings.Repeat("x", 2048))
+	writeFile(t, filepath.Join(store, "notes", "todo.md"), "todo")
+	writeFile(t, filepath.Join(store, deletionMarker), "12345")
+
+	tests := []struct {
+		answer   string
+		expected bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("answer %q", tt.answer), func(t *testing.T) {
+			var out bytes.Buffer
+			got := promptBranchDeletion(strings.NewReader(tt.answer), &out, "feature/old", store, deletionGraceDays)
+			if got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+
+			prompt := out.String()
+			for _, want := range []string{`branch "feature/old"`, "2.0 KiB  CLAUDE.md", filepath.Join("notes", "todo.md"), "[y/N]"} {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt missing %q:\n%s", want, prompt)
+				}
+			}
+			if strings.Contains(prompt, deletionMarker) {
+				t.Errorf("prompt should not list the deletion marker:\n%s", prompt)
+			}
+		})
+	}
+}
+
+func TestPromptBranchDeletion_TruncatesLongListings(t *testing.T) {
+	store := t.TempDir()
+	for i := 0; i < maxListedFiles+5; i++ {
+		writeFile(t, filepath.Join(store, fmt.Sprintf("file%02d.txt", i)), "x")
+	}
+
+	var out bytes.Buffer
+	promptBranchDeletion(strings.NewReader("n\n"), &out, "old", store, deletionGraceDays)
+	if !strings.Contains(out.String(), "... and 5 more") {
+		t.Errorf("expected truncated listing:\n%s", out.String())
+	}
+}
+
+func TestConfirmBranchDeletion_AssumeYes(t *testing.T) {
+	cfg := &Config{Settings: Settings{AssumeYes: true}}
+	if !confirmBranchDeletion(cfg, "old", t.TempDir()) {
+		t.Error("assume_yes should skip the prompt and allow deletion")
+	}
+}
+
+func TestCleanupDeletedBranches_KeepsStoreWhenDeletionDeclined(t *testing.T) {
+	store := t.TempDir()
+	branchesPath := filepath.Join(store, branchesDir)
+
+	writeFile(t, filepath.Join(branchesPath, "old-branch", "file.txt"), "data")
+	expiredTs := time.Now().Add(-8 * 24 * time.Hour).Unix()
+	writeFile(t, filepath.Join(branchesPath, "old-branch", deletionMarker), fmt.Sprintf("%d", expiredTs))
+	withBranches(t, map[string]bool{"main": true})
+
+	orig := confirmBranchDeletionFunc
+	confirmBranchDeletionFunc = func(*Config, string, string) bool { return false }
+	t.Cleanup(func() { confirmBranchDeletionFunc = orig })
+
+	cfg := &Config{
+		CurrentBranch: "main",
+		DefaultBranch: "main",
+		StoreBase:     store,
+		StoreLocation: store,
+	}
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	assertExists(t, filepath.Join(branchesPath, "old-branch", "file.txt"))
+
+	// The grace period restarts from now
+	content := readFileContent(t, filepath.Join(branchesPath, "old-branch", deletionMarker))
+	ts, err := strconv.ParseInt(strings.TrimSpace(content), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Error("expected deletion marker to be reset to now")
+	}
+}
@@ -0,0 +1,155 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// runPruneCommand implements `claude-wrapper prune <branch>...|--merged|--all-deleted`.
+// Selected branch stores are removed immediately, without waiting for the
+// deletion grace period. The current and default branches are never pruned.
+func runPruneCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("prune")
+	merged := fs.Bool("merged", false, "prune stores of branches merged into the default branch")
+	allDeleted := fs.Bool("all-deleted", false, "prune stores of branches that no longer exist in git")
+	dryRun := fs.Bool("dry-run", false, "show what would be pruned without deleting anything")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if fs.NArg() == 0 && !*merged && !*allDeleted {
+		fs.Usage()
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+
+	stored, err := storedBranches(cfg.StoreBase)
+	if err != nil {
+		return 1, fmt.Errorf("failed to list branch stores: %w", err)
+	}
+
+	selected := make(map[string]string)
+	for _, branch := range fs.Args() {
+		if !stored[branch] {
+			return 1, fmt.Errorf("no stored files for branch %q", branch)
+		}
+		selected[branch] = "pruned by request"
+	}
+	if *merged {
+		mergedBranches, err := gitRepo.MergedBranches(cfg.DefaultBranch)
+		if err != nil {
+			return 1, fmt.Errorf("failed to list branches merged into %s: %w", cfg.DefaultBranch, err)
+		}
+		for branch := range stored {
+			if mergedBranches[branch] {
+				selected[branch] = "branch merged into " + cfg.DefaultBranch
+			}
+		}
+	}
+	if *allDeleted {
+		gitBranches, err := getAllBranchesFunc()
+		if err != nil {
+			return 1, fmt.Errorf("failed to list git branches: %w", err)
+		}
+		for branch := range stored {
+			if !gitBranches[branch] {
+				selected[branch] = "branch deleted from git"
+			}
+		}
+	}
+
+	if err := pruneBranchStores(cfg, selected, *dryRun, os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// storedBranches returns the branches that have a store under storeBase.
+func storedBranches(storeBase string) (map[string]bool, error) {
+	branchesPath := filepath.Join(storeBase, branchesDir)
+	entries, err := os.ReadDir(branchesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	shortNames, err := readShortNames(branchesPath)
+	if err != nil {
+		return nil, err
+	}
+	branches := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			branches[branchOfDir(shortNames, entry.Name())] = true
+		}
+	}
+	return branches, nil
+}
+
+// pruneBranchStores removes the store of each selected branch, keyed to the
+// reason recorded in the audit log, and reports each store's size to w.
+func pruneBranchStores(cfg *Config, selected map[string]string, dryRun bool, w io.Writer) error {
+	var branches []string
+	inUse := sessionsByBranch(cfg.StoreBase)
+	var busy int
+	for branch := range selected {
+		// The default branch lives in the store base and the current
+		// branch's files are in use, so neither is ever pruned
+		if branch == cfg.CurrentBranch || branch == cfg.DefaultBranch {
+			fmt.Fprintf(w, "skipping %s: branch is checked out or the default branch\n", branch)
+			continue
+		}
+		if pid, ok := inUse[branch]; ok {
+			fmt.Fprintf(w, "skipping %s: in use by the session with PID %d\n", branch, pid)
+			busy++
+			continue
+		}
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+
+	verb := colorize(w, toneRemoved, "pruned")
+	if dryRun {
+		verb = colorize(w, toneMuted, "would prune")
+	}
+
+	var total int64
+	var failed int
+	for _, branch := range branches {
+		path := branchStoreLocation(cfg.StoreBase, branch, cfg.DefaultBranch)
+		files, size := storeContents(path)
+
+		if !dryRun {
+			err := auditedRemoveAll(cfg.StoreBase, auditEntry{
+				Path:   path,
+				Reason: selected[branch] + " (claude-wrapper prune)",
+				Repo:   cfg.RepoRoot,
+				Branch: branch,
+			})
+			if err != nil {
+				fmt.Fprintf(w, "failed to prune %s: %v\n", branch, err)
+				failed++
+				continue
+			}
+		}
+		fmt.Fprintf(w, "%s %s (%d file(s), %s)\n", verb, branch, len(files), formatBytes(size))
+		total += size
+	}
+
+	if len(branches) == 0 {
+		fmt.Fprintln(w, "nothing to prune")
+	} else {
+		fmt.Fprintf(w, "%s %d branch store(s), %s total\n", verb, len(branches)-failed, formatBytes(total))
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to prune %d branch store(s)", failed)
+	}
+	if busy > 0 {
+		return withExitCode(exitLocked, fmt.Errorf("not pruning %d branch store(s) in use by running sessions; see claude-wrapper sessions", busy))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPruneCommand_RemovesNamedBranchStore(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "feature/x", false)
+	home := inRepo(t, dir)
+
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	store := filepath.Join(storeBase, branchesDir, sanitizeBranchName("feature/x"))
+	writeFile(t, filepath.Join(store, "CLAUDE.md"), "feature config")
+
+	code, err := runPruneCommand(wrapperFlags{}, []string{"feature/x"})
+	if err != nil || code != 0 {
+		t.Fatalf("prune failed: %d, %v", code, err)
+	}
+
+	assertNotExists(t, store)
+	entries := readAuditLog(t, storeBase)
+	if len(entries) != 1 || entries[0].Branch != "feature/x" {
+		t.Errorf("expected one audit entry for feature/x, got %+v", entries)
+	}
+}
+
+func TestPruneCommand_UnknownBranchFails(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	inRepo(t, dir)
+
+	code, err := runPruneCommand(wrapperFlags{}, []string{"nope"})
+	if err == nil || code != 1 {
+		t.Errorf("expected failure for a branch without a store, got %d, %v", code, err)
+	}
+}
+
+func TestPruneCommand_MergedSkipsUnmergedAndCurrent(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "done", false)
+	commitOnBranch(t, repo, dir, "wip")
+	home := inRepo(t, dir)
+
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	for _, branch := range []string{"done", "wip", "main"} {
+		writeFile(t, filepath.Join(storeBase, branchesDir, branch, "notes.md"), branch)
+	}
+
+	code, err := runPruneCommand(wrapperFlags{}, []string{"--merged"})
+	if err != nil || code != 0 {
+		t.Fatalf("prune --merged failed: %d, %v", code, err)
+	}
+
+	assertNotExists(t, filepath.Join(storeBase, branchesDir, "done"))
+	assertExists(t, filepath.Join(storeBase, branchesDir, "wip"))
+	assertExists(t, filepath.Join(storeBase, branchesDir, "main"))
+}
+
+func TestPruneBranchStores_DryRunKeepsStoresAndReportsSize(t *testing.T) {
+	storeBase := t.TempDir()
+	store := filepath.Join(storeBase, branchesDir, "gone")
+	writeFile(t, filepath.Join(store, "big.md"), strings.Repeat("x", 2048))
+
+	cfg := &Config{StoreBase: storeBase, CurrentBranch: "main", DefaultBranch: "main"}
+	var out bytes.Buffer
+	err := pruneBranchStores(cfg, map[string]string{"gone": "branch deleted from git"}, true, &out)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+
+	assertExists(t, store)
+	if !strings.Contains(out.String(), "would prune gone (1 file(s), 2.0 KiB)") {
+		t.Errorf("expected size report, got:\n%s", out.String())
+	}
+	assertNotExists(t, filepath.Join(storeBase, auditLogFile))
+}
+
+func TestPruneCommand_AllDeleted(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "live", false)
+	home := inRepo(t, dir)
+
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, branchesDir, "gone", "a.md"), "a")
+	writeFile(t, filepath.Join(storeBase, branchesDir, "live", "a.md"), "a")
+
+	code, err := runPruneCommand(wrapperFlags{}, []string{"--all-deleted"})
+	if err != nil || code != 0 {
+		t.Fatalf("prune --all-deleted failed: %d, %v", code, err)
+	}
+
+	assertNotExists(t, filepath.Join(storeBase, branchesDir, "gone"))
+	assertExists(t, filepath.Join(storeBase, branchesDir, "live"))
+}
+
+func TestStoredBranches(t *testing.T) {
+	storeBase := t.TempDir()
+	writeFile(t, filepath.Join(storeBase, branchesDir, "gone", "a.md"), "a")
+	writeFile(t, filepath.Join(storeBase, branchesDir, sanitizeBranchName("feature/live"), "a.md"), "a")
+
+	stored, err := storedBranches(storeBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored["gone"] || !stored["feature/live"] || len(stored) != 2 {
+		t.Errorf("expected gone and feature/live stores, got %v", stored)
+	}
+}
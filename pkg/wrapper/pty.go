@@ -0,0 +1,101 @@
+//go:build unix
+
+package wrapper
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ptySysProcAttr makes a child the leader of a new session with its stdin,
+// the pseudo-terminal, as its controlling terminal.
+func ptySysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+}
+
+// forwardWindowSize copies from's window size to the pseudo-terminal to,
+// now and whenever the wrapper's terminal is resized, until the returned
+// function is called.
+func forwardWindowSize(from, to *os.File) (stop func()) {
+	resize := func() {
+		if ws, err := unix.IoctlGetWinsize(int(from.Fd()), unix.TIOCGWINSZ); err == nil {
+			unix.IoctlSetWinsize(int(to.Fd()), unix.TIOCSWINSZ, ws)
+		}
+	}
+	resize()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				resize()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// pumpInput copies what src reads to dst until the returned function is
+// called, which waits for the copy to end. Each read waits in poll(2) on
+// src and a pipe stop closes, so keystrokes typed afterwards are left for
+// whoever reads src next, such as the wrapper's own prompts.
+func pumpInput(src *os.File, dst io.Writer) (stop func()) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		warnf("keystrokes typed after claude exits may be lost: %v", err)
+		go io.Copy(dst, src)
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer r.Close()
+		fds := []unix.PollFd{
+			{Fd: int32(src.Fd()), Events: unix.POLLIN},
+			{Fd: int32(r.Fd()), Events: unix.POLLIN},
+		}
+		buf := make([]byte, 4096)
+		for {
+			fds[0].Revents, fds[1].Revents = 0, 0
+			if _, err := unix.Poll(fds, -1); err != nil {
+				if errors.Is(err, unix.EINTR) {
+					continue
+				}
+				return
+			}
+			if fds[1].Revents != 0 {
+				return // Stopped
+			}
+			if fds[0].Revents == 0 {
+				continue
+			}
+			n, err := unix.Read(int(fds[0].Fd), buf)
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			if n <= 0 || err != nil {
+				return // EOF, or the terminal went away
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+	return func() {
+		w.Close()
+		<-done
+	}
+}
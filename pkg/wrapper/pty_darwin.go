@@ -0,0 +1,40 @@
+package wrapper
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a new pseudo-terminal, returning its master and slave ends.
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := master.Fd()
+	var name [128]byte
+	for _, req := range []struct {
+		op  uint
+		arg uintptr
+	}{
+		{unix.TIOCPTYGRANT, 0},
+		{unix.TIOCPTYUNLK, 0},
+		{unix.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0]))},
+	} {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(req.op), req.arg); errno != 0 {
+			master.Close()
+			return nil, nil, errno
+		}
+	}
+	path := string(name[:bytes.IndexByte(name[:], 0)])
+	slave, err = os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
@@ -0,0 +1,30 @@
+//go:build !unix
+
+package wrapper
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// openPTY is only implemented on Linux and macOS.
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, errPTYUnsupported
+}
+
+// ptySysProcAttr is never needed where openPTY always fails.
+func ptySysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// forwardWindowSize has no pseudo-terminal to resize here.
+func forwardWindowSize(from, to *os.File) (stop func()) {
+	return func() {}
+}
+
+// pumpInput is never needed where openPTY always fails.
+func pumpInput(src *os.File, dst io.Writer) (stop func()) {
+	go io.Copy(dst, src)
+	return func() {}
+}
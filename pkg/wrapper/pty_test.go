@@ -0,0 +1,72 @@
+//go:build unix
+
+package wrapper
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a bytes.Buffer safe to write from one goroutine while
+// another reads it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestPumpInput_LeavesInputAfterStopForTheNextReader(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	var got lockedBuffer
+	stop := pumpInput(r, &got)
+	w.WriteString("during")
+	deadline := time.Now().Add(2 * time.Second)
+	for got.String() != "during" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got.String() != "during" {
+		t.Fatalf("pumped %q, want during", got.String())
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stop didn't return")
+	}
+
+	w.WriteString("after")
+	w.Close()
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != "after" || got.String() != "during" {
+		t.Errorf("next reader got %q and the pump %q; want after and during", rest, got.String())
+	}
+}
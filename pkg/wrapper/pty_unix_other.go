@@ -0,0 +1,10 @@
+//go:build unix && !linux && !darwin
+
+package wrapper
+
+import "os"
+
+// openPTY is only implemented on Linux and macOS.
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, errPTYUnsupported
+}
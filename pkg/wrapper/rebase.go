@@ -0,0 +1,204 @@
+package wrapper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultBaseDir in a branch store keeps, for files that came from the
+// default branch's store, the default branch's copy the branch's copy was
+// last brought up to date with: what the branch would have diverged from.
+const defaultBaseDir = ".default-base"
+
+// writeDefaultBase records the default branch's copy of rel as the base of
+// cfg's branch store's copy. It is a copy rather than a hard link, which a
+// file written in place would change along with the default branch's.
+func writeDefaultBase(cfg *Config, rel string) error {
+	path := filepath.Join(cfg.StoreLocation, defaultBaseDir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return copyFile(filepath.Join(cfg.StoreBase, rel), path)
+}
+
+// recordDefaultBases records the base of every file in cfg's new branch
+// store that is still the default branch's copy, so later changes to that
+// copy can reach the branch.
+func recordDefaultBases(cfg *Config) error {
+	return walkStoreFiles(cfg.StoreLocation, func(rel string) error {
+		if !sameContents(filepath.Join(cfg.StoreLocation, rel), filepath.Join(cfg.StoreBase, rel)) {
+			return nil
+		}
+		return writeDefaultBase(cfg, rel)
+	})
+}
+
+// walkStoreFiles calls fn with the store-relative path of every regular
+// file among store's items, skipping the wrapper's own.
+func walkStoreFiles(store string, fn func(rel string) error) error {
+	items, err := listDir(store)
+	if err != nil {
+		return err
+	}
+	for _, item := range filterItems(items) {
+		err := filepath.WalkDir(filepath.Join(store, item), func(path string, d os.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(store, path)
+			if err != nil {
+				return err
+			}
+			return fn(rel)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebaseStore brings every file of cfg's branch store that the branch never
+// changed up to date with the default branch's copy, returning their
+// store-relative paths. Files the branch changed, or whose base isn't
+// known, are left alone; those still identical to the default branch's
+// copy start being tracked. Overlaid files are left to rebaseOverlays.
+// With checkedOut, the working tree is the branch's, and its copies still
+// matching the branch's old copy are updated too, so the next sync out
+// doesn't save the old copy back.
+func rebaseStore(cfg *Config, dryRun, checkedOut bool) ([]string, error) {
+	if cfg.StoreLocation == cfg.StoreBase {
+		return nil, nil
+	}
+	tombstones := readTombstones(cfg.StoreBase)
+	branchTombstones := readTombstones(cfg.StoreLocation)
+	saved := make(map[string]manifestEntry)
+	c := &copier{journal: cfg.journal, saved: saved}
+	var updated []string
+	tracking := false
+	err := walkStoreFiles(cfg.StoreBase, func(rel string) error {
+		item := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+		if _, pending := tombstones[item]; pending || cfg.Settings.overlays(rel) {
+			return nil
+		}
+		if _, pending := branchTombstones[item]; pending {
+			return nil
+		}
+		layer := filepath.Join(cfg.StoreLocation, rel)
+		data, err := os.ReadFile(layer)
+		if os.IsNotExist(err) {
+			return nil // The branch doesn't have it
+		} else if err != nil {
+			return err
+		}
+		defaults, err := os.ReadFile(filepath.Join(cfg.StoreBase, rel))
+		if err != nil {
+			return err
+		}
+		base, err := os.ReadFile(filepath.Join(cfg.StoreLocation, defaultBaseDir, rel))
+		switch {
+		case os.IsNotExist(err):
+			if bytes.Equal(data, defaults) && !dryRun {
+				tracking = true
+				return writeDefaultBase(cfg, rel)
+			}
+			return nil
+		case err != nil:
+			return err
+		case bytes.Equal(defaults, base) || !bytes.Equal(data, base):
+			return nil // Up to date, or changed on the branch
+		}
+		updated = append(updated, filepath.ToSlash(rel))
+		if dryRun {
+			return nil
+		}
+		if err := c.copyFile(filepath.Join(cfg.StoreBase, rel), layer); err != nil {
+			return err
+		}
+		if checkedOut {
+			tree := filepath.Join(cfg.RepoRoot, rel)
+			if old, err := os.ReadFile(tree); err == nil && bytes.Equal(old, data) {
+				cfg.journal.beforeWrite(tree)
+				if err := copyFile(layer, tree); err != nil {
+					return err
+				}
+			}
+		}
+		return writeDefaultBase(cfg, rel)
+	})
+	if err == nil && (len(saved) > 0 || tracking) {
+		err = updateManifest(cfg.StoreLocation, saved)
+	}
+	return updated, err
+}
+
+// runRebaseStoresCommand implements `claude-wrapper rebase-stores
+// [--dry-run] [branch...]`.
+func runRebaseStoresCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("rebase-stores")
+	dryRun := fs.Bool("dry-run", false, "list the files that would be updated without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	stored, err := storedBranches(cfg.StoreBase)
+	if err != nil {
+		return 1, fmt.Errorf("failed to list branch stores: %w", err)
+	}
+	branches := fs.Args()
+	if len(branches) == 0 {
+		for branch := range stored {
+			branches = append(branches, branch)
+		}
+		sort.Strings(branches)
+	}
+	for _, branch := range branches {
+		if !stored[branch] {
+			return 1, fmt.Errorf("no stored files for branch %q", branch)
+		}
+	}
+	if err := rebaseStores(cfg, branches, *dryRun, os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// rebaseStores runs rebaseStore on each branch's store, reporting to w.
+func rebaseStores(cfg *Config, branches []string, dryRun bool, w io.Writer) error {
+	verb := colorize(w, toneAdded, "updated")
+	if dryRun {
+		verb = colorize(w, toneMuted, "would update")
+	}
+	total, stores := 0, 0
+	for _, branch := range branches {
+		if branch == cfg.DefaultBranch {
+			continue
+		}
+		updated, err := rebaseStore(cfg.forBranch(branch), dryRun, branch == cfg.CurrentBranch)
+		if err != nil {
+			return fmt.Errorf("failed to update %s's store: %w", branch, err)
+		}
+		for _, rel := range updated {
+			fmt.Fprintf(w, "%s %s: %s\n", verb, branch, rel)
+		}
+		if len(updated) > 0 {
+			total += len(updated)
+			stores++
+		}
+	}
+	if total == 0 {
+		fmt.Fprintln(w, "every branch store is up to date with the default branch's")
+	} else {
+		fmt.Fprintf(w, "%s %d file(s) in %d branch store(s)\n", verb, total, stores)
+	}
+	return nil
+}
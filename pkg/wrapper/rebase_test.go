@@ -0,0 +1,107 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// givenSeededBranch returns the config of a feature branch whose store was
+// seeded from a default branch store holding CLAUDE.md and notes.md.
+func givenSeededBranch(t *testing.T) *Config {
+	t.Helper()
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	writeFile(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "v1")
+	writeFile(t, filepath.Join(cfg.StoreBase, "notes.md"), "notes v1")
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, defaultBaseDir, "CLAUDE.md"), "v1")
+	return cfg
+}
+
+func TestRebaseStores_UpdatesFilesTheBranchNeverChanged(t *testing.T) {
+	cfg := givenSeededBranch(t)
+	writeFile(t, filepath.Join(cfg.StoreLocation, "notes.md"), "feature notes")
+	writeFile(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "v2")
+	writeFile(t, filepath.Join(cfg.StoreBase, "notes.md"), "notes v2")
+	writeFile(t, filepath.Join(cfg.StoreBase, "new.md"), "not on the branch")
+
+	var out bytes.Buffer
+	if err := rebaseStores(cfg, []string{"feature"}, true, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "v1")
+	if !strings.Contains(out.String(), "would update feature: CLAUDE.md") {
+		t.Errorf("expected a dry run listing CLAUDE.md, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := rebaseStores(cfg, []string{"feature"}, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "v2")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "notes.md"), "feature notes")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "new.md"))
+	if entry := readManifestEntry(t, cfg.StoreLocation, "CLAUDE.md"); entry.Size != 2 {
+		t.Errorf("manifest entry = %+v, want the updated copy", entry)
+	}
+	if !strings.Contains(out.String(), "updated 1 file(s) in 1 branch store(s)") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestRebaseStore_StartsTrackingFilesStillLikeTheDefault(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	// A store seeded before bases were recorded
+	writeFile(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "v1")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "v1")
+
+	if updated, err := rebaseStore(cfg, false, false); err != nil || len(updated) != 0 {
+		t.Fatalf("rebaseStore = %q, %v; want nothing updated yet", updated, err)
+	}
+	writeFile(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "v2")
+	if updated, err := rebaseStore(cfg, false, false); err != nil || len(updated) != 1 {
+		t.Fatalf("rebaseStore = %q, %v; want CLAUDE.md updated", updated, err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "v2")
+}
+
+func TestSyncIn_RebaseStoresSetting(t *testing.T) {
+	cfg := givenSeededBranch(t)
+	cfg.Settings.RebaseStores = true
+	writeFile(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "v2")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "v2")
+}
+
+func TestRebaseStores_UpdatesTheCheckedOutBranchsWorkingTree(t *testing.T) {
+	cfg := givenSeededBranch(t)
+	writeFile(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "v2")
+	writeFile(t, filepath.Join(cfg.StoreBase, "notes.md"), "notes v2")
+	writeFile(t, filepath.Join(cfg.RepoRoot, "notes.md"), "unsaved notes")
+	if err := rebaseStores(cfg, []string{"feature"}, false, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "v2")
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "notes.md"), "unsaved notes")
+
+	// So a sync out doesn't undo the update
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "v2")
+
+	// An edit made in the working tree is saved as usual
+	writeFile(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "edited")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "edited")
+}
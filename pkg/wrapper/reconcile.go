@@ -0,0 +1,245 @@
+package wrapper
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// syncInAfterSwitch syncs cfg's branch into the working tree. If a different
+// branch (or profile) was synced in last, the working tree still holds that
+// branch's files (and possibly edits to them), so they are saved to its
+// store first.
+func syncInAfterSwitch(cfg *Config) error {
+	prev, err := syncedElsewhere(cfg)
+	if err != nil {
+		return err
+	}
+	if prev != nil {
+		return syncOutAndSwitch(prev, cfg)
+	}
+	if err := syncIn(cfg); err != nil {
+		return err
+	}
+	recordSyncedBranch(cfg.RepoRoot, cfg.CurrentBranch, cfg.Settings.Profile)
+	return nil
+}
+
+// syncedElsewhere returns the Config for the store whose files the working
+// tree holds if the last sync-in was of another branch or profile than
+// cfg's, and nil otherwise.
+func syncedElsewhere(cfg *Config) (*Config, error) {
+	branch, profile := readSyncState(cfg.RepoRoot)
+	if branch == "" || (branch == cfg.CurrentBranch && profile == cfg.Settings.Profile) {
+		return nil, nil
+	}
+	prev := cfg.forBranch(branch)
+	if profile != cfg.Settings.Profile {
+		return prev.forProfile(profile)
+	}
+	return prev, nil
+}
+
+// syncOutAndSwitch saves the working tree to prev's store, then replaces
+// prev's files in it with next's.
+func syncOutAndSwitch(prev, next *Config) error {
+	if err := syncOut(prev); err != nil {
+		return err
+	}
+	infof("switching from %s to %s; saved personal files to %s's store and syncing in %s",
+		prev.storeName(), next.storeName(), prev.storeName(), next.storeName())
+	return switchStores(prev, next)
+}
+
+// storeName describes cfg's store in messages: its branch, and its profile
+// unless that's the default.
+func (cfg *Config) storeName() string {
+	if cfg.Settings.Profile == "" {
+		return cfg.CurrentBranch
+	}
+	return fmt.Sprintf("%s (profile %s)", cfg.CurrentBranch, cfg.Settings.Profile)
+}
+
+// syncOutAndReconcile syncs the working tree out to cfg's store, where cfg
+// describes the branch whose files the working tree currently holds. When
+// the checked-out branch has since changed to current (e.g. `git checkout`
+// outside the wrapper mid-session), the previous branch's files are removed
+// from the working tree once saved, and current's files are synced in.
+// An empty current means the branch can't be determined (detached HEAD), in
+// which case only the sync-out happens.
+func syncOutAndReconcile(cfg *Config, current string) error {
+	if err := syncOut(cfg); err != nil {
+		return err
+	}
+	if current == cfg.CurrentBranch {
+		return nil
+	}
+	if current == "" {
+		warnf("HEAD is no longer on %s; saved personal files to its store but not syncing in", cfg.CurrentBranch)
+		return nil
+	}
+
+	infof("branch changed from %s to %s during the session; saved personal files to %s's store and syncing in %s",
+		cfg.CurrentBranch, current, cfg.CurrentBranch, current)
+
+	return switchStores(cfg, cfg.forBranch(current))
+}
+
+// switchStores replaces the working tree copies of prev's items, just saved
+// to prev's store, with next's.
+func switchStores(prev, next *Config) error {
+	// Seed a new branch store first, so what it doesn't inherit is known
+	_, err := os.Stat(next.StoreLocation)
+	seeding := os.IsNotExist(err) && next.CurrentBranch != next.DefaultBranch
+	if err := initializeBranchStorage(next); err != nil {
+		return fmt.Errorf("failed to create %s's store: %w", next.storeName(), err)
+	}
+	// Undo restores what the switch removes along with what sync-in copies
+	next.journal = beginJournal(next, "sync-in")
+	if err := removeSwitchedOutItems(prev, next); err != nil {
+		return err
+	}
+	if err := removeUninherited(prev, next, seeding); err != nil {
+		return err
+	}
+	if err := syncIn(next); err != nil {
+		return fmt.Errorf("failed to sync in %s after switch: %w", next.storeName(), err)
+	}
+	recordSyncedBranch(next.RepoRoot, next.CurrentBranch, next.Settings.Profile)
+	return nil
+}
+
+// removeSwitchedOutItems deletes working tree copies of items that were just
+// saved to prev's store and have no counterpart in next's store, so the
+// previous branch's personal files don't leak into the new branch.
+func removeSwitchedOutItems(prev, next *Config) error {
+	items, err := listDir(prev.StoreLocation)
+	if err != nil {
+		return err
+	}
+	items = filterItems(items)
+	nextStores := []string{next.StoreLocation}
+	if len(prev.Settings.MachineScoped) > 0 {
+		// Items can also live only in the machine sub-store
+		prevMachine, err := machineStore(prev)
+		if err != nil {
+			return err
+		}
+		machineItems, err := listDir(prevMachine)
+		if err != nil {
+			return err
+		}
+		items = append(items, machineItems...)
+		nextMachine, err := machineStore(next)
+		if err != nil {
+			return err
+		}
+		nextStores = append(nextStores, nextMachine)
+	}
+
+	// The checkout has already happened, so this is what the new branch tracks
+	tracked, err := trackedItems(next, items)
+	if err != nil {
+		return fmt.Errorf("not removing the previous branch's files: %w", err)
+	}
+
+	for _, item := range items {
+		if inAnyStore(nextStores, item) {
+			continue // Replaced by the new branch's copy during sync-in
+		}
+		if next.CurrentBranch != next.DefaultBranch {
+			// A new branch store will be seeded from the default store
+			if _, err := os.Stat(next.StoreLocation); os.IsNotExist(err) {
+				if _, err := os.Stat(filepath.Join(next.StoreBase, item)); err == nil {
+					continue
+				}
+			}
+		}
+
+		// A committed file of the same name is never removed, but a copy
+		// synced in under its personal name is
+		var names []string
+		if !tracked[item] {
+			names = append(names, item)
+		}
+		if name := prev.Settings.syncedInName(item); name != "" {
+			names = append(names, name)
+		}
+		for _, name := range names {
+			path := filepath.Join(prev.RepoRoot, name)
+			if _, err := os.Lstat(path); err != nil {
+				continue
+			}
+			err := next.journal.removeAll(prev.StoreBase, auditEntry{
+				Path:   path,
+				Reason: fmt.Sprintf("working tree copy belongs to %s, which was switched away from", prev.CurrentBranch),
+				Repo:   prev.RepoRoot,
+				Branch: next.CurrentBranch,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to remove %s from working tree: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// removeUninherited deletes the working tree copies of paths inside prev's
+// items that next's store lacks and doesn't inherit (items with inherit =
+// false, or, when next's store was just seeded, what it wasn't seeded
+// with), so a branch's caches and logs don't leak into the next one.
+func removeUninherited(prev, next *Config, seeded bool) error {
+	items, err := listDir(next.StoreLocation)
+	if err != nil {
+		return err
+	}
+	for _, item := range filterItems(items) {
+		root := filepath.Join(prev.RepoRoot, item)
+		if info, err := os.Lstat(root); err != nil || !info.IsDir() {
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || path == root {
+				return err
+			}
+			rel, err := filepath.Rel(prev.RepoRoot, path)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Lstat(filepath.Join(next.StoreLocation, rel)); err == nil {
+				return nil
+			}
+			if next.Settings.inherits(rel) && (!seeded || next.Settings.seeds(rel, d.IsDir())) {
+				return nil
+			}
+			err = next.journal.removeAll(prev.StoreBase, auditEntry{
+				Path:   path,
+				Reason: fmt.Sprintf("working tree copy belongs to %s and %s doesn't inherit it", prev.CurrentBranch, next.CurrentBranch),
+				Repo:   prev.RepoRoot,
+				Branch: next.CurrentBranch,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to remove %s from working tree: %w", rel, err)
+			}
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inAnyStore reports whether item exists in any of the given store directories.
+func inAnyStore(stores []string, item string) bool {
+	for _, store := range stores {
+		if _, err := os.Stat(filepath.Join(store, item)); err == nil {
+			return true
+		}
+	}
+	return false
+}
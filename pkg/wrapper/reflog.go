@@ -0,0 +1,89 @@
+package wrapper
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lastCheckoutOf returns when a HEAD reflog of the repository at repoRoot,
+// that of any of its worktrees, last records a checkout moving from or to
+// branch. Git keeps no record of deleting a branch, and a branch can't be
+// deleted while checked out, so a deleted branch was deleted after then.
+// It is false if no reflog mentions the branch.
+func lastCheckoutOf(repoRoot, branch string) (time.Time, bool) {
+	gitDir, err := resolveGitDir(repoRoot)
+	if err != nil {
+		return time.Time{}, false
+	}
+	commonDir := resolveCommonDir(gitDir)
+	logs := []string{filepath.Join(commonDir, "logs", "HEAD")}
+	if worktrees, err := filepath.Glob(filepath.Join(commonDir, "worktrees", "*", "logs", "HEAD")); err == nil {
+		logs = append(logs, worktrees...)
+	}
+
+	var last time.Time
+	for _, path := range logs {
+		if t, ok := lastCheckoutIn(path, branch); ok && t.After(last) {
+			last = t
+		}
+	}
+	return last, !last.IsZero()
+}
+
+// lastCheckoutIn returns when the reflog at path last records a checkout
+// moving from or to branch.
+func lastCheckoutIn(path, branch string) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	var last time.Time
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// <old> <new> <name> <<email>> <unix time> <zone>\t<message>
+		header, message, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		moves, ok := strings.CutPrefix(message, "checkout: moving from ")
+		if !ok {
+			continue
+		}
+		from, to, _ := strings.Cut(moves, " to ")
+		if from != branch && to != branch {
+			continue
+		}
+		fields := strings.Fields(header)
+		if len(fields) < 2 {
+			continue
+		}
+		if unix, err := strconv.ParseInt(fields[len(fields)-2], 10, 64); err == nil {
+			if t := time.Unix(unix, 0); t.After(last) {
+				last = t
+			}
+		}
+	}
+	return last, !last.IsZero()
+}
+
+// deletionTime returns when to date the deletion marker of branch, found
+// deleted from git now: the last checkout of it in the reflog, if that was
+// after the previous cleanup (which saw the branch still there). Without a
+// previous cleanup to go by, or a reflog to read, it is now.
+func deletionTime(cfg *Config, branch string, lastCleanup, now time.Time) time.Time {
+	if lastCleanup.IsZero() {
+		return now
+	}
+	checkout, ok := lastCheckoutOf(cfg.RepoRoot, branch)
+	if !ok || !checkout.After(lastCleanup) || !checkout.Before(now) {
+		return now
+	}
+	return checkout
+}
@@ -0,0 +1,57 @@
+package wrapper
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reflogLine returns a HEAD reflog line recording message at t.
+func reflogLine(t time.Time, message string) string {
+	zero := strings.Repeat("0", 40)
+	return fmt.Sprintf("%s %s Dev <dev@example.com> %d +0000\t%s\n", zero, zero, t.Unix(), message)
+}
+
+func TestLastCheckoutOf_ReadsEveryWorktreesReflog(t *testing.T) {
+	repoRoot := t.TempDir()
+	base := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	writeFile(t, filepath.Join(repoRoot, ".git", "logs", "HEAD"),
+		reflogLine(base, "checkout: moving from main to feature")+
+			reflogLine(base.Add(time.Hour), "commit: work")+
+			reflogLine(base.Add(2*time.Hour), "checkout: moving from feature to main")+
+			reflogLine(base.Add(3*time.Hour), "checkout: moving from main to feature-two"))
+	writeFile(t, filepath.Join(repoRoot, ".git", "worktrees", "wt", "logs", "HEAD"),
+		reflogLine(base.Add(5*time.Hour), "checkout: moving from other to feature"))
+
+	if at, ok := lastCheckoutOf(repoRoot, "feature"); !ok || !at.Equal(base.Add(5*time.Hour)) {
+		t.Errorf("lastCheckoutOf(feature) = %v, %v; want the worktree's checkout", at, ok)
+	}
+	if at, ok := lastCheckoutOf(repoRoot, "feature-two"); !ok || !at.Equal(base.Add(3*time.Hour)) {
+		t.Errorf("lastCheckoutOf(feature-two) = %v, %v", at, ok)
+	}
+	if _, ok := lastCheckoutOf(repoRoot, "gone"); ok {
+		t.Error("expected no checkout of a branch the reflog doesn't mention")
+	}
+}
+
+func TestDeletionTime_BackdatesOnlyPastThePreviousCleanup(t *testing.T) {
+	repoRoot := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	left := now.Add(-10 * 24 * time.Hour)
+	writeFile(t, filepath.Join(repoRoot, ".git", "logs", "HEAD"), reflogLine(left, "checkout: moving from feature to main"))
+	cfg := &Config{RepoRoot: repoRoot}
+
+	if got := deletionTime(cfg, "feature", now.Add(-14*24*time.Hour), now); !got.Equal(left) {
+		t.Errorf("deletionTime = %v, want the checkout at %v", got, left)
+	}
+	// The previous cleanup saw the branch after that checkout
+	if got := deletionTime(cfg, "feature", now.Add(-24*time.Hour), now); !got.Equal(now) {
+		t.Errorf("deletionTime = %v, want now", got)
+	}
+	// Nothing to bound the reflog's checkouts by
+	if got := deletionTime(cfg, "feature", time.Time{}, now); !got.Equal(now) {
+		t.Errorf("deletionTime = %v, want now", got)
+	}
+}
@@ -0,0 +1,174 @@
+package wrapper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// renameStored renames the stored copies of items renamed in the working
+// tree since the last sync, so sync-out doesn't save a second copy while
+// the old one lingers in storage. A rename is an item listed in the
+// exclude file but missing from cfg's branch store whose content is exactly
+// that of a stored item gone from the working tree; an ambiguous match is
+// left alone. The stored copy's manifest entries move with it and any
+// tombstone is cleared. It returns the renames, old name to new.
+func renameStored(cfg *Config, excludeItems []string, tracked map[string]bool) (map[string]string, error) {
+	inTree, err := dirNames(cfg.RepoRoot)
+	if err != nil {
+		return nil, err
+	}
+	inStore, err := dirNames(cfg.StoreLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names are compared exactly, so renames that only change case are
+	// found on case-insensitive filesystems too. Items the store keeps to
+	// itself were never in the working tree
+	ignore := readWrapperIgnore(cfg.StoreLocation)
+	gone := make(map[string][]string) // Fingerprint to stored items
+	for item := range inStore {
+		if inTree[item] || isReservedItem(item) || tracked[item] || ignore.matches(item) {
+			continue
+		}
+		if fp := fingerprint(cfg.StoreLocation, item); fp != "" {
+			gone[fp] = append(gone[fp], item)
+		}
+	}
+	if len(gone) == 0 {
+		return nil, nil
+	}
+	added := make(map[string][]string)
+	for _, item := range excludeItems {
+		if strings.Contains(item, "/") || inStore[item] || tracked[item] || cfg.isNotesItem(item) {
+			continue
+		}
+		if fp := fingerprint(cfg.RepoRoot, item); fp != "" {
+			added[fp] = append(added[fp], item)
+		}
+	}
+
+	renames := make(map[string]string)
+	for fp, olds := range gone {
+		news := added[fp]
+		if len(olds) != 1 || len(news) != 1 {
+			continue // Nothing matches, or too much does
+		}
+		renames[olds[0]] = news[0]
+	}
+	if len(renames) == 0 {
+		return nil, nil
+	}
+
+	m, err := readManifest(cfg.StoreLocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the store's manifest: %w", err)
+	}
+	tombstones := readTombstones(cfg.StoreLocation)
+	applied := make(map[string]string)
+	for old, name := range renames {
+		from, to := filepath.Join(cfg.StoreLocation, old), filepath.Join(cfg.StoreLocation, name)
+		if err := os.Rename(from, to); err != nil {
+			return applied, fmt.Errorf("failed to rename %s to %s in storage: %w", old, name, err)
+		}
+		cfg.journal.renamed(from, to)
+		applied[old] = name
+		m.rename(old, name)
+		delete(tombstones, old)
+		infof("%s was renamed to %s; renamed its stored copy", old, name)
+		entry := auditEntry{
+			Time:   time.Now(),
+			Action: "rename",
+			Path:   filepath.Join(cfg.StoreLocation, old),
+			Reason: "renamed to " + name + " in the working tree",
+			Repo:   cfg.RepoRoot,
+			Branch: cfg.CurrentBranch,
+		}
+		if err := appendAuditLog(cfg.StoreBase, entry); err != nil {
+			warnf("failed to write audit log: %v", err)
+		}
+		cfg.report.addRenamed(old, name)
+	}
+	if err := writeTombstones(cfg.StoreLocation, tombstones); err != nil {
+		return applied, fmt.Errorf("failed to record items pending removal: %w", err)
+	}
+	if err := m.write(cfg.StoreLocation); err != nil {
+		return applied, fmt.Errorf("failed to update the store's manifest: %w", err)
+	}
+	return applied, nil
+}
+
+// dirNames returns the set of names in the directory at path, which is
+// empty if it doesn't exist.
+func dirNames(path string) (map[string]bool, error) {
+	items, err := listDir(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(items))
+	for _, item := range items {
+		names[item] = true
+	}
+	return names, nil
+}
+
+// fingerprint identifies the content of item under root: the SHA-256 of
+// every regular file in it and its path relative to item. It returns ""
+// for items without any content to tell them apart by, like empty files and
+// directories, or that can't be read.
+func fingerprint(root, item string) string {
+	var lines []string
+	base := filepath.Join(root, item)
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, filepath.ToSlash(rel)+"\x00"+sum)
+		return nil
+	})
+	if err != nil || len(lines) == 0 {
+		return ""
+	}
+	if len(lines) == 1 && strings.HasSuffix(lines[0], hex.EncodeToString(emptySHA256[:])) {
+		return ""
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// emptySHA256 is the SHA-256 of no content.
+var emptySHA256 = sha256.Sum256(nil)
+
+// fileSHA256 returns the hex SHA-256 of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := copyData(io.Discard, f, sum); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
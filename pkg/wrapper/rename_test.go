@@ -0,0 +1,108 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSyncOut_RenamesStoredCopy(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(repoRoot, "notes.md"), "my notes")
+	if err := addToExclude(repoRoot, cfg.excludeFile(), "notes.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	saved := readManifestEntry(t, cfg.StoreLocation, "notes.md")
+
+	if err := os.Rename(filepath.Join(repoRoot, "notes.md"), filepath.Join(repoRoot, "NOTES.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := addToExclude(repoRoot, cfg.excludeFile(), "NOTES.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := dirNames(cfg.StoreLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names["notes.md"] || !names["NOTES.md"] {
+		t.Errorf("expected only NOTES.md in storage, got %v", names)
+	}
+	if len(readTombstones(cfg.StoreLocation)) != 0 {
+		t.Errorf("expected no tombstones, got %v", readTombstones(cfg.StoreLocation))
+	}
+	if got := readManifestEntry(t, cfg.StoreLocation, "NOTES.md"); got.SHA256 != saved.SHA256 {
+		t.Errorf("manifest entry = %+v, want the hash of %+v", got, saved)
+	}
+	entries := readAuditLog(t, cfg.StoreBase)
+	if len(entries) != 1 || entries[0].Action != "rename" || !strings.Contains(entries[0].Reason, "NOTES.md") {
+		t.Errorf("expected the rename audited, got %+v", entries)
+	}
+}
+
+func TestRenameStored_Directory(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "prompts", "review.md"), "review")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "prompts", "fix", "bug.md"), "bug")
+	writeFile(t, filepath.Join(repoRoot, "my-prompts", "review.md"), "review")
+	writeFile(t, filepath.Join(repoRoot, "my-prompts", "fix", "bug.md"), "bug")
+	givenTombstoned(t, cfg.StoreLocation, "prompts")
+
+	renamed, err := renameStored(cfg, []string{"my-prompts"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"prompts": "my-prompts"}; !reflect.DeepEqual(renamed, want) {
+		t.Errorf("renamed = %v, want %v", renamed, want)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "my-prompts", "fix", "bug.md"), "bug")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, tombstonesFile))
+}
+
+func TestRenameStored_LeavesOthersAlone(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "a.md"), "same")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "b.md"), "same")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "empty.md"), "")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "old.md"), "old content")
+	writeFile(t, filepath.Join(repoRoot, "c.md"), "same")        // Matches two stored items
+	writeFile(t, filepath.Join(repoRoot, "blank.md"), "")        // Empty files all look alike
+	writeFile(t, filepath.Join(repoRoot, "new.md"), "new stuff") // Different content
+
+	renamed, err := renameStored(cfg, []string{"c.md", "blank.md", "new.md"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renamed) != 0 {
+		t.Errorf("expected no renames, got %v", renamed)
+	}
+	for _, item := range []string{"a.md", "b.md", "empty.md", "old.md"} {
+		assertExists(t, filepath.Join(cfg.StoreLocation, item))
+	}
+}
+
+// readManifestEntry returns the manifest entry of rel in the branch store
+// at store, failing the test if there is none.
+func readManifestEntry(t *testing.T, store, rel string) manifestEntry {
+	t.Helper()
+	m, err := readManifest(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := m.Files[manifestKey(rel)]
+	if !ok {
+		t.Fatalf("no manifest entry for %s in %v", rel, m.Files)
+	}
+	return entry
+}
@@ -0,0 +1,251 @@
+package wrapper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateSuffix marks a stored file as a template, rendered into the
+// working tree under its name without the suffix.
+const templateSuffix = ".tmpl"
+
+// mcpConfigs are the MCP server configuration files that may be stored as
+// templates, relative to the repository root.
+var mcpConfigs = []string{".mcp.json", filepath.Join(".claude", "mcp.json")}
+
+// templateVars are the values available to a template rendered at sync-in.
+type templateVars struct {
+	Branch        string
+	BranchSlug    string // Branch with every non-alphanumeric character replaced by "-"
+	DefaultBranch string
+	RepoRoot      string
+	RepoName      string
+	User          string
+	Env           map[string]string
+	Ports         map[string]int
+}
+
+// newTemplateVars returns the template values for cfg's branch. Each
+// configured port is offset on branches other than the default, so
+// servers on different branches don't collide.
+func newTemplateVars(cfg *Config) templateVars {
+	ports := make(map[string]int, len(cfg.Settings.Ports))
+	for name, base := range cfg.Settings.Ports {
+		ports[name] = base + branchPortOffset(cfg.CurrentBranch, cfg.DefaultBranch)
+	}
+	return templateVars{
+		Branch:        cfg.CurrentBranch,
+		BranchSlug:    nonAlphanumeric.ReplaceAllString(cfg.CurrentBranch, "-"),
+		DefaultBranch: cfg.DefaultBranch,
+		RepoRoot:      cfg.RepoRoot,
+		RepoName:      filepath.Base(cfg.RepoRoot),
+		User:          currentUser(),
+		Env:           environ(),
+		Ports:         ports,
+	}
+}
+
+// currentUser returns the name of the user running the wrapper.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// environ returns the environment as a map.
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// branchPortOffset returns 0 for the default branch and a stable number
+// from 1 to 99 derived from the name of any other branch.
+func branchPortOffset(branch, defaultBranch string) int {
+	if branch == defaultBranch {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(branch))
+	return 1 + int(h.Sum32()%99)
+}
+
+// isTemplate reports whether data uses template actions.
+func isTemplate(data []byte) bool {
+	return bytes.Contains(data, []byte("{{"))
+}
+
+// renderTemplate renders the template text, named name in errors, with
+// vars. Referring to a value that doesn't exist is an error.
+func renderTemplate(name string, text []byte, vars any) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// mcpTemplate returns the stored template the working tree's rel is
+// rendered from, or nil if rel isn't a templated MCP configuration.
+func mcpTemplate(cfg *Config, rel string) []byte {
+	isConfig := false
+	for _, config := range mcpConfigs {
+		isConfig = isConfig || filepath.Clean(rel) == config
+	}
+	if !isConfig {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.StoreLocation, rel))
+	if err != nil || !isTemplate(data) {
+		return nil
+	}
+	return data
+}
+
+// renderMCPConfigs renders the MCP configurations synced in from templates
+// in cfg's branch store over their template copies in the working tree.
+func renderMCPConfigs(cfg *Config) error {
+	vars := newTemplateVars(cfg)
+	for _, config := range mcpConfigs {
+		text := mcpTemplate(cfg, config)
+		if text == nil {
+			continue
+		}
+		dst := filepath.Join(cfg.RepoRoot, config)
+		if _, err := os.Stat(dst); err != nil {
+			continue // Not synced in, e.g. because the repository tracks it
+		}
+		rendered, err := renderTemplate(config, text, vars)
+		if err != nil {
+			return fmt.Errorf("failed to render %s: %w", config, err)
+		}
+		if err := os.WriteFile(dst, rendered, 0600); err != nil {
+			return fmt.Errorf("failed to render %s: %w", config, err)
+		}
+	}
+	return nil
+}
+
+// renderedName returns the working tree name of the stored template name,
+// and whether name is a template at all.
+func renderedName(name string) (string, bool) {
+	rendered := strings.TrimSuffix(name, templateSuffix)
+	return rendered, rendered != name && filepath.Base(rendered) != ""
+}
+
+// isTemplateFile reports whether the stored path is a template file, which
+// sync-in renders rather than copies.
+func isTemplateFile(path string) bool {
+	if _, ok := renderedName(path); !ok {
+		return false
+	}
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// renderStoredTemplates renders the template files in cfg's branch store
+// under items (or the items themselves) into the working tree.
+func renderStoredTemplates(cfg *Config, items []string) error {
+	vars := newTemplateVars(cfg)
+	var errs []error
+	for _, item := range items {
+		filepath.WalkDir(filepath.Join(cfg.StoreLocation, item), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() || !isTemplateFile(path) {
+				return nil
+			}
+			rel, err := filepath.Rel(cfg.StoreLocation, path)
+			if err != nil || cfg.Settings.neverManaged(rel) {
+				return nil
+			}
+			if err := renderStoredTemplate(cfg, rel, vars); err != nil {
+				errs = append(errs, err)
+			}
+			return nil
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// renderStoredTemplate renders the stored template rel into the working
+// tree under its rendered name.
+func renderStoredTemplate(cfg *Config, rel string, vars templateVars) error {
+	src := filepath.Join(cfg.StoreLocation, rel)
+	text, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %w", rel, err)
+	}
+	rendered, err := renderTemplate(rel, text, vars)
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %w", rel, err)
+	}
+	name, _ := renderedName(rel)
+	dst := filepath.Join(cfg.RepoRoot, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to render %s: %w", rel, err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %w", rel, err)
+	}
+	if err := os.WriteFile(dst, rendered, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to render %s: %w", rel, err)
+	}
+	return nil
+}
+
+// storedTemplate returns the stored template the working tree's rel is
+// rendered from: rel with the template suffix, or a templated MCP
+// configuration. It returns nil if rel isn't rendered.
+func storedTemplate(cfg *Config, rel string) []byte {
+	if text := mcpTemplate(cfg, rel); text != nil {
+		return text
+	}
+	path := filepath.Join(cfg.StoreLocation, rel+templateSuffix)
+	if !isTemplateFile(path) {
+		return nil
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return text
+}
+
+// renderedOutput reports whether the working tree file at src is rendered
+// from a stored template, which sync-out must not save over the template.
+// Edits made to the rendered file are lost, so they are warned about.
+func renderedOutput(cfg *Config, src string) bool {
+	rel, err := filepath.Rel(cfg.RepoRoot, src)
+	if err != nil {
+		return false
+	}
+	text := storedTemplate(cfg, rel)
+	if text == nil {
+		return false
+	}
+	current, err := os.ReadFile(src)
+	if err != nil {
+		return true
+	}
+	if rendered, err := renderTemplate(rel, text, newTemplateVars(cfg)); err == nil && !bytes.Equal(current, rendered) {
+		warnf("not saving %s: it is rendered from a template, so edit the template in %s instead", rel, cfg.StoreLocation)
+	}
+	return true
+}
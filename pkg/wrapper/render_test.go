@@ -0,0 +1,141 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const mcpTemplateText = `{"mcpServers": {"db": {"url": "http://localhost:{{.Ports.db}}/{{.BranchSlug}}"}}}`
+
+// givenMCPTemplate returns a config for branch whose store holds
+// .claude/mcp.json as a template, with a db port of 5000.
+func givenMCPTemplate(t *testing.T, branch string) *Config {
+	t.Helper()
+	cfg, _ := givenConfig(t, givenRepo(t), configOpts{currentBranch: branch})
+	cfg.Settings.Ports = map[string]int{"db": 5000}
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "mcp.json"), mcpTemplateText)
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "notes.md"), "notes")
+	return cfg
+}
+
+func TestBranchPortOffset(t *testing.T) {
+	if got := branchPortOffset("main", "main"); got != 0 {
+		t.Errorf("expected no offset on the default branch, got %d", got)
+	}
+	offset := branchPortOffset("feature/login", "main")
+	if offset < 1 || offset > 99 {
+		t.Errorf("expected an offset from 1 to 99, got %d", offset)
+	}
+	if again := branchPortOffset("feature/login", "main"); again != offset {
+		t.Errorf("expected a stable offset, got %d then %d", offset, again)
+	}
+}
+
+func TestRenderTemplate_MissingKeyIsAnError(t *testing.T) {
+	_, err := renderTemplate("mcp.json", []byte("{{.Ports.nope}}"), templateVars{Ports: map[string]int{}})
+	if err == nil {
+		t.Error("expected an error for an unknown port")
+	}
+}
+
+func TestSyncIn_RendersMCPTemplate(t *testing.T) {
+	cfg := givenMCPTemplate(t, "feature/login")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	port := 5000 + branchPortOffset("feature/login", "main")
+	want := strings.NewReplacer("{{.Ports.db}}", strconv.Itoa(port), "{{.BranchSlug}}", "feature-login").Replace(mcpTemplateText)
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".claude", "mcp.json"), want)
+}
+
+func TestSyncOut_KeepsMCPTemplate(t *testing.T) {
+	cfg := givenMCPTemplate(t, "main")
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	writeFile(t, filepath.Join(cfg.RepoRoot, ".claude", "notes.md"), "edited notes")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "mcp.json"), mcpTemplateText)
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "notes.md"), "edited notes")
+}
+
+func TestSyncIn_PlainMCPConfigUntouched(t *testing.T) {
+	cfg, _ := givenConfig(t, givenRepo(t), configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".mcp.json"), `{"mcpServers": {}}`)
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".mcp.json"), `{"mcpServers": {}}`)
+}
+
+func TestSyncIn_RendersTemplates(t *testing.T) {
+	t.Setenv("TEAM", "platform")
+	cfg, _ := givenConfig(t, givenRepo(t), configOpts{currentBranch: "feature"})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md.tmpl"), "Working on {{.Branch}} of {{.RepoName}} for {{.Env.TEAM}}")
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "agents", "reviewer.md.tmpl"), "reviewer for {{.User}}")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	repoName := filepath.Base(cfg.RepoRoot)
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "Working on feature of "+repoName+" for platform")
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".claude", "agents", "reviewer.md"), "reviewer for "+currentUser())
+	assertNotExists(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md.tmpl"))
+	assertNotExists(t, filepath.Join(cfg.RepoRoot, ".claude", "agents", "reviewer.md.tmpl"))
+	assertExcludeCount(t, cfg.RepoRoot, "CLAUDE.md.tmpl", 0)
+	assertExcludeContains(t, cfg.RepoRoot, "CLAUDE.md")
+}
+
+func TestSyncOut_KeepsTemplates(t *testing.T) {
+	cfg, _ := givenConfig(t, givenRepo(t), configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md.tmpl"), "on {{.Branch}}")
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "prompt.md.tmpl"), "prompt for {{.Branch}}")
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	writeFile(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "edited rendering")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md.tmpl"), "on {{.Branch}}")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "prompt.md.tmpl"), "prompt for {{.Branch}}")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"))
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, ".claude", "prompt.md"))
+}
+
+func TestSyncIn_TemplateNeverRendersOverCommittedFile(t *testing.T) {
+	repoRoot, _ := givenGitRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "README.md.tmpl"), "personal {{.Branch}}")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(repoRoot, "README.md"), "readme")
+}
+
+func TestSyncIn_BadTemplateReported(t *testing.T) {
+	cfg, _ := givenConfig(t, givenRepo(t), configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "notes.md.tmpl"), "{{.Nope}}")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "other.md"), "other")
+
+	err := syncIn(cfg)
+	if err == nil || !strings.Contains(err.Error(), "notes.md.tmpl") {
+		t.Errorf("expected an error naming the template, got %v", err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "other.md"), "other")
+}
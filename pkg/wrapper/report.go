@@ -0,0 +1,304 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyncReport summarizes what a single wrapper run did to the working tree
+// and storage. A nil *SyncReport is valid and records nothing.
+type SyncReport struct {
+	Time         time.Time `json:"time"`
+	Repo         string    `json:"repo"`
+	Branch       string    `json:"branch"`
+	Store        string    `json:"store"`
+	FilesIn      int       `json:"files_in"`
+	FilesOut     int       `json:"files_out"`
+	Removed      int       `json:"removed"`
+	RemovedItems []string  `json:"removed_items,omitempty"`
+	// Renamed maps items renamed in storage, because they were renamed in
+	// the working tree, from their old name to the new one.
+	Renamed      map[string]string `json:"renamed,omitempty"`
+	IgnoredItems []string          `json:"ignored_items,omitempty"`
+	Oversized    []string          `json:"oversized,omitempty"`
+	// InUse lists files sync-out skipped because another process kept them
+	// open or locked.
+	InUse []string `json:"in_use,omitempty"`
+	// Conflicted lists files that changed in both the working tree and
+	// storage and were merged with conflict markers.
+	Conflicted  []string `json:"conflicted,omitempty"`
+	BytesCopied int64    `json:"bytes_copied"`
+	DurationMS  int64    `json:"duration_ms"`
+	// TimingsMS breaks the run down by phase: startup (loading the
+	// configuration), sync_in, sync_out, cleanup, project_state and git (all
+	// VCS queries, GitCalls of them).
+	TimingsMS map[string]int64 `json:"timings_ms,omitempty"`
+	GitCalls  int              `json:"git_calls,omitempty"`
+	// ItemTimingsMS is how long copying each item took, in or out.
+	ItemTimingsMS map[string]int64 `json:"item_timings_ms,omitempty"`
+	ClaudeExit    int              `json:"claude_exit"`
+	Error         string           `json:"error,omitempty"`
+
+	// gitCalls and gitTime are the VCS totals when the run started.
+	gitCalls int
+	gitTime  time.Duration
+}
+
+func newSyncReport(cfg *Config) *SyncReport {
+	r := &SyncReport{
+		Time:   time.Now(),
+		Repo:   cfg.RepoRoot,
+		Branch: cfg.CurrentBranch,
+		Store:  cfg.StoreLocation,
+	}
+	r.gitCalls, r.gitTime = gitTotals()
+	return r
+}
+
+// addIn records files copied from storage into the working directory.
+func (r *SyncReport) addIn(c *copier) {
+	if r == nil {
+		return
+	}
+	r.FilesIn += c.files
+	r.BytesCopied += c.bytes
+}
+
+// addOut records files copied from the working directory into storage.
+func (r *SyncReport) addOut(c *copier) {
+	if r == nil {
+		return
+	}
+	r.FilesOut += c.files
+	r.BytesCopied += c.bytes
+}
+
+// addRemoved records an item deleted from storage.
+func (r *SyncReport) addRemoved(item string) {
+	if r == nil {
+		return
+	}
+	r.Removed++
+	r.RemovedItems = append(r.RemovedItems, item)
+}
+
+// addRenamed records an item renamed in storage.
+func (r *SyncReport) addRenamed(old, name string) {
+	if r == nil {
+		return
+	}
+	if r.Renamed == nil {
+		r.Renamed = make(map[string]string)
+	}
+	r.Renamed[old] = name
+}
+
+// addIgnored records a path left out of syncing by never_manage.
+func (r *SyncReport) addIgnored(item string) {
+	if r == nil {
+		return
+	}
+	for _, seen := range r.IgnoredItems {
+		if seen == item {
+			return
+		}
+	}
+	r.IgnoredItems = append(r.IgnoredItems, item)
+}
+
+// addOversized records an item sync-out skipped for exceeding max_item_size_mb.
+func (r *SyncReport) addOversized(item string) {
+	if r == nil {
+		return
+	}
+	r.Oversized = append(r.Oversized, item)
+}
+
+// addInUse records a file sync-out skipped for being in use.
+func (r *SyncReport) addInUse(rel string) {
+	if r == nil {
+		return
+	}
+	r.InUse = append(r.InUse, rel)
+}
+
+// addConflicted records a file merged with conflict markers.
+func (r *SyncReport) addConflicted(rel string) {
+	if r == nil {
+		return
+	}
+	r.Conflicted = append(r.Conflicted, rel)
+}
+
+// addDuration accumulates time spent syncing (claude's runtime is excluded).
+func (r *SyncReport) addDuration(d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.DurationMS += d.Milliseconds()
+}
+
+// addTiming accumulates time spent in phase.
+func (r *SyncReport) addTiming(phase string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	if r.TimingsMS == nil {
+		r.TimingsMS = make(map[string]int64)
+	}
+	r.TimingsMS[phase] += d.Milliseconds()
+}
+
+// addItemTiming accumulates time spent copying item.
+func (r *SyncReport) addItemTiming(item string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	if r.ItemTimingsMS == nil {
+		r.ItemTimingsMS = make(map[string]int64)
+	}
+	r.ItemTimingsMS[item] += d.Milliseconds()
+}
+
+// finishGitTiming records the VCS queries made since the run started.
+func (r *SyncReport) finishGitTiming() {
+	if r == nil {
+		return
+	}
+	calls, total := gitTotals()
+	r.GitCalls = calls - r.gitCalls
+	r.addTiming("git", total-r.gitTime)
+}
+
+// writeProfile writes the run's timings, slowest first, as --profile-sync
+// shows them.
+func (r *SyncReport) writeProfile(w io.Writer) {
+	fmt.Fprintf(w, "claude-wrapper: sync timings for %s (%dms total):\n", r.Branch, r.DurationMS)
+	for _, phase := range slowestFirst(r.TimingsMS) {
+		fmt.Fprintf(w, "  %-16s %7dms", phase, r.TimingsMS[phase])
+		if phase == "git" {
+			fmt.Fprintf(w, " (%d calls)", r.GitCalls)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(r.ItemTimingsMS) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "slowest items:")
+	for i, item := range slowestFirst(r.ItemTimingsMS) {
+		if i == maxProfiledItems {
+			break
+		}
+		fmt.Fprintf(w, "  %-16s %7dms\n", item, r.ItemTimingsMS[item])
+	}
+}
+
+// maxProfiledItems caps how many items --profile-sync lists.
+const maxProfiledItems = 10
+
+// slowestFirst returns the keys of timings, longest first, then by name.
+func slowestFirst(timings map[string]int64) []string {
+	keys := make([]string, 0, len(timings))
+	for key := range timings {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if timings[keys[i]] != timings[keys[j]] {
+			return timings[keys[i]] > timings[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// String renders the report as a single human-readable line.
+func (r *SyncReport) String() string {
+	s := fmt.Sprintf("claude-wrapper: %s: %d file(s) in, %d out, %d removed, %s copied in %dms",
+		r.Branch, r.FilesIn, r.FilesOut, r.Removed, formatBytes(r.BytesCopied), r.DurationMS)
+	if len(r.Renamed) > 0 {
+		var renames []string
+		for old, name := range r.Renamed {
+			renames = append(renames, old+" -> "+name)
+		}
+		sort.Strings(renames)
+		s += ", renamed " + strings.Join(renames, ", ")
+	}
+	if len(r.IgnoredItems) > 0 {
+		s += fmt.Sprintf(", skipped %s (never_manage)", strings.Join(r.IgnoredItems, ", "))
+	}
+	if len(r.Oversized) > 0 {
+		s += fmt.Sprintf(", NOT SAVED %s (max_item_size_mb)", strings.Join(r.Oversized, ", "))
+	}
+	if len(r.InUse) > 0 {
+		s += fmt.Sprintf(", NOT SAVED %s (in use)", strings.Join(r.InUse, ", "))
+	}
+	if len(r.Conflicted) > 0 {
+		s += fmt.Sprintf(", CONFLICTS in %s", strings.Join(r.Conflicted, ", "))
+	}
+	if r.Error != "" {
+		s += " (error: " + r.Error + ")"
+	}
+	return s
+}
+
+// emitReport prints and/or appends the report according to settings.
+func emitReport(settings Settings, r *SyncReport, stderr io.Writer) error {
+	r.finishGitTiming()
+	if settings.ProfileSync && r != nil {
+		r.writeProfile(stderr)
+	}
+	switch settings.Report {
+	case "":
+	case "text":
+		fmt.Fprintln(stderr, r.String())
+	case "json":
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stderr, string(data))
+	default:
+		return fmt.Errorf("unknown report format %q (want text or json)", settings.Report)
+	}
+
+	if settings.ReportFile == "" {
+		return nil
+	}
+	path := expandHome(settings.ReportFile)
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\n", data)
+	return err
+}
+
+// formatBytes renders a byte count using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
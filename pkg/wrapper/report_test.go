@@ -0,0 +1,182 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncReport_CountsSyncActivity(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	store := t.TempDir()
+
+	writeFile(t, filepath.Join(store, "notes.md"), "12345")
+	writeFile(t, filepath.Join(store, "prompts", "a.md"), "abc")
+	writeFile(t, filepath.Join(store, "stale.txt"), "old")
+
+	cfg := &Config{
+		RepoRoot:      repoRoot,
+		CurrentBranch: "main",
+		DefaultBranch: "main",
+		StoreBase:     store,
+		StoreLocation: store,
+	}
+	cfg.report = newSyncReport(cfg)
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	// Drop stale.txt from the exclude file, long enough ago that sync-out
+	// removes it
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "notes.md\nprompts\n")
+	givenTombstoned(t, store, "stale.txt")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	r := cfg.report
+	if r.FilesIn != 3 {
+		t.Errorf("expected 3 files in, got %d", r.FilesIn)
+	}
+	if r.FilesOut != 2 {
+		t.Errorf("expected 2 files out, got %d", r.FilesOut)
+	}
+	if r.Removed != 1 || len(r.RemovedItems) != 1 || r.RemovedItems[0] != "stale.txt" {
+		t.Errorf("expected stale.txt to be reported removed, got %d %v", r.Removed, r.RemovedItems)
+	}
+	if r.BytesCopied != 11+8 {
+		t.Errorf("expected 19 bytes copied, got %d", r.BytesCopied)
+	}
+}
+
+func TestSyncReport_NilIsSafe(t *testing.T) {
+	var r *SyncReport
+	r.addIn(&copier{files: 1})
+	r.addOut(&copier{files: 1})
+	r.addRemoved("x")
+	r.addIgnored("x")
+	r.addDuration(0)
+}
+
+func TestEmitReport(t *testing.T) {
+	r := &SyncReport{Branch: "feature", FilesIn: 2, FilesOut: 1, Removed: 1, BytesCopied: 2048}
+
+	t.Run("off by default", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := emitReport(Settings{}, r, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected no output, got %q", buf.String())
+		}
+	})
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := emitReport(Settings{Report: "text"}, r, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "2 file(s) in, 1 out, 1 removed, 2.0 KiB") {
+			t.Errorf("unexpected text report %q", buf.String())
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := emitReport(Settings{Report: "json"}, r, &buf); err != nil {
+			t.Fatal(err)
+		}
+		var decoded SyncReport
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("report is not valid JSON: %v", err)
+		}
+		if decoded.FilesIn != 2 || decoded.Branch != "feature" {
+			t.Errorf("unexpected decoded report %+v", decoded)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := emitReport(Settings{Report: "yaml"}, r, &buf); err == nil {
+			t.Error("expected error for unknown format")
+		}
+	})
+
+	t.Run("appends to report file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "logs", "sync.jsonl")
+		var buf bytes.Buffer
+		for i := 0; i < 2; i++ {
+			if err := emitReport(Settings{ReportFile: path}, r, &buf); err != nil {
+				t.Fatal(err)
+			}
+		}
+		lines := strings.Split(strings.TrimSpace(readFileContent(t, path)), "\n")
+		if len(lines) != 2 {
+			t.Errorf("expected 2 report lines, got %d", len(lines))
+		}
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KiB",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+	}
+	for n, expected := range tests {
+		if got := formatBytes(n); got != expected {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, expected)
+		}
+	}
+}
+
+func TestSyncReport_StringMentionsIgnoredItems(t *testing.T) {
+	r := &SyncReport{Branch: "main"}
+	r.addIgnored("node_modules")
+	r.addIgnored("node_modules")
+
+	if got := r.String(); !strings.Contains(got, "skipped node_modules (never_manage)") {
+		t.Errorf("expected ignored items in %q", got)
+	}
+	if len(r.IgnoredItems) != 1 {
+		t.Errorf("expected duplicates to be recorded once, got %v", r.IgnoredItems)
+	}
+}
+
+func TestSyncReport_Timings(t *testing.T) {
+	cfg, _ := givenConfig(t, givenRepo(t), configOpts{})
+	r := newSyncReport(cfg)
+	r.addTiming("sync_in", 30*time.Millisecond)
+	r.addTiming("sync_out", 10*time.Millisecond)
+	r.addTiming("sync_in", 20*time.Millisecond)
+	r.addItemTiming(".claude", 45*time.Millisecond)
+	r.addItemTiming("CLAUDE.md", time.Millisecond)
+	traceGit("", "git status", 5*time.Millisecond, nil, nil)
+	r.DurationMS = 60
+
+	var out bytes.Buffer
+	if err := emitReport(Settings{Report: "json", ProfileSync: true}, r, &out); err != nil {
+		t.Fatal(err)
+	}
+	if r.TimingsMS["sync_in"] != 50 || r.TimingsMS["git"] < 5 || r.GitCalls < 1 {
+		t.Errorf("unexpected timings %v with %d git calls", r.TimingsMS, r.GitCalls)
+	}
+
+	profile, jsonLine, _ := strings.Cut(out.String(), "{")
+	if !strings.Contains(jsonLine, `"timings_ms":{`) || !strings.Contains(jsonLine, `"item_timings_ms":{".claude":45`) {
+		t.Errorf("expected timings in the JSON report, got {%s", jsonLine)
+	}
+	if strings.Index(profile, "sync_in") > strings.Index(profile, "sync_out") {
+		t.Errorf("expected the slowest phase first:\n%s", profile)
+	}
+	if strings.Index(profile, ".claude") > strings.Index(profile, "CLAUDE.md") {
+		t.Errorf("expected the slowest item first:\n%s", profile)
+	}
+}
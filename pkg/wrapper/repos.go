@@ -0,0 +1,246 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// storeMetaFile describes a repository store: which repository (and remote)
+// it belongs to and when it was last synced. It lives in the store base.
+const storeMetaFile = ".store.json"
+
+// storeMeta is the content of storeMetaFile.
+type storeMeta struct {
+	Repo   string `json:"repo"`
+	Remote string `json:"remote,omitempty"`
+	// Host is the machine Repo is on. A store root shared between machines
+	// holds stores of repositories this one doesn't have.
+	Host     string    `json:"host,omitempty"`
+	LastSync time.Time `json:"last_sync"`
+	// LastCleanup is when cleanup last checked the repository's branch
+	// stores against its branches.
+	LastCleanup *time.Time `json:"last_cleanup,omitempty"`
+}
+
+// readStoreMeta returns the metadata recorded in storeBase, or false if there
+// is none (the store predates it).
+func readStoreMeta(storeBase string) (storeMeta, bool) {
+	var meta storeMeta
+	data, err := os.ReadFile(filepath.Join(storeBase, storeMetaFile))
+	if err != nil || json.Unmarshal(data, &meta) != nil {
+		return storeMeta{}, false
+	}
+	return meta, true
+}
+
+// recordStoreMeta records cfg's repository and the time in its store base.
+func recordStoreMeta(cfg *Config, now time.Time) {
+	meta, _ := readStoreMeta(cfg.StoreBase)
+	meta.Repo, meta.Remote, meta.LastSync = cfg.RepoRoot, cfg.remote(), now.UTC()
+	meta.Host = localHost()
+	writeStoreMeta(cfg.StoreBase, meta)
+}
+
+// recordCleanup records in cfg's store base that cleanup ran now, returning
+// when it last ran before (zero if unknown). Stores without metadata are
+// left without.
+func recordCleanup(cfg *Config, now time.Time) time.Time {
+	meta, ok := readStoreMeta(cfg.StoreBase)
+	if !ok {
+		return time.Time{}
+	}
+	last := derefTime(meta.LastCleanup)
+	at := now.UTC()
+	meta.LastCleanup = &at
+	writeStoreMeta(cfg.StoreBase, meta)
+	return last
+}
+
+// defaultCleanupIntervalHours is how often cleanup runs after sessions
+// unless cleanup_interval_hours says otherwise.
+const defaultCleanupIntervalHours = 6
+
+// cleanupInterval returns how long after a cleanup the next is due. A
+// negative cleanup_interval_hours runs it after every session (returns 0).
+func (s Settings) cleanupInterval() time.Duration {
+	hours := s.CleanupIntervalHours
+	if hours < 0 {
+		return 0
+	}
+	if hours == 0 {
+		hours = defaultCleanupIntervalHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// cleanupDue reports whether cleanup should run for cfg's repository now:
+// the cleanup interval has passed since it last ran, or it is not known to
+// have run.
+func cleanupDue(cfg *Config, now time.Time) bool {
+	meta, ok := readStoreMeta(cfg.StoreBase)
+	if !ok || meta.LastCleanup == nil {
+		return true
+	}
+	return !now.Before(meta.LastCleanup.Add(cfg.Settings.cleanupInterval()))
+}
+
+// writeStoreMeta replaces the metadata in storeBase with meta.
+func writeStoreMeta(storeBase string, meta storeMeta) {
+	data, err := json.Marshal(meta)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(storeBase, storeMetaFile), append(data, '\n'), 0644)
+	}
+	if err != nil {
+		warnf("failed to record store metadata: %v", err)
+	}
+}
+
+// repoStore summarizes one repository store under the store root.
+type repoStore struct {
+	name     string
+	store    string
+	repo     string // "" if unknown
+	remote   string
+	host     string // "" if unknown
+	missing  bool   // repo is on this machine and no longer exists
+	branches int
+	size     int64
+	lastSync time.Time
+	// pendingDeletion is when the oldest store awaiting deletion, the whole
+	// store or a branch store, was marked, if any is.
+	pendingDeletion time.Time
+}
+
+// runReposCommand implements `claude-wrapper repos`.
+func runReposCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("repos")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+
+	settings, err := LoadSettings()
+	if err != nil {
+		return 1, withExitCode(exitConfig, fmt.Errorf("failed to load settings: %w", err))
+	}
+	flags.apply(&settings)
+	root, err := settings.storeRoot()
+	if err != nil {
+		return 1, err
+	}
+	stores, err := collectRepoStores(root)
+	if err != nil {
+		return 1, err
+	}
+	if err := printRepoStores(root, stores, os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// collectRepoStores summarizes every repository store under root, in name
+// order.
+func collectRepoStores(root string) ([]repoStore, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list %s: %w", root, err)
+	}
+
+	var stores []repoStore
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == templatesDir {
+			continue
+		}
+		storeBase := filepath.Join(root, entry.Name())
+		branches, err := storedBranches(storeBase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list branch stores of %s: %w", entry.Name(), err)
+		}
+		_, size := storeContents(storeBase)
+		store := repoStore{
+			name:     entry.Name(),
+			store:    storeBase,
+			branches: len(branches) + 1, // The default branch's store is the base
+			size:     size,
+		}
+		if meta, ok := readStoreMeta(storeBase); ok {
+			store.repo, store.remote, store.host, store.lastSync = meta.Repo, meta.Remote, meta.Host, meta.LastSync
+			// Only this machine's repositories can be found missing
+			if meta.Host != "" && meta.Host == localHost() {
+				if _, err := os.Stat(meta.Repo); os.IsNotExist(err) {
+					store.missing = true
+				}
+			}
+		}
+		markers := []string{filepath.Join(storeBase, deletionMarker)} // The whole store, by gc --repos
+		for branch := range branches {
+			markers = append(markers, filepath.Join(branchStoreDir(storeBase, branch), deletionMarker))
+		}
+		for _, marker := range markers {
+			if markedAt, ok := readDeletionMarker(marker); ok && (store.pendingDeletion.IsZero() || markedAt.Before(store.pendingDeletion)) {
+				store.pendingDeletion = markedAt
+			}
+		}
+		stores = append(stores, store)
+	}
+	return stores, nil
+}
+
+// readDeletionMarker returns when the branch store owning the marker at path
+// was marked for deletion, or false if it isn't marked.
+func readDeletionMarker(path string) (time.Time, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	timestamp, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(timestamp, 0), true
+}
+
+// printRepoStores lists the repository stores under root.
+func printRepoStores(root string, stores []repoStore, w io.Writer) error {
+	if len(stores) == 0 {
+		fmt.Fprintf(w, "no repository stores in %s\n", root)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPO\tPATH\tBRANCHES\tSIZE\tLAST SYNC\tPENDING DELETION")
+	var total int64
+	for _, store := range stores {
+		path := store.repo
+		switch {
+		case path == "":
+			path = "unknown"
+		case store.missing:
+			path += " (missing)"
+		case store.host != "" && store.host != localHost():
+			path += " (on " + store.host + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", store.name, path, store.branches,
+			formatBytes(store.size), formatTime(store.lastSync), formatTime(store.pendingDeletion))
+		total += store.size
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%d repository store(s) in %s, %s total\n", len(stores), root, formatBytes(total))
+	return nil
+}
+
+// formatTime formats t for listings in local time, or "-" if unset.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,141 @@
+package wrapper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncOut_RecordsStoreMeta(t *testing.T) {
+	cfg, storeBase := givenConfig(t, givenRepo(t), configOpts{currentBranch: "feature"})
+
+	before := time.Now().Add(-time.Second)
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	meta, ok := readStoreMeta(storeBase)
+	if !ok {
+		t.Fatal("expected store metadata after sync-out")
+	}
+	if meta.Repo != cfg.RepoRoot || meta.LastSync.Before(before) {
+		t.Errorf("unexpected store metadata %+v", meta)
+	}
+	if !isReservedItem(storeMetaFile) {
+		t.Error("store metadata must not be synced as a personal file")
+	}
+}
+
+func TestCollectRepoStores(t *testing.T) {
+	root := t.TempDir()
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(root, templatesDir, "default", "CLAUDE.md"), "template")
+
+	// A synced store with two branch stores, one pending deletion
+	app := filepath.Join(root, "app")
+	writeFile(t, filepath.Join(app, "CLAUDE.md"), "12345")
+	writeFile(t, filepath.Join(app, branchesDir, "feature", "CLAUDE.md"), "123")
+	old := filepath.Join(app, branchesDir, sanitizeBranchName("old/work"))
+	writeFile(t, filepath.Join(old, "notes.md"), "1")
+	markedAt := time.Unix(1700000000, 0)
+	writeFile(t, filepath.Join(old, deletionMarker), strconv.FormatInt(markedAt.Unix(), 10))
+	recordStoreMeta(&Config{RepoRoot: repo, StoreBase: app}, time.Unix(1710000000, 0))
+
+	// A store from before metadata was recorded, and one whose repo is gone
+	writeFile(t, filepath.Join(root, "legacy", "CLAUDE.md"), "legacy")
+	gone := filepath.Join(root, "gone")
+	if err := os.MkdirAll(gone, 0755); err != nil {
+		t.Fatal(err)
+	}
+	recordStoreMeta(&Config{RepoRoot: filepath.Join(repo, "deleted"), StoreBase: gone}, time.Now())
+
+	stores, err := collectRepoStores(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stores) != 3 {
+		t.Fatalf("expected 3 stores (templates excluded), got %+v", stores)
+	}
+
+	app0, gone0, legacy0 := stores[0], stores[1], stores[2]
+	if app0.name != "app" || app0.repo != repo || app0.missing || app0.branches != 3 {
+		t.Errorf("unexpected app store %+v", app0)
+	}
+	if !app0.pendingDeletion.Equal(markedAt) || !app0.lastSync.Equal(time.Unix(1710000000, 0)) {
+		t.Errorf("unexpected app store times %+v", app0)
+	}
+	if app0.size < 9 {
+		t.Errorf("expected app store size to include every branch store, got %d", app0.size)
+	}
+	if !gone0.missing {
+		t.Errorf("expected store of a deleted repository to be missing, got %+v", gone0)
+	}
+	if legacy0.repo != "" || !legacy0.lastSync.IsZero() {
+		t.Errorf("expected legacy store to be unknown, got %+v", legacy0)
+	}
+
+	var out bytes.Buffer
+	if err := printRepoStores(root, stores, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{repo, "(missing)", "unknown", "3 repository store(s)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in listing:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPrintRepoStores_Empty(t *testing.T) {
+	var out bytes.Buffer
+	if err := printRepoStores("/stores", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "no repository stores in /stores") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestRecordCleanup_ReturnsThePreviousRun(t *testing.T) {
+	cfg := &Config{RepoRoot: "/src/app", StoreBase: t.TempDir()}
+	first := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	if last := recordCleanup(cfg, first); !last.IsZero() {
+		t.Errorf("recordCleanup without metadata = %v, want zero", last)
+	}
+	recordStoreMeta(cfg, first)
+	recordCleanup(cfg, first)
+	recordStoreMeta(cfg, first.Add(time.Hour))
+	if last := recordCleanup(cfg, first.Add(2*time.Hour)); !last.Equal(first) {
+		t.Errorf("recordCleanup = %v, want the first run kept across sync-outs", last)
+	}
+}
+
+func TestCleanupDue_AfterTheInterval(t *testing.T) {
+	cfg := &Config{RepoRoot: "/src/app", StoreBase: t.TempDir()}
+	ran := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	recordStoreMeta(cfg, ran)
+	if !cleanupDue(cfg, ran) {
+		t.Error("cleanup not due before it ever ran")
+	}
+	recordCleanup(cfg, ran)
+
+	for _, tt := range []struct {
+		hours int
+		after time.Duration
+		want  bool
+	}{
+		{0, time.Hour, false},
+		{0, 6 * time.Hour, true},
+		{24, 12 * time.Hour, false},
+		{24, 25 * time.Hour, true},
+		{-1, time.Minute, true},
+	} {
+		cfg.Settings.CleanupIntervalHours = tt.hours
+		if got := cleanupDue(cfg, ran.Add(tt.after)); got != tt.want {
+			t.Errorf("cleanup_interval_hours = %d: cleanupDue %v later = %v, want %v", tt.hours, tt.after, got, tt.want)
+		}
+	}
+}
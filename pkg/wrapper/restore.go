@@ -0,0 +1,125 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// restoreItems replaces the working tree copy of path, or of every item in
+// cfg's branch store if path is empty, with the stored copy, discarding
+// edits since the last sync-in. path is relative to dir, a directory in the
+// repository. An item pending removal from storage is only restored when
+// named; that lists it in the exclude file again and cancels the removal.
+// via names the caller in the audit log. It returns the restored paths.
+func restoreItems(cfg *Config, dir, path, via string) ([]string, error) {
+	tombstones := readTombstones(cfg.StoreLocation)
+
+	var paths []string
+	if path != "" {
+		rel, err := managedPath(cfg, dir, path)
+		if err != nil {
+			return nil, err
+		}
+		paths = []string{rel}
+	} else {
+		stored, err := listDir(cfg.StoreLocation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list store: %w", err)
+		}
+		tracked, err := trackedItems(cfg, stored)
+		if err != nil {
+			return nil, err
+		}
+		ignore := readWrapperIgnore(cfg.StoreLocation)
+		for _, item := range filterItems(stored) {
+			if _, pending := tombstones[item]; !tracked[item] && !pending && !ignore.matches(item) {
+				paths = append(paths, item)
+			}
+		}
+	}
+
+	restored := []string{}
+	var templates []string
+	for _, rel := range paths {
+		src := filepath.Join(cfg.StoreLocation, rel)
+		if _, err := os.Lstat(src); err != nil && isTemplateFile(src+templateSuffix) {
+			templates = append(templates, rel+templateSuffix)
+			restored = append(restored, rel)
+			continue
+		}
+		if _, err := os.Lstat(src); err != nil {
+			return restored, fmt.Errorf("%s is not in storage", rel)
+		}
+		templates = append(templates, rel)
+		dst := filepath.Join(cfg.RepoRoot, rel)
+		if _, err := os.Lstat(dst); err == nil {
+			err := auditedRemoveAll(cfg.StoreBase, auditEntry{
+				Path:   dst,
+				Reason: "restored from storage (" + via + ")",
+				Repo:   cfg.RepoRoot,
+				Branch: cfg.CurrentBranch,
+			})
+			if err != nil {
+				return restored, fmt.Errorf("failed to remove %s: %w", rel, err)
+			}
+		}
+		skip := cfg.skipper(cfg.StoreLocation, false)
+		c := &copier{skip: func(src string) bool { return skip(src) || isTemplateFile(src) }, verify: cfg.Settings.VerifyCopies, preserveOwnership: cfg.Settings.PreserveOwnership}
+		if err := c.copyPath(src, dst); err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", rel, err)
+		}
+		restored = append(restored, rel)
+
+		item := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+		if _, pending := tombstones[item]; pending {
+			if err := addToExclude(cfg.RepoRoot, cfg.excludeFile(), item); err != nil {
+				return restored, fmt.Errorf("failed to update exclude for %s: %w", item, err)
+			}
+			delete(tombstones, item)
+			if err := writeTombstones(cfg.StoreLocation, tombstones); err != nil {
+				return restored, fmt.Errorf("failed to cancel the removal of %s: %w", item, err)
+			}
+		}
+	}
+	if err := renderStoredTemplates(cfg, templates); err != nil {
+		return restored, err
+	}
+	if err := renderMCPConfigs(cfg); err != nil {
+		return restored, err
+	}
+	return restored, nil
+}
+
+// runRestoreCommand implements `claude-wrapper restore [PATH...]`.
+func runRestoreCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("restore")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return 1, err
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{""} // Everything
+	}
+	for _, path := range paths {
+		restored, err := restoreItems(cfg, dir, path, "claude-wrapper restore")
+		for _, rel := range restored {
+			fmt.Printf("%s %s\n", colorize(os.Stdout, toneAdded, "restored"), rel)
+		}
+		if err != nil {
+			return 1, err
+		}
+	}
+	return 0, nil
+}
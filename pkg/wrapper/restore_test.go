@@ -0,0 +1,53 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRestoreItems_KeepsItemPendingRemoval(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "prompts", "review.md"), "stored prompt")
+	givenTombstoned(t, cfg.StoreLocation, "prompts")
+
+	restored, err := restoreItems(cfg, repoRoot, "prompts", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, []string{"prompts"}) {
+		t.Errorf("restored = %v, want [prompts]", restored)
+	}
+	assertFileContent(t, filepath.Join(repoRoot, "prompts", "review.md"), "stored prompt")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, tombstonesFile))
+	if !excludeManages(cfg.excludeFile(), "prompts") {
+		t.Error("expected prompts to be excluded again")
+	}
+
+	// Without the tombstone, sync-out keeps the item
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "prompts", "review.md"), "stored prompt")
+}
+
+func TestRestoreItems_AllSkipsItemsPendingRemoval(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "stored")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "old.md"), "stale")
+	givenTombstoned(t, cfg.StoreLocation, "old.md")
+
+	restored, err := restoreItems(cfg, repoRoot, "", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, []string{"CLAUDE.md"}) {
+		t.Errorf("restored = %v, want [CLAUDE.md]", restored)
+	}
+	assertNotExists(t, filepath.Join(repoRoot, "old.md"))
+	if _, ok := readTombstones(cfg.StoreLocation)["old.md"]; !ok {
+		t.Error("expected old.md to stay pending removal")
+	}
+}
@@ -0,0 +1,171 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// unusedExpiry returns when the store at store, of a branch that still
+// exists, starts its warning period under expire_unused_days and when it
+// expires, or false if it never does: the setting is off, or the store
+// predates its metadata. Declining to delete it counts as using it.
+func (s Settings) unusedExpiry(store string) (warnAt, expiresAt time.Time, ok bool) {
+	if s.ExpireUnusedDays <= 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	meta, ok := readBranchMeta(store)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	used := meta.CreatedAt
+	for _, t := range []time.Time{meta.lastUsed(), derefTime(meta.KeptAt)} {
+		if t.After(used) {
+			used = t
+		}
+	}
+	warnAt = used.Add(time.Duration(s.ExpireUnusedDays) * 24 * time.Hour)
+	return warnAt, warnAt.Add(deletionGraceDays * 24 * time.Hour), true
+}
+
+// derefTime returns *t, or the zero time if t is nil.
+func derefTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// expireUnusedBranchStore removes the store at path of branch, which still
+// exists, once it has gone unused past its warning period, asking first
+// like cleanup does for deleted branches. During the warning period it
+// only says when the store will go.
+func expireUnusedBranchStore(cfg *Config, branch, path string, now time.Time) {
+	warnAt, expiresAt, ok := cfg.Settings.unusedExpiry(path)
+	if !ok || now.Before(warnAt) {
+		return
+	}
+	action := "removed"
+	if cfg.Settings.CleanupPolicy == "archive" {
+		action = "archived"
+	}
+	if now.Before(expiresAt) {
+		warnf("branch %s has not been used for %d days; its store will be %s after %s unless it is synced",
+			branch, cfg.Settings.ExpireUnusedDays, action, formatTime(expiresAt))
+		return
+	}
+
+	if cfg.Settings.CleanupPolicy != "archive" && !confirmUnusedDeletionFunc(cfg, branch, path) {
+		// Declined: start over as if just used
+		updateBranchMeta(path, cfg.RepoRoot, now, func(meta *branchMeta) {
+			at := now.UTC()
+			meta.KeptAt = &at
+		})
+		return
+	}
+	reason := fmt.Sprintf("branch unused for more than %d days", cfg.Settings.ExpireUnusedDays)
+	removeExpiredBranchStore(cfg, branch, path, reason, warnAt, now)
+}
+
+// confirmUnusedDeletionFunc decides whether the store of a branch gone
+// unused may be deleted. Replaced in tests.
+var confirmUnusedDeletionFunc = confirmUnusedDeletion
+
+// confirmUnusedDeletion asks before deleting the store of a branch gone
+// unused when attached to a terminal, like confirmBranchDeletion.
+func confirmUnusedDeletion(cfg *Config, branch, path string) bool {
+	if cfg.Settings.AssumeYes || !isInteractive() {
+		return true
+	}
+	why := fmt.Sprintf("has not been used for more than %d days", cfg.Settings.ExpireUnusedDays)
+	return promptStoreDeletion(os.Stdin, os.Stderr, fmt.Sprintf("branch %q", branch), why, path,
+		cfg.Settings.ExpireUnusedDays+deletionGraceDays)
+}
+
+// Default grace periods of the stores of branches deleted after being
+// merged into the default branch, and with commits it doesn't have.
+const (
+	defaultMergedGraceDays   = 2
+	defaultUnmergedGraceDays = 30
+)
+
+// How a branch was deleted from git, as branchMeta.Deletion records it.
+const (
+	deletedMerged   = "merged"
+	deletedUnmerged = "unmerged"
+)
+
+// graceDays returns how many days the store of a branch deleted as
+// deletion ("" if unknown) is kept.
+func (s Settings) graceDays(deletion string) int {
+	switch {
+	case deletion == deletedMerged && s.MergedGraceDays > 0:
+		return s.MergedGraceDays
+	case deletion == deletedMerged:
+		return defaultMergedGraceDays
+	case deletion == deletedUnmerged && s.UnmergedGraceDays > 0:
+		return s.UnmergedGraceDays
+	case deletion == deletedUnmerged:
+		return defaultUnmergedGraceDays
+	}
+	return deletionGraceDays
+}
+
+// branchTipsFunc lists the commit each local branch points to, and
+// isAncestorFunc whether a commit is reachable from a branch. Replaced in
+// tests.
+var (
+	branchTipsFunc = func() (map[string]string, error) { return gitRepo.BranchTips() }
+	isAncestorFunc = func(commit, target string) (bool, error) { return gitRepo.IsAncestor(commit, target) }
+)
+
+// recordBranchTip notes in the metadata of the store at path the commit
+// branch points to, from tips, so whether its work was merged can still be
+// told once it is deleted.
+func recordBranchTip(cfg *Config, branch, path string, tips map[string]string, now time.Time) {
+	commit, ok := tips[branch]
+	if meta, _ := readBranchMeta(path); !ok || meta.Commit == commit {
+		return
+	}
+	updateBranchMeta(path, cfg.RepoRoot, now, func(meta *branchMeta) {
+		meta.Commit = commit
+	})
+}
+
+// recordDeletion decides whether the branch whose store at path was just
+// found deleted from git had been merged into the default branch: cleanup
+// saw it merged, or the last commit it saw the branch point to is
+// reachable from the default branch. It records the answer in the store's
+// metadata and loudly warns about unmerged work, which is kept longer.
+func recordDeletion(cfg *Config, branch, path string, now time.Time) {
+	meta, ok := readBranchMeta(path)
+	if !ok {
+		return
+	}
+	deletion := ""
+	switch {
+	case meta.MergedAt != nil:
+		deletion = deletedMerged
+	case meta.Commit != "":
+		merged, err := isAncestorFunc(meta.Commit, cfg.DefaultBranch)
+		switch {
+		case err != nil:
+			// The commit may be gone with the branch
+			warnf("could not tell whether deleted branch %s was merged into %s: %v", branch, cfg.DefaultBranch, err)
+		case merged:
+			deletion = deletedMerged
+		default:
+			deletion = deletedUnmerged
+		}
+	}
+	if deletion == "" {
+		return
+	}
+	updateBranchMeta(path, cfg.RepoRoot, now, func(meta *branchMeta) {
+		meta.Deletion = deletion
+	})
+	if deletion == deletedUnmerged {
+		warnf("branch %s was deleted with commits %s doesn't have; keeping its store for %d days instead of %d (claude-wrapper list --diverged %s lists its personal files)",
+			branch, cfg.DefaultBranch, cfg.Settings.graceDays(deletion), deletionGraceDays, branch)
+	}
+}
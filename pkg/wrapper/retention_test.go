@@ -0,0 +1,179 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// givenUnusedBranchStore returns a config on main with expire_unused_days
+// set to 30 and the store of branch, which still exists, last synced
+// daysAgo.
+func givenUnusedBranchStore(t *testing.T, branch string, daysAgo int) (*Config, string) {
+	t.Helper()
+	store := t.TempDir()
+	branchStore := filepath.Join(store, branchesDir, branch)
+	writeFile(t, filepath.Join(branchStore, "CLAUDE.md"), "old notes")
+	used := time.Now().Add(-time.Duration(daysAgo) * 24 * time.Hour)
+	updateBranchMeta(branchStore, "", used, func(meta *branchMeta) { meta.LastSyncOut = &used })
+
+	orig := getAllBranchesFunc
+	getAllBranchesFunc = func() (map[string]bool, error) {
+		return map[string]bool{"main": true, branch: true}, nil
+	}
+	t.Cleanup(func() { getAllBranchesFunc = orig })
+
+	cfg := &Config{
+		CurrentBranch: "main",
+		DefaultBranch: "main",
+		StoreBase:     store,
+		StoreLocation: store,
+		Settings:      Settings{ExpireUnusedDays: 30},
+	}
+	return cfg, branchStore
+}
+
+func TestCleanupDeletedBranches_ExpiresUnusedStore(t *testing.T) {
+	cfg, branchStore := givenUnusedBranchStore(t, "abandoned", 40)
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, branchStore)
+	entries := readAuditLog(t, cfg.StoreBase)
+	if len(entries) != 1 || !strings.Contains(entries[0].Reason, "unused for more than 30 days") {
+		t.Errorf("audit log = %+v, want the unused store's removal", entries)
+	}
+}
+
+func TestCleanupDeletedBranches_WarnsDuringUnusedWarningPeriod(t *testing.T) {
+	cfg, branchStore := givenUnusedBranchStore(t, "quiet", 33)
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(branchStore, "CLAUDE.md"), "old notes")
+
+	preview := collectCleanupPreview(cfg)
+	if len(preview) != 1 || !preview[0].Unused || preview[0].Branch != "quiet" {
+		t.Errorf("cleanup preview = %+v, want the unused store", preview)
+	}
+}
+
+func TestCleanupDeletedBranches_KeepsUnusedStoreWhenDeclined(t *testing.T) {
+	cfg, branchStore := givenUnusedBranchStore(t, "keep-me", 40)
+	orig := confirmUnusedDeletionFunc
+	confirmUnusedDeletionFunc = func(*Config, string, string) bool { return false }
+	t.Cleanup(func() { confirmUnusedDeletionFunc = orig })
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(branchStore, "CLAUDE.md"), "old notes")
+	if warnAt, _, ok := cfg.Settings.unusedExpiry(branchStore); !ok || warnAt.Before(time.Now().Add(29*24*time.Hour)) {
+		t.Errorf("warning period starts %v, want it restarted", warnAt)
+	}
+}
+
+func TestCleanupDeletedBranches_ArchivesUnusedStore(t *testing.T) {
+	cfg, branchStore := givenUnusedBranchStore(t, "old", 40)
+	cfg.Settings.CleanupPolicy = "archive"
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, branchStore)
+	archives, _ := listDir(filepath.Join(cfg.StoreBase, archiveDir))
+	if len(archives) != 1 {
+		t.Errorf("archives = %v, want one", archives)
+	}
+}
+
+func TestUnusedExpiry_OffOrWithoutMetadata(t *testing.T) {
+	cfg, branchStore := givenUnusedBranchStore(t, "any", 400)
+
+	if _, _, ok := (Settings{}).unusedExpiry(branchStore); ok {
+		t.Error("expected no expiry with expire_unused_days unset")
+	}
+	if _, _, ok := cfg.Settings.unusedExpiry(t.TempDir()); ok {
+		t.Error("expected no expiry for a store without metadata")
+	}
+}
+
+// givenDeletedBranchStore returns a config on main and the store of the
+// feature branch, last used 40 days ago, which cleanup saw at commit abc123
+// before finding it deleted, with merged deciding whether that commit is
+// reachable from main. The deletion marker is dated markedDaysAgo.
+func givenDeletedBranchStore(t *testing.T, merged bool, markedDaysAgo int) (*Config, string) {
+	t.Helper()
+	cfg, branchStore := givenUnusedBranchStore(t, "feature", 40)
+	cfg.Settings.ExpireUnusedDays = 0
+	origTips, origAncestor := branchTipsFunc, isAncestorFunc
+	branchTipsFunc = func() (map[string]string, error) {
+		return map[string]string{"main": "def456", "feature": "abc123"}, nil
+	}
+	isAncestorFunc = func(commit, target string) (bool, error) {
+		if commit != "abc123" || target != "main" {
+			t.Errorf("IsAncestor(%q, %q), want abc123 checked against main", commit, target)
+		}
+		return merged, nil
+	}
+	t.Cleanup(func() { branchTipsFunc, isAncestorFunc = origTips, origAncestor })
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if meta, _ := readBranchMeta(branchStore); meta.Commit != "abc123" {
+		t.Fatalf("branch meta = %+v, want the tip recorded", meta)
+	}
+	withBranches(t, map[string]bool{"main": true})
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	marked := time.Now().Add(-time.Duration(markedDaysAgo) * 24 * time.Hour)
+	writeFile(t, filepath.Join(branchStore, deletionMarker), strconv.FormatInt(marked.Unix(), 10))
+	return cfg, branchStore
+}
+
+func TestCleanupDeletedBranches_ExpiresMergedBranchSooner(t *testing.T) {
+	cfg, branchStore := givenDeletedBranchStore(t, true, 3)
+	if meta, _ := readBranchMeta(branchStore); meta.Deletion != deletedMerged {
+		t.Fatalf("branch meta = %+v, want it deleted merged", meta)
+	}
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, branchStore)
+}
+
+func TestCleanupDeletedBranches_KeepsUnmergedBranchLonger(t *testing.T) {
+	diagnostics := captureDiagnostics(t)
+	cfg, branchStore := givenDeletedBranchStore(t, false, 10)
+	if !strings.Contains(diagnostics.String(), "branch feature was deleted with commits main doesn't have; keeping its store for 30 days") {
+		t.Errorf("expected a warning about unmerged work, got:\n%s", diagnostics.String())
+	}
+
+	if err := cleanupDeletedBranches(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, branchStore)
+	if expiry := branchStoreExpiry(cfg.Settings, branchStore, time.Now()); expiry.Before(time.Now().Add(29 * 24 * time.Hour)) {
+		t.Errorf("expiry = %v, want 30 days out", expiry)
+	}
+}
+
+func TestParseSettings_GraceDays(t *testing.T) {
+	var s Settings
+	if err := parseSettings("merged_grace_days = 1\nunmerged_grace_days = 60\n", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.graceDays(deletedMerged) != 1 || s.graceDays(deletedUnmerged) != 60 || s.graceDays("") != deletionGraceDays {
+		t.Errorf("grace days = %d/%d/%d", s.graceDays(deletedMerged), s.graceDays(deletedUnmerged), s.graceDays(""))
+	}
+	if err := parseSettings("merged_grace_days = -1", &Settings{}); err == nil {
+		t.Error("expected error for negative merged_grace_days")
+	}
+}
@@ -0,0 +1,34 @@
+package wrapper
+
+import (
+	"errors"
+)
+
+// runRunCommand implements `claude-wrapper run [--] <command> [args...]`: a
+// session like a claude invocation, but running command instead, so scripts
+// that need the personal files (e.g. an .env.local) can use them. The exit
+// code is the command's.
+func runRunCommand(flags wrapperFlags, args []string) (int, error) {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return usageError("run")
+	}
+	launch := func() int {
+		exitCode, err := runProcess(args[0], args[1:])
+		if err != nil {
+			errorf("%v", err)
+		}
+		return exitCode
+	}
+
+	cfg, err := openRepo(flags)
+	if errors.Is(err, ErrRepoDisabled) {
+		return launch(), nil // Nothing to sync; just run the command
+	}
+	if err != nil {
+		return 1, err
+	}
+	return runSession(cfg, launch)
+}
@@ -0,0 +1,103 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRunCommand_RunsWithPersonalFilesAndSyncsOut(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, ".env.local"), "A=1\n")
+
+	code, err := runRunCommand(wrapperFlags{}, []string{"--", "sh", "-c", "cat .env.local > seen.txt; echo B=2 >> .env.local; exit 3"})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want the command's 3", code)
+	}
+
+	assertFileContent(t, filepath.Join(dir, "seen.txt"), "A=1\n")
+	assertFileContent(t, filepath.Join(storeBase, ".env.local"), "A=1\nB=2\n")
+}
+
+func TestRunCommand_MissingCommand(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	inRepo(t, dir)
+
+	if code, err := runRunCommand(wrapperFlags{}, []string{"--"}); err != nil || code != 2 {
+		t.Errorf("run with no command = %d, %v; want usage exit 2", code, err)
+	}
+	if code, err := runRunCommand(wrapperFlags{}, []string{"no-such-command-for-claude-wrapper"}); err != nil || code != 127 {
+		t.Errorf("run of a missing command = %d, %v; want 127", code, err)
+	}
+}
+
+func TestRunCommand_InOnlyDoesntSaveTheSession(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, ".env.local"), "A=1\n")
+
+	flags := wrapperFlags{inOnly: true}
+	if _, err := runRunCommand(flags, []string{"sh", "-c", "cat .env.local > seen.txt; echo B=2 >> .env.local"}); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(dir, "seen.txt"), "A=1\n")
+	assertFileContent(t, filepath.Join(storeBase, ".env.local"), "A=1\n")
+}
+
+func TestRunCommand_OutOnlySavesWithoutSyncingIn(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, ".env.local"), "A=1\n")
+	if _, err := runRunCommand(wrapperFlags{}, []string{"true"}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(storeBase, ".env.local"), "A=stored elsewhere\n")
+
+	flags := wrapperFlags{outOnly: true}
+	if _, err := runRunCommand(flags, []string{"sh", "-c", "cat .env.local > seen.txt; echo B=2 >> .env.local"}); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(dir, "seen.txt"), "A=1\n")
+	assertFileContent(t, filepath.Join(storeBase, ".env.local"), "A=1\nB=2\n")
+}
+
+func TestRunCommand_BranchOverridePicksTheStore(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, ".env.local"), "A=main\n")
+	writeFile(t, filepath.Join(branchStoreDir(storeBase, "release"), ".env.local"), "A=release\n")
+
+	flags := wrapperFlags{branch: "release"}
+	if _, err := runRunCommand(flags, []string{"sh", "-c", "cat .env.local > seen.txt; echo B=2 >> .env.local"}); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(dir, "seen.txt"), "A=release\n")
+	assertFileContent(t, filepath.Join(branchStoreDir(storeBase, "release"), ".env.local"), "A=release\nB=2\n")
+	assertFileContent(t, filepath.Join(storeBase, ".env.local"), "A=main\n")
+}
+
+func TestRunCommand_EphemeralDiscardsTheSessionsChanges(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, ".env.local"), "A=1\n")
+	writeFile(t, filepath.Join(storeBase, ".claude", "settings.json"), "{}")
+
+	flags := wrapperFlags{ephemeral: true}
+	script := "echo B=2 >> .env.local; echo scratch > .claude/scratch.txt; cat .env.local > seen.txt"
+	if _, err := runRunCommand(flags, []string{"sh", "-c", script}); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(dir, "seen.txt"), "A=1\nB=2\n")
+	assertFileContent(t, filepath.Join(dir, ".env.local"), "A=1\n")
+	assertNotExists(t, filepath.Join(dir, ".claude", "scratch.txt"))
+	assertFileContent(t, filepath.Join(storeBase, ".env.local"), "A=1\n")
+	assertNotExists(t, filepath.Join(storeBase, ".claude", "scratch.txt"))
+}
@@ -0,0 +1,262 @@
+package wrapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sandboxPrefix names the temporary directories sandboxed sessions run in.
+const sandboxPrefix = "claude-wrapper-sandbox-"
+
+// runSandboxSession runs launch in a temporary worktree of cfg's repository
+// at HEAD, with the branch store's items put there instead of in the
+// working tree (--sandbox). Neither the working tree nor the store changes
+// during the session. Afterwards the personal files the session changed are
+// offered for promotion into the store, and the worktree is removed unless
+// the session changed the repository itself. The exit code is launch's.
+func runSandboxSession(cfg *Config, launch func() int) (int, error) {
+	if !gitBinaryAvailable() {
+		return 0, withExitCode(exitSyncIn, fmt.Errorf("--sandbox needs the git command to create a worktree"))
+	}
+	sb, head, err := createSandbox(cfg)
+	if err != nil {
+		return 0, withExitCode(exitSyncIn, err)
+	}
+	infof("sandboxed session in %s", sb.RepoRoot)
+	unregister := registerSession(cfg, time.Now())
+	defer unregister()
+
+	// Run in the sandbox's copy of the directory claude was started in
+	wd, err := enterWorktree(cfg.RepoRoot, sb.RepoRoot)
+	if err != nil {
+		return 0, err
+	}
+	exitCode := launch()
+	cfg.report.ClaudeExit = exitCode
+	if err := os.Chdir(wd); err != nil {
+		return exitCode, err
+	}
+
+	changed, err := sandboxChanges(sb, cfg.StoreLocation)
+	if err != nil {
+		warnf("failed to compare the sandbox with the store: %v", err)
+	}
+	var promote []string
+	if len(changed) > 0 {
+		promote = confirmSandboxPromotionFunc(cfg, sb, changed)
+		if err := promoteSandboxFiles(cfg, sb, promote); err != nil {
+			return exitCode, withExitCode(exitSyncOut, fmt.Errorf("failed to promote the sandbox's files: %w", err))
+		}
+		if len(promote) > 0 {
+			infof("promoted %d of %d changed file(s) from the sandbox into %s's store", len(promote), len(changed), cfg.storeName())
+		}
+	}
+
+	// Without a terminal nothing was promoted, so the changes stay there
+	unpromoted := len(promote) < len(changed) && !cfg.Settings.AssumeYes && !isInteractive()
+	if reason := sandboxKeepReason(sb.RepoRoot, head, unpromoted); reason != "" {
+		warnf("keeping the sandbox at %s: %s; remove it with `git worktree remove --force %s`", sb.RepoRoot, reason, sb.RepoRoot)
+	} else if _, err := gitOutput(cfg.RepoRoot, "worktree", "remove", "--force", sb.RepoRoot); err != nil {
+		warnf("failed to remove the sandbox at %s: %v", sb.RepoRoot, err)
+	}
+	emitSessionReport(cfg)
+	return exitCode, nil
+}
+
+// createSandbox adds a detached worktree of cfg's repository at HEAD in a
+// temporary directory and puts the branch store's items in it. It returns
+// the Config describing the sandbox and the commit it started from.
+func createSandbox(cfg *Config) (*Config, string, error) {
+	head, err := gitOutput(cfg.RepoRoot, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	dir, err := os.MkdirTemp("", sandboxPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := gitOutput(cfg.RepoRoot, "worktree", "add", "--detach", dir, "HEAD"); err != nil {
+		os.RemoveAll(dir)
+		return nil, "", fmt.Errorf("failed to create a worktree for the sandbox: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	sb := *cfg
+	sb.RepoRoot = dir
+	if err := fillSandbox(&sb); err != nil {
+		if _, err := gitOutput(cfg.RepoRoot, "worktree", "remove", "--force", dir); err != nil {
+			warnf("failed to remove the sandbox at %s: %v", dir, err)
+		}
+		return nil, "", err
+	}
+	return &sb, strings.TrimSpace(head), nil
+}
+
+// fillSandbox puts the items of sb's branch store in the sandbox, excluded
+// from git like synced-in items, seeding the store first if it is new.
+func fillSandbox(sb *Config) error {
+	if err := initializeBranchStorage(sb); err != nil {
+		return err
+	}
+	restored, err := restoreItems(sb, sb.RepoRoot, "", "--sandbox")
+	if err != nil {
+		return fmt.Errorf("failed to put personal files in the sandbox: %w", err)
+	}
+	for _, rel := range restored {
+		if err := addToExclude(sb.RepoRoot, sb.excludeFile(), rel); err != nil {
+			return fmt.Errorf("failed to update exclude for %s: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// sandboxChanges lists the paths, relative to the repository root, of the
+// files in the sandbox's copies of the store's items that were added or
+// differ from the stored copies. Rendered templates and files removed in
+// the sandbox are left out.
+func sandboxChanges(sb *Config, store string) ([]string, error) {
+	items, err := listDir(store)
+	if err != nil {
+		return nil, err
+	}
+	items = filterItems(items)
+	tracked, err := trackedItems(sb, items)
+	if err != nil {
+		return nil, err
+	}
+	tombstones := readTombstones(store)
+
+	var changed []string
+	for _, item := range items {
+		if _, pending := tombstones[item]; tracked[item] || pending {
+			continue
+		}
+		root := filepath.Join(sb.RepoRoot, item)
+		if _, err := os.Lstat(root); err != nil {
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(sb.RepoRoot, path)
+			if err != nil {
+				return err
+			}
+			if sb.Settings.neverManaged(rel) {
+				return nil
+			}
+			stored := filepath.Join(store, rel)
+			if _, err := os.Stat(stored + templateSuffix); err == nil {
+				return nil
+			}
+			if !sameContents(path, stored) {
+				changed = append(changed, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// confirmSandboxPromotionFunc picks which of the files a sandboxed session
+// changed are promoted. Replaced in tests.
+var confirmSandboxPromotionFunc = confirmSandboxPromotion
+
+// confirmSandboxPromotion asks about each file when attached to a terminal.
+// assume_yes/--yes promotes them all; automation (no TTY) promotes none,
+// keeping the sandbox instead.
+func confirmSandboxPromotion(cfg, sb *Config, files []string) []string {
+	if cfg.Settings.AssumeYes {
+		return files
+	}
+	if !isInteractive() {
+		return nil
+	}
+	return promptSandboxPromotion(os.Stdin, os.Stderr, cfg, sb, files)
+}
+
+// promptSandboxPromotion shows how each file differs from its stored copy
+// and asks whether to promote it. Anything but an explicit yes leaves it.
+func promptSandboxPromotion(in io.Reader, out io.Writer, cfg, sb *Config, files []string) []string {
+	fmt.Fprintf(out, "claude-wrapper: the sandboxed session changed %d personal file(s).\n", len(files))
+	reader := bufio.NewReader(in)
+	var promote []string
+	for _, rel := range files {
+		stored, err := os.ReadFile(filepath.Join(cfg.StoreLocation, rel))
+		fromName := cfg.CurrentBranch + "/" + filepath.ToSlash(rel)
+		if err != nil {
+			fromName = "/dev/null"
+		}
+		changed, err := os.ReadFile(filepath.Join(sb.RepoRoot, rel))
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s", unifiedDiff(fromName, "sandbox/"+filepath.ToSlash(rel), stored, changed))
+		fmt.Fprintf(out, "Promote %s into %s's store? [y/N] ", rel, cfg.storeName())
+		answer, _ := reader.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			promote = append(promote, rel)
+		}
+	}
+	return promote
+}
+
+// promoteSandboxFiles copies files from the sandbox into cfg's branch store
+// and, if it holds the branch's personal files, the working tree, so the
+// next sync-out doesn't save the old copies over them.
+func promoteSandboxFiles(cfg, sb *Config, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	branch, profile := readSyncState(cfg.RepoRoot)
+	holdsBranch := branch == cfg.CurrentBranch && profile == cfg.Settings.Profile
+	copyTo := func(c *copier, src, dst string) error {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return c.copyFile(src, dst)
+	}
+	c := &copier{saved: make(map[string]manifestEntry)}
+	for _, rel := range files {
+		src := filepath.Join(sb.RepoRoot, rel)
+		if err := copyTo(c, src, filepath.Join(cfg.StoreLocation, rel)); err != nil {
+			return err
+		}
+		if holdsBranch {
+			if err := copyTo(&copier{}, src, filepath.Join(cfg.RepoRoot, rel)); err != nil {
+				return err
+			}
+		}
+	}
+	return updateManifest(cfg.StoreLocation, c.saved)
+}
+
+// sandboxKeepReason returns why the sandbox worktree at dir, started at
+// head, should be kept rather than removed: the session committed or left
+// changes to the repository there, or changed personal files that weren't
+// promoted for want of a terminal. It is "" if it can go.
+func sandboxKeepReason(dir, head string, unpromoted bool) string {
+	if now, err := gitOutput(dir, "rev-parse", "HEAD"); err == nil && strings.TrimSpace(now) != head {
+		return "the session made commits there"
+	}
+	if status, err := gitOutput(dir, "status", "--porcelain"); err == nil && strings.TrimSpace(status) != "" {
+		return "the session changed the repository there"
+	}
+	if unpromoted {
+		return "changed personal files were not promoted without a terminal"
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// givenSandboxRepo sets up a git repository with .env.local in its default
+// store, with temporary directories (and so sandboxes) under the test's.
+func givenSandboxRepo(t *testing.T) (dir, storeBase string) {
+	t.Helper()
+	if !gitBinaryAvailable() {
+		t.Skip("git not available")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	dir, _ = givenGitRepo(t)
+	home := inRepo(t, dir)
+	storeBase = filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, ".env.local"), "A=1\n")
+	return dir, storeBase
+}
+
+// promoteInSandbox replaces confirmSandboxPromotionFunc with one answering
+// with pick, recording the files it was asked about in asked.
+func promoteInSandbox(t *testing.T, asked *[]string, pick func([]string) []string) {
+	t.Helper()
+	orig := confirmSandboxPromotionFunc
+	confirmSandboxPromotionFunc = func(_, _ *Config, files []string) []string {
+		*asked = append(*asked, files...)
+		return pick(files)
+	}
+	t.Cleanup(func() { confirmSandboxPromotionFunc = orig })
+}
+
+func TestSandbox_PromotesPickedFilesAndRemovesTheWorktree(t *testing.T) {
+	dir, storeBase := givenSandboxRepo(t)
+	var asked []string
+	promoteInSandbox(t, &asked, func(files []string) []string { return files })
+
+	script := `test "$PWD" != "` + dir + `" && cat .env.local > ../seen.txt; echo B=2 >> .env.local`
+	code, err := runRunCommand(wrapperFlags{sandbox: true}, []string{"sh", "-c", script})
+	if err != nil || code != 0 {
+		t.Fatalf("run = %d, %v", code, err)
+	}
+	if len(asked) != 1 || asked[0] != ".env.local" {
+		t.Errorf("asked about %v, want .env.local", asked)
+	}
+	assertFileContent(t, filepath.Join(storeBase, ".env.local"), "A=1\nB=2\n")
+	assertNotExists(t, filepath.Join(dir, ".env.local"))
+	sandboxes, _ := filepath.Glob(filepath.Join(os.Getenv("TMPDIR"), sandboxPrefix+"*"))
+	if len(sandboxes) != 0 {
+		t.Errorf("sandboxes left behind: %v", sandboxes)
+	}
+}
+
+func TestSandbox_KeepsTheWorktreeWhenTheRepositoryChanged(t *testing.T) {
+	dir, storeBase := givenSandboxRepo(t)
+	var asked []string
+	promoteInSandbox(t, &asked, func([]string) []string { return nil })
+
+	if _, err := runRunCommand(wrapperFlags{sandbox: true}, []string{"sh", "-c", "echo B=2 >> .env.local; echo edited > README.md"}); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(storeBase, ".env.local"), "A=1\n")
+	assertFileContent(t, filepath.Join(dir, "README.md"), "readme")
+	sandboxes, _ := filepath.Glob(filepath.Join(os.Getenv("TMPDIR"), sandboxPrefix+"*"))
+	if len(sandboxes) != 1 {
+		t.Fatalf("sandboxes = %v, want the changed one kept", sandboxes)
+	}
+	assertFileContent(t, filepath.Join(sandboxes[0], "README.md"), "edited\n")
+}
@@ -0,0 +1,142 @@
+package wrapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// seedDecision applies seed_branch to rel, a path in the default branch's
+// store: whether it is seeded into new branch stores, and whether any
+// pattern matched it or a directory it is in. Later patterns win, and a
+// directory's decision applies to everything in it unless a pattern for
+// something inside says otherwise.
+func (s Settings) seedDecision(rel string) (seeded, matched bool) {
+	if s.SeedBranch == nil {
+		return true, false
+	}
+	// Patterns that only exclude seed everything else
+	seeded = true
+	for _, pattern := range s.SeedBranch {
+		if !strings.HasPrefix(pattern, "!") {
+			seeded = false
+			break
+		}
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		for _, pattern := range s.SeedBranch {
+			pattern, negated := strings.CutPrefix(pattern, "!")
+			if matchesPattern(pattern, prefix) {
+				seeded, matched = !negated, true
+			}
+		}
+	}
+	return seeded, matched
+}
+
+// inherits reports whether rel, or a directory it is in, is copied into new
+// branch stores as far as its items settings go: whether none with
+// inherit = false matches it.
+func (s Settings) inherits(rel string) bool {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for pattern, item := range s.Items {
+		if item.Inherit == nil || *item.Inherit {
+			continue
+		}
+		for i := range parts {
+			if matchesPattern(pattern, strings.Join(parts[:i+1], "/")) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// seeds reports whether rel, a path in the default branch's store, is
+// copied into new branch stores. A directory seed_branch doesn't decide
+// on counts as seeded, since files in it may be.
+func (s Settings) seeds(rel string, isDir bool) bool {
+	if !s.inherits(rel) {
+		return false
+	}
+	seeded, matched := s.seedDecision(rel)
+	return seeded || (isDir && !matched)
+}
+
+// seedSkipper returns a copier skip func for seeding from the store at
+// base, skipping what seeds rules out. Directories it doesn't rule out are
+// entered to look for files to seed.
+func (s Settings) seedSkipper(base string) func(src string) bool {
+	return func(src string) bool {
+		rel, err := filepath.Rel(base, src)
+		if err != nil {
+			return false
+		}
+		info, err := os.Stat(src)
+		return !s.seeds(rel, err == nil && info.IsDir())
+	}
+}
+
+// pickSeedItemsFunc picks which of items, in the default branch's store, a
+// new branch store is seeded with. Replaced in tests.
+var pickSeedItemsFunc = pickSeedItems
+
+// pickSeedItems asks which items to seed with when seed_prompt is set and
+// attached to a terminal, and otherwise returns them all.
+func pickSeedItems(cfg *Config, items []string) []string {
+	if !cfg.Settings.SeedPrompt || cfg.Settings.AssumeYes || !isInteractive() || len(items) == 0 {
+		return items
+	}
+	return promptSeed(os.Stdin, os.Stderr, cfg, items)
+}
+
+// promptSeed lists items in the default branch's store with their sizes and
+// asks which to seed cfg's new branch store with. An empty answer seeds
+// them all, "none" nothing.
+func promptSeed(in io.Reader, out io.Writer, cfg *Config, items []string) []string {
+	fmt.Fprintf(out, "claude-wrapper: branch %q has no personal files yet. The default branch has:\n", cfg.CurrentBranch)
+	for i, item := range items {
+		fmt.Fprintf(out, "  %2d) %-30s %10s\n", i+1, item, formatBytes(itemSize(cfg.StoreBase, item, cfg.Settings)))
+	}
+	fmt.Fprint(out, "Copy which? (e.g. 1 3-4, or none; empty for all) ")
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return items
+	case "none":
+		return nil
+	}
+	picked, ok := parseSelection(answer, len(items))
+	if !ok {
+		fmt.Fprintln(out, "Not a selection; copying everything.")
+		return items
+	}
+	var chosen []string
+	for _, i := range picked {
+		chosen = append(chosen, items[i])
+	}
+	return chosen
+}
+
+// removeEmptyDirs removes the directories under root, and root itself,
+// that hold no files, like those a seed left with nothing in them.
+func removeEmptyDirs(root string) {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	// Deepest first, so emptied parents go too
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i]) // Fails, harmlessly, unless empty
+	}
+}
@@ -0,0 +1,122 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeedDecision(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		seeded   []string
+		skipped  []string
+	}{
+		{nil, []string{"CLAUDE.md", ".claude/cache/big.bin"}, nil},
+		{[]string{"CLAUDE.md", ".claude/"}, []string{"CLAUDE.md", ".claude/settings.json"}, []string{"notes.md"}},
+		{[]string{".claude/", "!.claude/cache/"}, []string{".claude/settings.json"}, []string{".claude/cache/big.bin", "CLAUDE.md"}},
+		{[]string{"!cache/"}, []string{"CLAUDE.md", ".claude/settings.json"}, []string{".claude/cache/big.bin"}},
+		{[]string{"*.md"}, []string{"CLAUDE.md", "prompts/review.md"}, []string{".claude/settings.json"}},
+	}
+	for _, tt := range tests {
+		s := Settings{SeedBranch: tt.patterns}
+		for _, rel := range tt.seeded {
+			if seeded, _ := s.seedDecision(rel); !seeded {
+				t.Errorf("%q: expected %s seeded", tt.patterns, rel)
+			}
+		}
+		for _, rel := range tt.skipped {
+			if seeded, _ := s.seedDecision(rel); seeded {
+				t.Errorf("%q: expected %s skipped", tt.patterns, rel)
+			}
+		}
+	}
+}
+
+func TestInitializeBranchStorage_SeedBranch(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, storeBase := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	cfg.Settings.SeedBranch = []string{"CLAUDE.md", ".claude/", "!.claude/cache/"}
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "config")
+	writeFile(t, filepath.Join(storeBase, "notes.md"), "notes")
+	writeFile(t, filepath.Join(storeBase, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(storeBase, ".claude", "cache", "big.bin"), "cache")
+	writeFile(t, filepath.Join(storeBase, "prompts", "review.md"), "review")
+
+	if err := initializeBranchStorage(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "config")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "{}")
+	for _, skipped := range []string{"notes.md", filepath.Join(".claude", "cache"), "prompts"} {
+		assertNotExists(t, filepath.Join(cfg.StoreLocation, skipped))
+	}
+}
+
+func TestInitializeBranchStorage_PickedItems(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, storeBase := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "config")
+	writeFile(t, filepath.Join(storeBase, "notes.md"), "notes")
+
+	orig := pickSeedItemsFunc
+	var offered []string
+	pickSeedItemsFunc = func(_ *Config, items []string) []string {
+		offered = items
+		return []string{"CLAUDE.md"}
+	}
+	t.Cleanup(func() { pickSeedItemsFunc = orig })
+
+	if err := initializeBranchStorage(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(offered, []string{"CLAUDE.md", "notes.md"}) {
+		t.Errorf("offered %q, want [CLAUDE.md notes.md]", offered)
+	}
+	assertExists(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"))
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "notes.md"))
+}
+
+func TestPromptSeed(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	items := []string{".claude", "CLAUDE.md", "notes.md"}
+
+	tests := []struct {
+		answer string
+		want   []string
+	}{
+		{"\n", items},
+		{"none\n", nil},
+		{"2-3\n", []string{"CLAUDE.md", "notes.md"}},
+		{"9\n", items},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := promptSeed(strings.NewReader(tt.answer), &out, cfg, items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("answer %q: got %q, want %q", tt.answer, got, tt.want)
+		}
+		if !strings.Contains(out.String(), `branch "feature"`) || !strings.Contains(out.String(), " 2) CLAUDE.md") {
+			t.Errorf("unexpected prompt:\n%s", out.String())
+		}
+	}
+}
+
+func TestInitializeBranchStorage_NoInherit(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, storeBase := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	noInherit := false
+	cfg.Settings.Items = map[string]ItemSettings{".claude/cache": {Inherit: &noInherit}, "*.log": {Inherit: &noInherit}}
+	writeFile(t, filepath.Join(storeBase, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(storeBase, ".claude", "cache", "big.bin"), "cache")
+	writeFile(t, filepath.Join(storeBase, "session.log"), "log")
+
+	if err := initializeBranchStorage(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "{}")
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, ".claude", "cache"))
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "session.log"))
+}
@@ -0,0 +1,123 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sessionPrefix names the temporary directories holding the worktrees of
+// `session new`.
+const sessionPrefix = "claude-wrapper-session-"
+
+// runSessionCommand implements `claude-wrapper session new BRANCH`.
+func runSessionCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("session")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	args = fs.Args()
+	if len(args) < 2 || args[0] != "new" {
+		return usageError("session")
+	}
+	branch, claudeArgs := args[1], args[2:]
+	if len(claudeArgs) > 0 && claudeArgs[0] == "--" {
+		claudeArgs = claudeArgs[1:]
+	}
+	if flags.sandbox {
+		return exitUsage, fmt.Errorf("--sandbox can't be combined with session new")
+	}
+	if !gitBinaryAvailable() {
+		return 1, fmt.Errorf("session new needs the git command to create a worktree")
+	}
+
+	// The session is pinned to the branch's store, as with --branch, and
+	// so are git hooks run during it
+	flags.branch = branch
+	os.Setenv(branchEnv, branch)
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	return runWorktreeSession(cfg, func(wt *Config) int { return runClaudeSession(wt, claudeArgs) })
+}
+
+// runWorktreeSession runs a session for cfg's branch in a worktree of its
+// own, created for it and removed afterwards, so sessions on different
+// branches can run side by side. The worktree is kept if the session left
+// changes to the repository there that sync-out doesn't save.
+func runWorktreeSession(cfg *Config, launch func(wt *Config) int) (int, error) {
+	dir, err := createSessionWorktree(cfg)
+	if err != nil {
+		return 1, err
+	}
+	infof("session for %s in %s", cfg.CurrentBranch, dir)
+
+	wt := *cfg
+	wt.RepoRoot = dir
+	wd, err := enterWorktree(cfg.RepoRoot, dir)
+	if err != nil {
+		return 1, err
+	}
+	exitCode, err := runSession(&wt, func() int { return launch(&wt) })
+	if err := os.Chdir(wd); err != nil {
+		warnf("failed to return to %s: %v", wd, err)
+	}
+	if err != nil {
+		warnf("keeping the worktree at %s, whose personal files weren't saved", dir)
+		return exitCode, err
+	}
+
+	if status, err := gitOutput(dir, "status", "--porcelain"); err != nil || strings.TrimSpace(status) != "" {
+		warnf("keeping the worktree at %s: the session left uncommitted changes there; remove it with `git worktree remove --force %s`", dir, dir)
+		return exitCode, nil
+	}
+	if _, err := gitOutput(cfg.RepoRoot, "worktree", "remove", "--force", dir); err != nil {
+		warnf("failed to remove the worktree at %s: %v", dir, err)
+		return exitCode, nil
+	}
+	os.Remove(filepath.Dir(dir))
+	return exitCode, nil
+}
+
+// createSessionWorktree adds a worktree of cfg's repository with its branch
+// checked out, creating the branch at HEAD if it doesn't exist. The worktree
+// is named like the repository, so wrapper runs inside it, such as git
+// hooks, find the repository's store.
+func createSessionWorktree(cfg *Config) (string, error) {
+	parent, err := os.MkdirTemp("", sessionPrefix)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(parent, filepath.Base(cfg.RepoRoot))
+	add := []string{"worktree", "add", dir, cfg.CurrentBranch}
+	if _, err := gitOutput(cfg.RepoRoot, "rev-parse", "--verify", "--quiet", "refs/heads/"+cfg.CurrentBranch); err != nil {
+		add = []string{"worktree", "add", "-b", cfg.CurrentBranch, dir}
+	}
+	if _, err := gitOutput(cfg.RepoRoot, add...); err != nil {
+		os.RemoveAll(parent)
+		return "", fmt.Errorf("failed to create a worktree for %s: %w", cfg.CurrentBranch, err)
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	return dir, nil
+}
+
+// enterWorktree changes to the directory of the worktree at dir matching
+// the current one in the repository at repoRoot, or to dir itself if there
+// is none, and returns the directory it left.
+func enterWorktree(repoRoot, dir string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	target := dir
+	if rel, err := filepath.Rel(repoRoot, wd); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		if info, err := os.Stat(filepath.Join(dir, rel)); err == nil && info.IsDir() {
+			target = filepath.Join(dir, rel)
+		}
+	}
+	return wd, os.Chdir(target)
+}
@@ -0,0 +1,74 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorktreeSession_RunsOnTheBranchAndRemovesTheWorktree(t *testing.T) {
+	dir, storeBase := givenSandboxRepo(t)
+	cfg, err := openRepo(wrapperFlags{branch: "feature"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sessionDir, branch string
+	code, err := runWorktreeSession(cfg, func(wt *Config) int {
+		sessionDir, _ = os.Getwd()
+		out, _ := gitOutput(sessionDir, "rev-parse", "--abbrev-ref", "HEAD")
+		branch = strings.TrimSpace(out)
+		f, err := os.OpenFile(".env.local", os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Error(err)
+			return 1
+		}
+		defer f.Close()
+		f.WriteString("B=2\n")
+		return 0
+	})
+	if err != nil || code != 0 {
+		t.Fatalf("session = %d, %v", code, err)
+	}
+	if sessionDir == dir || filepath.Base(sessionDir) != filepath.Base(dir) {
+		t.Errorf("session ran in %s, want a worktree named like %s", sessionDir, dir)
+	}
+	if branch != "feature" {
+		t.Errorf("worktree on %q, want feature", branch)
+	}
+	assertFileContent(t, filepath.Join(branchStoreDir(storeBase, "feature"), ".env.local"), "A=1\nB=2\n")
+	assertFileContent(t, filepath.Join(storeBase, ".env.local"), "A=1\n")
+	assertNotExists(t, filepath.Join(dir, ".env.local"))
+	assertNotExists(t, filepath.Dir(sessionDir))
+	if _, err := gitOutput(dir, "rev-parse", "--verify", "refs/heads/feature"); err != nil {
+		t.Errorf("branch feature not created: %v", err)
+	}
+}
+
+func TestWorktreeSession_KeepsAWorktreeWithUncommittedChanges(t *testing.T) {
+	dir, _ := givenSandboxRepo(t)
+	cfg, err := openRepo(wrapperFlags{branch: "feature"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sessionDir string
+	if _, err := runWorktreeSession(cfg, func(*Config) int {
+		sessionDir, _ = os.Getwd()
+		os.WriteFile("README.md", []byte("edited\n"), 0644)
+		return 0
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(sessionDir, "README.md"), "edited\n")
+	assertFileContent(t, filepath.Join(dir, "README.md"), "readme")
+}
+
+func TestSessionCommand_RequiresNewAndABranch(t *testing.T) {
+	for _, args := range [][]string{nil, {"new"}, {"old", "feature"}} {
+		if code, _ := runSessionCommand(wrapperFlags{}, args); code != exitUsage {
+			t.Errorf("session %q = %d, want %d", args, code, exitUsage)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runningDir holds the registry of running sessions in a store base, a
+// file per session: <store base>/.running/<host>-<pid>.json. Entries from
+// before hosts were recorded are named <pid>.json, and are still read.
+const runningDir = ".running"
+
+// runningSession is a registry entry: a wrapper session using a branch
+// store.
+type runningSession struct {
+	PID       int       `json:"pid"`
+	Repo      string    `json:"repo"`
+	Branch    string    `json:"branch"`
+	StartedAt time.Time `json:"started_at"`
+	// BootID identifies the boot the session started in (see bootID), so
+	// an entry surviving a reboot isn't taken for a new process's.
+	BootID string `json:"boot_id,omitempty"`
+	// Host is the machine the session runs on. A store base on a shared
+	// drive can hold entries of other machines' sessions, whose PIDs and
+	// boot IDs mean nothing here.
+	Host string `json:"host,omitempty"`
+}
+
+// localHost returns this machine's hostname, or "" if it can't be had.
+func localHost() string {
+	host, err := hostnameFunc()
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// fileName returns the name of the session's registry file. The host keeps
+// machines sharing a store base from writing over each other's entries of
+// sessions with the same PID.
+func (s runningSession) fileName() string {
+	if s.Host == "" {
+		return strconv.Itoa(s.PID) + ".json"
+	}
+	return s.Host + "-" + strconv.Itoa(s.PID) + ".json"
+}
+
+// remote reports whether the session runs on a machine other than host,
+// this one. Entries written before hosts were recorded count as local.
+func (s runningSession) remote(host string) bool {
+	return s.Host != "" && s.Host != host
+}
+
+// registerSession records the session about to run for cfg in its store
+// base's registry, returning a func that removes the entry again.
+func registerSession(cfg *Config, now time.Time) func() {
+	session := runningSession{PID: os.Getpid(), Repo: cfg.RepoRoot, Branch: cfg.CurrentBranch, StartedAt: now.UTC(), BootID: bootID(), Host: localHost()}
+	dir := filepath.Join(cfg.StoreBase, runningDir)
+	path := filepath.Join(dir, session.fileName())
+	data, err := json.Marshal(session)
+	if err == nil {
+		err = os.MkdirAll(dir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		warnf("failed to register the session: %v", err)
+		return func() {}
+	}
+	return func() { os.Remove(path) }
+}
+
+// registeredSession is a registry entry and the file holding it.
+type registeredSession struct {
+	runningSession
+	path string
+}
+
+// readSessions returns the sessions registered in storeBase, oldest first.
+func readSessions(storeBase string) []registeredSession {
+	dir := filepath.Join(storeBase, runningDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var sessions []registeredSession
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		session := registeredSession{path: filepath.Join(dir, entry.Name())}
+		data, err := os.ReadFile(session.path)
+		if err != nil || json.Unmarshal(data, &session.runningSession) != nil {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartedAt.Before(sessions[j].StartedAt) })
+	return sessions
+}
+
+// staleReason returns why the session's entry is stale: it started before
+// the machine last booted, or its process is gone. It is "" for a session
+// still running.
+func (s runningSession) staleReason(currentBoot string) string {
+	if s.BootID != "" && currentBoot != "" && s.BootID != currentBoot {
+		return "started before the last reboot"
+	}
+	if !processAlive(s.PID) {
+		return fmt.Sprintf("process %d is gone", s.PID)
+	}
+	return ""
+}
+
+// liveSessions returns the sessions registered in storeBase that are still
+// running, oldest first. Stale entries, left by sessions that crashed or
+// were running when the machine went down, are removed. Other machines'
+// sessions can't be checked from here, so they all count as running.
+func liveSessions(storeBase string) []runningSession {
+	boot, host := bootID(), localHost()
+	var sessions []runningSession
+	for _, session := range readSessions(storeBase) {
+		if session.remote(host) {
+			sessions = append(sessions, session.runningSession)
+			continue
+		}
+		if reason := session.staleReason(boot); reason != "" {
+			if err := os.Remove(session.path); err == nil {
+				infof("removed the stale entry of the session on %s: %s", session.Branch, reason)
+			}
+			continue
+		}
+		sessions = append(sessions, session.runningSession)
+	}
+	return sessions
+}
+
+// sessionsByBranch returns the PID of a live session in storeBase for each
+// branch one is using.
+func sessionsByBranch(storeBase string) map[string]int {
+	using := make(map[string]int)
+	for _, session := range liveSessions(storeBase) {
+		using[session.Branch] = session.PID
+	}
+	return using
+}
+
+// runSessionsCommand implements `claude-wrapper sessions [--all]`.
+func runSessionsCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("sessions")
+	all := fs.Bool("all", false, "list sessions of every repository, not just the current one")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage, nil
+	}
+
+	var storeBases []string
+	if *all {
+		settings, err := LoadSettings()
+		if err != nil {
+			return 1, withExitCode(exitConfig, fmt.Errorf("failed to load settings: %w", err))
+		}
+		flags.apply(&settings)
+		root, err := settings.storeRoot()
+		if err != nil {
+			return 1, err
+		}
+		stores, err := collectRepoStores(root)
+		if err != nil {
+			return 1, err
+		}
+		for _, store := range stores {
+			storeBases = append(storeBases, store.store)
+		}
+	} else {
+		cfg, err := openRepo(flags)
+		if err != nil {
+			return 1, err
+		}
+		storeBases = []string{cfg.StoreBase}
+	}
+
+	var sessions []runningSession
+	for _, storeBase := range storeBases {
+		sessions = append(sessions, liveSessions(storeBase)...)
+	}
+	if err := printSessions(sessions, os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// printSessions lists running sessions.
+func printSessions(sessions []runningSession, w io.Writer) error {
+	if len(sessions) == 0 {
+		fmt.Fprintln(w, "no running sessions")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PID\tHOST\tBRANCH\tSTARTED\tREPO")
+	for _, session := range sessions {
+		host := session.Host
+		if host == "" {
+			host = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", session.PID, host, session.Branch, formatTime(session.StartedAt), session.Repo)
+	}
+	return tw.Flush()
+}
+
+// runUnlockCommand implements `claude-wrapper unlock [--force] [BRANCH...]`.
+// It removes stale session entries of the current repository, and with
+// --force those of sessions still running too, for when one blocks prune
+// or cleanup and can't be ended.
+func runUnlockCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("unlock")
+	force := fs.Bool("force", false, "also remove the entries of sessions that still seem to be running")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	if err := unlockSessions(cfg.StoreBase, fs.Args(), *force, os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// unlockSessions removes the entries of sessions in storeBase that are
+// stale, or all of them if force is set, reporting each to w. With branches
+// only the entries of sessions on those branches are touched. Entries of
+// other machines' sessions are left alone, even with force: only the
+// machine a session runs on can tell whether it has ended.
+func unlockSessions(storeBase string, branches []string, force bool, w io.Writer) error {
+	selected := make(map[string]bool)
+	for _, branch := range branches {
+		selected[branch] = true
+	}
+	boot, host := bootID(), localHost()
+	var removed, live int
+	for _, session := range readSessions(storeBase) {
+		if len(selected) > 0 && !selected[session.Branch] {
+			continue
+		}
+		if session.remote(host) {
+			fmt.Fprintf(w, "session %d on %s runs on %s; run unlock there to remove its entry\n", session.PID, session.Branch, session.Host)
+			live++
+			continue
+		}
+		reason := session.staleReason(boot)
+		if reason == "" {
+			if !force {
+				fmt.Fprintf(w, "session %d on %s is still running; pass --force to remove its entry anyway\n", session.PID, session.Branch)
+				live++
+				continue
+			}
+			reason = "removed with --force"
+		}
+		if err := os.Remove(session.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove the entry of session %d: %w", session.PID, err)
+		}
+		fmt.Fprintf(w, "%s session %d on %s (%s)\n", colorize(w, toneRemoved, "removed"), session.PID, session.Branch, reason)
+		removed++
+	}
+	if removed == 0 && live == 0 {
+		fmt.Fprintln(w, "no session entries to remove")
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// givenRunningSession writes a registry entry for a session of pid on branch.
+func givenRunningSession(t *testing.T, storeBase string, pid int, branch string) string {
+	t.Helper()
+	return givenSessionEntry(t, storeBase, runningSession{PID: pid, Repo: "/repo", Branch: branch, StartedAt: time.Now(), BootID: bootID(), Host: localHost()})
+}
+
+// givenSessionEntry writes session to storeBase's registry.
+func givenSessionEntry(t *testing.T, storeBase string, session runningSession) string {
+	t.Helper()
+	data, err := json.Marshal(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(storeBase, runningDir, session.fileName())
+	writeFile(t, path, string(data))
+	return path
+}
+
+// deadPID returns the PID of a process that has exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("can't run true: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestRegisterSession_ListsTheSessionUntilItEnds(t *testing.T) {
+	cfg := &Config{RepoRoot: "/repo", CurrentBranch: "feature", StoreBase: t.TempDir()}
+	unregister := registerSession(cfg, time.Now())
+
+	sessions := liveSessions(cfg.StoreBase)
+	if len(sessions) != 1 || sessions[0].PID != os.Getpid() || sessions[0].Branch != "feature" {
+		t.Fatalf("sessions = %+v, want this one on feature", sessions)
+	}
+	unregister()
+	if sessions := liveSessions(cfg.StoreBase); len(sessions) != 0 {
+		t.Errorf("sessions after unregistering = %+v", sessions)
+	}
+}
+
+func TestLiveSessions_KeepsTheRunningProcess(t *testing.T) {
+	storeBase := t.TempDir()
+	givenRunningSession(t, storeBase, os.Getpid(), "main")
+	// The parent, go test, runs too, and isn't short-circuited as this one is
+	givenRunningSession(t, storeBase, os.Getppid(), "feature")
+
+	if using := sessionsByBranch(storeBase); using["main"] != os.Getpid() || using["feature"] != os.Getppid() {
+		t.Errorf("sessions by branch = %v, want both running processes", using)
+	}
+}
+
+func TestLiveSessions_DropsEntriesOfDeadProcesses(t *testing.T) {
+	storeBase := t.TempDir()
+	stale := givenRunningSession(t, storeBase, deadPID(t), "gone")
+	givenRunningSession(t, storeBase, os.Getpid(), "main")
+
+	if using := sessionsByBranch(storeBase); len(using) != 1 || using["main"] != os.Getpid() {
+		t.Errorf("sessions by branch = %v, want only main", using)
+	}
+	assertNotExists(t, stale)
+}
+
+func TestLiveSessions_DropsEntriesFromAnEarlierBoot(t *testing.T) {
+	if bootID() == "" {
+		t.Skip("boot ID unknown on this platform")
+	}
+	storeBase := t.TempDir()
+	// This process is running, but the entry's PID belongs to another boot
+	stale := givenSessionEntry(t, storeBase, runningSession{PID: os.Getpid(), Branch: "main", BootID: "an-earlier-boot"})
+
+	if sessions := liveSessions(storeBase); len(sessions) != 0 {
+		t.Errorf("sessions = %+v, want none", sessions)
+	}
+	assertNotExists(t, stale)
+}
+
+func TestUnlockSessions_ForceRemovesLiveEntries(t *testing.T) {
+	storeBase := t.TempDir()
+	stale := givenRunningSession(t, storeBase, deadPID(t), "gone")
+	live := givenRunningSession(t, storeBase, os.Getpid(), "busy")
+
+	var out bytes.Buffer
+	if err := unlockSessions(storeBase, nil, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, stale)
+	assertExists(t, live)
+	if !strings.Contains(out.String(), "pass --force") {
+		t.Errorf("output = %q", out.String())
+	}
+
+	out.Reset()
+	if err := unlockSessions(storeBase, []string{"other"}, true, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, live)
+	if err := unlockSessions(storeBase, []string{"busy"}, true, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, live)
+}
+
+func TestRegisterSession_KeepsOtherHostsEntryOfTheSamePID(t *testing.T) {
+	cfg := &Config{RepoRoot: "/repo", CurrentBranch: "feature", StoreBase: t.TempDir()}
+	remote := givenSessionEntry(t, cfg.StoreBase, runningSession{PID: os.Getpid(), Branch: "main", Host: localHost() + "-elsewhere"})
+	// An entry from before hosts were recorded is still read
+	legacy := filepath.Join(cfg.StoreBase, runningDir, strconv.Itoa(deadPID(t))+".json")
+	writeFile(t, legacy, `{"pid": 1, "branch": "old"}`)
+
+	unregister := registerSession(cfg, time.Now())
+	assertExists(t, filepath.Join(cfg.StoreBase, runningDir, localHost()+"-"+strconv.Itoa(os.Getpid())+".json"))
+	if sessions := readSessions(cfg.StoreBase); len(sessions) != 3 {
+		t.Errorf("sessions = %+v, want the remote, legacy and registered ones", sessions)
+	}
+	unregister()
+	assertExists(t, remote)
+}
+
+func TestLiveSessions_KeepsEntriesOfOtherHosts(t *testing.T) {
+	storeBase := t.TempDir()
+	// A dead PID from an earlier boot would be stale on this machine
+	remote := givenSessionEntry(t, storeBase, runningSession{PID: deadPID(t), Branch: "feature", BootID: "another-boot", Host: "elsewhere"})
+
+	sessions := liveSessions(storeBase)
+	if len(sessions) != 1 || sessions[0].Host != "elsewhere" {
+		t.Errorf("sessions = %+v, want the one on elsewhere", sessions)
+	}
+	assertExists(t, remote)
+}
+
+func TestUnlockSessions_LeavesEntriesOfOtherHosts(t *testing.T) {
+	storeBase := t.TempDir()
+	remote := givenSessionEntry(t, storeBase, runningSession{PID: deadPID(t), Branch: "feature", Host: "elsewhere"})
+
+	var out bytes.Buffer
+	if err := unlockSessions(storeBase, nil, true, &out); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, remote)
+	if !strings.Contains(out.String(), "runs on elsewhere") {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestPruneBranchStores_RefusesBranchesInUse(t *testing.T) {
+	storeBase := t.TempDir()
+	cfg := &Config{StoreBase: storeBase, CurrentBranch: "main", DefaultBranch: "main"}
+	for _, branch := range []string{"busy", "idle"} {
+		writeFile(t, filepath.Join(branchStoreDir(storeBase, branch), "notes.md"), branch)
+	}
+	givenRunningSession(t, storeBase, os.Getpid(), "busy")
+
+	var out bytes.Buffer
+	err := pruneBranchStores(cfg, map[string]string{"busy": "test", "idle": "test"}, false, &out)
+	if exitCodeFor(err) != exitLocked {
+		t.Errorf("err = %v, want exit code %d", err, exitLocked)
+	}
+	assertExists(t, branchStoreDir(storeBase, "busy"))
+	assertNotExists(t, branchStoreDir(storeBase, "idle"))
+	if !strings.Contains(out.String(), "in use by the session with PID") {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestPrintSessions(t *testing.T) {
+	var out bytes.Buffer
+	printSessions(nil, &out)
+	if out.String() != "no running sessions\n" {
+		t.Errorf("empty listing = %q", out.String())
+	}
+	out.Reset()
+	printSessions([]runningSession{{PID: 42, Repo: "/repo", Branch: "feature", Host: "laptop"}}, &out)
+	if !strings.Contains(out.String(), "42") || !strings.Contains(out.String(), "feature") || !strings.Contains(out.String(), "laptop") {
+		t.Errorf("listing = %q", out.String())
+	}
+}
@@ -0,0 +1,437 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Settings holds user preferences read from the wrapper's settings file.
+// The zero value is valid and gives the wrapper's default behavior.
+type Settings struct {
+	// Report prints a sync summary to stderr after each run: "text" or "json".
+	Report string `toml:"report"`
+	// ReportFile appends a JSON sync summary per run to the given file.
+	ReportFile string `toml:"report_file"`
+	// GitBackend selects how git is queried: "auto" (go-git, falling back to
+	// the git CLI), "go-git" or "cli".
+	GitBackend string `toml:"git_backend"`
+	// DefaultBranchRemotes lists the remotes whose HEAD decides the default
+	// branch, in order of preference (default origin, then upstream). Other
+	// remotes are tried by name after these.
+	DefaultBranchRemotes []string `toml:"default_branch_remotes"`
+	// SparseCheckout decides what sync-in does in a cone-mode sparse
+	// checkout with directories outside the cone: "skip" (the default)
+	// leaves them in storage, "add" adds them to the cone with
+	// `git sparse-checkout add`, and "ignore" syncs them in regardless.
+	SparseCheckout string `toml:"sparse_checkout"`
+	// VCS selects the version control system: "auto" (the default) uses jj
+	// for Jujutsu repositories colocated with git and git otherwise, "git"
+	// or "jj" force one. jj is only used when it is on PATH.
+	VCS string `toml:"vcs"`
+	// AssumeYes answers yes to confirmation prompts, e.g. before cleanup
+	// deletes an expired branch store.
+	AssumeYes bool `toml:"assume_yes"`
+	// CleanupPolicy decides what happens to expired branch stores: "delete"
+	// (the default) or "archive" to keep a tar.gz under <store>/archive/.
+	CleanupPolicy string `toml:"cleanup_policy"`
+	// ArchiveRetentionDays is how long archives are kept (default 90,
+	// negative keeps them forever).
+	ArchiveRetentionDays int `toml:"archive_retention_days"`
+	// CleanupIntervalHours is how often the cleanup after a session checks
+	// branch stores against git and removes expired ones (default 6,
+	// negative checks after every session).
+	CleanupIntervalHours int `toml:"cleanup_interval_hours"`
+	// PromoteMerged decides what cleanup does with the diverged files of a
+	// branch merged into the default branch once the branch is deleted:
+	// "off" (the default) leaves them, "ask" offers to copy them into the
+	// default branch's store and "always" does so without asking.
+	PromoteMerged string `toml:"promote_merged"`
+	// MergeExtensions lists the extensions (like ".md") of text files that
+	// are merged three ways, against the content last synced, when both the
+	// working tree and the store changed them, instead of copied over.
+	MergeExtensions []string `toml:"merge_extensions"`
+	// RebaseStores makes sync-in on a branch first bring the files of its
+	// store that the branch never changed up to date with the default
+	// branch's copies, as `claude-wrapper rebase-stores` does.
+	RebaseStores bool `toml:"rebase_stores"`
+	// FreshSyncSeconds skips sync-in when the last session on the branch
+	// synced out less than this many seconds ago and the store's manifest
+	// hasn't changed since (default 0, always sync in).
+	FreshSyncSeconds int `toml:"fresh_sync_seconds"`
+	// ExpireUnusedDays expires the stores of branches that still exist but
+	// haven't been synced for this many days, after a warning period as long
+	// as the grace period (default 0, never).
+	ExpireUnusedDays int `toml:"expire_unused_days"`
+	// MergedGraceDays and UnmergedGraceDays replace the 7-day grace period
+	// of the stores of branches deleted from git after being merged into
+	// the default branch (default 2), or with commits it doesn't have
+	// (default 30).
+	MergedGraceDays   int `toml:"merged_grace_days"`
+	UnmergedGraceDays int `toml:"unmerged_grace_days"`
+	// Templates configures the template sets under ~/.workspaces/_templates/,
+	// keyed by set name. Sets without an entry apply to every repository.
+	Templates map[string]TemplateSet `toml:"templates"`
+	// MachineScoped lists paths (relative to the repository root, glob
+	// patterns allowed) stored per host under <store>/machines/<host>/
+	// instead of being shared between machines.
+	MachineScoped []string `toml:"machine_scoped"`
+	// NeverManage lists paths never copied to or from storage, even when
+	// listed in the exclude file (gitignore-like glob patterns). Unset means
+	// common dependency and build directories; [] disables the defaults.
+	NeverManage []string `toml:"never_manage"`
+	// SeedBranch limits what a new branch store is seeded with from the
+	// default branch's store to paths matching these patterns (like
+	// never_manage's; "!" excludes what earlier patterns include). Unset
+	// seeds everything.
+	SeedBranch []string `toml:"seed_branch"`
+	// SeedPrompt asks which items to seed a new branch store with when
+	// attached to a terminal.
+	SeedPrompt bool `toml:"seed_prompt"`
+	// Items configures individual paths, keyed by path relative to the
+	// repository root (patterns like never_manage's allowed).
+	Items map[string]ItemSettings `toml:"items"`
+	// MaxItemSizeMB is the largest item sync-out copies to storage (default
+	// 100, negative for no limit). Larger items are skipped with a warning.
+	MaxItemSizeMB int `toml:"max_item_size_mb"`
+	// Quiet suppresses progress output during long syncs.
+	Quiet bool `toml:"quiet"`
+	// ProfileSync prints how long each phase of a sync took, and the
+	// slowest items, after every run.
+	ProfileSync bool `toml:"profile_sync"`
+	// DisabledRepos turns the wrapper off for matching repositories, which
+	// then pass straight through to claude. Patterns with a slash match the
+	// repository path, others its directory name.
+	DisabledRepos []string `toml:"disabled_repos"`
+	// EnabledRepos, when set, turns the wrapper on only for matching
+	// repositories (same patterns as DisabledRepos).
+	EnabledRepos []string `toml:"enabled_repos"`
+	// SessionNotes names a per-branch scratch file (relative to the
+	// repository root, e.g. "SESSION_NOTES.md") the wrapper creates at
+	// sync-in if missing, excludes from git and saves at sync-out. Unset
+	// means no notes file.
+	SessionNotes string `toml:"session_notes"`
+	// Transcripts records the output of every claude session to
+	// <store>/sessions/<timestamp>.log in the branch store.
+	Transcripts bool `toml:"transcripts"`
+	// TranscriptMaxMB is the size at which a transcript is rotated, keeping
+	// the previous part as <timestamp>.log.1 (default 10, negative for no
+	// limit).
+	TranscriptMaxMB int `toml:"transcript_max_mb"`
+	// ClaudeProjectState snapshots Claude Code's own state for the
+	// repository (~/.claude/projects/<path>) into the branch store at
+	// sync-out and restores it at sync-in, so conversations are per branch.
+	ClaudeProjectState bool `toml:"claude_project_state"`
+	// Ports names base port numbers for rendered templates
+	// ({{.Ports.name}}). Branches other than the default get the base plus
+	// a stable offset from 1 to 99 derived from the branch name.
+	Ports map[string]int `toml:"ports"`
+	// VerifyCopies reads every file back after copying it and checks its
+	// SHA-256 against the original, retrying copies that don't match.
+	VerifyCopies bool `toml:"verify_copies"`
+	// PreserveOwnership gives copies the owner, group and POSIX ACLs of
+	// their originals (Linux only), e.g. for files owned by a service user
+	// on a shared server. Changing owners needs root or CAP_CHOWN; without
+	// them the wrapper warns and carries on.
+	PreserveOwnership bool `toml:"preserve_ownership"`
+	// OfflineStore is where personal files are kept while the store (e.g.
+	// ~/.workspaces on a network mount) is unreachable, until it returns.
+	// Unset means claude-wrapper/offline in the user's cache directory.
+	OfflineStore string `toml:"offline_store"`
+	// ExcludeFile is the ignore file the wrapper writes its entries to and
+	// reads them back from, relative to the repository root unless
+	// absolute, for repositories whose info/exclude belongs to other
+	// tooling. git must be told to read it, e.g. through core.excludesFile.
+	// Unset means the info/exclude file git reads for the working tree.
+	ExcludeFile string `toml:"exclude_file"`
+	// Profile selects one of Profiles, or an implicit profile stored under
+	// ~/.workspaces-<name>; --profile and CLAUDE_WRAPPER_PROFILE override it.
+	// Unset means the default store under ~/.workspaces.
+	Profile string `toml:"profile"`
+	// Profiles configures named profiles, each an independent set of
+	// personal files for every repository.
+	Profiles map[string]Profile `toml:"profiles"`
+	// SyncInCI keeps the wrapper syncing when the CI environment variable
+	// is set; by default CI runs pass straight through to claude.
+	SyncInCI bool `toml:"sync_in_ci"`
+	// LogPassthrough logs a line to stderr whenever the wrapper passes
+	// straight through to claude without syncing, and why.
+	LogPassthrough bool `toml:"log_passthrough"`
+	// Notify decides how failed background syncs (daemon requests and git
+	// hooks) are surfaced: "desktop" (the default) shows a desktop
+	// notification where one is available, "none" doesn't.
+	Notify string `toml:"notify"`
+	// NotifyWebhook, if set, receives a JSON POST for every failed
+	// background sync.
+	NotifyWebhook string `toml:"notify_webhook"`
+	// TrackedCollision decides what sync-in does with a stored item that is
+	// also tracked in git: "skip" (the default) keeps the committed file,
+	// "rename" syncs the personal copy in as e.g. CLAUDE.personal.md,
+	// "import" syncs it in as e.g. CLAUDE.local.md and @imports that from
+	// the committed file, "append" adds it to the end of the committed file,
+	// and "refuse" stops with an error.
+	TrackedCollision string `toml:"tracked_collision"`
+	// CollisionStrategy overrides TrackedCollision for individual items,
+	// keyed by item name, e.g. "CLAUDE.md" = "import".
+	CollisionStrategy map[string]string `toml:"collision_strategy"`
+	// DirectorySync decides what sync-in does with files in a managed
+	// directory in the working tree that the branch's stored copy doesn't
+	// have: "merge" (the default) keeps them, "mirror" removes them, and
+	// "prompt" asks, keeping them without a terminal.
+	DirectorySync string `toml:"directory_sync"`
+	// DirectorySyncItems overrides DirectorySync for individual items,
+	// keyed by item name, e.g. "prompts" = "mirror".
+	DirectorySyncItems map[string]string `toml:"directory_sync_items"`
+
+	// syncOnly makes a session sync only "in" (--in-only) or only "out"
+	// (--out-only). It has no key in the settings file.
+	syncOnly string
+	// ephemeral discards what a session changed in the personal files,
+	// putting back the stored copies, instead of syncing out (--ephemeral).
+	ephemeral bool
+	// sandbox runs the session in a temporary worktree (--sandbox).
+	sandbox bool
+	// branch names the branch whose store is used instead of the checked-out
+	// branch's (--branch). It has no key in the settings file.
+	branch string
+}
+
+// ItemSettings configures the paths matching one key of Items.
+type ItemSettings struct {
+	// Inherit set to false keeps the path out of new branch stores seeded
+	// from the default branch's, so caches and logs exist on a branch only
+	// once created there. Unset means true.
+	Inherit *bool `toml:"inherit"`
+	// Merge picks how the path is merged when both the working tree and the
+	// store changed it since the last sync: "text", "ours", "theirs",
+	// "union-lines", "json-deep-merge" or "command". Unset leaves it to
+	// merge_extensions.
+	Merge string `toml:"merge"`
+	// MergeCommand is the shell command the "command" driver runs, with %O,
+	// %A and %B replaced by files holding the base, the working tree's copy
+	// and storage's, and %P by the path. It leaves the result in %A.
+	MergeCommand string `toml:"merge_command"`
+	// Overlay set to true keeps only a branch's changes to the path, a JSON
+	// or YAML config file, in the branch's store, applied to the default
+	// branch's copy on sync-in, instead of a whole copy per branch.
+	Overlay bool `toml:"overlay"`
+}
+
+// TemplateSet configures one directory of ~/.workspaces/_templates/.
+type TemplateSet struct {
+	// Repos limits the set to repositories whose directory name matches one
+	// of these glob patterns. Empty means every repository.
+	Repos []string `toml:"repos"`
+	// Disabled stops the set from being used at all.
+	Disabled bool `toml:"disabled"`
+}
+
+// settingsPath returns the location of the settings file. CLAUDE_WRAPPER_CONFIG
+// overrides the default of $XDG_CONFIG_HOME/claude-wrapper/config.toml.
+func settingsPath() (string, error) {
+	if path := os.Getenv("CLAUDE_WRAPPER_CONFIG"); path != "" {
+		return path, nil
+	}
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configDir, "claude-wrapper", "config.toml"), nil
+}
+
+// LoadSettings reads the settings file. A missing file yields default settings.
+func LoadSettings() (Settings, error) {
+	var s Settings
+
+	path, err := settingsPath()
+	if err != nil {
+		return s, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+
+	if err := parseSettings(string(data), &s); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// expandHome replaces a leading ~ in path with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, path[1:])
+}
+
+// parseSettings decodes TOML settings data into s. Unknown keys are
+// rejected so typos in the settings file don't go unnoticed.
+func parseSettings(data string, s *Settings) error {
+	md, err := toml.Decode(data, s)
+	if err != nil {
+		return err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("unknown setting %q", undecoded[0].String())
+	}
+	return s.validate()
+}
+
+// validate rejects settings values the wrapper does not understand.
+func (s *Settings) validate() error {
+	switch s.Report {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("report: unknown format %q (want text or json)", s.Report)
+	}
+	switch s.GitBackend {
+	case "", "auto", "go-git", "cli":
+	default:
+		return fmt.Errorf("git_backend: unknown backend %q (want auto, go-git or cli)", s.GitBackend)
+	}
+	switch s.SparseCheckout {
+	case "", "skip", "add", "ignore":
+	default:
+		return fmt.Errorf("sparse_checkout: unknown mode %q (want skip, add or ignore)", s.SparseCheckout)
+	}
+	if !validNotesPath(s.SessionNotes) {
+		return fmt.Errorf("session_notes: %q is not a path inside the repository", s.SessionNotes)
+	}
+	if s.Profile != "" && !validProfileName.MatchString(s.Profile) {
+		return fmt.Errorf("profile: invalid name %q", s.Profile)
+	}
+	for name := range s.Profiles {
+		if !validProfileName.MatchString(name) {
+			return fmt.Errorf("profiles: invalid name %q", name)
+		}
+	}
+	switch s.VCS {
+	case "", "auto", "git", "jj":
+	default:
+		return fmt.Errorf("vcs: unknown version control system %q (want auto, git or jj)", s.VCS)
+	}
+	switch s.Notify {
+	case "", "desktop", "none":
+	default:
+		return fmt.Errorf("notify: unknown mode %q (want desktop or none)", s.Notify)
+	}
+	if s.NotifyWebhook != "" && !strings.HasPrefix(s.NotifyWebhook, "http://") && !strings.HasPrefix(s.NotifyWebhook, "https://") {
+		return fmt.Errorf("notify_webhook: %q is not an http(s) URL", s.NotifyWebhook)
+	}
+	switch s.CleanupPolicy {
+	case "", "delete", "archive":
+	default:
+		return fmt.Errorf("cleanup_policy: unknown policy %q (want delete or archive)", s.CleanupPolicy)
+	}
+	switch s.PromoteMerged {
+	case "", "off", "ask", "always":
+	default:
+		return fmt.Errorf("promote_merged: unknown mode %q (want off, ask or always)", s.PromoteMerged)
+	}
+	for _, ext := range s.MergeExtensions {
+		if !strings.HasPrefix(ext, ".") || len(ext) < 2 || strings.ContainsAny(ext, "/\\") {
+			return fmt.Errorf("merge_extensions: %q is not an extension like \".md\"", ext)
+		}
+	}
+	if s.ExpireUnusedDays < 0 {
+		return fmt.Errorf("expire_unused_days: %d is negative", s.ExpireUnusedDays)
+	}
+	if s.FreshSyncSeconds < 0 {
+		return fmt.Errorf("fresh_sync_seconds: %d is negative", s.FreshSyncSeconds)
+	}
+	if s.MergedGraceDays < 0 {
+		return fmt.Errorf("merged_grace_days: %d is negative", s.MergedGraceDays)
+	}
+	if s.UnmergedGraceDays < 0 {
+		return fmt.Errorf("unmerged_grace_days: %d is negative", s.UnmergedGraceDays)
+	}
+	if !validCollisionStrategy(s.TrackedCollision) {
+		return fmt.Errorf("tracked_collision: unknown strategy %q (want skip, rename, import, append or refuse)", s.TrackedCollision)
+	}
+	for item, strategy := range s.CollisionStrategy {
+		if !validCollisionStrategy(strategy) {
+			return fmt.Errorf("collision_strategy.%s: unknown strategy %q (want skip, rename, import, append or refuse)", item, strategy)
+		}
+	}
+	if !validDirectorySync(s.DirectorySync) {
+		return fmt.Errorf("directory_sync: unknown mode %q (want merge, mirror or prompt)", s.DirectorySync)
+	}
+	for item, mode := range s.DirectorySyncItems {
+		if !validDirectorySync(mode) {
+			return fmt.Errorf("directory_sync_items.%s: unknown mode %q (want merge, mirror or prompt)", item, mode)
+		}
+	}
+	for _, pattern := range append(append([]string(nil), s.DisabledRepos...), s.EnabledRepos...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("disabled_repos/enabled_repos: bad pattern %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range s.NeverManage {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("never_manage: bad pattern %q: %w", pattern, err)
+		}
+	}
+	for pattern, item := range s.Items {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("items: bad pattern %q: %w", pattern, err)
+		}
+		if !validMergeDriver(item.Merge) {
+			return fmt.Errorf("items.%s.merge: unknown driver %q (want text, ours, theirs, union-lines, json-deep-merge or command)", pattern, item.Merge)
+		}
+		if (item.Merge == mergeDriverCommand) != (item.MergeCommand != "") {
+			return fmt.Errorf("items.%s: merge_command goes with merge = \"command\"", pattern)
+		}
+	}
+	for _, pattern := range s.SeedBranch {
+		if _, err := path.Match(strings.TrimPrefix(pattern, "!"), ""); err != nil {
+			return fmt.Errorf("seed_branch: bad pattern %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range s.MachineScoped {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("machine_scoped: bad pattern %q: %w", pattern, err)
+		}
+	}
+	for name, set := range s.Templates {
+		for _, pattern := range set.Repos {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("templates.%s.repos: bad pattern %q: %w", name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validCollisionStrategy(strategy string) bool {
+	switch strategy {
+	case "", "skip", "rename", "import", "append", "refuse":
+		return true
+	}
+	return false
+}
+
+// defaultBranchRemotes returns the remotes probed first for the default branch.
+func (s Settings) defaultBranchRemotes() []string {
+	if s.DefaultBranchRemotes == nil {
+		return []string{"origin", "upstream"}
+	}
+	return s.DefaultBranchRemotes
+}
@@ -0,0 +1,304 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSettings_TOMLSyntax(t *testing.T) {
+	var s Settings
+	err := parseSettings(`
+# top-level comment
+report = "json" # trailing comment
+report_file = 'C:\sync.log'
+max_item_size_mb = 1_000
+quiet = true
+merge_extensions = [".md", ".b#c",
+  ".txt",
+]
+
+[items."notes.md"]
+merge = "union-lines"
+`, &s)
+	if err != nil {
+		t.Fatalf("parseSettings failed: %v", err)
+	}
+
+	if s.Report != "json" || s.ReportFile != `C:\sync.log` || s.MaxItemSizeMB != 1000 || !s.Quiet {
+		t.Errorf("unexpected settings: %+v", s)
+	}
+	if want := []string{".md", ".b#c", ".txt"}; !reflect.DeepEqual(s.MergeExtensions, want) {
+		t.Errorf("merge_extensions = %q, want %q", s.MergeExtensions, want)
+	}
+	if s.Items["notes.md"].Merge != "union-lines" {
+		t.Errorf("items = %+v", s.Items)
+	}
+}
+
+func TestParseSettings_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing value", "report ="},
+		{"missing equals", "report"},
+		{"unterminated string", `report = "abc`},
+		{"duplicate key", "quiet = true\nquiet = false"},
+		{"unterminated header", "[templates"},
+		{"invalid bare value", "quiet = maybe"},
+		{"trailing content", `report = "a" "b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := parseSettings(tt.input, &Settings{}); err == nil {
+				t.Errorf("expected error for %q", tt.input)
+			}
+		})
+	}
+}
+
+func TestParseSettings_RejectsUnknownNestedKeys(t *testing.T) {
+	err := parseSettings("[items.logs]\ninherti = false\n", &Settings{})
+	if err == nil || !strings.Contains(err.Error(), "items.logs.inherti") {
+		t.Errorf("parseSettings error = %v, want one naming items.logs.inherti", err)
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	var s Settings
+	if err := parseSettings("report = \"json\"\nreport_file = \"/tmp/sync.log\"\n", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Report != "json" || s.ReportFile != "/tmp/sync.log" {
+		t.Errorf("unexpected settings: %+v", s)
+	}
+}
+
+func TestParseSettings_RejectsUnknownKeys(t *testing.T) {
+	var s Settings
+	if err := parseSettings("reprot = \"json\"\n", &s); err == nil {
+		t.Error("expected error for misspelled setting")
+	}
+}
+
+func TestParseSettings_RejectsWrongType(t *testing.T) {
+	var s Settings
+	if err := parseSettings("report = true\n", &s); err == nil {
+		t.Error("expected error for boolean report setting")
+	}
+}
+
+func TestLoadSettings_MissingFileGivesDefaults(t *testing.T) {
+	t.Setenv("CLAUDE_WRAPPER_CONFIG", filepath.Join(t.TempDir(), "missing.toml"))
+
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if !reflect.DeepEqual(s, Settings{}) {
+		t.Errorf("expected zero settings, got %+v", s)
+	}
+}
+
+func TestLoadSettings_ReadsConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, path, "report = \"text\"\n")
+	t.Setenv("CLAUDE_WRAPPER_CONFIG", path)
+
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if s.Report != "text" {
+		t.Errorf("expected report = text, got %q", s.Report)
+	}
+}
+
+func TestSettingsPath_UsesXDGConfigHome(t *testing.T) {
+	t.Setenv("CLAUDE_WRAPPER_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+
+	path, err := settingsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join("/xdg", "claude-wrapper", "config.toml") {
+		t.Errorf("unexpected settings path %s", path)
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	t.Setenv("HOME", "/home/tester")
+
+	tests := map[string]string{
+		"~":             "/home/tester",
+		"~/logs/a.json": "/home/tester/logs/a.json",
+		"/abs/path":     "/abs/path",
+		"~other/path":   "~other/path",
+	}
+	for input, expected := range tests {
+		if got := expandHome(input); got != expected {
+			t.Errorf("expandHome(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+func TestParseSettings_TemplateSets(t *testing.T) {
+	var s Settings
+	input := `
+[templates.go]
+repos = ["*-service", "api"]
+
+[templates.legacy]
+disabled = true
+`
+	if err := parseSettings(input, &s); err != nil {
+		t.Fatalf("parseSettings failed: %v", err)
+	}
+	want := map[string]TemplateSet{
+		"go":     {Repos: []string{"*-service", "api"}},
+		"legacy": {Disabled: true},
+	}
+	if !reflect.DeepEqual(s.Templates, want) {
+		t.Errorf("expected %+v, got %+v", want, s.Templates)
+	}
+}
+
+func TestParseSettings_RejectsBadTemplatePattern(t *testing.T) {
+	var s Settings
+	if err := parseSettings("[templates.go]\nrepos = [\"[\"]\n", &s); err == nil {
+		t.Error("expected error for malformed repos pattern")
+	}
+}
+
+func TestParseSettings_CollisionStrategies(t *testing.T) {
+	var s Settings
+	input := `
+tracked_collision = "rename"
+
+[collision_strategy]
+"CLAUDE.md" = "import"
+`
+	if err := parseSettings(input, &s); err != nil {
+		t.Fatalf("parseSettings failed: %v", err)
+	}
+	if got := s.collisionStrategy("CLAUDE.md"); got != "import" {
+		t.Errorf("collisionStrategy(CLAUDE.md) = %q, want import", got)
+	}
+	if got := s.collisionStrategy(".claude"); got != "rename" {
+		t.Errorf("collisionStrategy(.claude) = %q, want rename", got)
+	}
+
+	if err := parseSettings("[collision_strategy]\n\"CLAUDE.md\" = \"merge\"\n", &Settings{}); err == nil {
+		t.Error("expected error for unknown collision strategy")
+	}
+}
+
+func TestParseSettings_SparseCheckout(t *testing.T) {
+	var s Settings
+	if err := parseSettings(`sparse_checkout = "add"`, &s); err != nil || s.SparseCheckout != "add" {
+		t.Fatalf("parseSettings = %v, sparse_checkout %q", err, s.SparseCheckout)
+	}
+	if err := parseSettings(`sparse_checkout = "expand"`, &Settings{}); err == nil {
+		t.Error("expected error for unknown sparse_checkout mode")
+	}
+}
+
+func TestParseSettings_DirectorySync(t *testing.T) {
+	var s Settings
+	if err := parseSettings(`directory_sync = "mirror"`, &s); err != nil || s.DirectorySync != "mirror" {
+		t.Fatalf("parseSettings = %v, directory_sync %q", err, s.DirectorySync)
+	}
+	if err := parseSettings(`directory_sync = "replace"`, &Settings{}); err == nil {
+		t.Error("expected error for unknown directory_sync mode")
+	}
+
+	s = Settings{}
+	if err := parseSettings("directory_sync = \"prompt\"\n[directory_sync_items]\nprompts = \"mirror\"\n", &s); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.directorySync("prompts"); got != "mirror" {
+		t.Errorf("prompts mode = %q, want mirror", got)
+	}
+	if got := s.directorySync(".claude"); got != "prompt" {
+		t.Errorf(".claude mode = %q, want prompt", got)
+	}
+	if got := (Settings{}).directorySync(".claude"); got != "merge" {
+		t.Errorf("default mode = %q, want merge", got)
+	}
+	if err := parseSettings("[directory_sync_items]\nprompts = \"exact\"\n", &Settings{}); err == nil {
+		t.Error("expected error for unknown directory_sync_items mode")
+	}
+}
+
+func TestParseSettings_Profiles(t *testing.T) {
+	var s Settings
+	input := `
+profile = "work"
+
+[profiles.work]
+store = "~/work-workspaces"
+
+[profiles.work.templates.go]
+repos = ["acme-*"]
+`
+	if err := parseSettings(input, &s); err != nil {
+		t.Fatalf("parseSettings failed: %v", err)
+	}
+	work := s.Profiles["work"]
+	if s.Profile != "work" || work.Store != "~/work-workspaces" {
+		t.Errorf("got profile %q with store %q", s.Profile, work.Store)
+	}
+	if got := work.Templates["go"].Repos; len(got) != 1 || got[0] != "acme-*" {
+		t.Errorf("work templates.go repos = %v", got)
+	}
+
+	if err := parseSettings(`profile = "../work"`, &Settings{}); err == nil {
+		t.Error("expected error for an invalid profile name")
+	}
+}
+
+func TestParseSettings_Notify(t *testing.T) {
+	var s Settings
+	if err := parseSettings("notify = \"none\"\nnotify_webhook = \"https://hooks.example.com/x\"\n", &s); err != nil {
+		t.Fatalf("parseSettings failed: %v", err)
+	}
+	if s.Notify != "none" || s.NotifyWebhook != "https://hooks.example.com/x" {
+		t.Errorf("got notify %q, webhook %q", s.Notify, s.NotifyWebhook)
+	}
+	if err := parseSettings(`notify = "email"`, &Settings{}); err == nil {
+		t.Error("expected error for unknown notify mode")
+	}
+	if err := parseSettings(`notify_webhook = "hooks.example.com"`, &Settings{}); err == nil {
+		t.Error("expected error for a webhook that isn't a URL")
+	}
+}
+
+func TestParseSettings_Items(t *testing.T) {
+	var s Settings
+	if err := parseSettings("[items.\".claude/cache\"]\ninherit = false\n\n[items.logs]\ninherit = true\n", &s); err != nil {
+		t.Fatal(err)
+	}
+	if inherit := s.Items[".claude/cache"].Inherit; inherit == nil || *inherit {
+		t.Errorf(".claude/cache inherit = %v, want false", inherit)
+	}
+	if inherit := s.Items["logs"].Inherit; inherit == nil || !*inherit {
+		t.Errorf("logs inherit = %v, want true", inherit)
+	}
+	if err := parseSettings("[items.logs]\ninherit = \"no\"\n", &Settings{}); err == nil {
+		t.Error("expected error for a non-boolean inherit")
+	}
+}
+
+func TestParseSettings_ExpireUnusedDays(t *testing.T) {
+	var s Settings
+	if err := parseSettings(`expire_unused_days = 60`, &s); err != nil || s.ExpireUnusedDays != 60 {
+		t.Fatalf("parseSettings = %v, expire_unused_days %d", err, s.ExpireUnusedDays)
+	}
+	if err := parseSettings(`expire_unused_days = -1`, &Settings{}); err == nil {
+		t.Error("expected error for negative expire_unused_days")
+	}
+}
@@ -0,0 +1,136 @@
+package wrapper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// sharedDir holds items synced into every branch's working tree. The wrapper
+// never writes to it; a branch store item of the same name overrides it.
+const sharedDir = "shared"
+
+// syncInShared copies shared items that cfg's branch doesn't override, and
+// the repository doesn't track, into the working tree and excludes them
+// from git.
+func syncInShared(cfg *Config, c *copier) error {
+	sharedPath := filepath.Join(cfg.StoreBase, sharedDir)
+	items, err := listDir(sharedPath)
+	if err != nil {
+		return err
+	}
+	c.skip = cfg.skipper(sharedPath, false)
+	tracked, err := trackedItems(cfg, items)
+	if err != nil {
+		return fmt.Errorf("not syncing in shared files: %w", err)
+	}
+	sparse := sparseSkips(cfg, sharedPath, items)
+
+	var errs []error
+	for _, item := range items {
+		if _, err := os.Lstat(filepath.Join(cfg.StoreLocation, item)); err == nil {
+			continue // Branch-specific override
+		}
+		if tracked[item] {
+			continue // The committed file takes precedence over a template
+		}
+		if sparse[item] {
+			continue
+		}
+		src := filepath.Join(sharedPath, item)
+		dst := filepath.Join(cfg.RepoRoot, item)
+		if err := c.copyPath(src, dst); err != nil {
+			errs = append(errs, fmt.Errorf("failed to copy shared %s: %w", item, err))
+		}
+		if err := addToExclude(cfg.RepoRoot, cfg.excludeFile(), item); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update exclude for %s: %w", item, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isUnchangedShared reports whether the working tree copy of item is an
+// unmodified shared item, which sync-out leaves out of the branch store.
+// Once edited, the item is saved to the branch store as an override.
+func isUnchangedShared(cfg *Config, item string) bool {
+	if _, err := os.Lstat(filepath.Join(cfg.StoreLocation, item)); err == nil {
+		return false
+	}
+	shared := filepath.Join(cfg.StoreBase, sharedDir, item)
+	if _, err := os.Lstat(shared); err != nil {
+		return false
+	}
+	return sameContents(filepath.Join(cfg.RepoRoot, item), shared)
+}
+
+// sameFileContents reports whether the files a and b have identical bytes,
+// comparing them a buffer at a time.
+func sameFileContents(a, b string) bool {
+	fileA, err := os.Open(a)
+	if err != nil {
+		return false
+	}
+	defer fileA.Close()
+	fileB, err := os.Open(b)
+	if err != nil {
+		return false
+	}
+	defer fileB.Close()
+
+	bufA := copyBuffers.Get().([]byte)
+	defer copyBuffers.Put(bufA)
+	bufB := copyBuffers.Get().([]byte)
+	defer copyBuffers.Put(bufB)
+	for {
+		n, errA := io.ReadFull(fileA, bufA)
+		m, errB := io.ReadFull(fileB, bufB)
+		if n != m || !bytes.Equal(bufA[:n], bufB[:m]) {
+			return false
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == errA
+		}
+		if errA != nil || errB != nil {
+			return false
+		}
+	}
+}
+
+// sameContents reports whether a and b are files with identical bytes or
+// directories with identical trees.
+func sameContents(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil || infoA.IsDir() != infoB.IsDir() {
+		return false
+	}
+
+	if !infoA.IsDir() {
+		// Never read special files: opening a FIFO blocks
+		if !infoA.Mode().IsRegular() || !infoB.Mode().IsRegular() || infoA.Size() != infoB.Size() {
+			return false
+		}
+		return sameFileContents(a, b)
+	}
+
+	entriesA, err := listDir(a)
+	if err != nil {
+		return false
+	}
+	entriesB, err := listDir(b)
+	if err != nil || len(entriesA) != len(entriesB) {
+		return false
+	}
+	for i, name := range entriesA {
+		if entriesB[i] != name || !sameContents(filepath.Join(a, name), filepath.Join(b, name)) {
+			return false
+		}
+	}
+	return true
+}
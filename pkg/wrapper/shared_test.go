@@ -0,0 +1,103 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncInShared_BranchItemsOverrideShared(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, storeBase := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+
+	writeFile(t, filepath.Join(storeBase, sharedDir, "prompts", "review.md"), "shared review")
+	writeFile(t, filepath.Join(storeBase, sharedDir, "CLAUDE.md"), "shared config")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "feature config")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(repoRoot, "CLAUDE.md"), "feature config")
+	assertFileContent(t, filepath.Join(repoRoot, "prompts", "review.md"), "shared review")
+	assertExcludeContains(t, repoRoot, "prompts")
+}
+
+func TestSyncOut_UnchangedSharedItemsStayOutOfBranchStore(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, storeBase := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+
+	writeFile(t, filepath.Join(storeBase, sharedDir, "prompts", "review.md"), "shared review")
+	writeFile(t, filepath.Join(storeBase, sharedDir, "style.md"), "shared style")
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+	writeFile(t, filepath.Join(repoRoot, "style.md"), "feature style")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "prompts"))
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "style.md"), "feature style")
+	assertFileContent(t, filepath.Join(storeBase, sharedDir, "style.md"), "shared style")
+}
+
+func TestSameContents(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a", "x.md"), "x")
+	writeFile(t, filepath.Join(dir, "b", "x.md"), "x")
+	writeFile(t, filepath.Join(dir, "c", "x.md"), "y")
+	writeFile(t, filepath.Join(dir, "d", "x.md"), "x")
+	writeFile(t, filepath.Join(dir, "d", "extra.md"), "x")
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"a", "b", true},
+		{"a", "c", false},
+		{"a", "d", false},
+		{"a/x.md", "b/x.md", true},
+		{"a", "b/x.md", false},
+		{"a", "missing", false},
+	}
+	for _, tt := range tests {
+		if got := sameContents(filepath.Join(dir, tt.a), filepath.Join(dir, tt.b)); got != tt.want {
+			t.Errorf("sameContents(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSameContents_StreamsHugeFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.bin"), filepath.Join(dir, "b.bin")
+	givenHugeSparseFile(t, a, 2<<30)
+	givenHugeSparseFile(t, b, 2<<30)
+
+	assertBoundedMemory(t, 8<<20, func() {
+		if !sameContents(a, b) {
+			t.Error("expected identical huge files to compare equal")
+		}
+	})
+}
+
+func TestSameFileContents(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("x", copyBufferSize+10)
+	for _, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{big, big, true},
+		{big, big[:len(big)-1] + "y", false},
+		{"", "", true},
+		{"short", "shorter", false},
+	} {
+		writeFile(t, filepath.Join(dir, "a"), tt.a)
+		writeFile(t, filepath.Join(dir, "b"), tt.b)
+		if got := sameFileContents(filepath.Join(dir, "a"), filepath.Join(dir, "b")); got != tt.want {
+			t.Errorf("sameFileContents(%d bytes, %d bytes) = %v, want %v", len(tt.a), len(tt.b), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package wrapper
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+const defaultMaxItemSizeMB = 100
+
+// maxItemSize returns the largest item sync-out copies to storage, in bytes.
+// A negative max_item_size_mb removes the limit (returns 0).
+func (s Settings) maxItemSize() int64 {
+	mb := s.MaxItemSizeMB
+	if mb < 0 {
+		return 0
+	}
+	if mb == 0 {
+		mb = defaultMaxItemSizeMB
+	}
+	return int64(mb) << 20
+}
+
+// itemSize returns the total size of the regular files in the item at
+// root/item that syncing would copy, leaving out never-managed paths.
+func itemSize(root, item string, s Settings) int64 {
+	var total int64
+	filepath.WalkDir(filepath.Join(root, item), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if s.neverManaged(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// oversized reports whether the working tree item exceeds max_item_size_mb,
+// along with its size.
+func oversized(cfg *Config, item string) (bool, int64) {
+	limit := cfg.Settings.maxItemSize()
+	if limit == 0 {
+		return false, 0
+	}
+	size := itemSize(cfg.RepoRoot, item, cfg.Settings)
+	return size > limit, size
+}
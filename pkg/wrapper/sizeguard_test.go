@@ -0,0 +1,54 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMaxItemSize(t *testing.T) {
+	tests := []struct {
+		mb   int
+		want int64
+	}{
+		{0, 100 << 20},
+		{5, 5 << 20},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		if got := (Settings{MaxItemSizeMB: tt.mb}).maxItemSize(); got != tt.want {
+			t.Errorf("maxItemSize(%d) = %d, want %d", tt.mb, got, tt.want)
+		}
+	}
+}
+
+func TestItemSize_LeavesOutNeverManagedPaths(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "data", "a.bin"), strings.Repeat("x", 100))
+	writeFile(t, filepath.Join(root, "data", "node_modules", "dep.js"), strings.Repeat("x", 1000))
+
+	if got := itemSize(root, "data", Settings{}); got != 100 {
+		t.Errorf("expected 100 bytes, got %d", got)
+	}
+}
+
+func TestSyncOut_SkipsOversizedItems(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.MaxItemSizeMB = 1
+	cfg.report = newSyncReport(cfg)
+
+	writeFile(t, filepath.Join(repoRoot, "dataset", "dump.bin"), strings.Repeat("x", 2<<20))
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "config")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "dataset/\nCLAUDE.md\n")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "dataset"))
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "config")
+	if len(cfg.report.Oversized) != 1 || cfg.report.Oversized[0] != "dataset" {
+		t.Errorf("expected dataset to be reported as oversized, got %v", cfg.report.Oversized)
+	}
+}
@@ -0,0 +1,92 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sparseCone returns the directories in the sparse-checkout cone of the
+// working tree at repoRoot. ok is false unless it is a cone-mode sparse
+// checkout: non-cone patterns are too general to reason about.
+func sparseCone(repoRoot string) (dirs []string, ok bool) {
+	gitDir, err := resolveGitDir(repoRoot)
+	if err != nil {
+		return nil, false
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "info", "sparse-checkout")); err != nil {
+		return nil, false // Never sparse; no need to ask git
+	}
+	for _, key := range []string{"core.sparseCheckout", "core.sparseCheckoutCone"} {
+		output, err := gitOutput(repoRoot, "config", "--get", "--bool", key)
+		if err != nil || strings.TrimSpace(output) != "true" {
+			return nil, false
+		}
+	}
+	output, err := gitOutput(repoRoot, "sparse-checkout", "list")
+	if err != nil {
+		return nil, false
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs, true
+}
+
+// outsideSparseCone returns which of the items under root are directories
+// outside cfg's sparse-checkout cone. Files at the top level are always in
+// the cone, and so is a directory holding a cone directory.
+func outsideSparseCone(cfg *Config, root string, items []string) map[string]bool {
+	if cfg.Settings.SparseCheckout == "ignore" || len(items) == 0 {
+		return nil
+	}
+	dirs, ok := sparseCone(cfg.RepoRoot)
+	if !ok {
+		return nil
+	}
+
+	outside := make(map[string]bool)
+	for _, item := range items {
+		info, err := os.Stat(filepath.Join(root, item))
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		inCone := false
+		for _, dir := range dirs {
+			inCone = inCone || dir == item || strings.HasPrefix(dir, item+"/")
+		}
+		if !inCone {
+			outside[item] = true
+		}
+	}
+	return outside
+}
+
+// sparseSkips applies the sparse_checkout setting to items about to be
+// synced in from root, returning those to leave in storage. With "add"
+// directories outside the cone are added to it instead.
+func sparseSkips(cfg *Config, root string, items []string) map[string]bool {
+	outside := outsideSparseCone(cfg, root, items)
+	if len(outside) == 0 {
+		return nil
+	}
+	var names []string
+	for item := range outside {
+		names = append(names, item)
+	}
+	sort.Strings(names)
+
+	if cfg.Settings.SparseCheckout == "add" {
+		_, err := gitOutput(cfg.RepoRoot, append([]string{"sparse-checkout", "add", "--"}, names...)...)
+		if err == nil {
+			infof("added %s to the sparse-checkout cone", strings.Join(names, ", "))
+			return nil
+		}
+		warnf("failed to add %s to the sparse-checkout cone: %v", strings.Join(names, ", "), err)
+	}
+	warnf("leaving %s in storage: outside the sparse-checkout cone (see sparse_checkout)", strings.Join(names, ", "))
+	return outside
+}
@@ -0,0 +1,98 @@
+package wrapper
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// givenSparseCheckout returns a config for a git repository whose working
+// tree is a cone-mode sparse checkout of docs/, with a stored .claude
+// directory and CLAUDE.md.
+func givenSparseCheckout(t *testing.T, mode string) *Config {
+	t.Helper()
+	if !gitBinaryAvailable() {
+		t.Skip("sparse checkouts need the git binary")
+	}
+	repoRoot, _ := givenGitRepo(t)
+	// go-git leaves out core.repositoryformatversion, without which git
+	// ignores the per-worktree config sparse-checkout writes to
+	for _, args := range [][]string{
+		{"config", "core.repositoryformatversion", "1"},
+		{"sparse-checkout", "set", "--cone", "docs"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repoRoot}, args...)...).CombinedOutput(); err != nil {
+			t.Skipf("git sparse-checkout unavailable: %v: %s", err, out)
+		}
+	}
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.SparseCheckout = mode
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "notes")
+	writeFile(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "{}")
+	return cfg
+}
+
+func TestSparseCone(t *testing.T) {
+	cfg := givenSparseCheckout(t, "")
+
+	dirs, ok := sparseCone(cfg.RepoRoot)
+	if !ok || len(dirs) != 1 || dirs[0] != "docs" {
+		t.Errorf("expected cone [docs], got %v, %v", dirs, ok)
+	}
+}
+
+func TestSparseCone_NotSparse(t *testing.T) {
+	repoRoot, _ := givenGitRepo(t)
+
+	if _, ok := sparseCone(repoRoot); ok {
+		t.Error("a full checkout is not sparse")
+	}
+}
+
+func TestSyncIn_SparseCheckoutSkipsDirectoriesOutsideCone(t *testing.T) {
+	cfg := givenSparseCheckout(t, "")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "notes")
+	if _, err := os.Stat(filepath.Join(cfg.RepoRoot, ".claude")); !os.IsNotExist(err) {
+		t.Error(".claude is outside the cone and should stay in storage")
+	}
+
+	// Sync-out must not take the skipped directory out of storage
+	if err := syncOut(cfg); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, ".claude", "settings.json"), "{}")
+}
+
+func TestSyncIn_SparseCheckoutAddExtendsCone(t *testing.T) {
+	cfg := givenSparseCheckout(t, "add")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".claude", "settings.json"), "{}")
+	dirs, _ := sparseCone(cfg.RepoRoot)
+	found := false
+	for _, dir := range dirs {
+		found = found || dir == ".claude"
+	}
+	if !found {
+		t.Errorf("expected .claude in the cone, got %v", dirs)
+	}
+}
+
+func TestSyncIn_SparseCheckoutIgnore(t *testing.T) {
+	cfg := givenSparseCheckout(t, "ignore")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, ".claude", "settings.json"), "{}")
+}
@@ -0,0 +1,259 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Status describes a branch store and the personal files it manages.
+type Status struct {
+	Repo          string `json:"repo"`
+	Branch        string `json:"branch"`
+	DefaultBranch string `json:"default_branch"`
+	Store         string `json:"store"`
+	// CreatedAt, SeededFrom and the last syncs come from the branch
+	// store's metadata; stores older than it have none.
+	CreatedAt   *time.Time   `json:"created_at,omitempty"`
+	SeededFrom  string       `json:"seeded_from,omitempty"`
+	LastSyncIn  *time.Time   `json:"last_sync_in,omitempty"`
+	LastSyncOut *time.Time   `json:"last_sync_out,omitempty"`
+	Items       []StatusItem `json:"items"`
+	// Cleanup previews the branch stores that cleanup will reclaim once
+	// their grace period ends.
+	Cleanup []CleanupItem `json:"cleanup,omitempty"`
+}
+
+// StatusItem is one managed item. Items in the working tree come first,
+// followed by items only in storage.
+type StatusItem struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	Oversized bool   `json:"oversized,omitempty"`
+	StoreOnly bool   `json:"store_only,omitempty"`
+	// RemovedAfter is when sync-out will remove a store-only item that is
+	// no longer listed in the exclude file.
+	RemovedAfter *time.Time `json:"removed_after,omitempty"`
+}
+
+// CleanupItem is a branch store marked for deletion because its branch is
+// gone from git, or in its warning period for going unused (Unused, marked
+// when the period started). Action is "delete", or "archive" with
+// cleanup_policy set to archive.
+type CleanupItem struct {
+	Branch    string    `json:"branch"`
+	Store     string    `json:"store"`
+	MarkedAt  time.Time `json:"marked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Size      int64     `json:"size"`
+	Action    string    `json:"action"`
+	Unused    bool      `json:"unused,omitempty"`
+}
+
+// runStatusCommand implements `claude-wrapper status`.
+func runStatusCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("status")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	if err := printStatus(cfg, os.Stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// collectStatus gathers the status of cfg's branch store.
+func collectStatus(cfg *Config) (*Status, error) {
+	items, err := readExcludeFile(cfg.RepoRoot, cfg.excludeFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exclude file: %w", err)
+	}
+	stored, err := listDir(cfg.StoreLocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list store: %w", err)
+	}
+
+	status := &Status{
+		Repo:          cfg.RepoRoot,
+		Branch:        cfg.CurrentBranch,
+		DefaultBranch: cfg.DefaultBranch,
+		Store:         cfg.StoreLocation,
+	}
+	if meta, ok := readBranchMeta(cfg.StoreLocation); ok {
+		status.CreatedAt, status.SeededFrom = &meta.CreatedAt, meta.SeededFrom
+		status.LastSyncIn, status.LastSyncOut = meta.LastSyncIn, meta.LastSyncOut
+	}
+	inTree := make(map[string]bool)
+	for _, item := range items {
+		inTree[item] = true
+		size := itemSize(cfg.RepoRoot, item, cfg.Settings)
+		limit := cfg.Settings.maxItemSize()
+		status.Items = append(status.Items, StatusItem{
+			Name:      item,
+			Size:      size,
+			Oversized: limit > 0 && size > limit,
+		})
+	}
+	tombstones := readTombstones(cfg.StoreLocation)
+	for _, item := range filterItems(stored) {
+		if !inTree[item] {
+			si := StatusItem{
+				Name:      item,
+				Size:      itemSize(cfg.StoreLocation, item, cfg.Settings),
+				StoreOnly: true,
+			}
+			if markedAt, ok := tombstones[item]; ok {
+				after := markedAt.Add(deletionGraceDays * 24 * time.Hour)
+				si.RemovedAfter = &after
+			}
+			status.Items = append(status.Items, si)
+		}
+	}
+	status.Cleanup = collectCleanupPreview(cfg)
+	return status, nil
+}
+
+// collectCleanupPreview lists the branch stores of cfg's repository that
+// carry deletion markers or are in their warning period for going unused,
+// soonest to expire first. Unlike cleanup itself it
+// changes nothing: stores whose branch is gone but that aren't marked yet
+// are left out, and so are markers cleanup would clear.
+func collectCleanupPreview(cfg *Config) []CleanupItem {
+	branches, err := storedBranches(cfg.StoreBase)
+	if err != nil {
+		return nil
+	}
+	action := "delete"
+	if cfg.Settings.CleanupPolicy == "archive" {
+		action = "archive"
+	}
+
+	var preview []CleanupItem
+	for branch := range branches {
+		store := branchStoreDir(cfg.StoreBase, branch)
+		item := CleanupItem{Branch: branch, Store: store, Action: action}
+		if markedAt, ok := readDeletionMarker(filepath.Join(store, deletionMarker)); ok {
+			item.MarkedAt, item.ExpiresAt = markedAt, branchStoreExpiry(cfg.Settings, store, markedAt)
+		} else if warnAt, expiresAt, ok := cfg.Settings.unusedExpiry(store); ok && branch != cfg.CurrentBranch && !time.Now().Before(warnAt) {
+			item.MarkedAt, item.ExpiresAt, item.Unused = warnAt, expiresAt, true
+		} else {
+			continue
+		}
+		_, item.Size = storeContents(store)
+		preview = append(preview, item)
+	}
+	sort.Slice(preview, func(i, j int) bool {
+		if !preview[i].ExpiresAt.Equal(preview[j].ExpiresAt) {
+			return preview[i].ExpiresAt.Before(preview[j].ExpiresAt)
+		}
+		return preview[i].Branch < preview[j].Branch
+	})
+	return preview
+}
+
+// printStatus describes cfg's branch store and the personal files it manages.
+func printStatus(cfg *Config, w io.Writer) error {
+	status, err := collectStatus(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "repo:   %s\n", status.Repo)
+	fmt.Fprintf(w, "branch: %s (default: %s)\n", status.Branch, status.DefaultBranch)
+	fmt.Fprintf(w, "store:  %s\n", status.Store)
+	if status.CreatedAt != nil {
+		created := formatTime(*status.CreatedAt)
+		if status.SeededFrom != "" {
+			created += " from " + status.SeededFrom
+		}
+		fmt.Fprintf(w, "        created %s; last sync in %s, out %s\n", created, formatLastSync(status.LastSyncIn), formatLastSync(status.LastSyncOut))
+	}
+
+	if len(status.Items) == 0 {
+		fmt.Fprintln(w, "\nno personal files managed")
+		return printCleanupPreview(status.Cleanup, w)
+	}
+
+	fmt.Fprintln(w, "\nmanaged items:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var tooBig int
+	for _, item := range status.Items {
+		// Only the last column is colored, so escapes don't skew alignment
+		note := ""
+		switch {
+		case item.Oversized:
+			note = colorize(w, toneConflict, fmt.Sprintf("OVERSIZED: not saved (max_item_size_mb is %s)", formatBytes(cfg.Settings.maxItemSize())))
+			tooBig++
+		case item.RemovedAfter != nil:
+			note = colorize(w, toneRemoved, fmt.Sprintf("no longer excluded: removed from storage after %s (%s); `claude-wrapper restore %s` keeps it",
+				formatTime(*item.RemovedAfter), formatRemaining(time.Until(*item.RemovedAfter)), item.Name))
+		case item.StoreOnly:
+			note = colorize(w, toneMuted, "in storage only")
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", item.Name, formatBytes(item.Size), note)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if tooBig > 0 {
+		fmt.Fprintf(w, "\n%s\n", colorize(w, toneConflict, fmt.Sprintf("%d item(s) exceed max_item_size_mb and are not being saved", tooBig)))
+	}
+	return printCleanupPreview(status.Cleanup, w)
+}
+
+// formatRemaining describes the time left until a pending removal.
+func formatRemaining(d time.Duration) string {
+	hours := int(d.Hours())
+	switch {
+	case d <= 0:
+		return "due at the next sync-out"
+	case hours >= 24:
+		return fmt.Sprintf("%dd %dh left", hours/24, hours%24)
+	case hours > 0:
+		return fmt.Sprintf("%dh left", hours)
+	}
+	return "under an hour left"
+}
+
+// printCleanupPreview describes the branch stores pending cleanup, if any.
+// formatLastSync formats when a branch store was last synced, if ever.
+func formatLastSync(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return formatTime(*t)
+}
+
+func printCleanupPreview(preview []CleanupItem, w io.Writer) error {
+	if len(preview) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w, "\ncleanup preview (branches deleted from git or unused):")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var total int64
+	for _, item := range preview {
+		total += item.Size
+		marked := "marked " + formatTime(item.MarkedAt)
+		if item.Unused {
+			marked = "unused, warned " + formatTime(item.MarkedAt)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", item.Branch, formatBytes(item.Size), marked,
+			colorize(w, toneRemoved, item.Action+" after "+formatTime(item.ExpiresAt)))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s will be freed once these expire\n", formatBytes(total))
+	return nil
+}
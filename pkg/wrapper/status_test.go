@@ -0,0 +1,131 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintStatus_ListsItemsAndOversized(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{currentBranch: "feature"})
+	cfg.Settings.MaxItemSizeMB = 1
+
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "config")
+	writeFile(t, filepath.Join(repoRoot, "dataset", "dump.bin"), strings.Repeat("x", 2<<20))
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "CLAUDE.md\ndataset/\n")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "old-notes.md"), "notes")
+
+	var out bytes.Buffer
+	if err := printStatus(cfg, &out); err != nil {
+		t.Fatalf("printStatus failed: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"branch: feature (default: main)",
+		"CLAUDE.md",
+		"2.0 MiB  OVERSIZED: not saved",
+		"old-notes.md",
+		"in storage only",
+		"1 item(s) exceed max_item_size_mb",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected status to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestPrintStatus_NothingManaged(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+
+	var out bytes.Buffer
+	if err := printStatus(cfg, &out); err != nil {
+		t.Fatalf("printStatus failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "no personal files managed") {
+		t.Errorf("unexpected status:\n%s", out.String())
+	}
+}
+
+func TestCollectStatus_CleanupPreview(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	markedAt := time.Now().Add(-2 * 24 * time.Hour).Truncate(time.Second)
+	gone := filepath.Join(cfg.StoreBase, branchesDir, "old-work")
+	writeFile(t, filepath.Join(gone, "CLAUDE.md"), "0123456789")
+	if err := writeDeletionMarker(filepath.Join(gone, deletionMarker), markedAt); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(cfg.StoreBase, branchesDir, "live", "CLAUDE.md"), "live")
+
+	status, err := collectStatus(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Cleanup) != 1 {
+		t.Fatalf("expected one store pending cleanup, got %+v", status.Cleanup)
+	}
+	item := status.Cleanup[0]
+	if item.Branch != "old-work" || item.Size != 10 || item.Action != "delete" ||
+		!item.MarkedAt.Equal(markedAt) || !item.ExpiresAt.Equal(markedAt.Add(7*24*time.Hour)) {
+		t.Errorf("unexpected cleanup preview %+v", item)
+	}
+	assertFileContent(t, filepath.Join(gone, deletionMarker), strconv.FormatInt(markedAt.Unix(), 10))
+
+	var out bytes.Buffer
+	if err := printStatus(cfg, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "cleanup preview") || !strings.Contains(out.String(), "10 B will be freed") {
+		t.Errorf("unexpected status:\n%s", out.String())
+	}
+}
+
+func TestPrintStatus_PendingRemoval(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "prompts", "review.md"), "prompt")
+	marked := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	if err := writeTombstones(cfg.StoreLocation, map[string]time.Time{"prompts": marked}); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := collectStatus(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Items) != 1 || status.Items[0].RemovedAfter == nil || !status.Items[0].RemovedAfter.Equal(marked.Add(deletionGraceDays*24*time.Hour)) {
+		t.Fatalf("expected prompts pending removal, got %+v", status.Items)
+	}
+
+	var out bytes.Buffer
+	if err := printStatus(cfg, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"no longer excluded: removed from storage after " + formatTime(marked.Add(deletionGraceDays*24*time.Hour)),
+		"claude-wrapper restore prompts",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected status to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestFormatRemaining(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		-time.Hour:                "due at the next sync-out",
+		30 * time.Minute:          "under an hour left",
+		5*time.Hour + time.Minute: "5h left",
+		(6*24 + 3) * time.Hour:    "6d 3h left",
+	} {
+		if got := formatRemaining(d); got != want {
+			t.Errorf("formatRemaining(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package wrapper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runSyncCommand implements `claude-wrapper sync [--in] [--out]`. Without
+// direction flags it syncs out (saving working tree edits) and then in. If
+// the branch changed since the last sync-in, the previous branch's files are
+// saved to its store and this branch's files synced in, whatever the flags.
+func runSyncCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("sync")
+	in := fs.Bool("in", false, "copy personal files from storage into the working tree")
+	out := fs.Bool("out", false, "copy personal files from the working tree into storage")
+	ifBranchChanged := fs.Bool("if-branch-changed", false, "only sync in when the branch differs from the last sync-in")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if !*in && !*out {
+		*in, *out = true, true
+	}
+
+	cfg, err := openRepo(flags)
+	if errors.Is(err, ErrRepoDisabled) {
+		return 0, nil // Nothing to sync; hooks must stay quiet here
+	}
+	if err != nil {
+		return 1, err
+	}
+	cfg.report = newSyncReport(cfg)
+	cfg.progress = newProgressMeter(cfg.Settings)
+
+	start := time.Now()
+	if err := syncRepo(cfg, *in, *out, *ifBranchChanged); err != nil {
+		if *ifBranchChanged {
+			// Run from a git hook, where the error is easily missed
+			notifySyncFailure(cfg.Settings, cfg.RepoRoot, err)
+		}
+		return 1, err
+	}
+	cfg.report.addDuration(time.Since(start))
+
+	if err := emitReport(cfg.Settings, cfg.report, os.Stderr); err != nil {
+		return 1, fmt.Errorf("failed to write sync report: %w", err)
+	}
+	return 0, nil
+}
+
+// syncRepo syncs cfg's branch out and/or in. If the branch changed since the
+// last sync-in, the previous branch's files are saved to its store and this
+// branch's files synced in instead.
+func syncRepo(cfg *Config, in, out, ifBranchChanged bool) error {
+	prev, err := syncedElsewhere(cfg)
+	if err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+	if prev != nil {
+		// The working tree still holds another branch's files: save them to
+		// that branch's store before bringing this branch's files in
+		if err := syncOutAndSwitch(prev, cfg); err != nil {
+			return fmt.Errorf("sync failed: %w", err)
+		}
+		return nil
+	}
+	synced := lastSyncedBranch(cfg.RepoRoot)
+	if out && synced == "" {
+		// A working tree that was never synced in and manages nothing holds
+		// none of the store's files; syncing it out would empty the store
+		if items, err := readExcludeFile(cfg.RepoRoot, cfg.excludeFile()); err == nil && len(items) == 0 {
+			out = false
+		}
+	}
+	if out {
+		start := time.Now()
+		err := syncOut(cfg)
+		cfg.report.addTiming("sync_out", time.Since(start))
+		if err != nil {
+			return withExitCode(exitSyncOut, fmt.Errorf("sync out failed: %w", err))
+		}
+	}
+	if in && !(ifBranchChanged && synced == cfg.CurrentBranch) {
+		start := time.Now()
+		err := syncIn(cfg)
+		cfg.report.addTiming("sync_in", time.Since(start))
+		if err != nil {
+			return withExitCode(exitSyncIn, fmt.Errorf("sync in failed: %w", err))
+		}
+		recordSyncedBranch(cfg.RepoRoot, cfg.CurrentBranch, cfg.Settings.Profile)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// inRepo runs the rest of the test from dir with HOME pointing at a fresh
+// directory, so commands resolve the repository and store like a real run.
+func inRepo(t *testing.T, dir string) (home string) {
+	t.Helper()
+	home = t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CLAUDE_WRAPPER_CONFIG", filepath.Join(home, "config.toml"))
+
+	orig, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(orig)
+		gitRepo = newGitBackend(Settings{}, "")
+	})
+	return home
+}
+
+func TestSyncCommand_SyncsInAndRecordsBranch(t *testing.T) {
+	dir, repo := givenGitRepo(t)
+	createBranch(t, repo, "feature", true)
+	home := inRepo(t, dir)
+
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "default config")
+
+	code, err := runSyncCommand(wrapperFlags{}, []string{"--in"})
+	if err != nil || code != 0 {
+		t.Fatalf("sync --in failed: %d, %v", code, err)
+	}
+
+	assertFileContent(t, filepath.Join(dir, "CLAUDE.md"), "default config")
+	assertFileContent(t, filepath.Join(storeBase, branchesDir, "feature", "CLAUDE.md"), "default config")
+	if got := lastSyncedBranch(dir); got != "feature" {
+		t.Errorf("expected last synced branch feature, got %q", got)
+	}
+}
+
+func TestSyncCommand_IfBranchChangedSkipsSameBranch(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "stored")
+	writeFile(t, filepath.Join(dir, "CLAUDE.md"), "edited in working tree")
+	recordSyncedBranch(dir, "main", "")
+
+	code, err := runSyncCommand(wrapperFlags{}, []string{"--in", "--if-branch-changed"})
+	if err != nil || code != 0 {
+		t.Fatalf("sync failed: %d, %v", code, err)
+	}
+
+	// Still on the branch that was synced last: working tree edits are kept
+	assertFileContent(t, filepath.Join(dir, "CLAUDE.md"), "edited in working tree")
+}
+
+func TestSyncCommand_OutOnly(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+
+	writeFile(t, filepath.Join(dir, "notes.md"), "my notes")
+	writeFile(t, filepath.Join(dir, excludeFile), "notes.md\n")
+
+	code, err := runSyncCommand(wrapperFlags{}, []string{"--out"})
+	if err != nil || code != 0 {
+		t.Fatalf("sync --out failed: %d, %v", code, err)
+	}
+	assertFileContent(t, filepath.Join(home, ".workspaces", filepath.Base(dir), "notes.md"), "my notes")
+}
+
+func TestLookupCommand(t *testing.T) {
+	if _, ok := lookupCommand([]string{"sync", "--in"}); !ok {
+		t.Error("expected sync to be a wrapper command")
+	}
+	if _, ok := lookupCommand([]string{"-p", "hello"}); ok {
+		t.Error("claude arguments must not be treated as wrapper commands")
+	}
+	if _, ok := lookupCommand(nil); ok {
+		t.Error("empty args must pass through to claude")
+	}
+}
+
+func TestSyncCommand_FreshWorkingTreeKeepsStore(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	home := inRepo(t, dir)
+
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "stored")
+
+	code, err := runSyncCommand(wrapperFlags{}, nil)
+	if err != nil || code != 0 {
+		t.Fatalf("sync failed: %d, %v", code, err)
+	}
+
+	assertFileContent(t, filepath.Join(storeBase, "CLAUDE.md"), "stored")
+	assertFileContent(t, filepath.Join(dir, "CLAUDE.md"), "stored")
+}
+
+func TestSyncCommand_FromSubdirectory(t *testing.T) {
+	dir, _ := givenGitRepo(t)
+	nested := filepath.Join(dir, "src", "pkg")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	home := inRepo(t, nested)
+	storeBase := filepath.Join(home, ".workspaces", filepath.Base(dir))
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "stored")
+	writeFile(t, filepath.Join(storeBase, ".claude", "settings.json"), "{}")
+
+	if code, err := runSyncCommand(wrapperFlags{}, []string{"--in"}); err != nil || code != 0 {
+		t.Fatalf("sync --in failed: %d, %v", code, err)
+	}
+	assertFileContent(t, filepath.Join(dir, "CLAUDE.md"), "stored")
+	assertFileContent(t, filepath.Join(dir, ".claude", "settings.json"), "{}")
+	assertNotExists(t, filepath.Join(nested, "CLAUDE.md"))
+	assertNotExists(t, filepath.Join(nested, ".claude"))
+
+	// Entries written by hand, anchored to the root, are items at the root
+	writeFile(t, filepath.Join(dir, "notes.md"), "my notes")
+	lines, err := readLines(filepath.Join(dir, excludeFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeLines(filepath.Join(dir, excludeFile), insertIntoManagedBlock(lines, "/notes.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "CLAUDE.md"), "edited")
+
+	if code, err := runSyncCommand(wrapperFlags{}, []string{"--out"}); err != nil || code != 0 {
+		t.Fatalf("sync --out failed: %d, %v", code, err)
+	}
+	assertFileContent(t, filepath.Join(storeBase, "CLAUDE.md"), "edited")
+	assertFileContent(t, filepath.Join(storeBase, "notes.md"), "my notes")
+	assertNotExists(t, filepath.Join(storeBase, "src"))
+}
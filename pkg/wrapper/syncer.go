@@ -0,0 +1,115 @@
+package wrapper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// LoadConfig returns the Config for the repository containing dir ("" means
+// the current directory) with the given settings. It fails outside a
+// branch of a git repository, and with ErrRepoDisabled where the wrapper is
+// turned off.
+func LoadConfig(dir string, settings Settings) (*Config, error) {
+	return openConfig(newVCS(settings, dir), settings)
+}
+
+// openConfig builds the Config for the repository g queries.
+func openConfig(g VCS, settings Settings) (*Config, error) {
+	cfg, err := loadConfigFrom(g, settings)
+	if errors.Is(err, ErrNotInRepo) || errors.Is(err, ErrNotOnBranch) {
+		return nil, fmt.Errorf("not on a branch of a git repository: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reason, disabled := repoDisabled(cfg.RepoRoot, settings); disabled {
+		return nil, fmt.Errorf("%w (%s)", ErrRepoDisabled, reason)
+	}
+	cfg.Settings = settings
+	return cfg, nil
+}
+
+// Store is where a branch's personal files are kept: Location, inside the
+// repository's store Base. The default branch's Location is Base itself.
+type Store struct {
+	Base     string
+	Location string
+}
+
+// Store returns cfg's branch store.
+func (cfg *Config) Store() Store {
+	return Store{Base: cfg.StoreBase, Location: cfg.StoreLocation}
+}
+
+// Items returns the personal items in the store, leaving out the
+// wrapper's own bookkeeping.
+func (s Store) Items() ([]string, error) {
+	items, err := listDir(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	return filterItems(items), nil
+}
+
+// Branches returns the non-default branches with a store of their own.
+func (s Store) Branches() ([]string, error) {
+	stored, err := storedBranches(s.Base)
+	if err != nil {
+		return nil, err
+	}
+	branches := make([]string, 0, len(stored))
+	for branch := range stored {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	return branches, nil
+}
+
+// Syncer syncs one repository's personal files between its working tree
+// and its branch store, as the claude-wrapper command does around a claude
+// session. If the branch changed since the last sync-in, every sync first
+// saves the previous branch's files to its store and then brings the
+// current branch's files in.
+type Syncer struct {
+	cfg *Config
+}
+
+// NewSyncer returns a Syncer for cfg's repository and branch.
+func NewSyncer(cfg *Config) *Syncer {
+	return &Syncer{cfg: cfg}
+}
+
+// SyncIn copies the branch's stored files into the working tree.
+func (s *Syncer) SyncIn() (*SyncReport, error) {
+	return s.sync(true, false)
+}
+
+// SyncOut saves the working tree's personal files to the branch store.
+func (s *Syncer) SyncOut() (*SyncReport, error) {
+	return s.sync(false, true)
+}
+
+// Sync saves the working tree's personal files and then syncs the branch's
+// files back in.
+func (s *Syncer) Sync() (*SyncReport, error) {
+	return s.sync(true, true)
+}
+
+// Status describes the branch store and the personal files it manages.
+func (s *Syncer) Status() (*Status, error) {
+	return collectStatus(s.cfg)
+}
+
+func (s *Syncer) sync(in, out bool) (*SyncReport, error) {
+	cfg := *s.cfg
+	cfg.report = newSyncReport(&cfg)
+	start := time.Now()
+	err := syncRepo(&cfg, in, out, false)
+	cfg.report.addDuration(time.Since(start))
+	if err != nil {
+		cfg.report.Error = err.Error()
+	}
+	return cfg.report, err
+}
@@ -0,0 +1,88 @@
+package wrapper_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/yourusername/claude-wrapper/pkg/wrapper"
+)
+
+// TestSyncer_EmbeddedUse drives the sync engine only through the exported
+// API, as another tool would.
+func TestSyncer_EmbeddedUse(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = worktree.Commit("initial", &git.CommitOptions{
+		AllowEmptyCommits: true,
+		Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := wrapper.LoadConfig(dir, wrapper.Settings{GitBackend: "go-git"})
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	store := cfg.Store()
+	if want := filepath.Join(home, ".workspaces", filepath.Base(dir)); store.Location != want {
+		t.Fatalf("store location = %s, want %s", store.Location, want)
+	}
+	if err := os.MkdirAll(store.Location, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(store.Location, "CLAUDE.md"), []byte("stored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	syncer := wrapper.NewSyncer(cfg)
+	report, err := syncer.SyncIn()
+	if err != nil {
+		t.Fatalf("SyncIn failed: %v", err)
+	}
+	if report.FilesIn != 1 {
+		t.Errorf("FilesIn = %d, want 1", report.FilesIn)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "CLAUDE.md"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncer.SyncOut(); err != nil {
+		t.Fatalf("SyncOut failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(store.Location, "CLAUDE.md"))
+	if err != nil || string(data) != "edited" {
+		t.Errorf("stored CLAUDE.md = %q, %v; want edited", data, err)
+	}
+
+	status, err := syncer.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status.Items) != 1 || status.Items[0].Name != "CLAUDE.md" {
+		t.Errorf("status items = %+v, want CLAUDE.md", status.Items)
+	}
+	if items, err := store.Items(); err != nil || len(items) != 1 {
+		t.Errorf("store items = %v, %v; want [CLAUDE.md]", items, err)
+	}
+}
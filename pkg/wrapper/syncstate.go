@@ -0,0 +1,123 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// syncedBranchFile records, per working tree, which branch's store was last
+// synced into the working tree, and on a second line the profile it belongs
+// to (none for the default store). It lives in the git directory so every
+// worktree tracks its own branch.
+const syncedBranchFile = "claude-wrapper-branch"
+
+// readSyncState returns the branch and profile recorded by the last sync-in
+// for the working tree at repoRoot; the branch is "" if none was recorded.
+func readSyncState(repoRoot string) (branch, profile string) {
+	gitDir, err := resolveGitDir(repoRoot)
+	if err != nil {
+		return "", ""
+	}
+	data, err := os.ReadFile(filepath.Join(gitDir, syncedBranchFile))
+	if err != nil {
+		return "", ""
+	}
+	branch, profile, _ = strings.Cut(strings.TrimSpace(string(data)), "\n")
+	return strings.TrimSpace(branch), strings.TrimSpace(profile)
+}
+
+// lastSyncedBranch returns the branch recorded by the last sync-in for the
+// working tree at repoRoot, or "" if none was recorded.
+func lastSyncedBranch(repoRoot string) string {
+	branch, _ := readSyncState(repoRoot)
+	return branch
+}
+
+// recordSyncedBranch remembers that branch's store in profile was just
+// synced in.
+func recordSyncedBranch(repoRoot, branch, profile string) {
+	gitDir, err := resolveGitDir(repoRoot)
+	if err != nil {
+		return
+	}
+	state := branch + "\n"
+	if profile != "" {
+		state += profile + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, syncedBranchFile), []byte(state), 0644); err != nil {
+		warnf("failed to record synced branch: %v", err)
+	}
+}
+
+// freshSyncFile records, per working tree, when the last session synced out
+// and what the store's manifest then held, so that under fresh_sync_seconds
+// the next session can skip sync-in. It lives next to syncedBranchFile.
+const freshSyncFile = "claude-wrapper-fresh"
+
+// freshSync is the content of freshSyncFile.
+type freshSync struct {
+	At       time.Time `json:"at"`
+	Branch   string    `json:"branch"`
+	Profile  string    `json:"profile,omitempty"`
+	Manifest string    `json:"manifest"` // SHA-256 of the store's manifest
+}
+
+// recordFreshSync remembers that cfg's working tree and store were just
+// synced, when fresh_sync_seconds is set.
+func recordFreshSync(cfg *Config, now time.Time) {
+	if cfg.Settings.FreshSyncSeconds <= 0 {
+		return
+	}
+	gitDir, err := resolveGitDir(cfg.RepoRoot)
+	if err != nil {
+		return
+	}
+	path := filepath.Join(gitDir, freshSyncFile)
+	sum, err := fileSHA256(filepath.Join(cfg.StoreLocation, manifestFile))
+	if err != nil {
+		os.Remove(path)
+		return
+	}
+	data, err := json.Marshal(freshSync{At: now.UTC(), Branch: cfg.CurrentBranch, Profile: cfg.Settings.Profile, Manifest: sum})
+	if err == nil {
+		err = os.WriteFile(path, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		warnf("failed to record sync time: %v", err)
+	}
+}
+
+// syncedRecently reports whether sync-in can be skipped for cfg now: the
+// last session on the same branch and profile synced out less than
+// fresh_sync_seconds ago, nothing has synced the working tree since, and
+// the store's manifest is unchanged, so no other working tree has saved
+// to the store.
+func syncedRecently(cfg *Config, now time.Time) bool {
+	window := time.Duration(cfg.Settings.FreshSyncSeconds) * time.Second
+	if window <= 0 {
+		return false
+	}
+	gitDir, err := resolveGitDir(cfg.RepoRoot)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(gitDir, freshSyncFile))
+	if err != nil {
+		return false
+	}
+	var fresh freshSync
+	if json.Unmarshal(data, &fresh) != nil {
+		return false
+	}
+	if fresh.Branch != cfg.CurrentBranch || fresh.Profile != cfg.Settings.Profile || now.Before(fresh.At) || now.Sub(fresh.At) >= window {
+		return false
+	}
+	if branch, profile := readSyncState(cfg.RepoRoot); branch != fresh.Branch || profile != fresh.Profile {
+		return false
+	}
+	sum, err := fileSHA256(filepath.Join(cfg.StoreLocation, manifestFile))
+	return err == nil && sum == fresh.Manifest
+}
@@ -0,0 +1,64 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncedRecently_SkipsOnlyWhileFreshAndUnchanged(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.FreshSyncSeconds = 60
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "hello")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "CLAUDE.md\n")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	recordSyncedBranch(repoRoot, cfg.CurrentBranch, "")
+	now := time.Now()
+	recordFreshSync(cfg, now)
+
+	if !syncedRecently(cfg, now.Add(30*time.Second)) {
+		t.Error("sync-in not skipped within fresh_sync_seconds")
+	}
+	if syncedRecently(cfg, now.Add(time.Minute)) {
+		t.Error("sync-in skipped after fresh_sync_seconds")
+	}
+	if syncedRecently(cfg.forBranch("feature"), now) {
+		t.Error("sync-in skipped for another branch")
+	}
+	disabled := *cfg
+	disabled.Settings.FreshSyncSeconds = 0
+	if syncedRecently(&disabled, now) {
+		t.Error("sync-in skipped without fresh_sync_seconds")
+	}
+
+	// Another working tree saving to the store changes its manifest
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "changed elsewhere")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if syncedRecently(cfg, now) {
+		t.Error("sync-in skipped after the store's manifest changed")
+	}
+}
+
+func TestSyncedRecently_NotAfterAnotherBranchWasSyncedIn(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.FreshSyncSeconds = 60
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "hello")
+	writeFile(t, filepath.Join(repoRoot, excludeFile), "CLAUDE.md\n")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	recordSyncedBranch(repoRoot, cfg.CurrentBranch, "")
+	recordFreshSync(cfg, now)
+
+	recordSyncedBranch(repoRoot, "feature", "")
+	if syncedRecently(cfg, now) {
+		t.Error("sync-in skipped though the working tree holds another branch's files")
+	}
+}
@@ -0,0 +1,94 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// templatesDir lives beside the repository stores in ~/.workspaces (or a
+// profile's store) and holds one directory per template set.
+const templatesDir = "_templates"
+
+// seedRepoStore creates cfg's store base the first time the wrapper runs in a
+// repository, copying in every template set that applies to it. Sets are
+// copied in name order, so a later set's item replaces an earlier one's.
+// An existing store is never touched.
+func seedRepoStore(cfg *Config) error {
+	if _, err := os.Stat(cfg.StoreBase); err == nil {
+		return nil
+	}
+
+	setsPath := filepath.Join(filepath.Dir(cfg.StoreBase), templatesDir)
+	sets, err := templateSets(setsPath, filepath.Base(cfg.StoreBase), cfg.Settings.templateSetConfig())
+	if err != nil {
+		return fmt.Errorf("failed to list template sets: %w", err)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(cfg.StoreBase, 0755); err != nil {
+		return err
+	}
+	for _, set := range sets {
+		items, err := listDir(filepath.Join(setsPath, set))
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			// A set may seed the shared area, but not other wrapper state
+			if isReservedItem(item) && item != sharedDir {
+				continue
+			}
+			src := filepath.Join(setsPath, set, item)
+			dst := filepath.Join(cfg.StoreBase, item)
+			if err := os.RemoveAll(dst); err != nil {
+				return err
+			}
+			if err := copyPath(src, dst); err != nil {
+				return fmt.Errorf("failed to copy %s from template set %s: %w", item, set, err)
+			}
+		}
+	}
+	infof("seeded new store for %s from template set(s): %s", filepath.Base(cfg.StoreBase), strings.Join(sets, ", "))
+	return nil
+}
+
+// templateSets returns the names, in order, of the template sets under
+// setsPath that apply to the repository named repo.
+func templateSets(setsPath, repo string, config map[string]TemplateSet) ([]string, error) {
+	entries, err := os.ReadDir(setsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		set, ok := config[entry.Name()]
+		if !ok || (!set.Disabled && set.matches(repo)) {
+			sets = append(sets, entry.Name())
+		}
+	}
+	return sets, nil
+}
+
+// matches reports whether the set applies to the repository named repo.
+func (s TemplateSet) matches(repo string) bool {
+	if len(s.Repos) == 0 {
+		return true
+	}
+	for _, pattern := range s.Repos {
+		if ok, _ := filepath.Match(pattern, repo); ok {
+			return true
+		}
+	}
+	return false
+}
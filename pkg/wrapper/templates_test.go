@@ -0,0 +1,102 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// givenNewStore returns a Config whose store base doesn't exist yet, along
+// with the template sets directory beside it.
+func givenNewStore(t *testing.T, repo string) (*Config, string) {
+	t.Helper()
+	workspaces := t.TempDir()
+	storeBase := filepath.Join(workspaces, repo)
+	cfg := &Config{
+		RepoRoot:      givenRepo(t),
+		CurrentBranch: "main",
+		DefaultBranch: "main",
+		StoreBase:     storeBase,
+		StoreLocation: storeBase,
+	}
+	return cfg, filepath.Join(workspaces, templatesDir)
+}
+
+func TestSeedRepoStore_CopiesTemplateSetsIntoNewStore(t *testing.T) {
+	cfg, templates := givenNewStore(t, "api")
+	writeFile(t, filepath.Join(templates, "default", "CLAUDE.md"), "starter")
+	writeFile(t, filepath.Join(templates, "default", ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(templates, "zz-team", "CLAUDE.md"), "team starter")
+
+	if err := seedRepoStore(cfg); err != nil {
+		t.Fatalf("seedRepoStore failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"), "team starter")
+	assertFileContent(t, filepath.Join(cfg.StoreBase, ".claude", "settings.json"), "{}")
+}
+
+func TestSeedRepoStore_LeavesExistingStoreAlone(t *testing.T) {
+	cfg, templates := givenNewStore(t, "api")
+	writeFile(t, filepath.Join(templates, "default", "CLAUDE.md"), "starter")
+	writeFile(t, filepath.Join(cfg.StoreBase, "notes.md"), "mine")
+
+	if err := seedRepoStore(cfg); err != nil {
+		t.Fatalf("seedRepoStore failed: %v", err)
+	}
+
+	assertNotExists(t, filepath.Join(cfg.StoreBase, "CLAUDE.md"))
+}
+
+func TestSeedRepoStore_SeedsSharedArea(t *testing.T) {
+	cfg, templates := givenNewStore(t, "api")
+	writeFile(t, filepath.Join(templates, "default", sharedDir, "prompts.md"), "prompts")
+	writeFile(t, filepath.Join(templates, "default", branchesDir, "x", "a.md"), "a")
+
+	if err := seedRepoStore(cfg); err != nil {
+		t.Fatalf("seedRepoStore failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.StoreBase, sharedDir, "prompts.md"), "prompts")
+	assertNotExists(t, filepath.Join(cfg.StoreBase, branchesDir))
+}
+
+func TestSyncIn_SeedsFromTemplatesOnFirstRun(t *testing.T) {
+	cfg, templates := givenNewStore(t, "api")
+	cfg = cfg.forBranch("feature")
+	writeFile(t, filepath.Join(templates, "default", "CLAUDE.md"), "starter")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatalf("syncIn failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "starter")
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "starter")
+}
+
+func TestTemplateSets_FiltersByConfig(t *testing.T) {
+	templates := t.TempDir()
+	for _, set := range []string{"default", "go", "off", "python"} {
+		writeFile(t, filepath.Join(templates, set, "CLAUDE.md"), set)
+	}
+	config := map[string]TemplateSet{
+		"go":     {Repos: []string{"*-service", "api"}},
+		"off":    {Disabled: true},
+		"python": {Repos: []string{"*-py"}},
+	}
+
+	sets, err := templateSets(templates, "api", config)
+	if err != nil {
+		t.Fatalf("templateSets failed: %v", err)
+	}
+	if want := []string{"default", "go"}; !reflect.DeepEqual(sets, want) {
+		t.Errorf("expected %v, got %v", want, sets)
+	}
+}
+
+func TestTemplateSets_MissingDirectory(t *testing.T) {
+	sets, err := templateSets(filepath.Join(t.TempDir(), "missing"), "api", nil)
+	if err != nil || sets != nil {
+		t.Errorf("expected no sets and no error, got %v, %v", sets, err)
+	}
+}
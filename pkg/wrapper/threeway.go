@@ -0,0 +1,327 @@
+package wrapper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// mergeBaseDir in a branch store keeps, for files merge_extensions or a
+// merge driver opts in, the content the working tree and the store last agreed on: the
+// base a three-way merge of the two compares them against.
+const mergeBaseDir = ".merge-base"
+
+// maxMergeCells caps the work of matching up the lines of two versions of a
+// file (the product of their line counts, past a common start and end).
+// Files that differ more than that are copied over as before.
+const maxMergeCells = 4 << 20
+
+// Conflict markers, as git writes them.
+const (
+	conflictOurs   = "<<<<<<< working tree\n"
+	conflictSep    = "=======\n"
+	conflictTheirs = ">>>>>>> storage\n"
+)
+
+// mergesText reports whether the store-relative path rel is merged rather
+// than copied over when both copies changed: whether merge_extensions lists
+// its extension.
+func (s Settings) mergesText(rel string) bool {
+	ext := filepath.Ext(rel)
+	for _, want := range s.MergeExtensions {
+		if ext != "" && strings.EqualFold(ext, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileMerger returns the copier merge func for the copies of a sync in or
+// out: overlaid config files first, then three-way merges. It is nil if
+// neither applies.
+func (cfg *Config) fileMerger(in bool) func(src, dst string) (bool, error) {
+	overlay, threeWay := cfg.overlayer(in), cfg.threeWayMerger(in)
+	switch {
+	case overlay == nil:
+		return threeWay
+	case threeWay == nil:
+		return overlay
+	}
+	return func(src, dst string) (bool, error) {
+		if merged, err := overlay(src, dst); merged || err != nil {
+			return merged, err
+		}
+		return threeWay(src, dst)
+	}
+}
+
+// threeWayMerger returns a copier merge func for the copies of a sync in
+// (from the store) or out (to it) that merges the files merge_extensions or
+// a merge driver opts in, or nil if none are.
+func (cfg *Config) threeWayMerger(in bool) func(src, dst string) (bool, error) {
+	if !cfg.Settings.merges() {
+		return nil
+	}
+	return func(src, dst string) (bool, error) {
+		tree, store := dst, src
+		if !in {
+			tree, store = src, dst
+		}
+		// Only the branch store's own items have a base; shared and
+		// machine-scoped copies are copied as usual
+		rel, err := filepath.Rel(cfg.StoreLocation, store)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false, nil
+		}
+		if isReservedItem(strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]) {
+			return false, nil
+		}
+		driver := cfg.Settings.mergeDriver(rel)
+		if driver == "" {
+			return false, nil
+		}
+		return mergeText(cfg, rel, driver, tree, store, in)
+	}
+}
+
+// mergeText syncs the file at rel in cfg's branch store with its working
+// tree copy at tree, in or out. A copy that hasn't changed since they last
+// agreed takes the other's changes as usual; one that has is kept, merging
+// the other's changes in with driver if both did. merged reports whether
+// that left nothing for the copier to do.
+func mergeText(cfg *Config, rel, driver, tree, store string, in bool) (merged bool, err error) {
+	src, dst := store, tree
+	if !in {
+		src, dst = tree, store
+	}
+	srcData, err := os.ReadFile(src)
+	if err != nil || (lineDriver(driver) && !isText(srcData)) {
+		return false, nil // The copy reports any error
+	}
+	dstData, err := os.ReadFile(dst)
+	if err != nil {
+		return false, writeMergeBase(cfg, rel, srcData)
+	}
+	base, err := os.ReadFile(filepath.Join(cfg.StoreLocation, mergeBaseDir, rel))
+	switch {
+	case err != nil || bytes.Equal(srcData, dstData) || bytes.Equal(dstData, base):
+		// Nothing to keep at the destination
+		return false, writeMergeBase(cfg, rel, srcData)
+	case bytes.Equal(srcData, base):
+		return true, nil // Only the destination changed
+	case lineDriver(driver) && !isText(dstData):
+		return false, writeMergeBase(cfg, rel, srcData)
+	}
+
+	ours, theirs := dstData, srcData
+	if !in {
+		ours, theirs = srcData, dstData
+	}
+	result, conflicts, err := cfg.Settings.runMergeDriver(driver, rel, base, ours, theirs)
+	if err != nil {
+		warnf("%s changed in both the working tree and storage and couldn't be merged (%v); copying it over", rel, err)
+		return false, writeMergeBase(cfg, rel, srcData)
+	}
+	if conflicts > 0 {
+		if lineDriver(driver) {
+			warnf("%s changed in both the working tree and storage: %d conflict(s) marked with <<<<<<< in %s", rel, conflicts, tree)
+		} else {
+			warnf("%s changed in both the working tree and storage: the %s merge driver reported %d conflict(s) in %s", rel, driver, conflicts, tree)
+		}
+		cfg.report.addConflicted(rel)
+	} else {
+		infof("%s changed in both the working tree and storage; merged the changes", rel)
+	}
+	if err := replaceFile(tree, result); err != nil {
+		return true, err
+	}
+	if in {
+		// The store still holds its version, which sync-out compares with
+		return true, writeMergeBase(cfg, rel, srcData)
+	}
+	// The merged working tree copy is then saved as usual
+	return false, writeMergeBase(cfg, rel, result)
+}
+
+// writeMergeBase records data as the base of rel in cfg's branch store.
+func writeMergeBase(cfg *Config, rel string, data []byte) error {
+	path := filepath.Join(cfg.StoreLocation, mergeBaseDir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return replaceFile(path, data)
+}
+
+// replaceFile replaces the file at path with data, keeping its mode, by
+// renaming a new file over it so the old one is never half written.
+func replaceFile(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// isText reports whether data looks like text: it has no NUL bytes in its
+// first 8000, as git decides.
+func isText(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) < 0
+}
+
+// splitLines splits data into lines, each keeping its line ending.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		lines = append(lines, string(data[:n]))
+		data = data[n:]
+	}
+	return lines
+}
+
+// mergeLines merges the changes ours and theirs each made to base, line by
+// line like diff3. Where both changed the same lines differently, both
+// versions are kept between conflict markers, or with union one after the
+// other without them. ok is false if the versions are too big to match up.
+func mergeLines(base, ours, theirs []string, union bool) (merged []string, conflicts int, ok bool) {
+	return mergeLinesLabeled(base, ours, theirs, union, conflictOurs, conflictTheirs)
+}
+
+// mergeLinesLabeled is mergeLines with the given markers opening and closing
+// conflicts.
+func mergeLinesLabeled(base, ours, theirs []string, union bool, oursMarker, theirsMarker string) (merged []string, conflicts int, ok bool) {
+	inOurs, ok := lcsMatches(base, ours)
+	if !ok {
+		return nil, 0, false
+	}
+	inTheirs, ok := lcsMatches(base, theirs)
+	if !ok {
+		return nil, 0, false
+	}
+
+	i, a, b := 0, 0, 0
+	for i < len(base) || a < len(ours) || b < len(theirs) {
+		// A line all three share as they stand
+		if i < len(base) && inOurs[i] == a && inTheirs[i] == b {
+			merged = append(merged, base[i])
+			i, a, b = i+1, a+1, b+1
+			continue
+		}
+
+		// Otherwise the chunk up to the next base line both kept
+		next := i
+		for next < len(base) && (inOurs[next] < 0 || inTheirs[next] < 0) {
+			next++
+		}
+		nextA, nextB := len(ours), len(theirs)
+		if next < len(base) {
+			nextA, nextB = inOurs[next], inTheirs[next]
+		}
+		was, o, t := base[i:next], ours[a:nextA], theirs[b:nextB]
+		switch {
+		case equalLines(o, was):
+			merged = append(merged, t...)
+		case equalLines(t, was), equalLines(o, t):
+			merged = append(merged, o...)
+		case union:
+			merged = append(merged, terminated(o)...)
+			merged = append(merged, t...)
+		default:
+			conflicts++
+			merged = append(merged, oursMarker)
+			merged = append(merged, terminated(o)...)
+			merged = append(merged, conflictSep)
+			merged = append(merged, terminated(t)...)
+			merged = append(merged, theirsMarker)
+		}
+		i, a, b = next, nextA, nextB
+	}
+	return merged, conflicts, true
+}
+
+// terminated returns lines with a line ending added to the last if it
+// lacks one, so a conflict marker after it starts a line of its own.
+func terminated(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	out := append([]string(nil), lines...)
+	out[len(out)-1] += "\n"
+	return out
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lcsMatches matches up the lines of a and b along a longest common
+// subsequence: the index in b of each line of a, or -1 for lines b lacks.
+// ok is false if a and b are too big to match up.
+func lcsMatches(a, b []string) (match []int, ok bool) {
+	match = make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	// A common start and end match as they are
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		match[pre] = pre
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		match[len(a)-1-suf] = len(b) - 1 - suf
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	n, m := len(ma), len(mb)
+	if n*m > maxMergeCells {
+		return nil, false
+	}
+
+	// lengths[i*w+j] is the length of the longest common subsequence of
+	// ma[i:] and mb[j:]
+	w := m + 1
+	lengths := make([]int32, (n+1)*w)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lengths[i*w+j] = lengths[(i+1)*w+j+1] + 1
+			} else {
+				lengths[i*w+j] = max(lengths[(i+1)*w+j], lengths[i*w+j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case ma[i] == mb[j]:
+			match[pre+i] = pre + j
+			i, j = i+1, j+1
+		case lengths[(i+1)*w+j] >= lengths[i*w+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return match, true
+}
@@ -0,0 +1,126 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		wantConflicts      int
+	}{
+		{"only ours changed", "a\nb\nc\n", "a\nB\nc\n", "a\nb\nc\n", "a\nB\nc\n", 0},
+		{"only theirs changed", "a\nb\nc\n", "a\nb\nc\n", "a\nb\nC\n", "a\nb\nC\n", 0},
+		{"separate changes", "a\nb\nc\nd\n", "A\nb\nc\nd\n", "a\nb\nc\nD\n", "A\nb\nc\nD\n", 0},
+		{"same change", "a\nb\n", "a\nX\n", "a\nX\n", "a\nX\n", 0},
+		{"both append", "a\n", "a\nours\n", "a\n", "a\nours\n", 0},
+		{"insertions apart", "a\nb\nc\n", "a\nnew\nb\nc\n", "a\nb\nc\nend\n", "a\nnew\nb\nc\nend\n", 0},
+		{"one deletes", "a\nb\nc\n", "a\nc\n", "a\nb\nc\nd\n", "a\nc\nd\n", 0},
+		{"conflict", "a\nb\nc\n", "a\nours\nc\n", "a\ntheirs\nc\n",
+			"a\n" + conflictOurs + "ours\n" + conflictSep + "theirs\n" + conflictTheirs + "c\n", 1},
+		{"conflict without final newline", "a", "ours", "theirs",
+			conflictOurs + "ours\n" + conflictSep + "theirs\n" + conflictTheirs, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts, ok := mergeLines(splitLines([]byte(tt.base)), splitLines([]byte(tt.ours)), splitLines([]byte(tt.theirs)), false)
+			if !ok {
+				t.Fatal("mergeLines gave up")
+			}
+			if got := strings.Join(merged, ""); got != tt.want || conflicts != tt.wantConflicts {
+				t.Errorf("merged = %q with %d conflict(s), want %q with %d", got, conflicts, tt.want, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+// givenMergeableSync returns a config merging .md files whose CLAUDE.md
+// and settings.json were synced in with the given stored contents.
+func givenMergeableSync(t *testing.T, claude, settings string) *Config {
+	t.Helper()
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	cfg.Settings.MergeExtensions = []string{".md"}
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), claude)
+	writeFile(t, filepath.Join(cfg.StoreLocation, "settings.json"), settings)
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestSyncIn_MergesTextChangedOnBothSides(t *testing.T) {
+	cfg := givenMergeableSync(t, "# Notes\nstyle: tabs\n\nend\n", "{}")
+	// Another worktree saved a change while this one was edited too
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "# Notes\nstyle: spaces\n\nend\n")
+	writeFile(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "# Notes\nstyle: tabs\n\nend\nlocal rule\n")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "settings.json"), `{"a": 1}`)
+	writeFile(t, filepath.Join(cfg.RepoRoot, "settings.json"), `{"b": 2}`)
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "# Notes\nstyle: spaces\n\nend\nlocal rule\n")
+	// Extensions not opted in are copied over as before
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "settings.json"), `{"a": 1}`)
+
+	// Sync-out saves the merge, and the store's own change isn't a conflict
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "# Notes\nstyle: spaces\n\nend\nlocal rule\n")
+}
+
+func TestSyncIn_KeepsWorkingTreeEditsWhenStoreUnchanged(t *testing.T) {
+	cfg := givenMergeableSync(t, "stored\n", "{}")
+	writeFile(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "edited since\n")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "edited since\n")
+}
+
+func TestSyncOut_MarksConflicts(t *testing.T) {
+	cfg := givenMergeableSync(t, "a\nb\nc\n", "{}")
+	cfg.report = &SyncReport{}
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "a\nstore\nc\n")
+	writeFile(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), "a\ntree\nc\n")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := "a\n" + conflictOurs + "tree\n" + conflictSep + "store\n" + conflictTheirs + "c\n"
+	assertFileContent(t, filepath.Join(cfg.RepoRoot, "CLAUDE.md"), want)
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), want)
+	if len(cfg.report.Conflicted) != 1 || cfg.report.Conflicted[0] != "CLAUDE.md" {
+		t.Errorf("conflicted = %q, want [CLAUDE.md]", cfg.report.Conflicted)
+	}
+}
+
+func TestSyncOut_KeepsStoreChangesWhenTreeUnchanged(t *testing.T) {
+	cfg := givenMergeableSync(t, "synced\n", "{}")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "saved elsewhere\n")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "saved elsewhere\n")
+}
+
+func TestParseSettings_MergeExtensions(t *testing.T) {
+	var s Settings
+	if err := parseSettings(`merge_extensions = [".md", ".TXT"]`, &s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.mergesText("docs/notes.txt") || s.mergesText("settings.json") || s.mergesText("Makefile") {
+		t.Errorf("mergesText disagrees with %q", s.MergeExtensions)
+	}
+	if err := parseSettings(`merge_extensions = ["md"]`, &Settings{}); err == nil {
+		t.Error("expected error for an extension without a dot")
+	}
+}
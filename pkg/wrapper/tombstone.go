@@ -0,0 +1,49 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tombstonesFile in a branch store records when each stored item stopped
+// being listed in the exclude file. Sync-out removes an item only once it
+// has been unlisted for deletionGraceDays, so an accidental edit of the
+// exclude file doesn't wipe the stored copy.
+const tombstonesFile = ".tombstones.json"
+
+// readTombstones returns the tombstones of the branch store at store,
+// keyed by item. A missing or unreadable file means none.
+func readTombstones(store string) map[string]time.Time {
+	tombstones := make(map[string]time.Time)
+	data, err := os.ReadFile(filepath.Join(store, tombstonesFile))
+	if err != nil {
+		return tombstones
+	}
+	if err := json.Unmarshal(data, &tombstones); err != nil {
+		warnf("ignoring unreadable %s: %v", tombstonesFile, err)
+	}
+	return tombstones
+}
+
+// writeTombstones replaces the tombstones of the branch store at store,
+// removing the file when there are none.
+func writeTombstones(store string, tombstones map[string]time.Time) error {
+	path := filepath.Join(store, tombstonesFile)
+	if len(tombstones) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(tombstones, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
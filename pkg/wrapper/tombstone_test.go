@@ -0,0 +1,97 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// givenTombstoned marks items in the branch store at store as unlisted for
+// longer than the grace period, so the next sync-out removes them.
+func givenTombstoned(t *testing.T, store string, items ...string) {
+	t.Helper()
+	tombstones := readTombstones(store)
+	for _, item := range items {
+		tombstones[item] = time.Now().Add(-(deletionGraceDays + 1) * 24 * time.Hour)
+	}
+	if err := writeTombstones(store, tombstones); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncOut_TombstonesUnlistedItems(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "prompts", "review.md"), "stored prompt")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "stored")
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// An accidental edit drops prompts from the exclude file
+	writeFile(t, filepath.Join(repoRoot, excludeFile), managedBlockStart+"\nCLAUDE.md\n"+managedBlockEnd+"\n")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(cfg.StoreLocation, "prompts", "review.md"), "stored prompt")
+	marked, ok := readTombstones(cfg.StoreLocation)["prompts"]
+	if !ok || time.Since(marked) > time.Minute {
+		t.Fatalf("expected prompts to be tombstoned now, got %v", readTombstones(cfg.StoreLocation))
+	}
+
+	// Still pending on later syncs within the grace period
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(cfg.StoreLocation, "prompts"))
+	if got := readTombstones(cfg.StoreLocation)["prompts"]; !got.Equal(marked) {
+		t.Errorf("tombstone moved from %v to %v", marked, got)
+	}
+
+	// Listing the item again clears the tombstone
+	if err := addToExclude(repoRoot, cfg.excludeFile(), "prompts"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(readTombstones(cfg.StoreLocation)) != 0 {
+		t.Errorf("expected no tombstones, got %v", readTombstones(cfg.StoreLocation))
+	}
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, tombstonesFile))
+}
+
+func TestSyncOut_RemovesItemsAfterGracePeriod(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "old.md"), "stale")
+	givenTombstoned(t, cfg.StoreLocation, "old.md")
+
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, "old.md"))
+	assertNotExists(t, filepath.Join(cfg.StoreLocation, tombstonesFile))
+
+	entries := readAuditLog(t, cfg.StoreBase)
+	if len(entries) != 1 || entries[0].MarkedAt == nil {
+		t.Errorf("expected the removal audited with its tombstone, got %+v", entries)
+	}
+}
+
+func TestSyncIn_SkipsTombstonedItems(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "deleted.md"), "removed by the user")
+	writeFile(t, filepath.Join(cfg.StoreLocation, "CLAUDE.md"), "stored")
+	if err := writeTombstones(cfg.StoreLocation, map[string]time.Time{"deleted.md": time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(repoRoot, "CLAUDE.md"))
+	assertNotExists(t, filepath.Join(repoRoot, "deleted.md"))
+	assertNotExists(t, filepath.Join(repoRoot, tombstonesFile))
+}
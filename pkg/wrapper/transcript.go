@@ -0,0 +1,183 @@
+package wrapper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// sessionsDir holds transcripts of claude sessions, under each branch
+	// store: <store>/sessions/<timestamp>.log.
+	sessionsDir = "sessions"
+	// defaultTranscriptMaxMB is the size at which a transcript is rotated
+	// when transcript_max_mb is unset.
+	defaultTranscriptMaxMB = 10
+)
+
+// transcriptMax returns the size in bytes at which a transcript is rotated,
+// or 0 for no limit.
+func (s Settings) transcriptMax() int64 {
+	mb := s.TranscriptMaxMB
+	if mb < 0 {
+		return 0
+	}
+	if mb == 0 {
+		mb = defaultTranscriptMaxMB
+	}
+	return int64(mb) << 20
+}
+
+// transcriptPath returns where a session started at start is recorded in
+// cfg's branch store.
+func transcriptPath(cfg *Config, start time.Time) string {
+	return filepath.Join(cfg.StoreLocation, sessionsDir, start.Format("2006-01-02T15-04-05")+".log")
+}
+
+// rotatingWriter appends to a file, moving it aside to <path>.1 (replacing
+// any earlier part) whenever it reaches max bytes, so a transcript keeps
+// at most the last two parts of a long session.
+type rotatingWriter struct {
+	mu   sync.Mutex
+	path string
+	max  int64
+	file *os.File
+	size int64
+}
+
+func newRotatingWriter(path string, max int64) (*rotatingWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	w := &rotatingWriter{path: path, max: max}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w.file, w.size = file, 0
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.max > 0 && w.size > 0 && w.size+int64(len(p)) > w.max {
+		w.file.Close()
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return 0, err
+		}
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// runClaudeSession runs claude for cfg's session, recording its output
+// when transcripts are on.
+func runClaudeSession(cfg *Config, args []string) int {
+	if !cfg.Settings.Transcripts {
+		return runClaude(args)
+	}
+	path := transcriptPath(cfg, time.Now())
+	transcript, err := newRotatingWriter(path, cfg.Settings.transcriptMax())
+	if err != nil {
+		warnf("not recording a transcript: %v", err)
+		return runClaude(args)
+	}
+	defer transcript.Close()
+
+	exitCode, err := runTranscribed("claude", args, transcript)
+	if err != nil {
+		errorf("%v", err)
+	}
+	return exitCode
+}
+
+// errPTYUnsupported is returned by openPTY where pseudo-terminals aren't
+// implemented; callers fall back to plain pipes.
+var errPTYUnsupported = errors.New("pseudo-terminals are not supported on this platform")
+
+// runTranscribed runs name like runProcess, also copying everything it
+// writes to transcript. At a terminal it runs on a pseudo-terminal so it
+// still sees one; otherwise its stdout and stderr are tee'd.
+func runTranscribed(name string, args []string, transcript io.Writer) (int, error) {
+	cmd := exec.Command(name, args...)
+	if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+		master, slave, err := openPTY()
+		if err == nil {
+			return runOnPTY(cmd, master, slave, transcript)
+		}
+		if !errors.Is(err, errPTYUnsupported) {
+			warnf("transcript falls back to pipes: %v", err)
+		}
+	}
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, transcript)
+	cmd.Stderr = io.MultiWriter(os.Stderr, transcript)
+	return exitStatus(cmd.Run())
+}
+
+// runOnPTY runs cmd on the pseudo-terminal slave, mirroring the wrapper's
+// own terminal: keystrokes are passed through in raw mode, window size
+// changes are forwarded, and the output read from master goes to stdout and
+// transcript.
+func runOnPTY(cmd *exec.Cmd, master, slave *os.File, transcript io.Writer) (int, error) {
+	defer master.Close()
+
+	stopResize := forwardWindowSize(os.Stdin, master)
+	defer stopResize()
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = ptySysProcAttr()
+	if err := cmd.Start(); err != nil {
+		slave.Close()
+		return startFailureCode(err), fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+	slave.Close() // The child holds it now; reads see EOF once it exits
+
+	if state, err := term.MakeRaw(int(os.Stdin.Fd())); err == nil {
+		defer term.Restore(int(os.Stdin.Fd()), state)
+	}
+	// Stopped once claude exits, so prompts after the session get every
+	// keystroke
+	stopInput := pumpInput(os.Stdin, master)
+
+	copied := make(chan struct{})
+	go func() {
+		// Ends with EIO on Linux when the child closes the terminal
+		io.Copy(io.MultiWriter(os.Stdout, transcript), master)
+		close(copied)
+	}()
+
+	exitCode, err := exitStatus(cmd.Wait())
+	stopInput()
+	select {
+	case <-copied:
+	case <-time.After(time.Second): // A leftover background process holds the terminal
+	}
+	return exitCode, err
+}
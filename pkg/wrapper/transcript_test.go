@@ -0,0 +1,103 @@
+package wrapper
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscriptMax(t *testing.T) {
+	if got := (Settings{}).transcriptMax(); got != defaultTranscriptMaxMB<<20 {
+		t.Errorf("expected the default limit, got %d", got)
+	}
+	if got := (Settings{TranscriptMaxMB: 1}).transcriptMax(); got != 1<<20 {
+		t.Errorf("expected 1 MB, got %d", got)
+	}
+	if got := (Settings{TranscriptMaxMB: -1}).transcriptMax(); got != 0 {
+		t.Errorf("expected no limit, got %d", got)
+	}
+}
+
+func TestTranscriptPath(t *testing.T) {
+	cfg := &Config{StoreLocation: "/store/branches/feature"}
+	start := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+
+	want := filepath.Join("/store/branches/feature", sessionsDir, "2024-05-01T09-30-00.log")
+	if got := transcriptPath(cfg, start); got != want {
+		t.Errorf("transcriptPath = %s, want %s", got, want)
+	}
+}
+
+func TestRotatingWriter_RotatesAtLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), sessionsDir, "session.log")
+	w, err := newRotatingWriter(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range []string{"first ", "second ", "third"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	assertFileContent(t, path+".1", "second ")
+	assertFileContent(t, path, "third")
+}
+
+func TestRunTranscribed_TeesOutput(t *testing.T) {
+	var transcript strings.Builder
+	exitCode, err := runTranscribed("sh", []string{"-c", "echo to-stdout; echo to-stderr >&2; exit 3"}, &transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 {
+		t.Errorf("expected the command's exit code, got %d", exitCode)
+	}
+	for _, want := range []string{"to-stdout", "to-stderr"} {
+		if !strings.Contains(transcript.String(), want) {
+			t.Errorf("transcript missing %q: %q", want, transcript.String())
+		}
+	}
+}
+
+func TestRunOnPTY_CommandSeesTerminal(t *testing.T) {
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("no pseudo-terminal: %v", err)
+	}
+	var transcript strings.Builder
+	cmd := exec.Command("sh", "-c", "test -t 0 && test -t 1 && echo on-a-terminal")
+
+	exitCode, err := runOnPTY(cmd, master, slave, &transcript)
+	if err != nil || exitCode != 0 {
+		t.Fatalf("runOnPTY = %d, %v", exitCode, err)
+	}
+	if !strings.Contains(transcript.String(), "on-a-terminal") {
+		t.Errorf("expected the command to run on a terminal, got %q", transcript.String())
+	}
+}
+
+func TestRunClaudeSession_RecordsTranscript(t *testing.T) {
+	bin := t.TempDir()
+	writeFile(t, filepath.Join(bin, "claude"), "#!/bin/sh\necho claude says hi\n")
+	if err := os.Chmod(filepath.Join(bin, "claude"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cfg, _ := givenConfig(t, givenRepo(t), configOpts{})
+	cfg.Settings.Transcripts = true
+
+	if exitCode := runClaudeSession(cfg, nil); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d", exitCode)
+	}
+
+	logs, err := filepath.Glob(filepath.Join(cfg.StoreLocation, sessionsDir, "*.log"))
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("expected one transcript, got %v, %v", logs, err)
+	}
+	assertFileContent(t, logs[0], "claude says hi\n")
+}
@@ -0,0 +1,186 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// explanation is the wrapper's view of one path in a repository, as shown
+// by `claude-wrapper why`.
+type explanation struct {
+	Path string // Relative to the repository root
+	Item string // The top-level item it is in
+	// ExcludeLine is the line number (from 1) of the exclude file entry
+	// covering the path, or 0 if none does. An entry outside the wrapper's
+	// block, once there is one, hides the path from git without the
+	// wrapper managing it.
+	ExcludeLine   int
+	ExcludeEntry  string
+	OutsideBlock  bool
+	Tracked       bool
+	NeverManaged  bool
+	StoreOnly     bool // Matched by the store's .wrapperignore
+	MachineScoped bool
+	InTree        bool
+	StoreCopy     string         // Where its stored copy is, if there is one
+	Saved         *manifestEntry // What sync-out last saved, for a file
+	Meta          *branchMeta    // The branch store's metadata, if any
+	RemovedAfter  *time.Time     // When sync-out removes it from storage
+}
+
+// explainPath gathers the wrapper's view of path, relative to dir, a
+// directory in cfg's repository.
+func explainPath(cfg *Config, dir, path string) (*explanation, error) {
+	rel, err := repoRelative(cfg.RepoRoot, dir, path)
+	if err != nil {
+		return nil, err
+	}
+	e := &explanation{Path: filepath.ToSlash(rel)}
+	e.Item = strings.SplitN(e.Path, "/", 2)[0]
+	if isReservedItem(e.Item) {
+		return nil, fmt.Errorf("%s is reserved for the wrapper's own files", e.Item)
+	}
+
+	lines, err := readLines(cfg.excludeFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exclude file: %w", err)
+	}
+	start, end := managedBlock(lines)
+	for i, line := range lines {
+		item, ok := parseExcludeEntry(line)
+		if ok && (e.Path == item || strings.HasPrefix(e.Path, item+"/")) {
+			e.ExcludeLine, e.ExcludeEntry = i+1, line
+			e.OutsideBlock = start >= 0 && (i < start || i > end)
+			if !e.OutsideBlock {
+				break // The wrapper's own entry is the one that counts
+			}
+		}
+	}
+
+	tracked, err := trackedItems(cfg, []string{e.Item})
+	if err != nil {
+		return nil, err
+	}
+	e.Tracked = tracked[e.Item]
+	e.NeverManaged = cfg.Settings.neverManaged(e.Path)
+	e.StoreOnly = readWrapperIgnore(cfg.StoreLocation).matches(e.Path)
+	e.MachineScoped = cfg.Settings.machineScoped(e.Path)
+	if _, err := os.Lstat(filepath.Join(cfg.RepoRoot, rel)); err == nil {
+		e.InTree = true
+	}
+
+	stores := []string{cfg.StoreLocation}
+	if e.MachineScoped {
+		if store, err := machineStore(cfg); err == nil {
+			stores = []string{store}
+		}
+	}
+	stores = append(stores, filepath.Join(cfg.StoreBase, sharedDir))
+	for _, store := range stores {
+		if _, err := os.Lstat(filepath.Join(store, rel)); err == nil {
+			e.StoreCopy = filepath.Join(store, rel)
+			break
+		}
+	}
+	if m, err := readManifest(cfg.StoreLocation); err == nil {
+		if entry, ok := m.Files[manifestKey(rel)]; ok {
+			e.Saved = &entry
+		}
+	}
+	if meta, ok := readBranchMeta(cfg.StoreLocation); ok {
+		e.Meta = &meta
+	}
+	if markedAt, ok := readTombstones(cfg.StoreLocation)[e.Item]; ok {
+		after := markedAt.Add(deletionGraceDays * 24 * time.Hour)
+		e.RemovedAfter = &after
+	}
+	return e, nil
+}
+
+// printExplanation writes e for a person to w.
+func printExplanation(cfg *Config, e *explanation, w io.Writer) {
+	fmt.Fprintf(w, "path:     %s\n", e.Path)
+
+	switch {
+	case e.Tracked:
+		fmt.Fprintf(w, "managed:  no, %s is tracked in git\n", e.Item)
+	case e.NeverManaged:
+		fmt.Fprintln(w, "managed:  no, never_manage matches it")
+	case e.StoreOnly:
+		fmt.Fprintln(w, "managed:  no, the store's .wrapperignore keeps it in storage only")
+	case e.ExcludeLine == 0:
+		fmt.Fprintf(w, "managed:  no, no entry in %s covers it; `claude-wrapper init %s` starts managing it\n", cfg.excludeFile(), e.Item)
+	case e.OutsideBlock:
+		fmt.Fprintf(w, "managed:  no, line %d of %s (%s) hides it from git, but outside the wrapper's block\n",
+			e.ExcludeLine, cfg.excludeFile(), e.ExcludeEntry)
+	default:
+		fmt.Fprintf(w, "managed:  yes, by line %d of %s (%s)\n", e.ExcludeLine, cfg.excludeFile(), e.ExcludeEntry)
+	}
+	if e.MachineScoped {
+		fmt.Fprintln(w, "          machine_scoped: stored for this machine only")
+	}
+
+	tree := "missing"
+	if e.InTree {
+		tree = "present"
+	}
+	fmt.Fprintf(w, "tree:     %s\n", tree)
+
+	switch {
+	case e.StoreCopy == "":
+		fmt.Fprintf(w, "stored:   no copy in %s\n", cfg.StoreLocation)
+	case e.Saved != nil:
+		fmt.Fprintf(w, "stored:   %s (%s, saved %s)\n", e.StoreCopy, formatBytes(e.Saved.Size), formatTime(e.Saved.Saved))
+	default:
+		fmt.Fprintf(w, "stored:   %s\n", e.StoreCopy)
+	}
+
+	if e.Meta != nil {
+		fmt.Fprintf(w, "synced:   in %s, out %s (branch %s)\n", formatLastSync(e.Meta.LastSyncIn), formatLastSync(e.Meta.LastSyncOut), cfg.CurrentBranch)
+	} else {
+		fmt.Fprintf(w, "synced:   unknown (branch %s's store predates sync records)\n", cfg.CurrentBranch)
+	}
+
+	if e.RemovedAfter != nil {
+		fmt.Fprintf(w, "removal:  %s\n", colorize(w, toneRemoved, fmt.Sprintf("%s is no longer excluded: removed from storage after %s (%s); `claude-wrapper restore %s` keeps it",
+			e.Item, formatTime(*e.RemovedAfter), formatRemaining(time.Until(*e.RemovedAfter)), e.Item)))
+	} else {
+		fmt.Fprintln(w, "removal:  none scheduled")
+	}
+}
+
+// runWhyCommand implements `claude-wrapper why PATH...`.
+func runWhyCommand(flags wrapperFlags, args []string) (int, error) {
+	fs := newFlagSet("why")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage, nil
+	}
+
+	cfg, err := openRepo(flags)
+	if err != nil {
+		return 1, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return 1, err
+	}
+	for i, path := range fs.Args() {
+		e, err := explainPath(cfg, dir, path)
+		if err != nil {
+			return 1, err
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		printExplanation(cfg, e, os.Stdout)
+	}
+	return 0, nil
+}
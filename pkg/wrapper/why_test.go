@@ -0,0 +1,83 @@
+package wrapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainPath_ManagedAndSaved(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	if err := addToExclude(repoRoot, cfg.excludeFile(), ".claude"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := explainPath(cfg, filepath.Join(repoRoot, ".claude"), "settings.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Path != ".claude/settings.json" || e.Item != ".claude" || e.ExcludeLine == 0 || e.OutsideBlock {
+		t.Errorf("explanation = %+v, want it covered by the .claude entry", e)
+	}
+	if e.StoreCopy != filepath.Join(cfg.StoreLocation, ".claude", "settings.json") || e.Saved == nil || e.Meta == nil || e.Meta.LastSyncOut == nil {
+		t.Errorf("explanation = %+v, want the saved store copy", e)
+	}
+
+	var out bytes.Buffer
+	printExplanation(cfg, e, &out)
+	for _, want := range []string{"managed:  yes, by line", "(.claude)", "tree:     present", "removal:  none scheduled"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestExplainPath_PendingRemoval(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(cfg.StoreLocation, "old.md"), "stale")
+	givenTombstoned(t, cfg.StoreLocation, "old.md")
+
+	e, err := explainPath(cfg, repoRoot, "old.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ExcludeLine != 0 || e.InTree || e.RemovedAfter == nil || e.StoreCopy == "" {
+		t.Errorf("explanation = %+v, want a store-only copy pending removal", e)
+	}
+	var out bytes.Buffer
+	printExplanation(cfg, e, &out)
+	for _, want := range []string{"managed:  no, no entry", "tree:     missing", "`claude-wrapper restore old.md` keeps it"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestExplainPath_HandWrittenEntryOutsideBlock(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, _ := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(repoRoot, "notes.md"), "mine")
+	writeFile(t, filepath.Join(repoRoot, "CLAUDE.md"), "config")
+	writeFile(t, cfg.excludeFile(), "notes.md\n")
+	if err := addToExclude(repoRoot, cfg.excludeFile(), "CLAUDE.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := explainPath(cfg, repoRoot, "notes.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ExcludeLine != 1 || !e.OutsideBlock {
+		t.Errorf("explanation = %+v, want line 1 outside the wrapper's block", e)
+	}
+	if _, err := explainPath(cfg, repoRoot, "../elsewhere"); err == nil {
+		t.Error("expected an error for a path outside the repository")
+	}
+}
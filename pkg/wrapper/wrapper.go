@@ -0,0 +1,1427 @@
+// Package wrapper syncs personal, git-excluded files such as CLAUDE.md
+// between a repository's working tree and per-branch storage under
+// ~/.workspaces, and implements the claude-wrapper command around that.
+//
+// Tools embedding the sync engine load a Config with LoadConfig and drive
+// it through a Syncer; Main runs the command line.
+package wrapper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	excludeFile       = ".git/info/exclude"
+	deletionMarker    = ".deleted_at"
+	branchesDir       = "branches"
+	auditLogFile      = ".audit.log"
+	deletionGraceDays = 7
+)
+
+// isReservedItem reports whether a store entry belongs to the wrapper itself
+// rather than being a personal file to sync.
+func isReservedItem(item string) bool {
+	switch item {
+	case deletionMarker, branchesDir, auditLogFile, storeMetaFile, manifestFile, tombstonesFile, wrapperIgnoreFile, branchMetaFile, journalDir, mergeBaseDir, defaultBaseDir, archiveDir, sharedDir, machinesDir, notesFile, sessionsDir, projectStateDir, runningDir:
+		return true
+	}
+	return false
+}
+
+// Config describes a repository's working tree and the store holding the
+// current branch's personal files. Build one with LoadConfig.
+type Config struct {
+	RepoRoot      string
+	CurrentBranch string
+	DefaultBranch string
+	StoreBase     string
+	StoreLocation string
+	Settings      Settings
+
+	// remoteURL returns the repository's remote URL, recorded in the
+	// store's metadata; "" if it has none. See remote.
+	remoteURL func() string
+	// loadTime is how long building the Config took, git queries included.
+	loadTime time.Duration
+	// report collects statistics for the current run; nil when not reporting.
+	report *SyncReport
+	// journal records the changes of the sync in progress for undo; nil
+	// outside a sync.
+	journal *syncJournal
+	// progress shows progress of long syncs; nil when quiet.
+	progress *progressMeter
+}
+
+// Main runs claude-wrapper with the given command line arguments (without
+// the program name) and returns the exit code.
+func Main(args []string) int {
+	flags, args := parseWrapperFlags(args)
+	if flags.traceGit {
+		gitTrace = os.Stderr
+	}
+	if flags.profile != "" {
+		// Git hooks run by claude during the session use the same profile
+		os.Setenv(profileEnv, flags.profile)
+	}
+	if flags.branch != "" {
+		os.Setenv(branchEnv, flags.branch)
+	}
+
+	if flags.outOnly && (flags.inOnly || flags.ephemeral) {
+		errorf("--out-only can't be combined with --in-only or --ephemeral")
+		return exitUsage
+	}
+	if flags.sandbox && (flags.inOnly || flags.outOnly || flags.ephemeral) {
+		errorf("--sandbox can't be combined with --in-only, --out-only or --ephemeral")
+		return exitUsage
+	}
+
+	var exitCode int
+	var err error
+	if cmd, ok := lookupCommand(args); ok && !flags.separated {
+		exitCode, err = cmd.run(flags, args[1:])
+	} else {
+		exitCode, err = run(flags, args)
+	}
+	if err != nil {
+		errorf("%v", err)
+		return exitCodeFor(err)
+	}
+	return exitCode
+}
+
+func run(flags wrapperFlags, args []string) (int, error) {
+	settings, err := LoadSettings()
+	if err != nil {
+		return 0, withExitCode(exitConfig, fmt.Errorf("failed to load settings: %w", err))
+	}
+	flags.apply(&settings)
+
+	if reason, ok := passthroughReason(settings, ""); ok {
+		logPassthrough(settings, reason)
+		return 0, execClaude(args)
+	}
+
+	gitRepo = newVCS(settings, "")
+
+	cfg, err := loadConfig(settings)
+	switch {
+	case errors.Is(err, ErrNotInRepo):
+		// Nothing to sync, just exec claude directly (replaces process)
+		return 0, execClaude(args)
+	case errors.Is(err, ErrNotOnBranch):
+		logPassthrough(settings, err.Error())
+		return 0, execClaude(args)
+	case exitCodeFor(err) == exitConfig:
+		// Claude would run with personal files silently unmanaged
+		return 0, err
+	case err != nil:
+		// git itself failed, even after retrying
+		warnf("not syncing personal files, running claude directly: %v", err)
+		return 0, execClaude(args)
+	}
+	if reason, disabled := repoDisabled(cfg.RepoRoot, settings); disabled {
+		logPassthrough(settings, reason)
+		return 0, execClaude(args)
+	}
+	cfg.Settings = settings
+	return runSession(cfg, func() int { return runClaudeSession(cfg, args) })
+}
+
+// runSession syncs cfg's branch in, runs launch with the personal files in
+// place, syncs them back out and cleans up expired branch stores. The exit
+// code is launch's.
+func runSession(cfg *Config, launch func() int) (int, error) {
+	cfg.report = newSyncReport(cfg)
+	cfg.progress = newProgressMeter(cfg.Settings)
+	cfg.report.addTiming("startup", cfg.loadTime)
+	if cfg.Settings.sandbox {
+		return runSandboxSession(cfg, launch)
+	}
+
+	// Sync in: storage -> working directory, unless nothing can have
+	// changed since the last session
+	start := time.Now()
+	switch {
+	case cfg.Settings.syncOnly == "out":
+		// The working tree must hold this branch's files, or sync-out
+		// would save another branch's to its store
+		if prev, err := syncedElsewhere(cfg); err != nil || prev != nil {
+			if err == nil {
+				err = fmt.Errorf("the working tree holds %s's personal files; run without --out-only to switch to %s", prev.storeName(), cfg.storeName())
+			}
+			return 0, withExitCode(exitSyncIn, err)
+		}
+		infof("not syncing in (--out-only)")
+	case syncedRecently(cfg, start):
+		infof("skipping sync-in: %s was synced less than %ds ago and its store is unchanged", cfg.storeName(), cfg.Settings.FreshSyncSeconds)
+	default:
+		if err := syncInAfterSwitch(cfg); err != nil {
+			return 0, withExitCode(exitSyncIn, fmt.Errorf("sync in failed: %w", err))
+		}
+	}
+	cfg.report.addDuration(time.Since(start))
+	cfg.report.addTiming("sync_in", time.Since(start))
+
+	// Registered once the store exists, so sync-in still sees a new one
+	defer registerSession(cfg, start)()
+
+	// Execute the session and capture exit code
+	exitCode := launch()
+	cfg.report.ClaudeExit = exitCode
+
+	// Sync out: always run regardless of the exit code, unless the session
+	// was read-only. If the branch was switched during the session, files
+	// go back to the branch they were synced in for and the new branch's
+	// files are brought in.
+	currentBranch, branchErr := gitRepo.CurrentBranch()
+	if branchErr != nil {
+		currentBranch = ""
+	}
+	if cfg.Settings.branch != "" {
+		// Checkouts don't move a session pinned to a branch's store
+		currentBranch = cfg.CurrentBranch
+	}
+	switch {
+	case cfg.Settings.ephemeral:
+		restored, err := restoreItems(cfg, cfg.RepoRoot, "", "--ephemeral")
+		if err != nil {
+			warnf("failed to discard the session's changes: %v", err)
+		}
+		infof("not syncing out (--ephemeral); put back the stored copies of %d item(s)", len(restored))
+	case cfg.Settings.syncOnly == "in":
+		warnf("not syncing out (--in-only); changes to personal files during the session were not saved")
+	default:
+		start = time.Now()
+		err := syncOutAndReconcile(cfg, currentBranch)
+		cfg.report.addDuration(time.Since(start))
+		cfg.report.addTiming("sync_out", time.Since(start))
+		if err != nil {
+			cfg.report.Error = err.Error()
+			emitSessionReport(cfg)
+			return exitCode, withExitCode(exitSyncOut, fmt.Errorf("sync out failed: %w", err))
+		}
+		if currentBranch == cfg.CurrentBranch {
+			recordFreshSync(cfg, time.Now())
+		}
+	}
+
+	start = time.Now()
+	// Drop exclude entries for personal files that no longer exist anywhere
+	if currentBranch != "" {
+		tidyExcludeAfterSync(cfg.forBranch(currentBranch))
+	}
+
+	// Cleanup old branches, unless it ran recently: it checks every branch
+	// store against git, which adds up on large stores. An ephemeral
+	// session leaves storage alone.
+	if !cfg.Settings.ephemeral && cleanupDue(cfg, start) {
+		if err := cleanupDeletedBranches(cfg); err != nil {
+			warnf("cleanup failed: %v", err)
+		}
+		// And stores of repositories deleted from disk
+		if err := gcRepoStores(filepath.Dir(cfg.StoreBase), cfg.Settings, time.Now(), false, true, diagnostics); err != nil {
+			warnf("repository store cleanup failed: %v", err)
+		}
+	}
+	cfg.report.addTiming("cleanup", time.Since(start))
+	emitSessionReport(cfg)
+
+	return exitCode, nil
+}
+
+// emitSessionReport prints and/or appends the session's report.
+func emitSessionReport(cfg *Config) {
+	if err := emitReport(cfg.Settings, cfg.report, os.Stderr); err != nil {
+		warnf("failed to write sync report: %v", err)
+	}
+}
+
+func loadConfig(settings Settings) (*Config, error) {
+	return loadConfigFrom(gitRepo, settings)
+}
+
+// loadConfigFrom builds the Config for the repository g queries, with its
+// store in settings' profile. While the profile's store root is unreachable
+// the offline store stands in for it; once it is back, changes saved
+// offline are moved into it.
+func loadConfigFrom(g VCS, settings Settings) (*Config, error) {
+	start := time.Now()
+
+	// The three queries don't depend on each other, so they run at once.
+	// The remote URL is only needed at sync-out and the branch list only by
+	// cleanup; both are left until then.
+	var (
+		wg                      sync.WaitGroup
+		repoRoot, currentBranch string
+		defaultBranch           string
+		rootErr, branchErr      error
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		repoRoot, rootErr = g.RepoRoot()
+	}()
+	go func() {
+		defer wg.Done()
+		if settings.branch != "" {
+			// --branch picks the store, even with HEAD detached
+			currentBranch = settings.branch
+			return
+		}
+		currentBranch, branchErr = g.CurrentBranch()
+	}()
+	go func() {
+		defer wg.Done()
+		defaultBranch = g.DefaultBranch()
+	}()
+	wg.Wait()
+
+	if errors.Is(rootErr, ErrNotInRepo) {
+		return nil, rootErr
+	}
+	if rootErr != nil {
+		return nil, vcsFailure("find the repository root", rootErr)
+	}
+	if errors.Is(branchErr, ErrNotOnBranch) {
+		return nil, branchErr
+	}
+	if branchErr != nil {
+		return nil, vcsFailure("read the current branch", branchErr)
+	}
+	repoName := filepath.Base(repoRoot)
+
+	storeRoot, err := settings.storeRoot()
+	if err != nil {
+		return nil, withExitCode(exitConfig, fmt.Errorf("failed to locate storage: %w", err))
+	}
+
+	storeRoot, offline, err := availableStoreRoot(settings, storeRoot)
+	if err != nil {
+		return nil, withExitCode(exitConfig, err)
+	}
+	storeBase := filepath.Join(storeRoot, repoName)
+	if err := migrateBranchDirs(storeBase); err != nil {
+		warnf("%v", err)
+	}
+	if !offline {
+		if cached, err := settings.offlineStoreRoot(); err == nil {
+			if err := reconcileOfflineStore(storeBase, filepath.Join(cached, repoName)); err != nil {
+				warnf("%v", err)
+			}
+		}
+	}
+
+	return &Config{
+		RepoRoot:      repoRoot,
+		CurrentBranch: currentBranch,
+		DefaultBranch: defaultBranch,
+		StoreBase:     storeBase,
+		StoreLocation: branchStoreLocation(storeBase, currentBranch, defaultBranch),
+		remoteURL:     sync.OnceValue(g.RemoteURL),
+		loadTime:      time.Since(start),
+	}, nil
+}
+
+// remote returns the repository's remote URL, asking the VCS the first time.
+func (cfg *Config) remote() string {
+	if cfg.remoteURL == nil {
+		return ""
+	}
+	return cfg.remoteURL()
+}
+
+// branchStoreLocation returns where branch's personal files are stored. The
+// default branch uses the store base itself for backwards compatibility.
+func branchStoreLocation(storeBase, branch, defaultBranch string) string {
+	if branch == defaultBranch {
+		return storeBase
+	}
+	return branchStoreDir(storeBase, branch)
+}
+
+// forBranch returns a copy of cfg describing branch instead of the current one.
+func (cfg *Config) forBranch(branch string) *Config {
+	next := *cfg
+	next.CurrentBranch = branch
+	next.StoreLocation = branchStoreLocation(cfg.StoreBase, branch, cfg.DefaultBranch)
+	return &next
+}
+
+func syncIn(cfg *Config) error {
+	defer func() {
+		cfg.journal.save()
+		cfg.journal = nil
+	}()
+
+	// Seed a brand new repository store from templates
+	if err := seedRepoStore(cfg); err != nil {
+		return err
+	}
+
+	// A branch switch has already started the journal with its removals
+	if cfg.journal == nil {
+		cfg.journal = beginJournal(cfg, "sync-in")
+	}
+
+	// Initialize branch storage if needed
+	if err := initializeBranchStorage(cfg); err != nil {
+		return err
+	}
+
+	// Bring the default branch's changes into files the branch never
+	// changed, and into overlaid files
+	if cfg.Settings.RebaseStores && cfg.CurrentBranch != cfg.DefaultBranch {
+		updated, err := rebaseStore(cfg, false, true)
+		if err != nil {
+			return err
+		}
+		for _, rel := range updated {
+			infof("updated %s from the %s branch's store", rel, cfg.DefaultBranch)
+		}
+	}
+	if err := rebaseOverlays(cfg); err != nil {
+		return err
+	}
+
+	// Get items from storage
+	items, err := listDir(cfg.StoreLocation)
+	if err != nil {
+		return err
+	}
+
+	// Filter out special items
+	items = filterItems(items)
+
+	// Never overwrite files committed to the repository
+	targets, err := collisionTargets(cfg, items)
+	if err != nil {
+		return err
+	}
+	// Directories outside a sparse checkout's cone would confuse git
+	sparse := sparseSkips(cfg, cfg.StoreLocation, items)
+
+	// Templates are rendered rather than copied, never over committed files
+	rendered := make(map[string]string)
+	var renderedNames []string
+	for _, item := range items {
+		if name, ok := renderedName(item); ok && isTemplateFile(filepath.Join(cfg.StoreLocation, item)) {
+			rendered[item] = name
+			renderedNames = append(renderedNames, name)
+		}
+	}
+	trackedRendered, err := trackedItems(cfg, renderedNames)
+	if err != nil {
+		return err
+	}
+
+	// Copy from storage to working directory, leaving out what the store's
+	// .wrapperignore keeps there
+	skip := cfg.skipper(cfg.StoreLocation, false)
+	ignored := readWrapperIgnore(cfg.StoreLocation).skipper(cfg.StoreLocation)
+	c := &copier{skip: func(src string) bool { return skip(src) || ignored(src) || isTemplateFile(src) }, progress: cfg.progress, verify: cfg.Settings.VerifyCopies, preserveOwnership: cfg.Settings.PreserveOwnership, journal: cfg.journal, merge: cfg.fileMerger(true)}
+	defer cfg.report.addIn(c)
+	cfg.progress.begin("sync in", cfg.RepoRoot)
+	defer cfg.progress.end()
+
+	// Items pending removal from storage were dropped from the working
+	// tree on purpose, or will be listed again while still there
+	tombstones := readTombstones(cfg.StoreLocation)
+
+	// A failed item doesn't stop the others; failures are reported together
+	var errs []error
+	var synced []string
+	for _, item := range items {
+		if _, pending := tombstones[item]; sparse[item] || pending || ignored(filepath.Join(cfg.StoreLocation, item)) {
+			continue
+		}
+		name := item
+		if target, ok := targets[item]; ok {
+			if target == "" {
+				continue
+			}
+			name = target
+		}
+		if r, ok := rendered[item]; ok {
+			if trackedRendered[r] {
+				warnf("not rendering %s: %s is committed to the repository", item, r)
+				continue
+			}
+			name = r
+		}
+		if _, err := excludeEntry(name); err != nil {
+			// Restored but not excluded, it would show up as untracked
+			warnf("not restoring %q: %v", name, err)
+			continue
+		}
+		synced = append(synced, item)
+		src := filepath.Join(cfg.StoreLocation, item)
+		dst := filepath.Join(cfg.RepoRoot, name)
+		start := time.Now()
+		if err := reconcileDir(cfg, item, src, dst); err != nil {
+			errs = append(errs, err)
+		}
+		if err := c.copyPath(src, dst); err != nil {
+			errs = append(errs, fmt.Errorf("failed to copy %s: %w", item, err))
+		}
+		cfg.report.addItemTiming(item, time.Since(start))
+
+		// Add to git exclude, even after a partial copy
+		if err := addToExclude(cfg.RepoRoot, cfg.excludeFile(), name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update exclude for %s: %w", name, err))
+		}
+	}
+
+	// Personal sections of committed files are merged into them
+	if err := mergeIn(cfg, targets); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Templates, and MCP configurations stored as templates, are rendered
+	// for the branch
+	if err := renderStoredTemplates(cfg, synced); err != nil {
+		errs = append(errs, err)
+	}
+	if err := renderMCPConfigs(cfg); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Shared items fill in whatever the branch doesn't provide itself
+	if err := syncInShared(cfg, c); err != nil {
+		errs = append(errs, err)
+	}
+	if err := syncInMachine(cfg, c); err != nil {
+		errs = append(errs, err)
+	}
+	if err := syncInNotes(cfg, c); err != nil {
+		errs = append(errs, err)
+	}
+	if err := syncInProjectState(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := os.Stat(cfg.StoreLocation); err == nil {
+		now := time.Now()
+		updateBranchMeta(cfg.StoreLocation, cfg.RepoRoot, now, func(meta *branchMeta) {
+			at := now.UTC()
+			meta.LastSyncIn = &at
+		})
+	}
+	return errors.Join(errs...)
+}
+
+func initializeBranchStorage(cfg *Config) error {
+	// Nothing to do on default branch
+	if cfg.CurrentBranch == cfg.DefaultBranch {
+		return nil
+	}
+
+	// Nothing to do if storage already exists
+	if _, err := os.Stat(cfg.StoreLocation); err == nil {
+		return nil
+	}
+
+	// Create new branch storage directory
+	if err := os.MkdirAll(cfg.StoreLocation, 0755); err != nil {
+		return err
+	}
+
+	// Copy from default branch if it exists, limited to the items picked
+	// and the paths seed_branch selects, and without what its
+	// .wrapperignore keeps there
+	seededFrom := ""
+	seeded := make(map[string]manifestEntry)
+	if _, err := os.Stat(cfg.StoreBase); err == nil {
+		items, err := listDir(cfg.StoreBase)
+		if err != nil {
+			return err
+		}
+
+		// Skip branches directory and markers
+		ignore := readWrapperIgnore(cfg.StoreBase)
+		var candidates []string
+		for _, item := range filterItems(items) {
+			if !ignore.matches(item) {
+				candidates = append(candidates, item)
+			}
+		}
+		items = pickSeedItemsFunc(cfg, candidates)
+		if len(items) > 0 {
+			seededFrom = cfg.DefaultBranch
+		}
+		skip := cfg.Settings.seedSkipper(cfg.StoreBase)
+		ignored := ignore.skipper(cfg.StoreBase)
+		c := &copier{skip: func(src string) bool { return skip(src) || ignored(src) }, saved: seeded}
+		for _, item := range items {
+			src := filepath.Join(cfg.StoreBase, item)
+			dst := filepath.Join(cfg.StoreLocation, item)
+			if err := c.copyPath(src, dst); err != nil {
+				return fmt.Errorf("failed to copy %s from default branch: %w", item, err)
+			}
+			if cfg.Settings.SeedBranch != nil || cfg.Settings.Items != nil {
+				removeEmptyDirs(dst)
+			}
+		}
+	}
+
+	if seededFrom != "" {
+		if err := recordDefaultBases(cfg); err != nil {
+			warnf("failed to record what %s's store was seeded with: %v", cfg.CurrentBranch, err)
+		} else if err := updateManifest(cfg.StoreLocation, seeded); err != nil {
+			warnf("failed to update %s's manifest: %v", cfg.CurrentBranch, err)
+		}
+	}
+	updateBranchMeta(cfg.StoreLocation, cfg.RepoRoot, time.Now(), func(meta *branchMeta) {
+		meta.SeededFrom = seededFrom
+	})
+	return nil
+}
+
+func syncOut(cfg *Config) error {
+	cfg.journal = beginJournal(cfg, "sync-out")
+	defer func() {
+		cfg.journal.save()
+		cfg.journal = nil
+	}()
+
+	// Entries written before the marker block existed stay managed
+	if err := adoptStoredEntries(cfg); err != nil {
+		return fmt.Errorf("failed to update exclude file: %w", err)
+	}
+
+	// Get items from exclude file
+	excludeItems, err := readExcludeFile(cfg.RepoRoot, cfg.excludeFile())
+	if err != nil {
+		return err
+	}
+
+	// Create storage directory if needed
+	if err := os.MkdirAll(cfg.StoreLocation, 0755); err != nil {
+		return err
+	}
+	syncedAt := time.Now()
+	recordStoreMeta(cfg, syncedAt)
+	updateBranchMeta(cfg.StoreLocation, cfg.RepoRoot, syncedAt, func(meta *branchMeta) {
+		at := syncedAt.UTC()
+		meta.LastSyncOut = &at
+	})
+
+	// Files tracked in git are never saved over, or removed from, storage.
+	// Personal copies synced in under another name, or merged into the
+	// committed file, are saved back under their stored name.
+	storedItems, err := listDir(cfg.StoreLocation)
+	if err != nil {
+		return err
+	}
+	storedItems = filterItems(storedItems)
+	tracked, err := trackedItems(cfg, append(append([]string(nil), excludeItems...), storedItems...))
+	if err != nil {
+		return err
+	}
+	storeNames := make(map[string]string)
+	for _, item := range storedItems {
+		if name := cfg.Settings.syncedInName(item); tracked[item] && name != "" {
+			storeNames[name] = item
+		}
+	}
+
+	// A failed item doesn't stop the others; failures are reported together
+	var errs []error
+
+	// Items renamed in the working tree are renamed in storage, rather than
+	// saved again next to their old copy
+	renamed, err := renameStored(cfg, excludeItems, tracked)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for i, item := range storedItems {
+		if name, ok := renamed[item]; ok {
+			storedItems[i] = name
+		}
+	}
+
+	// Copy excluded items to storage
+	c := &copier{progress: cfg.progress, verify: cfg.Settings.VerifyCopies, saved: make(map[string]manifestEntry), preserveOwnership: cfg.Settings.PreserveOwnership, journal: cfg.journal, merge: cfg.fileMerger(false), skipInUse: true}
+	defer cfg.report.addOut(c)
+	cfg.progress.begin("sync out", cfg.RepoRoot)
+	defer cfg.progress.end()
+	// Machine-scoped paths go to this machine's sub-store instead, and
+	// rendered files are never saved over their templates
+	skip := cfg.skipper(cfg.RepoRoot, true)
+	// Nor over what the store's .wrapperignore keeps there
+	ignore := readWrapperIgnore(cfg.StoreLocation)
+	ignored := ignore.skipper(cfg.RepoRoot)
+	c.skip = func(src string) bool { return skip(src) || ignored(src) || renderedOutput(cfg, src) }
+
+	if err := mergeOut(cfg, storedItems, tracked, c); err != nil {
+		errs = append(errs, err)
+	}
+	for _, item := range excludeItems {
+		src := filepath.Join(cfg.RepoRoot, item)
+		if _, err := os.Stat(src); err != nil {
+			continue // Item doesn't exist
+		}
+		if tracked[item] {
+			continue // The committed file, not a personal one
+		}
+		if cfg.isNotesItem(item) {
+			continue // Saved under its own name below
+		}
+		if isUnchangedShared(cfg, item) {
+			continue // Still the shared copy; nothing branch-specific to save
+		}
+		if tooBig, size := oversized(cfg, item); tooBig {
+			warnf("not saving %s to storage: %s exceeds max_item_size_mb (%s); add it to never_manage or raise the limit",
+				item, formatBytes(size), formatBytes(cfg.Settings.maxItemSize()))
+			cfg.report.addOversized(item)
+			continue
+		}
+
+		name := item
+		if stored, ok := storeNames[item]; ok {
+			name = stored
+		}
+		dst := filepath.Join(cfg.StoreLocation, name)
+		start := time.Now()
+		if err := c.copyPath(src, dst); err != nil {
+			errs = append(errs, fmt.Errorf("failed to copy %s to storage: %w", item, err))
+		}
+		cfg.report.addItemTiming(item, time.Since(start))
+	}
+	if err := syncOutMachine(cfg, excludeItems, c); err != nil {
+		errs = append(errs, err)
+	}
+	if err := syncOutNotes(cfg, c); err != nil {
+		errs = append(errs, err)
+	}
+	if err := syncOutProjectState(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	// Files claude's subprocesses still hold open keep their stored copy
+	// until the next sync-out
+	for _, src := range c.inUse {
+		rel, err := filepath.Rel(cfg.RepoRoot, src)
+		if err != nil {
+			rel = src
+		}
+		warnf("not saving %s to storage: another process still has it open or locked; it is saved at the next sync-out", rel)
+		cfg.report.addInUse(rel)
+	}
+
+	// Remove items from storage that aren't in exclude file
+	storageItems, err := listDir(cfg.StoreLocation)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	excludeMap := make(map[string]bool)
+	for _, item := range excludeItems {
+		excludeMap[item] = true
+		if stored, ok := storeNames[item]; ok {
+			excludeMap[stored] = true
+		}
+		excludeMap[item+templateSuffix] = true // The template it's rendered from
+	}
+
+	// Items left in storage outside a sparse checkout's cone stay there.
+	// Items no longer listed are tombstoned first and only removed once
+	// they have stayed unlisted for the grace period.
+	sparse := outsideSparseCone(cfg, cfg.StoreLocation, storageItems)
+	tombstones := readTombstones(cfg.StoreLocation)
+	now := time.Now()
+	gracePeriod := deletionGraceDays * 24 * time.Hour
+	for _, item := range storageItems {
+		// Skip special items, and personal copies of tracked files or
+		// sparse directories that weren't synced in
+		if isReservedItem(item) || tracked[item] || sparse[item] {
+			continue
+		}
+		if ignore.matches(item) {
+			delete(tombstones, item) // Kept in the store by .wrapperignore
+			continue
+		}
+
+		if excludeMap[item] {
+			delete(tombstones, item) // Listed again
+			continue
+		}
+		markedAt, marked := tombstones[item]
+		if !marked {
+			tombstones[item] = now
+			warnf("%s is no longer in the exclude file; its stored copy will be removed after %s",
+				item, formatTime(now.Add(gracePeriod)))
+			continue
+		}
+		if now.Sub(markedAt) <= gracePeriod {
+			continue
+		}
+
+		path := filepath.Join(cfg.StoreLocation, item)
+		err := cfg.journal.removeAll(cfg.StoreBase, auditEntry{
+			Path:     path,
+			Reason:   fmt.Sprintf("item no longer listed in exclude file for more than %d days", deletionGraceDays),
+			Repo:     cfg.RepoRoot,
+			Branch:   cfg.CurrentBranch,
+			Exclude:  excludeItems,
+			MarkedAt: &markedAt,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove %s from storage: %w", item, err))
+			continue
+		}
+		delete(tombstones, item)
+		cfg.report.addRemoved(item)
+	}
+	for item := range tombstones {
+		if _, err := os.Lstat(filepath.Join(cfg.StoreLocation, item)); os.IsNotExist(err) {
+			delete(tombstones, item)
+		}
+	}
+	if err := writeTombstones(cfg.StoreLocation, tombstones); err != nil {
+		errs = append(errs, fmt.Errorf("failed to record items pending removal: %w", err))
+	}
+
+	if err := updateManifest(cfg.StoreLocation, c.saved); err != nil {
+		errs = append(errs, fmt.Errorf("failed to update the store's manifest: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func cleanupDeletedBranches(cfg *Config) error {
+	branchesPath := filepath.Join(cfg.StoreBase, branchesDir)
+	now := time.Now()
+	lastCleanup := recordCleanup(cfg, now)
+	pruneArchives(cfg, now)
+
+	// Check if branches directory exists
+	if _, err := os.Stat(branchesPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	// Get all current git branches
+	gitBranches, err := getAllBranchesFunc()
+	if err != nil {
+		return err
+	}
+
+	// List all stored branch directories
+	entries, err := os.ReadDir(branchesPath)
+	if err != nil {
+		return err
+	}
+	shortNames, err := readShortNames(branchesPath)
+	if err != nil {
+		return err
+	}
+
+	// Branches merged into the default branch are noted while they still
+	// exist, so their files can be promoted once they are deleted
+	var merged map[string]bool
+	if cfg.Settings.promotesMerged() {
+		if merged, err = mergedBranchesFunc(cfg.DefaultBranch); err != nil {
+			warnf("failed to list branches merged into %s: %v", cfg.DefaultBranch, err)
+		}
+	}
+	tips, err := branchTipsFunc()
+	if err != nil {
+		warnf("failed to list the commits branches point to: %v", err)
+	}
+	inUse := sessionsByBranch(cfg.StoreBase)
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		dirName := entry.Name()
+		branchName := branchOfDir(shortNames, dirName)
+		branchPath := filepath.Join(branchesPath, dirName)
+		markerPath := filepath.Join(branchPath, deletionMarker)
+
+		// Skip current branch, and those other sessions are using
+		if branchName == cfg.CurrentBranch {
+			continue
+		}
+		if pid, ok := inUse[branchName]; ok {
+			warnf("not cleaning up %s: in use by the session with PID %d", branchName, pid)
+			continue
+		}
+
+		// Check if branch exists in git
+		if gitBranches[branchName] {
+			// Branch exists - remove marker if present, but the store may
+			// still expire for going unused
+			os.Remove(markerPath)
+			if merged != nil {
+				recordMerged(cfg, branchName, branchPath, merged, now)
+			}
+			recordBranchTip(cfg, branchName, branchPath, tips, now)
+			expireUnusedBranchStore(cfg, branchName, branchPath, now)
+			continue
+		}
+
+		// Branch doesn't exist in git
+		markerExists := false
+		if data, err := os.ReadFile(markerPath); err == nil {
+			markerExists = true
+
+			// Check age of marker
+			timestamp, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+			if err == nil {
+				// A store still in use (from a detached HEAD, say) is
+				// kept until it has been unused for the grace period too
+				deletedAt := time.Unix(timestamp, 0)
+				if now.After(branchStoreExpiry(cfg.Settings, branchPath, deletedAt)) {
+					archive := cfg.Settings.CleanupPolicy == "archive"
+					if !archive && !confirmBranchDeletionFunc(cfg, branchName, branchPath) {
+						// Declined: restart the grace period
+						timestamp := strconv.FormatInt(now.Unix(), 10)
+						if err := os.WriteFile(markerPath, []byte(timestamp), 0644); err != nil {
+							warnf("failed to reset deletion marker for %s: %v", branchName, err)
+						}
+						continue
+					}
+
+					meta, _ := readBranchMeta(branchPath)
+					reason := fmt.Sprintf("branch deleted from git more than %d days ago", cfg.Settings.graceDays(meta.Deletion))
+					if meta.Deletion != "" {
+						reason += " (" + meta.Deletion + ")"
+					}
+					removeExpiredBranchStore(cfg, branchName, branchPath, reason, deletedAt, now)
+				}
+			}
+		}
+
+		// Create marker if it doesn't exist, first offering to keep what
+		// a merged branch customized
+		if !markerExists {
+			recordDeletion(cfg, branchName, branchPath, now)
+			if cfg.Settings.promotesMerged() {
+				promoteMerged(cfg, branchName, branchPath)
+			}
+			// Dated when the branch was deleted, as far as the reflog tells
+			deletedAt := deletionTime(cfg, branchName, lastCleanup, now)
+			if deletedAt.Before(now) {
+				infof("branch %s was deleted from git after %s, going by the reflog", branchName, formatTime(deletedAt))
+			}
+			timestamp := strconv.FormatInt(deletedAt.Unix(), 10)
+			if err := os.WriteFile(markerPath, []byte(timestamp), 0644); err != nil {
+				warnf("failed to create deletion marker for %s: %v", branchName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeExpiredBranchStore archives, under cleanup_policy = "archive", and
+// then deletes the expired store of branch at path, which expired for reason
+// after being marked at markedAt.
+func removeExpiredBranchStore(cfg *Config, branch, path, reason string, markedAt, now time.Time) {
+	if cfg.Settings.CleanupPolicy == "archive" {
+		diverged, err := divergedFiles(path)
+		switch {
+		case err != nil:
+			warnf("failed to archive old branch %s, keeping it: %v", branch, err)
+			return
+		case !diverged:
+			reason += "; not archived, nothing differed from the default branch's store"
+		default:
+			archivePath, err := archiveBranchStore(cfg.StoreBase, branch, path, now)
+			if err != nil {
+				warnf("failed to archive old branch %s, keeping it: %v", branch, err)
+				return
+			}
+			reason += "; archived to " + archivePath
+		}
+	}
+
+	// Delete the branch directory
+	err := auditedRemoveAll(cfg.StoreBase, auditEntry{
+		Path:     path,
+		Reason:   reason,
+		Repo:     cfg.RepoRoot,
+		Branch:   branch,
+		MarkedAt: &markedAt,
+	})
+	if err != nil {
+		warnf("failed to delete old branch %s: %v", branch, err)
+	}
+}
+
+func listDir(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []string
+	for _, entry := range entries {
+		items = append(items, entry.Name())
+	}
+	return items, nil
+}
+
+func filterItems(items []string) []string {
+	var filtered []string
+	for _, item := range items {
+		if isReservedItem(item) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// readExcludeFile returns the managed items listed in excludeFile that exist
+// in the working tree at repoRoot. Once the wrapper's marker block exists only its
+// entries are managed; hand-written lines outside it are left to git. A file
+// without a block predates the markers, so every entry is managed.
+func readExcludeFile(repoRoot, excludeFile string) ([]string, error) {
+	lines, err := readLines(excludeFile)
+	if err != nil {
+		return nil, err
+	}
+	if start, end := managedBlock(lines); start >= 0 {
+		lines = lines[start+1 : end]
+	}
+
+	var items []string
+	for _, line := range lines {
+		// Skip blank lines, comments and patterns
+		item, ok := parseExcludeEntry(line)
+		if !ok {
+			continue
+		}
+
+		// Check if item exists
+		if _, err := os.Stat(filepath.Join(repoRoot, item)); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// addToExclude adds item, a path relative to the repository root at
+// repoRoot, to the wrapper's block in excludeFile unless the block already
+// lists it.
+// Entries are compared normalized, so ./notes.md, /notes.md and notes.md/
+// all name notes.md, and ignoring case when git does.
+func addToExclude(repoRoot, excludeFile, item string) error {
+	item = normalizeExcludeItem(item)
+	if item == "" {
+		return fmt.Errorf("can't exclude the repository root")
+	}
+	entry, err := excludeEntry(item)
+	if err != nil {
+		return err
+	}
+	// Ensure the exclude file's directory (.git/info) exists
+	if err := os.MkdirAll(filepath.Dir(excludeFile), 0755); err != nil {
+		return err
+	}
+
+	lines, err := readLines(excludeFile)
+	if err != nil {
+		return fmt.Errorf("failed to read exclude file: %w", err)
+	}
+
+	// Check if item already exists in the wrapper's block. A hand-written
+	// line elsewhere doesn't count: only the block's entries are managed.
+	// A line meant for item that git doesn't match (like ./notes.md) is
+	// replaced rather than left beside a duplicate.
+	if start, end := managedBlock(lines); start >= 0 {
+		meant, folded := -1, -1
+		for i := start + 1; i < end; i++ {
+			existing, ok := lineNames(lines[i])
+			switch {
+			case !ok:
+			case existing == item:
+				if parsed, ok := parseExcludeEntry(lines[i]); ok && parsed == item {
+					return nil
+				}
+				meant = i
+			case folded < 0 && strings.EqualFold(existing, item):
+				folded = i
+			}
+		}
+		if meant < 0 && folded >= 0 && gitIgnoresCase(repoRoot) {
+			if parsed, ok := parseExcludeEntry(lines[folded]); ok && strings.EqualFold(parsed, item) {
+				return nil
+			}
+			meant = folded
+		}
+		if meant >= 0 {
+			lines[meant] = entry
+			return writeLines(excludeFile, lines)
+		}
+	}
+
+	// Add to the wrapper's block so tidying never touches user-authored lines
+	return writeLines(excludeFile, insertIntoManagedBlock(lines, entry))
+}
+
+// copier copies files and directories, counting what it copies.
+type copier struct {
+	files int
+	bytes int64
+
+	// skip, if set, leaves out source paths it returns true for.
+	skip func(src string) bool
+	// progress, if set, is told about every file copied.
+	progress *progressMeter
+	// verify reads every copy back and checks its SHA-256 against what was
+	// read from the source.
+	verify bool
+	// saved, if set, records every file copied, keyed by destination.
+	saved map[string]manifestEntry
+	// preserveOwnership gives copies the owner, group and POSIX ACLs of
+	// their originals, as far as the wrapper is allowed to.
+	preserveOwnership bool
+	// ownershipWarned is set once a failure to preserve ownership has been
+	// reported, so it is only reported once.
+	ownershipWarned bool
+	// journal, if set, records what copies create or replace, for undo.
+	journal *syncJournal
+	// merge, if set, is offered each file before it is copied, and reports
+	// whether it took care of it.
+	merge func(src, dst string) (bool, error)
+	// skipInUse leaves out files another process still has locked after
+	// retrying, at either end of the copy, listing them in inUse instead
+	// of failing on them.
+	skipInUse bool
+	inUse     []string
+}
+
+func copyPath(src, dst string) error {
+	return new(copier).copyPath(src, dst)
+}
+
+func copyFile(src, dst string) error {
+	return new(copier).copyFile(src, dst)
+}
+
+func copyDir(src, dst string) error {
+	return new(copier).copyDir(src, dst)
+}
+
+func (c *copier) copyPath(src, dst string) error {
+	if c.skip != nil && c.skip(src) {
+		return nil
+	}
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if srcInfo.IsDir() {
+		return c.copyDir(src, dst)
+	}
+	return c.copyFile(src, dst)
+}
+
+func (c *copier) copyFile(src, dst string) error {
+	// Sockets, FIFOs and devices can't be copied, and opening a FIFO blocks
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if srcInfo.IsDir() {
+		return fmt.Errorf("cannot copy directory %s as a file", src)
+	}
+	if !srcInfo.Mode().IsRegular() {
+		warnf("skipping %s: not a regular file (%s)", src, fileKind(srcInfo.Mode()))
+		return nil
+	}
+	c.journal.beforeWrite(dst)
+	if c.merge != nil {
+		if merged, err := c.merge(src, dst); merged || err != nil {
+			if err == nil && c.saved != nil {
+				err = c.recordSaved(dst)
+			}
+			return err
+		}
+	}
+
+	// Copy into a temporary file and only move it into place once a copy
+	// wasn't disturbed by something writing to src (and, with verify, reads
+	// back intact), so dst never holds a torn copy
+	for attempt := 1; ; attempt++ {
+		var sum hash.Hash
+		if c.verify || c.saved != nil {
+			sum = sha256.New()
+		}
+		n, tmp, err := copyContentsWaiting(src, dst, sum)
+		if err != nil {
+			if c.skipInUse && fileInUse(err) {
+				c.inUse = append(c.inUse, src)
+				return nil
+			}
+			return err
+		}
+		after, err := os.Stat(src)
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+
+		var problem string
+		if changedDuringCopy(srcInfo, after, n) {
+			problem = "kept changing while being copied; try again once it is no longer being written"
+		} else if c.verify {
+			matches, err := fileMatchesHash(tmp, sum.Sum(nil))
+			if err != nil {
+				os.Remove(tmp)
+				return err
+			}
+			if !matches {
+				problem = "was copied incorrectly: the copy's SHA-256 doesn't match (is the filesystem truncating writes?)"
+			}
+		}
+		if problem == "" {
+			if err := os.Chmod(tmp, after.Mode()); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+			c.keepOwnership(src, after, tmp)
+			if err := renameWaiting(tmp, dst); err != nil {
+				os.Remove(tmp)
+				if c.skipInUse && fileInUse(err) {
+					c.inUse = append(c.inUse, src)
+					return nil
+				}
+				return err
+			}
+			c.files++
+			c.bytes += n
+			c.progress.add(src, n)
+			if c.saved != nil {
+				c.saved[dst] = manifestEntry{Size: n, SHA256: hex.EncodeToString(sum.Sum(nil)), Saved: time.Now().UTC()}
+			}
+			return nil
+		}
+		os.Remove(tmp)
+		if attempt == copyAttempts {
+			return fmt.Errorf("%s %s", src, problem)
+		}
+		time.Sleep(time.Duration(attempt) * copyRetryDelay)
+		srcInfo = after
+	}
+}
+
+// recordSaved records the file at dst, written by other means than a copy,
+// in c.saved.
+func (c *copier) recordSaved(dst string) error {
+	f, err := os.Open(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	n, err := copyData(io.Discard, f, sum)
+	if err != nil {
+		return err
+	}
+	c.saved[dst] = manifestEntry{Size: n, SHA256: hex.EncodeToString(sum.Sum(nil)), Saved: time.Now().UTC()}
+	return nil
+}
+
+// fileMatchesHash reports whether the SHA-256 of the file at path is want.
+func fileMatchesHash(path string, want []byte) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	got := sha256.New()
+	if _, err := copyData(io.Discard, f, got); err != nil {
+		return false, err
+	}
+	return bytes.Equal(got.Sum(nil), want), nil
+}
+
+// keepOwnership gives dst the ownership and ACLs of src, described by
+// srcInfo, if c preserves them. Failing to only warns, once: the copy is
+// still made, owned by the user running the wrapper.
+func (c *copier) keepOwnership(src string, srcInfo os.FileInfo, dst string) {
+	if !c.preserveOwnership {
+		return
+	}
+	if err := copyOwnership(src, srcInfo, dst); err != nil && !c.ownershipWarned {
+		c.ownershipWarned = true
+		warnf("can't preserve ownership and ACLs, so copies belong to the current user: %v", err)
+	}
+}
+
+// copyAttempts is how many times a file that changes while being copied is
+// copied before giving up, waiting copyRetryDelay longer after each attempt.
+const (
+	copyAttempts   = 3
+	copyRetryDelay = 50 * time.Millisecond
+)
+
+// copyContents copies src into a new temporary file next to dst, feeding
+// what it reads to sum unless that is nil, and returns how many bytes it
+// copied and the temporary file's path. Replaced in tests.
+var copyContents = func(src, dst string, sum hash.Hash) (int64, string, error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, "", err
+	}
+	defer srcFile.Close()
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".claude-wrapper-*")
+	if err != nil {
+		return 0, "", err
+	}
+	n, err := copyFileData(tmpFile, srcFile, sum)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return 0, "", err
+	}
+	return n, tmpFile.Name(), nil
+}
+
+// copyBufferSize is the size of the buffers files are streamed through.
+// Copying, hashing and comparing never hold more of a file in memory, so
+// files of any size sync in constant memory.
+const copyBufferSize = 256 << 10
+
+// copyBuffers holds the buffers for copyData.
+var copyBuffers = sync.Pool{New: func() any { return make([]byte, copyBufferSize) }}
+
+// copyData streams src to dst through a fixed-size buffer, feeding what it
+// reads to sum unless that is nil. Files copied file to file may be copied
+// by the kernel without a buffer at all.
+func copyData(dst io.Writer, src io.Reader, sum hash.Hash) (int64, error) {
+	if sum != nil {
+		src = io.TeeReader(src, sum)
+	}
+	buf := copyBuffers.Get().([]byte)
+	defer copyBuffers.Put(buf)
+	return io.CopyBuffer(dst, src, buf)
+}
+
+// changedDuringCopy reports whether a file that looked like before when
+// its copy of n bytes started, and like after once it ended, was written to
+// in between.
+func changedDuringCopy(before, after os.FileInfo, n int64) bool {
+	return !after.ModTime().Equal(before.ModTime()) || after.Size() != before.Size() || n != after.Size()
+}
+
+// fileKind names the type of a file that isn't a regular file or directory.
+func fileKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	}
+	return "irregular file"
+}
+
+func (c *copier) copyDir(src, dst string) error {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	c.journal.beforeWrite(dst)
+	if err := os.MkdirAll(dst, srcInfo.Mode()); err != nil {
+		return err
+	}
+	c.keepOwnership(src, srcInfo, dst)
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	// Keep copying the rest of the directory past a failed entry
+	var errs []error
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+		if c.skip != nil && c.skip(srcPath) {
+			continue
+		}
+
+		if entry.IsDir() {
+			err = c.copyDir(srcPath, dstPath)
+		} else {
+			err = c.copyFile(srcPath, dstPath)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// execClaude replaces the current process with claude (used for non-git pass-through).
+func execClaude(args []string) error {
+	claudePath, err := exec.LookPath("claude")
+	if err != nil {
+		return withExitCode(exitNotFound, fmt.Errorf("claude not found: %w", err))
+	}
+	err = syscall.Exec(claudePath, append([]string{"claude"}, args...), os.Environ())
+	return withExitCode(exitNotExecutable, fmt.Errorf("failed to run claude: %w", err))
+}
+
+// runClaude runs claude as a subprocess and returns its exit code.
+func runClaude(args []string) int {
+	exitCode, err := runProcess("claude", args)
+	if err != nil {
+		errorf("%v", err)
+	}
+	return exitCode
+}
+
+// runProcess runs name as a subprocess attached to the wrapper's stdio and
+// returns its exit code. A process that couldn't be started gives exit code
+// 127 (not found) or 126 and the error.
+func runProcess(name string, args []string) (int, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return exitStatus(cmd.Run())
+}
+
+// exitStatus turns the error from running a process into its exit code. A
+// process that couldn't be started gives exit code 127 (not found) or 126
+// and the error.
+func exitStatus(err error) (int, error) {
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), nil
+		}
+		return startFailureCode(err), err
+	}
+	return 0, nil
+}
@@ -1,19 +1,28 @@
-package main
+package wrapper
 
 import (
+	"errors"
 	"fmt"
+	"hash"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/go-git/go-git/v5"
 )
 
-// helper: create a fake repo root with .git/info directory
+// helper: create an empty git repository with a .git/info directory
 func setupRepoRoot(t *testing.T) string {
 	t.Helper()
 	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
 	if err := os.MkdirAll(filepath.Join(dir, ".git", "info"), 0755); err != nil {
 		t.Fatal(err)
 	}
@@ -147,7 +156,7 @@ test3.txt/
 		t.Fatal(err)
 	}
 
-	items, err := readExcludeFile(tempDir)
+	items, err := readExcludeFile(tempDir, excludePath)
 	if err != nil {
 		t.Fatalf("readExcludeFile failed: %v", err)
 	}
@@ -169,7 +178,7 @@ func TestAddToExclude(t *testing.T) {
 	tempDir := t.TempDir()
 
 	// Test adding first item
-	if err := addToExclude(tempDir, "file1.txt"); err != nil {
+	if err := addToExclude(tempDir, filepath.Join(tempDir, excludeFile), "file1.txt"); err != nil {
 		t.Fatalf("failed to add first item: %v", err)
 	}
 
@@ -185,7 +194,7 @@ func TestAddToExclude(t *testing.T) {
 	}
 
 	// Test adding duplicate (should not duplicate)
-	if err := addToExclude(tempDir, "file1.txt"); err != nil {
+	if err := addToExclude(tempDir, excludePath, "file1.txt"); err != nil {
 		t.Fatalf("failed to add duplicate item: %v", err)
 	}
 
@@ -200,7 +209,7 @@ func TestAddToExclude(t *testing.T) {
 	}
 
 	// Test adding second item
-	if err := addToExclude(tempDir, "file2.txt"); err != nil {
+	if err := addToExclude(tempDir, excludePath, "file2.txt"); err != nil {
 		t.Fatalf("failed to add second item: %v", err)
 	}
 
@@ -248,6 +257,132 @@ func TestCopyFile(t *testing.T) {
 	}
 }
 
+// givenWriterDuringCopy makes the next copies of a file append to it right
+// after copying it, as if another process were writing it, for the first
+// writes copies.
+func givenWriterDuringCopy(t *testing.T, writes int) {
+	t.Helper()
+	orig := copyContents
+	copyContents = func(src, dst string, sum hash.Hash) (int64, string, error) {
+		n, tmp, err := orig(src, dst, sum)
+		if writes > 0 {
+			writes--
+			f, _ := os.OpenFile(src, os.O_APPEND|os.O_WRONLY, 0)
+			f.WriteString(" more")
+			f.Close()
+		}
+		return n, tmp, err
+	}
+	t.Cleanup(func() { copyContents = orig })
+}
+
+func TestCopyFile_RetriesWhenSourceChangesMidCopy(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "CLAUDE.md"), filepath.Join(dir, "stored.md")
+	writeFile(t, src, "being written")
+	givenWriterDuringCopy(t, 1)
+
+	c := new(copier)
+	if err := c.copyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dst, "being written more")
+	if c.files != 1 || c.bytes != int64(len("being written more")) {
+		t.Errorf("expected one file counted once, got %d files, %d bytes", c.files, c.bytes)
+	}
+}
+
+func TestCopyFile_VerifyCatchesTruncatedCopies(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "CLAUDE.md"), filepath.Join(dir, "stored.md")
+	writeFile(t, src, "0123456789")
+	truncations := 1
+	orig := copyContents
+	copyContents = func(src, dst string, sum hash.Hash) (int64, string, error) {
+		n, tmp, err := orig(src, dst, sum)
+		if truncations > 0 {
+			truncations--
+			os.Truncate(tmp, 4)
+		}
+		return n, tmp, err
+	}
+	t.Cleanup(func() { copyContents = orig })
+
+	c := &copier{verify: true}
+	if err := c.copyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dst, "0123456789")
+
+	truncations = copyAttempts
+	err := c.copyFile(src, filepath.Join(dir, "other.md"))
+	if err == nil || !strings.Contains(err.Error(), "SHA-256") {
+		t.Fatalf("expected verification to fail, got %v", err)
+	}
+	assertNotExists(t, filepath.Join(dir, "other.md"))
+}
+
+// givenHugeSparseFile creates a file of size bytes at path that takes next
+// to no disk space: a short header, then a hole.
+func givenHugeSparseFile(t *testing.T, path string, size int64) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("multi-GB fixture skipped in -short mode")
+	}
+	writeFile(t, path, "header")
+	if err := os.Truncate(path, size); err != nil {
+		t.Skip("can't create sparse files here:", err)
+	}
+}
+
+// assertBoundedMemory fails if fn allocates more than limit bytes in all.
+func assertBoundedMemory(t *testing.T, limit uint64, fn func()) {
+	t.Helper()
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > limit {
+		t.Errorf("allocated %d bytes, want at most %d", allocated, limit)
+	}
+}
+
+func TestCopyFile_StreamsHugeFiles(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "cache.bin"), filepath.Join(dir, "stored.bin")
+	givenHugeSparseFile(t, src, 3<<30)
+
+	// Hashing for the manifest reads every byte, holes included
+	c := &copier{saved: make(map[string]manifestEntry)}
+	assertBoundedMemory(t, 8<<20, func() {
+		if err := c.copyFile(src, dst); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if info, err := os.Stat(dst); err != nil || info.Size() != 3<<30 {
+		t.Errorf("expected a 3 GiB copy, got %v, %v", info, err)
+	}
+}
+
+func TestCopyFile_GivesUpOnFileThatKeepsChanging(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "CLAUDE.md"), filepath.Join(dir, "stored.md")
+	writeFile(t, src, "being written")
+	writeFile(t, dst, "previous copy")
+	givenWriterDuringCopy(t, copyAttempts)
+
+	err := copyFile(src, dst)
+	if err == nil || !strings.Contains(err.Error(), "kept changing") {
+		t.Fatalf("expected copyFile to give up, got %v", err)
+	}
+	assertFileContent(t, dst, "previous copy")
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected no temporary files left behind, got %v", entries)
+	}
+}
+
 func TestCopyDir(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -329,7 +464,7 @@ func TestListDir(t *testing.T) {
 func TestReadExcludeFile_NoExcludeFile(t *testing.T) {
 	repoRoot := setupRepoRoot(t)
 
-	items, err := readExcludeFile(repoRoot)
+	items, err := readExcludeFile(repoRoot, excludePath(repoRoot))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -507,12 +642,14 @@ func TestSyncOut_RemovesStaleItems(t *testing.T) {
 		StoreBase:     store,
 		StoreLocation: store,
 	}
+	givenTombstoned(t, store, "old-file.txt")
 
 	if err := syncOut(cfg); err != nil {
 		t.Fatal(err)
 	}
 
-	// current.txt updated in storage, old-file.txt removed
+	// current.txt updated in storage, old-file.txt removed once its grace
+	// period is over
 	assertFileContent(t, filepath.Join(store, "current.txt"), "new content")
 	assertNotExists(t, filepath.Join(store, "old-file.txt"))
 }
@@ -654,7 +791,7 @@ func TestInitializeBranchStorage_NoDefaultStorage(t *testing.T) {
 
 	assertExists(t, branchStore)
 	items, _ := listDir(branchStore)
-	if len(items) != 0 {
+	if items := filterItems(items); len(items) != 0 {
 		t.Errorf("expected empty branch store, got %v", items)
 	}
 }
@@ -861,3 +998,238 @@ func TestCleanupDeletedBranches_KeepsDuringGracePeriod(t *testing.T) {
 	assertExists(t, filepath.Join(branchesPath, "recent-branch", "file.txt"))
 	assertExists(t, filepath.Join(branchesPath, "recent-branch", deletionMarker))
 }
+
+func TestCopyDir_ContinuesPastFailedEntries(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	writeFile(t, filepath.Join(src, "a.md"), "a")
+	writeFile(t, filepath.Join(src, "b.md"), "b")
+	writeFile(t, filepath.Join(src, "c.md"), "c")
+	// A directory in the way makes b.md's copy fail
+	if err := os.MkdirAll(filepath.Join(dst, "b.md"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := copyDir(src, dst)
+	if err == nil {
+		t.Fatal("expected an error for b.md")
+	}
+	assertFileContent(t, filepath.Join(dst, "a.md"), "a")
+	assertFileContent(t, filepath.Join(dst, "c.md"), "c")
+}
+
+func TestSyncIn_ReportsAllFailedItems(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	storeBase := t.TempDir()
+	cfg := &Config{RepoRoot: repoRoot, CurrentBranch: "main", DefaultBranch: "main", StoreBase: storeBase, StoreLocation: storeBase}
+
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		writeFile(t, filepath.Join(storeBase, name), name)
+	}
+	for _, name := range []string{"a.md", "c.md"} {
+		if err := os.MkdirAll(filepath.Join(repoRoot, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := syncIn(cfg)
+	if err == nil || !strings.Contains(err.Error(), "a.md") || !strings.Contains(err.Error(), "c.md") {
+		t.Errorf("expected both failures to be reported, got %v", err)
+	}
+	assertFileContent(t, filepath.Join(repoRoot, "b.md"), "b.md")
+}
+
+// withinTimeout fails the test if fn doesn't return promptly.
+func withinTimeout(t *testing.T, fn func() error) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("copy blocked on a special file")
+		return nil
+	}
+}
+
+func TestCopyDir_SkipsFIFO(t *testing.T) {
+	src := filepath.Join(t.TempDir(), ".claude")
+	dst := filepath.Join(t.TempDir(), ".claude")
+	writeFile(t, filepath.Join(src, "settings.json"), "{}")
+	mkfifo(t, filepath.Join(src, "editor.sock"))
+
+	if err := withinTimeout(t, func() error { return copyDir(src, dst) }); err != nil {
+		t.Fatalf("copyDir failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(dst, "settings.json"), "{}")
+	assertNotExists(t, filepath.Join(dst, "editor.sock"))
+}
+
+func TestCopyPath_SkipsFIFOItem(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "pipe")
+	mkfifo(t, src)
+
+	if err := withinTimeout(t, func() error { return copyPath(src, filepath.Join(dir, "copy")) }); err != nil {
+		t.Fatalf("copyPath failed: %v", err)
+	}
+	assertNotExists(t, filepath.Join(dir, "copy"))
+}
+
+func TestSyncOut_SkipsFIFOInManagedDirectory(t *testing.T) {
+	repoRoot := setupRepoRoot(t)
+	storeBase := t.TempDir()
+	cfg := &Config{RepoRoot: repoRoot, CurrentBranch: "main", DefaultBranch: "main", StoreBase: storeBase, StoreLocation: storeBase}
+
+	writeFile(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	mkfifo(t, filepath.Join(repoRoot, ".claude", "ide.pipe"))
+	writeFile(t, filepath.Join(repoRoot, excludeFile), ".claude\n")
+
+	if err := withinTimeout(t, func() error { return syncOut(cfg) }); err != nil {
+		t.Fatalf("syncOut failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(storeBase, ".claude", "settings.json"), "{}")
+	assertNotExists(t, filepath.Join(storeBase, ".claude", "ide.pipe"))
+}
+
+func TestFileKind(t *testing.T) {
+	tests := map[os.FileMode]string{
+		os.ModeSocket:                     "socket",
+		os.ModeNamedPipe:                  "named pipe",
+		os.ModeDevice | os.ModeCharDevice: "character device",
+		os.ModeDevice:                     "device",
+		os.ModeIrregular:                  "irregular file",
+	}
+	for mode, want := range tests {
+		if got := fileKind(mode); got != want {
+			t.Errorf("fileKind(%v) = %q, want %q", mode, got, want)
+		}
+	}
+}
+
+// givenLargeTree creates files of size bytes each under dir, spread over
+// subdirectories like a sizeable .claude/ directory.
+func givenLargeTree(b *testing.B, dir string, files, size int) {
+	b.Helper()
+	data := make([]byte, size)
+	for i := 0; i < files; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("d%02d", i%20), fmt.Sprintf("f%04d", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCopyDir_ManySmallFiles(b *testing.B) {
+	src := filepath.Join(b.TempDir(), "src")
+	givenLargeTree(b, src, 1000, 1<<10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := copyDir(src, filepath.Join(b.TempDir(), "dst")); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCopyFile_Large(b *testing.B) {
+	dir := b.TempDir()
+	src := filepath.Join(dir, "big")
+	if err := os.WriteFile(src, make([]byte, 32<<20), 0644); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(32 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := copyFile(src, filepath.Join(dir, "copy")); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSyncOut_LargeClaudeDir(b *testing.B) {
+	repoRoot := b.TempDir()
+	if err := os.MkdirAll(filepath.Join(repoRoot, ".git", "info"), 0755); err != nil {
+		b.Fatal(err)
+	}
+	givenLargeTree(b, filepath.Join(repoRoot, ".claude"), 1000, 4<<10)
+	if err := addToExclude(repoRoot, excludePath(repoRoot), ".claude"); err != nil {
+		b.Fatal(err)
+	}
+	storeBase := b.TempDir()
+	cfg := &Config{RepoRoot: repoRoot, CurrentBranch: "main", DefaultBranch: "main", StoreBase: storeBase, StoreLocation: storeBase}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := syncOut(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLoadConfigFrom_ClassifiesFailures(t *testing.T) {
+	if _, err := loadConfigFrom(stubGit{err: ErrNotInRepo}, Settings{}); !errors.Is(err, ErrNotInRepo) {
+		t.Errorf("expected ErrNotInRepo to pass through, got %v", err)
+	}
+	if _, err := loadConfigFrom(stubGit{root: "/repo", err: ErrNotOnBranch}, Settings{}); !errors.Is(err, ErrNotOnBranch) {
+		t.Errorf("expected ErrNotOnBranch to pass through, got %v", err)
+	}
+
+	_, err := loadConfigFrom(stubGit{err: syscall.EAGAIN}, Settings{})
+	if errors.Is(err, ErrNotInRepo) || !errors.Is(err, syscall.EAGAIN) || !strings.Contains(err.Error(), "failed to find the repository root") {
+		t.Errorf("expected a git failure to be reported as such, got %v", err)
+	}
+
+	_, err = loadConfigFrom(stubGit{root: "/repo", branch: "main"}, Settings{Profile: "../escape"})
+	if exitCodeFor(err) != exitConfig {
+		t.Errorf("expected a storage failure to exit with %d, got %v", exitConfig, err)
+	}
+}
+
+// countingGit counts the RemoteURL queries made of it.
+type countingGit struct {
+	stubGit
+	remoteCalls *int
+}
+
+func (g countingGit) RemoteURL() string {
+	*g.remoteCalls++
+	return "git@example.com:me/app.git"
+}
+
+func TestLoadConfigFrom_DefersTheRemoteURL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	calls := 0
+	cfg, err := loadConfigFrom(countingGit{stubGit{root: "/src/app", branch: "feature"}, &calls}, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CurrentBranch != "feature" || cfg.DefaultBranch != "main" {
+		t.Errorf("branches = %s, %s; want feature, main", cfg.CurrentBranch, cfg.DefaultBranch)
+	}
+	if calls != 0 {
+		t.Errorf("remote URL queried %d time(s) while loading the config, want none", calls)
+	}
+	for range 2 {
+		if remote := cfg.forBranch("main").remote(); remote != "git@example.com:me/app.git" {
+			t.Errorf("remote = %q", remote)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("remote URL queried %d time(s), want once", calls)
+	}
+}
+
+func TestLoadConfigFrom_BranchOverride(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg, err := loadConfigFrom(stubGit{root: "/src/app", branch: "feature"}, Settings{branch: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CurrentBranch != "main" || cfg.StoreLocation != cfg.StoreBase {
+		t.Errorf("CurrentBranch = %q, StoreLocation = %s; want main's store", cfg.CurrentBranch, cfg.StoreLocation)
+	}
+}
@@ -0,0 +1,60 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// wrapperIgnoreFile in a store lists paths kept in the store but never
+// synced into working trees or seeded into new branch stores, like
+// reference material. Sync-out never saves over them or removes them.
+const wrapperIgnoreFile = ".wrapperignore"
+
+// wrapperIgnore is the list of patterns in a store's wrapperIgnoreFile,
+// matched like never_manage patterns.
+type wrapperIgnore []string
+
+// readWrapperIgnore returns the patterns of the store at store, skipping
+// blank lines and comments. A missing or unreadable file means none.
+func readWrapperIgnore(store string) wrapperIgnore {
+	lines, err := readLines(filepath.Join(store, wrapperIgnoreFile))
+	if err != nil {
+		warnf("ignoring unreadable %s: %v", wrapperIgnoreFile, err)
+		return nil
+	}
+	var patterns wrapperIgnore
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns
+}
+
+// matches reports whether rel, a path relative to the store (or the
+// repository root), or a directory it is in matches a pattern.
+func (w wrapperIgnore) matches(rel string) bool {
+	if len(w) == 0 {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		for _, pattern := range w {
+			if matchesPattern(pattern, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skipper returns a copier skip func for copies out of root that skips
+// what w matches.
+func (w wrapperIgnore) skipper(root string) func(src string) bool {
+	return func(src string) bool {
+		rel, err := filepath.Rel(root, src)
+		return err == nil && w.matches(rel)
+	}
+}
@@ -0,0 +1,75 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWrapperIgnore_Matches(t *testing.T) {
+	w := wrapperIgnore{"reference/", ".claude/docs/", "*.pdf"}
+	for rel, want := range map[string]bool{
+		"reference":                 true,
+		"reference/api.md":          true,
+		".claude/docs/guide.md":     true,
+		".claude/settings.json":     false,
+		"papers/spec.pdf":           true,
+		"CLAUDE.md":                 false,
+		filepath.Join("a", "b.pdf"): true,
+	} {
+		if got := w.matches(rel); got != want {
+			t.Errorf("matches(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
+
+func TestReadWrapperIgnore(t *testing.T) {
+	store := t.TempDir()
+	if got := readWrapperIgnore(store); got != nil {
+		t.Errorf("expected no patterns without the file, got %q", got)
+	}
+	writeFile(t, filepath.Join(store, wrapperIgnoreFile), "# kept in the store\nreference/\n\n  *.pdf  \n")
+	if got := readWrapperIgnore(store); len(got) != 2 || got[0] != "reference/" || got[1] != "*.pdf" {
+		t.Errorf("readWrapperIgnore = %q, want [reference/ *.pdf]", got)
+	}
+}
+
+func TestWrapperIgnore_KeepsReferenceMaterialInStore(t *testing.T) {
+	repoRoot := givenRepo(t)
+	cfg, storeBase := givenConfig(t, repoRoot, configOpts{})
+	writeFile(t, filepath.Join(storeBase, wrapperIgnoreFile), "reference/\n.claude/docs/\n")
+	writeFile(t, filepath.Join(storeBase, "CLAUDE.md"), "config")
+	writeFile(t, filepath.Join(storeBase, "reference", "api.md"), "api docs")
+	writeFile(t, filepath.Join(storeBase, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(storeBase, ".claude", "docs", "guide.md"), "guide")
+
+	if err := syncIn(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(repoRoot, "CLAUDE.md"), "config")
+	assertFileContent(t, filepath.Join(repoRoot, ".claude", "settings.json"), "{}")
+	for _, kept := range []string{"reference", filepath.Join(".claude", "docs"), wrapperIgnoreFile} {
+		assertNotExists(t, filepath.Join(repoRoot, kept))
+	}
+	if excludeManages(cfg.excludeFile(), "reference") {
+		t.Error("expected reference not to be excluded")
+	}
+
+	// Sync-out neither tombstones nor removes what the store keeps
+	givenTombstoned(t, cfg.StoreLocation, "reference")
+	if err := syncOut(cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, filepath.Join(storeBase, "reference", "api.md"), "api docs")
+	assertFileContent(t, filepath.Join(storeBase, ".claude", "docs", "guide.md"), "guide")
+	assertFileContent(t, filepath.Join(storeBase, wrapperIgnoreFile), "reference/\n.claude/docs/\n")
+	assertNotExists(t, filepath.Join(storeBase, tombstonesFile))
+
+	// Nor is it seeded into new branch stores
+	feature := cfg.forBranch("feature")
+	if err := initializeBranchStorage(feature); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, filepath.Join(feature.StoreLocation, "CLAUDE.md"))
+	assertNotExists(t, filepath.Join(feature.StoreLocation, "reference"))
+	assertNotExists(t, filepath.Join(feature.StoreLocation, ".claude", "docs"))
+}
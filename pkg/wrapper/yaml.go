@@ -0,0 +1,189 @@
+package wrapper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Overlaid YAML config files are merged as yaml.v3 node trees rather than
+// plain values, so comments, key order and quoting survive the round trip.
+// Scalars compare by tag and value, so "npx" and npx are the same. Anchors
+// and aliases are refused: an alias in a branch's layer can't refer to an
+// anchor in the default branch's copy.
+
+// parseYAML parses a single YAML document into its node tree. An empty
+// document is a null one.
+func parseYAML(data []byte) (*yaml.Node, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var doc yaml.Node
+	err := dec.Decode(&doc)
+	if err == io.EOF {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{yamlNull()}}
+	} else if err != nil {
+		return nil, err
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); err == nil {
+		return nil, errors.New("more than one YAML document")
+	} else if err != io.EOF {
+		return nil, err
+	}
+	if err := checkYAMLNodes(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// checkYAMLNodes rejects node trees using anchors or aliases, or with a
+// key twice in a mapping, which would leave layers ambiguous.
+func checkYAMLNodes(n *yaml.Node) error {
+	if n.Kind == yaml.AliasNode || n.Anchor != "" {
+		return errors.New("YAML anchors and aliases aren't supported")
+	}
+	if n.Kind == yaml.MappingNode {
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			if seen[key.Value] {
+				return fmt.Errorf("line %d: duplicate key %q", key.Line, key.Value)
+			}
+			seen[key.Value] = true
+		}
+	}
+	for _, child := range n.Content {
+		if err := checkYAMLNodes(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatYAML writes the node tree doc indented with two spaces.
+func formatYAML(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlNull returns a null scalar node.
+func yamlNull() *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
+
+// isYAMLNull reports whether n is a null scalar (null, ~ or nothing).
+func isYAMLNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.ShortTag() == "!!null"
+}
+
+// yamlKeyIndex returns the index in mapping's content of the key named
+// key, or -1.
+func yamlKeyIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlEqual reports whether a and b are the same YAML, comments included.
+func yamlEqual(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || len(a.Content) != len(b.Content) ||
+		a.HeadComment != b.HeadComment || a.LineComment != b.LineComment || a.FootComment != b.FootComment {
+		return false
+	}
+	if a.Kind == yaml.ScalarNode && (a.ShortTag() != b.ShortTag() || a.Value != b.Value) {
+		return false
+	}
+	for i := range a.Content {
+		if !yamlEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyYAMLPatch is applyMergePatch for YAML node trees: mappings are
+// merged key by key, keeping the target's order and comments and adding
+// new keys at the end, a null removes the key, and anything else replaces
+// the target's node. target is left unchanged.
+func applyYAMLPatch(target, patch *yaml.Node) *yaml.Node {
+	if patch.Kind == yaml.DocumentNode && target != nil && target.Kind == yaml.DocumentNode {
+		merged := *target
+		if patch.HeadComment != "" || patch.FootComment != "" {
+			merged.HeadComment, merged.FootComment = patch.HeadComment, patch.FootComment
+		}
+		merged.Content = []*yaml.Node{applyYAMLPatch(target.Content[0], patch.Content[0])}
+		return &merged
+	}
+	if patch.Kind != yaml.MappingNode {
+		return patch
+	}
+	var merged yaml.Node
+	if target != nil && target.Kind == yaml.MappingNode {
+		merged = *target
+		merged.Content = append([]*yaml.Node(nil), target.Content...)
+	} else {
+		merged = *patch
+		merged.Content = nil
+	}
+	for i := 0; i+1 < len(patch.Content); i += 2 {
+		key, v := patch.Content[i], patch.Content[i+1]
+		j := yamlKeyIndex(&merged, key.Value)
+		switch {
+		case isYAMLNull(v):
+			if j >= 0 {
+				merged.Content = append(merged.Content[:j], merged.Content[j+2:]...)
+			}
+		case j >= 0:
+			merged.Content[j] = key
+			merged.Content[j+1] = applyYAMLPatch(merged.Content[j+1], v)
+		default:
+			merged.Content = append(merged.Content, key, applyYAMLPatch(nil, v))
+		}
+	}
+	return &merged
+}
+
+// createYAMLPatch is createMergePatch for YAML node trees. Keys whose
+// comments changed are in the patch too, so comments edited on a branch
+// are kept in its layer.
+func createYAMLPatch(from, to *yaml.Node) *yaml.Node {
+	if to.Kind == yaml.DocumentNode && from.Kind == yaml.DocumentNode {
+		patch := *to
+		patch.Content = []*yaml.Node{createYAMLPatch(from.Content[0], to.Content[0])}
+		return &patch
+	}
+	if from.Kind != yaml.MappingNode || to.Kind != yaml.MappingNode {
+		return to
+	}
+	patch := *to
+	patch.Content = nil
+	for i := 0; i+1 < len(to.Content); i += 2 {
+		key, v := to.Content[i], to.Content[i+1]
+		j := yamlKeyIndex(from, key.Value)
+		switch {
+		case j < 0:
+			patch.Content = append(patch.Content, key, v)
+		case !yamlEqual(from.Content[j], key) || !yamlEqual(from.Content[j+1], v):
+			patch.Content = append(patch.Content, key, createYAMLPatch(from.Content[j+1], v))
+		}
+	}
+	for i := 0; i+1 < len(from.Content); i += 2 {
+		if key := from.Content[i]; yamlKeyIndex(to, key.Value) < 0 {
+			patch.Content = append(patch.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: key.Tag, Value: key.Value}, yamlNull())
+		}
+	}
+	return &patch
+}
@@ -0,0 +1,77 @@
+package wrapper
+
+import (
+	"testing"
+)
+
+// yamlLayer runs format's layer or apply on two YAML documents.
+func yamlLayer(t *testing.T, merge func(base, doc []byte) ([]byte, error), base, doc string) string {
+	t.Helper()
+	out, err := merge([]byte(base), []byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestFormatYAML_KeepsComments(t *testing.T) {
+	doc := `# Servers
+servers:
+  github:
+    command: "npx" # pinned
+    args:
+      - -y
+      - "@modelcontextprotocol/server-github"
+  local: ~
+`
+	parsed, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := formatYAML(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != doc {
+		t.Errorf("round trip = %q, want %q", out, doc)
+	}
+}
+
+func TestParseYAML_Unsupported(t *testing.T) {
+	for _, doc := range []string{
+		"a: &anchor 1\nb: *anchor\n",
+		"a: 1\n---\nb: 2\n",
+		"a: 1\na: 2\n",
+		"a: [1\n",
+	} {
+		if _, err := parseYAML([]byte(doc)); err == nil {
+			t.Errorf("expected an error for %q", doc)
+		}
+	}
+}
+
+func TestYAMLLayers_KeepComments(t *testing.T) {
+	format, _ := configFormatOf("config.yaml")
+	defaults := "# Team servers\nservers:\n  github: npx # pinned\n  local: ./run\nold: 1\n"
+	data := "# Team servers\nservers:\n  github: npx # pinned\n  # for the feature\n  local: ./run-feature\nnew: 2 # added\n"
+
+	layer := yamlLayer(t, format.layer, defaults, data)
+	want := "# Team servers\nservers:\n  # for the feature\n  local: ./run-feature\nnew: 2 # added\nold: null\n"
+	if layer != want {
+		t.Errorf("layer = %q, want %q", layer, want)
+	}
+	if got := yamlLayer(t, format.apply, defaults, layer); got != data {
+		t.Errorf("applied = %q, want %q", got, data)
+	}
+}
+
+func TestYAMLLayers_CommentOnlyChange(t *testing.T) {
+	format, _ := configFormatOf("config.yaml")
+	defaults := "model: a\ntheme: dark\n"
+	data := "model: a # the branch's favorite\ntheme: dark\n"
+
+	layer := yamlLayer(t, format.layer, defaults, data)
+	if got := yamlLayer(t, format.apply, defaults, layer); got != data {
+		t.Errorf("applied = %q, want %q (layer %q)", got, data, layer)
+	}
+}